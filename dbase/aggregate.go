@@ -0,0 +1,196 @@
+package dbase
+
+import (
+	"encoding/binary"
+)
+
+// Aggregation is a builder for simple aggregations over all rows of a table.
+// Rows are streamed from the file, so only one row and the group results are held in memory.
+// Values are read through Row.ToMap, so column modifications (trimming, conversion and external keys) are applied.
+type Aggregation struct {
+	file           *File
+	groupBy        []string
+	sum            []string
+	min            []string
+	max            []string
+	includeDeleted bool
+}
+
+// AggregateResult contains the aggregated values of one group
+type AggregateResult struct {
+	Group map[string]interface{} // Values of the group by columns
+	Count uint32                 // Number of rows in the group
+	Sum   map[string]float64     // Sum of the numeric values per column
+	Min   map[string]interface{} // Smallest value per column, null values are ignored
+	Max   map[string]interface{} // Largest value per column, null values are ignored
+}
+
+// Aggregate returns a new aggregation builder for the table.
+// The rows of each group are always counted, deleted rows are skipped unless IncludeDeleted is set.
+func (file *File) Aggregate() *Aggregation {
+	return &Aggregation{file: file}
+}
+
+// GroupBy groups the rows by the values of the given columns
+func (a *Aggregation) GroupBy(columns ...string) *Aggregation {
+	a.groupBy = append(a.groupBy, columns...)
+	return a
+}
+
+// Sum adds up the numeric values of the columns
+func (a *Aggregation) Sum(columns ...string) *Aggregation {
+	a.sum = append(a.sum, columns...)
+	return a
+}
+
+// Min determines the smallest value of the columns
+func (a *Aggregation) Min(columns ...string) *Aggregation {
+	a.min = append(a.min, columns...)
+	return a
+}
+
+// Max determines the largest value of the columns
+func (a *Aggregation) Max(columns ...string) *Aggregation {
+	a.max = append(a.max, columns...)
+	return a
+}
+
+// IncludeDeleted includes rows marked as deleted in the aggregation
+func (a *Aggregation) IncludeDeleted() *Aggregation {
+	a.includeDeleted = true
+	return a
+}
+
+// Run reads all rows and returns one result per group in the order the groups were first encountered.
+// Without group by columns a single result is returned, even if the table is empty.
+// The internal row pointer is restored afterwards.
+func (a *Aggregation) Run() ([]*AggregateResult, error) {
+	columns := make(map[string]string)
+	types := make(map[string]DataType)
+	for _, list := range [][]string{a.groupBy, a.sum, a.min, a.max} {
+		for _, name := range list {
+			key, err := a.file.mapKey(name)
+			if err != nil {
+				return nil, WrapError(err)
+			}
+			columns[name] = key
+			types[name] = DataType(a.file.table.columns[a.file.columnPosByKey(name)].DataType)
+		}
+	}
+	debugf("Aggregating rows - group by: %v sum: %v min: %v max: %v", a.groupBy, a.sum, a.min, a.max)
+	results := make([]*AggregateResult, 0)
+	groups := make(map[string]*AggregateResult)
+	if len(a.groupBy) == 0 {
+		result := a.newResult(nil)
+		results = append(results, result)
+		groups[""] = result
+	}
+	pointer := a.file.table.rowPointer
+	defer func() { a.file.table.rowPointer = pointer }()
+	a.file.table.rowPointer = 0
+	it := &rowIterator{file: a.file}
+	for !a.file.EOF() {
+		row, err := it.next()
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		if row.Deleted && !a.includeDeleted {
+			continue
+		}
+		values, err := row.ToMap()
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		// Build a unique key of the group values
+		groupKey := make([]byte, 0)
+		for _, name := range a.groupBy {
+			key := sortKey(values[columns[name]])
+			groupKey = binary.AppendUvarint(groupKey, uint64(len(key)))
+			groupKey = append(groupKey, key...)
+		}
+		result, ok := groups[string(groupKey)]
+		if !ok {
+			group := make(map[string]interface{})
+			for _, name := range a.groupBy {
+				group[name] = values[columns[name]]
+			}
+			result = a.newResult(group)
+			groups[string(groupKey)] = result
+			results = append(results, result)
+		}
+		result.Count++
+		for _, name := range a.sum {
+			val, err := aggregateFloat(values[columns[name]])
+			if err != nil {
+				return nil, NewErrorf("unable to sum column '%s'", name).Details(err)
+			}
+			result.Sum[name] += val
+		}
+		for _, name := range a.min {
+			val := values[columns[name]]
+			if current, ok := result.Min[name]; val != nil && (!ok || Compare(val, current, types[name]) < 0) {
+				result.Min[name] = val
+			}
+		}
+		for _, name := range a.max {
+			val := values[columns[name]]
+			if current, ok := result.Max[name]; val != nil && (!ok || Compare(val, current, types[name]) > 0) {
+				result.Max[name] = val
+			}
+		}
+	}
+	return results, nil
+}
+
+func (a *Aggregation) newResult(group map[string]interface{}) *AggregateResult {
+	if group == nil {
+		group = make(map[string]interface{})
+	}
+	result := &AggregateResult{
+		Group: group,
+		Sum:   make(map[string]float64),
+		Min:   make(map[string]interface{}),
+		Max:   make(map[string]interface{}),
+	}
+	for _, name := range a.sum {
+		result.Sum[name] = 0
+	}
+	return result
+}
+
+// mapKey returns the key of the column in the map returned by Row.ToMap.
+// The name can either be the column name or the external key or an alias of a modification.
+func (file *File) mapKey(name string) (string, error) {
+	pos := file.columnPosByKey(name)
+	if pos < 0 {
+		return "", NewErrorf("column '%s' not found", name)
+	}
+	if mod := file.table.mods[pos]; mod != nil && len(mod.ExternalKey) != 0 {
+		return mod.ExternalKey, nil
+	}
+	return file.table.columns[pos].Name(), nil
+}
+
+// aggregateFloat converts a numeric value to float64, null values are counted as zero
+func aggregateFloat(val interface{}) (float64, error) {
+	switch v := val.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	default:
+		return 0, NewErrorf("invalid data type %T, expected a numeric value", val)
+	}
+}
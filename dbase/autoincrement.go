@@ -0,0 +1,78 @@
+package dbase
+
+// lockAutoincrement places a header lock on the table and reloads the next values of the autoincrement columns,
+// so other processes following the locking scheme cannot assign the same values until the lock is released.
+// Tables without autoincrement columns are not locked.
+func (file *File) lockAutoincrement() (func() error, error) {
+	if !file.hasAutoincrement() {
+		return func() error { return nil }, nil
+	}
+	unlock, err := file.LockHeader()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	next, err := file.readAutoincrement()
+	if err != nil {
+		release(unlock, &err)
+		return nil, WrapError(err)
+	}
+	for column, value := range next {
+		if column.Next != value {
+			debugf("Next value of autoincrement column %s changed from %d to %d", column.Name(), column.Next, value)
+			column.Next = value
+		}
+	}
+	return unlock, nil
+}
+
+// checkAutoincrement reads the column header again and returns an error if the next value of an autoincrement column
+// differs from the written value, because another process changed it without respecting the header lock
+func (file *File) checkAutoincrement() error {
+	next, err := file.readAutoincrement()
+	if err != nil {
+		return WrapError(err)
+	}
+	for column, value := range next {
+		if column.Next != value {
+			return NewErrorf("next value of autoincrement column %s is %d instead of %d, the column header was changed by another process", column.Name(), value, column.Next)
+		}
+	}
+	return nil
+}
+
+// readAutoincrement reads the column header and returns the stored next value of each autoincrement column
+func (file *File) readAutoincrement() (map[*Column]uint32, error) {
+	columns, _, err := file.ReadColumns()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if len(columns) != len(file.table.columns) {
+		return nil, NewErrorf("column header contains %d columns instead of %d", len(columns), len(file.table.columns))
+	}
+	next := make(map[*Column]uint32)
+	for i, column := range file.table.columns {
+		if column.Flag == byte(AutoincrementFlag) {
+			next[column] = columns[i].Next
+		}
+	}
+	return next, nil
+}
+
+// hasAutoincrement returns if the table has at least one autoincrement column
+func (file *File) hasAutoincrement() bool {
+	for _, column := range file.table.columns {
+		if column.Flag == byte(AutoincrementFlag) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAutoincrement writes the column header containing the next values and verifies them afterwards
+func (file *File) writeAutoincrement() error {
+	err := file.WriteColumns()
+	if err != nil {
+		return WrapError(err)
+	}
+	return file.checkAutoincrement()
+}
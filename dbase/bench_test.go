@@ -0,0 +1,239 @@
+package dbase_test
+
+import (
+	"encoding/json"
+	"flag"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+	"github.com/Valentin-Kaiser/go-dbase/dbase/dbasetest"
+)
+
+var (
+	benchRows   = flag.Int("bench.rows", 10000, "number of rows of the tables generated for the benchmarks")
+	benchUpdate = flag.Bool("bench.update", false, "rewrite the allocation baseline of the benchmarks with the measured allocations")
+)
+
+const (
+	// allocsBaseline contains the allocations per operation of the benchmarks, TestBenchmarkAllocations fails if they grow
+	allocsBaseline = "testdata/bench_allocs.json"
+	// allocsTolerance is the fraction the allocations may exceed the baseline, e.g. due to another Go version
+	allocsTolerance = 0.1
+	// allocsRows is the number of rows of the tables generated for TestBenchmarkAllocations, independent of -bench.rows
+	allocsRows = 100
+)
+
+// benchColumns are the columns of the generated tables, a memo column is added for the memo benchmarks
+var benchColumns = []dbasetest.ColumnSpec{
+	{Name: "ID", Type: dbase.Integer},
+	{Name: "NAME", Type: dbase.Character, Length: 30},
+	{Name: "AMOUNT", Type: dbase.Numeric, Length: 12, Decimals: 2},
+	{Name: "CREATED", Type: dbase.Date},
+	{Name: "ACTIVE", Type: dbase.Logical},
+}
+
+// benchOperation prepares the operation on generated tables of the rows and returns a function running it once
+type benchOperation func(tb testing.TB, rows int) func()
+
+// benchOperations are the measured operations by name, each is run by a benchmark of the same name
+var benchOperations = []struct {
+	name      string
+	operation benchOperation
+}{
+	{name: "Open", operation: benchOpen},
+	{name: "Scan", operation: func(tb testing.TB, rows int) func() { return benchScan(tb, rows, false) }},
+	{name: "ScanMemo", operation: func(tb testing.TB, rows int) func() { return benchScan(tb, rows, true) }},
+	{name: "Random", operation: benchRandom},
+	{name: "Write", operation: benchWrite},
+}
+
+// generateBench generates the table with the rows in a temporary directory and returns its filename.
+// Memos are up to three blocks of 512 bytes long.
+func generateBench(tb testing.TB, rows int, memo bool) string {
+	tb.Helper()
+	spec := dbasetest.Spec{Seed: 1, Columns: benchColumns, MemoBlockSize: 512}
+	if memo {
+		spec.Columns = append(append([]dbasetest.ColumnSpec(nil), benchColumns...), dbasetest.ColumnSpec{Name: "NOTES", Type: dbase.Memo})
+	}
+	filename := filepath.Join(tb.TempDir(), "BENCH.DBF")
+	file, err := dbasetest.GenerateTable(&dbase.Config{Filename: filename}, rows, spec)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	err = file.Close()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return filename
+}
+
+func openBench(tb testing.TB, filename string) *dbase.File {
+	tb.Helper()
+	file, err := dbase.OpenTable(&dbase.Config{Filename: filename})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return file
+}
+
+// benchOpen opens and closes the table
+func benchOpen(tb testing.TB, rows int) func() {
+	filename := generateBench(tb, rows, false)
+	return func() {
+		err := openBench(tb, filename).Close()
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// benchScan reads and converts all rows
+func benchScan(tb testing.TB, rows int, memo bool) func() {
+	file := openBench(tb, generateBench(tb, rows, memo))
+	tb.Cleanup(func() { file.Close() })
+	return func() {
+		err := file.GoTo(0)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		for !file.EOF() {
+			row, err := file.Next()
+			if err != nil {
+				tb.Fatal(err)
+			}
+			_, err = row.ToMap()
+			if err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+}
+
+// benchRandom reads and converts one row at a random position
+func benchRandom(tb testing.TB, rows int) func() {
+	file := openBench(tb, generateBench(tb, rows, false))
+	tb.Cleanup(func() { file.Close() })
+	random := rand.New(rand.NewSource(1))
+	return func() {
+		err := file.GoTo(uint32(random.Intn(rows)))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		row, err := file.Row()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		_, err = row.ToMap()
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// benchWrite appends one row with a memo, the values are taken from a generated table of up to 1000 rows
+func benchWrite(tb testing.TB, rows int) func() {
+	if rows > 1000 {
+		rows = 1000
+	}
+	source := openBench(tb, generateBench(tb, rows, true))
+	read, err := source.Rows(false, true)
+	source.Close()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	values := make([]map[string]interface{}, 0, len(read))
+	for _, row := range read {
+		m, err := row.ToMap()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		values = append(values, m)
+	}
+	file := openBench(tb, generateBench(tb, 0, true))
+	tb.Cleanup(func() { file.Close() })
+	i := 0
+	return func() {
+		row := file.NewRow()
+		err := row.SetFromMap(values[i%len(values)])
+		if err != nil {
+			tb.Fatal(err)
+		}
+		err = row.Add()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		i++
+	}
+}
+
+// runBenchmark runs the operation on tables of -bench.rows rows b.N times
+func runBenchmark(b *testing.B, operation benchOperation) {
+	run := operation(b, *benchRows)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		run()
+	}
+}
+
+func BenchmarkOpen(b *testing.B) {
+	runBenchmark(b, benchOpen)
+}
+
+func BenchmarkScan(b *testing.B) {
+	runBenchmark(b, func(tb testing.TB, rows int) func() { return benchScan(tb, rows, false) })
+}
+
+func BenchmarkScanMemo(b *testing.B) {
+	runBenchmark(b, func(tb testing.TB, rows int) func() { return benchScan(tb, rows, true) })
+}
+
+func BenchmarkRandom(b *testing.B) {
+	runBenchmark(b, benchRandom)
+}
+
+func BenchmarkWrite(b *testing.B) {
+	runBenchmark(b, benchWrite)
+}
+
+// TestBenchmarkAllocations compares the allocations of the benchmarked operations with the baseline,
+// so changes allocating more than before fail. Run it with -bench.update to accept the measured allocations.
+func TestBenchmarkAllocations(t *testing.T) {
+	measured := make(map[string]float64, len(benchOperations))
+	for _, bench := range benchOperations {
+		measured[bench.name] = testing.AllocsPerRun(20, bench.operation(t, allocsRows))
+	}
+	if *benchUpdate {
+		data, err := json.MarshalIndent(measured, "", "\t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(allocsBaseline, append(data, '\n'), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	data, err := os.ReadFile(allocsBaseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline := make(map[string]float64)
+	err = json.Unmarshal(data, &baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bench := range benchOperations {
+		expected, ok := baseline[bench.name]
+		if !ok {
+			t.Errorf("%s: no baseline, run the test with -bench.update", bench.name)
+			continue
+		}
+		if allocs := measured[bench.name]; allocs > expected*(1+allocsTolerance)+1 {
+			t.Errorf("%s: %.0f allocations per operation exceed the baseline of %.0f", bench.name, allocs, expected)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package dbase
+
+// TableStat is the used and the remaining capacity of a table, see File.Stat
+type TableStat struct {
+	Rows          uint32 // Number of rows, including deleted rows
+	Size          int64  // Size of the header and the rows in bytes
+	RemainingRows uint32 // Number of rows that can be appended before ErrTableFull is returned
+}
+
+// Stat returns the used and the remaining capacity of the table.
+// Appending rows fails with ErrTableFull once MaxRecordsPerTable or MaxTableFileSize would be exceeded.
+// An ErrInvalidHeader error is returned if the header has no row length.
+func (file *File) Stat() (TableStat, error) {
+	remaining, err := remainingRows(file.header)
+	if err != nil {
+		return TableStat{}, WrapError(err)
+	}
+	return TableStat{
+		Rows:          file.header.RowsCount,
+		Size:          file.rowsEnd(),
+		RemainingRows: remaining,
+	}, nil
+}
+
+// remainingRows returns the number of rows that can be appended without exceeding the limits of a table
+func remainingRows(header *Header) (uint32, error) {
+	if header.RowLength == 0 {
+		return 0, NewError("row length of the header is 0").Details(ErrInvalidHeader)
+	}
+	if header.RowsCount >= MaxRecordsPerTable {
+		return 0, nil
+	}
+	remaining := int64(MaxRecordsPerTable - header.RowsCount)
+	end := int64(header.FirstRow) + int64(header.RowsCount)*int64(header.RowLength)
+	// The end of file marker follows the last row
+	if bySize := (MaxTableFileSize - end - 1) / int64(header.RowLength); bySize < remaining {
+		remaining = bySize
+	}
+	if remaining < 0 {
+		return 0, nil
+	}
+	return uint32(remaining), nil
+}
+
+// checkCapacity returns an ErrTableFull error if the rows can not be appended to the table
+func checkCapacity(header *Header, rows uint32) error {
+	remaining, err := remainingRows(header)
+	if err != nil {
+		return WrapError(err)
+	}
+	if rows > remaining {
+		return NewErrorf("can not append %d rows to %d rows, only %d rows fit into the table", rows, header.RowsCount, remaining).Details(ErrTableFull)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package dbase
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestTableFull(t *testing.T) {
+	column, err := NewColumn("NAME", Character, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Filename: filepath.Join(t.TempDir(), "FULL.DBF"), Converter: NewDefaultConverter(charmap.Windows1252)}
+	file, err := NewTable(FoxPro, config, []*Column{column}, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	bySize := uint32((MaxTableFileSize - int64(file.header.FirstRow) - 1) / int64(file.header.RowLength))
+	for name, rows := range map[string]uint32{"MaxRecordsPerTable": MaxRecordsPerTable, "MaxTableFileSize": bySize} {
+		// Only the header claims the rows, the append fails before anything is written
+		file.header.RowsCount = rows
+		stat, err := file.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.RemainingRows != 0 {
+			t.Errorf("%s: expected no remaining rows, got %d", name, stat.RemainingRows)
+		}
+		row := file.NewRow()
+		err = row.FieldByName("NAME").SetValue("FULL")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = row.Add()
+		if !errors.Is(err, ErrTableFull) {
+			t.Errorf("%s: expected ErrTableFull appending to a full table, got %v", name, err)
+		}
+		if file.header.RowsCount != rows {
+			t.Errorf("%s: expected %d rows after the failed append, got %d", name, rows, file.header.RowsCount)
+		}
+	}
+	file.header.RowsCount = bySize - 1
+	stat, err := file.Stat()
+	if err != nil || stat.RemainingRows != 1 {
+		t.Errorf("expected 1 remaining row, got %d: %v", stat.RemainingRows, err)
+	}
+	file.header.RowsCount = 0
+	file.header.RowLength = 0
+	_, err = file.Stat()
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("expected ErrInvalidHeader for a row length of 0, got %v", err)
+	}
+	err = checkCapacity(file.header, 1)
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("expected ErrInvalidHeader appending with a row length of 0, got %v", err)
+	}
+	file.header.RowLength = uint16(file.table.layout.Length)
+}
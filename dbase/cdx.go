@@ -0,0 +1,379 @@
+package dbase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+)
+
+// Compound index files (CDX) are read to iterate a table in the order of a tag.
+// Tags are added and removed by CreateIndex and RemoveIndex, see indexes.go. The keys are only updated when rows are written
+// if Config.MaintainIndexes is set, see cdxwrite.go, otherwise the index may be outdated if the table was changed by this package.
+// https://learn.microsoft.com/en-us/previous-versions/visualstudio/foxpro/s8tb8f47(v=vs.71)
+
+const (
+	cdxPageSize = 512
+	cdxNoPage   = 0xFFFFFFFF
+)
+
+// Index options of a tag header
+const (
+	cdxUnique    = 0x01
+	cdxCandidate = 0x04
+	cdxFor       = 0x08
+	cdxCompact   = 0x20
+	cdxCompound  = 0x40
+)
+
+// Node attributes
+const (
+	cdxRootNode uint16 = 0x01
+	cdxLeafNode uint16 = 0x02
+)
+
+// cdxHeader is the header of the compound index file and of each tag contained in it.
+// The expression pool follows the header in the next page.
+type cdxHeader struct {
+	Root       uint32    // Offset of the root node
+	FreeList   uint32    // Offset of the free node list or -1
+	Version    uint32    // Update counter
+	KeyLength  uint16    // Length of the key
+	Options    byte      // Index options
+	Signature  byte      // Index signature
+	Reserved   [484]byte // Reserved
+	IgnoreCase uint16    // 1 if the key is converted to upper case
+	Descending uint16    // 0 = ascending, 1 = descending
+	ForPos     uint16    // Offset of the FOR expression in the pool
+	ForLength  uint16    // Length of the FOR expression including the null terminator
+	ExprPos    uint16    // Offset of the key expression in the pool
+	ExprLength uint16    // Length of the key expression including the null terminator
+}
+
+// cdxNode is an interior or exterior (leaf) node of the B-tree
+type cdxNode struct {
+	Attributes uint16                 // Node type, see cdxRootNode and cdxLeafNode
+	Keys       uint16                 // Number of keys in this node
+	Left       uint32                 // Offset of the left sibling or -1
+	Right      uint32                 // Offset of the right sibling or -1
+	Data       [cdxPageSize - 12]byte // Keys and node information
+}
+
+// cdxEntry is a decoded key of a node
+type cdxEntry struct {
+	key   []byte
+	recno uint32 // 1-based record number (or the tag header offset in the tag directory)
+	child uint32 // Offset of the child node (interior nodes only)
+}
+
+// cdxIndex is an opened compound index file
+type cdxIndex struct {
+	handle    *os.File
+	size      int64
+	directory *cdxHeader // Header of the tag directory
+	tags      []*cdxTag
+}
+
+// cdxTag is a single index order inside the compound index file
+type cdxTag struct {
+	name       string
+	offset     int64 // Offset of the tag header
+	header     *cdxHeader
+	expression string
+	filter     string
+}
+
+// openCDX opens the compound index file for reading and reads the tag directory
+func openCDX(filename string) (*cdxIndex, error) {
+	return openCDXFile(filename, os.O_RDONLY)
+}
+
+// openCDXFile opens the compound index file with the flag and reads the tag directory
+func openCDXFile(filename string, flag int) (*cdxIndex, error) {
+	debugf("Opening compound index file: %s", filename)
+	handle, err := os.OpenFile(filename, flag, 0600)
+	if err != nil {
+		return nil, NewError("opening CDX file failed").Details(err)
+	}
+	stat, err := handle.Stat()
+	if err != nil {
+		handle.Close()
+		return nil, NewError("failed to stat CDX file").Details(err)
+	}
+	index := &cdxIndex{handle: handle, size: stat.Size()}
+	index.directory, err = index.readHeader(0)
+	if err != nil {
+		handle.Close()
+		return nil, WrapError(err)
+	}
+	cursor := index.cursor(index.directory, false)
+	for {
+		entry, ok, err := cursor.next()
+		if err != nil {
+			handle.Close()
+			return nil, WrapError(err)
+		}
+		if !ok {
+			break
+		}
+		header, err := index.readHeader(int64(entry.recno))
+		if err != nil {
+			handle.Close()
+			return nil, WrapError(err)
+		}
+		pool := make([]byte, cdxPageSize)
+		_, err = handle.ReadAt(pool, int64(entry.recno)+cdxPageSize)
+		if err != nil {
+			handle.Close()
+			return nil, NewError("failed to read tag expressions").Details(err)
+		}
+		tag := &cdxTag{
+			name:       strings.TrimSpace(string(bytes.TrimRight(entry.key, "\x00"))),
+			offset:     int64(entry.recno),
+			header:     header,
+			expression: cdxExpression(pool, header.ExprPos, header.ExprLength),
+			filter:     cdxExpression(pool, header.ForPos, header.ForLength),
+		}
+		debugf("Found index tag %s with expression '%s'", tag.name, tag.expression)
+		index.tags = append(index.tags, tag)
+	}
+	return index, nil
+}
+
+// Close closes the compound index file
+func (index *cdxIndex) Close() error {
+	return index.handle.Close()
+}
+
+// tagFor returns the first tag that orders the table by the plain column value.
+// Tags with a FOR clause or unique keys are skipped as they do not contain every row.
+func (index *cdxIndex) tagFor(column *Column) *cdxTag {
+	for _, tag := range index.tags {
+		if tag.header.Options&(cdxUnique|cdxFor) != 0 || len(tag.filter) > 0 {
+			continue
+		}
+		if tag.header.Options&cdxCompact == 0 {
+			continue
+		}
+		if cdxColumnExpression(tag.expression, column) {
+			return tag
+		}
+	}
+	return nil
+}
+
+// cdxColumnExpression returns true if the expression is the column.
+// Tables of a database use the long names of the database container in expressions, the column name is its first 10 characters.
+func cdxColumnExpression(expression string, column *Column) bool {
+	if len(expression) > MaxColumnNameLength {
+		expression = expression[:MaxColumnNameLength]
+	}
+	return strings.EqualFold(expression, column.Name())
+}
+
+func (index *cdxIndex) readHeader(offset int64) (*cdxHeader, error) {
+	if offset < 0 || offset+2*cdxPageSize > index.size {
+		return nil, NewErrorf("invalid CDX header offset %d", offset)
+	}
+	header := &cdxHeader{}
+	err := binary.Read(io.NewSectionReader(index.handle, offset, cdxPageSize), binary.LittleEndian, header)
+	if err != nil {
+		return nil, NewError("failed to read CDX header").Details(err)
+	}
+	if header.KeyLength == 0 || int(header.KeyLength) > cdxPageSize-24 {
+		return nil, NewErrorf("invalid CDX key length %d", header.KeyLength)
+	}
+	return header, nil
+}
+
+func (index *cdxIndex) readNode(offset uint32) (*cdxNode, error) {
+	if offset == cdxNoPage || int64(offset)+cdxPageSize > index.size {
+		return nil, NewErrorf("invalid CDX node offset %d", offset)
+	}
+	node := &cdxNode{}
+	err := binary.Read(io.NewSectionReader(index.handle, int64(offset), cdxPageSize), binary.LittleEndian, node)
+	if err != nil {
+		return nil, NewError("failed to read CDX node").Details(err)
+	}
+	return node, nil
+}
+
+// entries decodes the keys of the node.
+// Leaf keys are compressed, the duplicated bytes are taken from the previous key and trailing bytes are padded with the pad byte,
+// spaces for character keys and zeros for binary keys.
+func (node *cdxNode) entries(keyLength int, pad byte) ([]cdxEntry, error) {
+	entries := make([]cdxEntry, 0, node.Keys)
+	if node.Attributes&cdxLeafNode == 0 {
+		size := keyLength + 8
+		if int(node.Keys)*size > len(node.Data) {
+			return nil, NewErrorf("invalid CDX interior node with %d keys", node.Keys)
+		}
+		for i := 0; i < int(node.Keys); i++ {
+			entry := node.Data[i*size : (i+1)*size]
+			entries = append(entries, cdxEntry{
+				key:   append([]byte(nil), entry[:keyLength]...),
+				recno: binary.BigEndian.Uint32(entry[keyLength:]),
+				child: binary.BigEndian.Uint32(entry[keyLength+4:]),
+			})
+		}
+		return entries, nil
+	}
+	data := node.Data[:]
+	recnoMask := binary.LittleEndian.Uint32(data[2:6])
+	dupMask, trailMask := uint64(data[6]), uint64(data[7])
+	recnoBits, dupBits := data[8], data[9]
+	size := int(data[11])
+	if size == 0 || size > 8 || 12+int(node.Keys)*size > len(data) {
+		return nil, NewErrorf("invalid CDX leaf node with %d keys of %d bytes", node.Keys, size)
+	}
+	end := len(data)
+	previous := make([]byte, keyLength)
+	for i := 0; i < int(node.Keys); i++ {
+		info := uint64(0)
+		for b := 0; b < size; b++ {
+			info |= uint64(data[12+i*size+b]) << (8 * b)
+		}
+		dup := int((info >> recnoBits) & dupMask)
+		trail := int((info >> (recnoBits + dupBits)) & trailMask)
+		length := keyLength - dup - trail
+		end -= length
+		if length < 0 || end < 12+int(node.Keys)*size {
+			return nil, NewError("invalid CDX leaf node key compression")
+		}
+		key := make([]byte, keyLength)
+		copy(key, previous[:dup])
+		copy(key[dup:], data[end:end+length])
+		for j := dup + length; j < keyLength; j++ {
+			key[j] = pad
+		}
+		previous = key
+		entries = append(entries, cdxEntry{
+			key:   key,
+			recno: uint32(info) & recnoMask,
+		})
+	}
+	return entries, nil
+}
+
+// cursor returns a cursor walking the leaf nodes of the tree.
+// If reverse is set the leaves are walked from the last to the first key.
+func (index *cdxIndex) cursor(header *cdxHeader, reverse bool) *cdxCursor {
+	return &cdxCursor{
+		index:   index,
+		header:  header,
+		reverse: reverse,
+		visited: make(map[uint32]bool),
+	}
+}
+
+// cdxCursor iterates over the leaf entries of an index tree
+type cdxCursor struct {
+	index   *cdxIndex
+	header  *cdxHeader
+	reverse bool
+	node    *cdxNode
+	entries []cdxEntry
+	pos     int
+	visited map[uint32]bool
+	done    bool
+}
+
+// next returns the next leaf entry or false if the last entry was reached
+func (cursor *cdxCursor) next() (cdxEntry, bool, error) {
+	for !cursor.done && cursor.pos >= len(cursor.entries) {
+		var offset uint32
+		switch {
+		case cursor.node == nil:
+			// Descend from the root to the first (or last) leaf
+			leaf, err := cursor.descend(cursor.header.Root)
+			if err != nil {
+				return cdxEntry{}, false, WrapError(err)
+			}
+			offset = leaf
+		case cursor.reverse:
+			offset = cursor.node.Left
+		default:
+			offset = cursor.node.Right
+		}
+		if offset == cdxNoPage {
+			cursor.done = true
+			break
+		}
+		err := cursor.load(offset)
+		if err != nil {
+			return cdxEntry{}, false, WrapError(err)
+		}
+	}
+	if cursor.done {
+		return cdxEntry{}, false, nil
+	}
+	i := cursor.pos
+	if cursor.reverse {
+		i = len(cursor.entries) - 1 - cursor.pos
+	}
+	cursor.pos++
+	return cursor.entries[i], true, nil
+}
+
+// descend follows the first (or last) child pointers until a leaf is reached and returns its offset
+func (cursor *cdxCursor) descend(offset uint32) (uint32, error) {
+	for depth := 0; ; depth++ {
+		if depth > 64 {
+			return 0, NewError("CDX tree is too deep, the index might be corrupted")
+		}
+		node, err := cursor.index.readNode(offset)
+		if err != nil {
+			return 0, WrapError(err)
+		}
+		if node.Attributes&cdxLeafNode != 0 {
+			return offset, nil
+		}
+		entries, err := node.entries(int(cursor.header.KeyLength), byte(Blank))
+		if err != nil {
+			return 0, WrapError(err)
+		}
+		if len(entries) == 0 {
+			return 0, NewError("empty CDX interior node")
+		}
+		if cursor.reverse {
+			offset = entries[len(entries)-1].child
+		} else {
+			offset = entries[0].child
+		}
+	}
+}
+
+func (cursor *cdxCursor) load(offset uint32) error {
+	if cursor.visited[offset] {
+		return NewErrorf("CDX node %d visited twice, the index might be corrupted", offset)
+	}
+	cursor.visited[offset] = true
+	node, err := cursor.index.readNode(offset)
+	if err != nil {
+		return WrapError(err)
+	}
+	if node.Attributes&cdxLeafNode == 0 {
+		return NewErrorf("CDX node %d is not a leaf node", offset)
+	}
+	entries, err := node.entries(int(cursor.header.KeyLength), byte(Blank))
+	if err != nil {
+		return WrapError(err)
+	}
+	cursor.node = node
+	cursor.entries = entries
+	cursor.pos = 0
+	return nil
+}
+
+// cdxExpression extracts a null terminated expression from the expression pool
+func cdxExpression(pool []byte, pos uint16, length uint16) string {
+	if length <= 1 || int(pos)+int(length) > len(pool) {
+		return ""
+	}
+	expr := pool[pos : pos+length]
+	if i := bytes.IndexByte(expr, 0); i >= 0 {
+		expr = expr[:i]
+	}
+	return strings.TrimSpace(string(expr))
+}
@@ -0,0 +1,579 @@
+package dbase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"os"
+	"sort"
+	"time"
+)
+
+// The tags of the structural compound index are maintained when rows are written if Config.MaintainIndexes is set.
+// Only tags this package can compute the keys of are supported: compact, ascending tags without FOR clause and
+// UNIQUE keyword whose expression is a single column that is not nullable. Keys of character columns are the stored bytes,
+// integer keys are stored big endian with the sign bit flipped and numeric, float, double, date and datetime keys as IEEE 754 double
+// (dates as julian day number, datetimes with the fraction of the day) with the sign bit flipped for positive and all bits flipped for negative values.
+// Nodes emptied by removed keys are unlinked but not reused, FoxPro reclaims them with REINDEX.
+
+// cdxIndexes are the maintained tags of the structural compound index of a table
+type cdxIndexes struct {
+	index *cdxIndex
+	trees []*cdxTree
+}
+
+// cdxTree is a maintained tag and the column it indexes
+type cdxTree struct {
+	index     *cdxIndex
+	tag       *cdxTag
+	column    *Column
+	layout    *ColumnLayout
+	keyLength int
+	pad       byte // Byte the trailing bytes of a key are compressed to
+}
+
+// cdxPathNode is a node on the path from the root to a leaf
+type cdxPathNode struct {
+	offset  uint32
+	node    *cdxNode
+	entries []cdxEntry
+	index   int // Entry followed to the child node
+}
+
+// openIndexes opens the structural compound index of the table for writing if MaintainIndexes is set.
+// Fails if a tag can not be maintained, as the index would be outdated after the next write.
+func (file *File) openIndexes() error {
+	if !file.config.MaintainIndexes || !StructuralFlag.Defined(file.header.TableFlags) || len(file.config.Filename) == 0 {
+		return nil
+	}
+	filename, err := file.indexFilename(CDX)
+	if err != nil {
+		return WrapError(err)
+	}
+	if len(filename) == 0 {
+		return nil
+	}
+	index, err := openCDXFile(filename, os.O_RDWR)
+	if err != nil {
+		return WrapError(err)
+	}
+	indexes := &cdxIndexes{index: index}
+	for _, tag := range index.tags {
+		tree, err := file.cdxTree(index, tag)
+		if err != nil {
+			index.Close()
+			return NewErrorf("index tag %s can not be maintained", tag.name).Details(err)
+		}
+		indexes.trees = append(indexes.trees, tree)
+	}
+	debugf("Maintaining %d index tags of %s", len(indexes.trees), filename)
+	file.indexes = indexes
+	return nil
+}
+
+// closeIndexes closes the maintained compound index
+func (file *File) closeIndexes() error {
+	if file.indexes == nil {
+		return nil
+	}
+	err := file.indexes.index.Close()
+	file.indexes = nil
+	if err != nil {
+		return NewError("failed to close CDX file").Details(err)
+	}
+	return nil
+}
+
+// cdxTree returns the maintained tag or an error if the keys of the tag can not be computed
+func (file *File) cdxTree(index *cdxIndex, tag *cdxTag) (*cdxTree, error) {
+	header := tag.header
+	switch {
+	case header.Options&cdxCompact == 0:
+		return nil, NewError("only compact tags are supported")
+	case header.Options&(cdxUnique|cdxFor) != 0 || len(tag.filter) > 0:
+		return nil, NewError("tags with UNIQUE keyword or FOR clause are not supported")
+	case header.Descending != 0:
+		return nil, NewError("descending tags are not supported")
+	case header.IgnoreCase != 0:
+		return nil, NewError("case insensitive tags are not supported")
+	}
+	var column *Column
+	for _, c := range file.table.columns {
+		if cdxColumnExpression(tag.expression, c) {
+			column = c
+			break
+		}
+	}
+	if column == nil {
+		return nil, NewErrorf("expression '%s' is not a column", tag.expression)
+	}
+	if ColumnFlag(column.Flag)&NullableFlag != 0 {
+		return nil, NewErrorf("column %s is nullable", column.Name())
+	}
+	length, pad, err := cdxKeyLength(column)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	tree := &cdxTree{
+		index:     index,
+		tag:       tag,
+		column:    column,
+		layout:    file.table.layout.column(column),
+		keyLength: int(header.KeyLength),
+		pad:       pad,
+	}
+	if tree.keyLength != length {
+		return nil, NewErrorf("key length %d does not match the %d bytes of column %s", tree.keyLength, length, column.Name())
+	}
+	return tree, nil
+}
+
+// cdxKeyLength returns the length of the keys of the column and the byte the trailing bytes of a key are compressed to
+func cdxKeyLength(column *Column) (int, byte, error) {
+	switch DataType(column.DataType) {
+	case Character:
+		return column.Size(), byte(Blank), nil
+	case Integer:
+		return 4, 0, nil
+	case Numeric, Float, Double, Date, DateTime:
+		return 8, 0, nil
+	}
+	return 0, 0, NewErrorf("keys of %s columns are not supported", DataType(column.DataType))
+}
+
+// indexKeys returns the keys of the row at the position in the maintained tags, nil if the row is appended
+func (file *File) indexKeys(position uint32) ([][]byte, error) {
+	if file.indexes == nil || position >= file.header.RowsCount {
+		return nil, nil
+	}
+	data, err := file.ReadRow(position)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return file.indexes.keys(file, data)
+}
+
+// updateIndexes moves the entries of the written row from the previous keys to its keys
+func (file *File) updateIndexes(position uint32, previous [][]byte) error {
+	if file.indexes == nil {
+		return nil
+	}
+	data, err := file.ReadRow(position)
+	if err != nil {
+		return WrapError(err)
+	}
+	keys, err := file.indexes.keys(file, data)
+	if err != nil {
+		return WrapError(err)
+	}
+	recno := position + 1
+	for i, tree := range file.indexes.trees {
+		if previous != nil && bytes.Equal(previous[i], keys[i]) {
+			continue
+		}
+		if previous != nil {
+			err = tree.remove(previous[i], recno)
+			if err != nil {
+				return NewErrorf("failed to remove row %d from index tag %s", position, tree.tag.name).Details(err)
+			}
+		}
+		err = tree.insert(keys[i], recno)
+		if err != nil {
+			return NewErrorf("failed to add row %d to index tag %s", position, tree.tag.name).Details(err)
+		}
+		err = tree.touch()
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	return nil
+}
+
+// unindexRow removes the entries of the row at the position from the maintained tags, e.g. before the row is truncated
+func (file *File) unindexRow(position uint32) error {
+	keys, err := file.indexKeys(position)
+	if err != nil {
+		return WrapError(err)
+	}
+	if keys == nil {
+		return nil
+	}
+	for i, tree := range file.indexes.trees {
+		err = tree.remove(keys[i], position+1)
+		if err != nil {
+			return NewErrorf("failed to remove row %d from index tag %s", position, tree.tag.name).Details(err)
+		}
+		err = tree.touch()
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	return nil
+}
+
+// keys returns the keys of the raw row in the order of the trees
+func (indexes *cdxIndexes) keys(file *File, data []byte) ([][]byte, error) {
+	keys := make([][]byte, len(indexes.trees))
+	for i, tree := range indexes.trees {
+		key, err := tree.key(file, data)
+		if err != nil {
+			return nil, NewErrorf("failed to compute the key of index tag %s", tree.tag.name).Details(err)
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// key returns the key of the raw row
+func (tree *cdxTree) key(file *File, data []byte) ([]byte, error) {
+	if len(data) < tree.layout.Offset+tree.layout.Length {
+		return nil, NewErrorf("invalid row data size %d bytes", len(data))
+	}
+	raw := data[tree.layout.Offset : tree.layout.Offset+tree.layout.Length]
+	switch DataType(tree.column.DataType) {
+	case Character:
+		return append([]byte(nil), raw...), nil
+	case Integer:
+		key := make([]byte, 4)
+		binary.BigEndian.PutUint32(key, binary.LittleEndian.Uint32(raw)^0x80000000)
+		return key, nil
+	}
+	value, err := file.interpret(raw, tree.column, nil)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	f := 0.0
+	switch v := value.(type) {
+	case nil:
+	case int64:
+		f = float64(v)
+	case float64:
+		f = v
+	case time.Time:
+		if !IsEmptyDate(v) {
+			midnight := time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, v.Location())
+			f = float64(julianDate(v.Year(), int(v.Month()), v.Day())) + float64(v.Sub(midnight).Milliseconds())/float64(24*time.Hour/time.Millisecond)
+		}
+	default:
+		return nil, NewErrorf("unsupported key value of type %T", value)
+	}
+	return cdxDoubleKey(f), nil
+}
+
+// cdxDoubleKey returns the key of a double, ordered like the values when compared byte by byte
+func cdxDoubleKey(f float64) []byte {
+	if f == 0 {
+		f = 0 // Negative zero
+	}
+	u := math.Float64bits(f)
+	if u&(1<<63) == 0 {
+		u |= 1 << 63
+	} else {
+		u = ^u
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, u)
+	return key
+}
+
+// compareCdxEntry orders the entries by key and record number
+func compareCdxEntry(entry cdxEntry, key []byte, recno uint32) int {
+	c := bytes.Compare(entry.key, key)
+	switch {
+	case c != 0:
+		return c
+	case entry.recno < recno:
+		return -1
+	case entry.recno > recno:
+		return 1
+	}
+	return 0
+}
+
+// descend returns the path from the root to the leaf the key and record number belong to
+func (tree *cdxTree) descend(key []byte, recno uint32) ([]*cdxPathNode, error) {
+	path := make([]*cdxPathNode, 0, 4)
+	offset := tree.tag.header.Root
+	for depth := 0; ; depth++ {
+		if depth > 64 {
+			return nil, NewError("CDX tree is too deep, the index might be corrupted")
+		}
+		node, err := tree.index.readNode(offset)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		entries, err := node.entries(tree.keyLength, tree.pad)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		p := &cdxPathNode{offset: offset, node: node, entries: entries}
+		path = append(path, p)
+		if node.Attributes&cdxLeafNode != 0 {
+			return path, nil
+		}
+		if len(entries) == 0 {
+			return nil, NewError("empty CDX interior node")
+		}
+		p.index = sort.Search(len(entries), func(i int) bool {
+			return compareCdxEntry(entries[i], key, recno) >= 0
+		})
+		if p.index == len(entries) {
+			p.index--
+		}
+		offset = entries[p.index].child
+	}
+}
+
+// insert adds the key of the record to the tree
+func (tree *cdxTree) insert(key []byte, recno uint32) error {
+	path, err := tree.descend(key, recno)
+	if err != nil {
+		return WrapError(err)
+	}
+	leaf := path[len(path)-1]
+	i := sort.Search(len(leaf.entries), func(i int) bool {
+		return compareCdxEntry(leaf.entries[i], key, recno) >= 0
+	})
+	leaf.entries = append(leaf.entries, cdxEntry{})
+	copy(leaf.entries[i+1:], leaf.entries[i:])
+	leaf.entries[i] = cdxEntry{key: key, recno: recno}
+	return tree.store(path, len(path)-1)
+}
+
+// remove removes the key of the record from the tree
+func (tree *cdxTree) remove(key []byte, recno uint32) error {
+	path, err := tree.descend(key, recno)
+	if err != nil {
+		return WrapError(err)
+	}
+	leaf := path[len(path)-1]
+	i := sort.Search(len(leaf.entries), func(i int) bool {
+		return compareCdxEntry(leaf.entries[i], key, recno) >= 0
+	})
+	if i == len(leaf.entries) || compareCdxEntry(leaf.entries[i], key, recno) != 0 {
+		return NewErrorf("no entry for record %d, the index might be outdated", recno)
+	}
+	leaf.entries = append(leaf.entries[:i], leaf.entries[i+1:]...)
+	return tree.store(path, len(path)-1)
+}
+
+// store writes the changed node at the level of the path and updates its parents.
+// Nodes that do not fit into a page are split, empty nodes are removed from their parent.
+func (tree *cdxTree) store(path []*cdxPathNode, level int) error {
+	p := path[level]
+	root := level == 0
+	if len(p.entries) == 0 && !root {
+		err := tree.unlink(p.node)
+		if err != nil {
+			return WrapError(err)
+		}
+		parent := path[level-1]
+		parent.entries = append(parent.entries[:parent.index], parent.entries[parent.index+1:]...)
+		return tree.store(path, level-1)
+	}
+	if len(p.entries) == 0 {
+		// The tree is empty, the root becomes an empty leaf
+		p.node.Attributes |= cdxLeafNode
+	}
+	if tree.encode(p.node, p.entries) {
+		err := tree.writeNode(p.offset, p.node)
+		if err != nil || root {
+			return err
+		}
+		// The key of a node in its parent is the last key of the node
+		parent := path[level-1]
+		last := p.entries[len(p.entries)-1]
+		if compareCdxEntry(parent.entries[parent.index], last.key, last.recno) == 0 {
+			return nil
+		}
+		parent.entries[parent.index] = cdxEntry{key: last.key, recno: last.recno, child: p.offset}
+		return tree.store(path, level-1)
+	}
+	// Split the node, the right half is moved to a new node
+	half := len(p.entries) / 2
+	left, right := p.entries[:half], p.entries[half:]
+	p.node.Attributes &^= cdxRootNode
+	offset := tree.allocate()
+	sibling := &cdxNode{Attributes: p.node.Attributes, Left: p.offset, Right: p.node.Right}
+	if p.node.Right != cdxNoPage {
+		next, err := tree.index.readNode(p.node.Right)
+		if err != nil {
+			return WrapError(err)
+		}
+		next.Left = offset
+		err = tree.writeNode(p.node.Right, next)
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	p.node.Right = offset
+	if !tree.encode(p.node, left) || !tree.encode(sibling, right) {
+		return NewError("CDX keys do not fit into a node")
+	}
+	err := tree.writeNode(offset, sibling)
+	if err != nil {
+		return WrapError(err)
+	}
+	err = tree.writeNode(p.offset, p.node)
+	if err != nil {
+		return WrapError(err)
+	}
+	leftLast, rightLast := left[len(left)-1], right[len(right)-1]
+	if root {
+		rootOffset := tree.allocate()
+		rootNode := &cdxNode{Attributes: p.node.Attributes&^cdxLeafNode | cdxRootNode, Left: cdxNoPage, Right: cdxNoPage}
+		tree.encode(rootNode, []cdxEntry{
+			{key: leftLast.key, recno: leftLast.recno, child: p.offset},
+			{key: rightLast.key, recno: rightLast.recno, child: offset},
+		})
+		err = tree.writeNode(rootOffset, rootNode)
+		if err != nil {
+			return WrapError(err)
+		}
+		debugf("Split root of index tag %s, new root at %d", tree.tag.name, rootOffset)
+		tree.tag.header.Root = rootOffset
+		return tree.writeHeader()
+	}
+	parent := path[level-1]
+	parent.entries[parent.index] = cdxEntry{key: leftLast.key, recno: leftLast.recno, child: p.offset}
+	parent.entries = append(parent.entries, cdxEntry{})
+	copy(parent.entries[parent.index+2:], parent.entries[parent.index+1:])
+	parent.entries[parent.index+1] = cdxEntry{key: rightLast.key, recno: rightLast.recno, child: offset}
+	return tree.store(path, level-1)
+}
+
+// unlink removes the node from the chain of its siblings
+func (tree *cdxTree) unlink(node *cdxNode) error {
+	if node.Left != cdxNoPage {
+		left, err := tree.index.readNode(node.Left)
+		if err != nil {
+			return WrapError(err)
+		}
+		left.Right = node.Right
+		err = tree.writeNode(node.Left, left)
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	if node.Right != cdxNoPage {
+		right, err := tree.index.readNode(node.Right)
+		if err != nil {
+			return WrapError(err)
+		}
+		right.Left = node.Left
+		return tree.writeNode(node.Right, right)
+	}
+	return nil
+}
+
+// encode encodes the entries into the node and returns false if they do not fit
+func (tree *cdxTree) encode(node *cdxNode, entries []cdxEntry) bool {
+	data := [cdxPageSize - 12]byte{}
+	if node.Attributes&cdxLeafNode == 0 {
+		size := tree.keyLength + 8
+		if len(entries)*size > len(data) {
+			return false
+		}
+		for i, entry := range entries {
+			copy(data[i*size:], entry.key)
+			binary.BigEndian.PutUint32(data[i*size+tree.keyLength:], entry.recno)
+			binary.BigEndian.PutUint32(data[i*size+tree.keyLength+4:], entry.child)
+		}
+		node.Keys = uint16(len(entries))
+		node.Data = data
+		return true
+	}
+	// Leaf keys are compressed, each key has an info of record number, duplicate and trailing byte count
+	// sized to the largest record number of the node
+	countBits := bits.Len(uint(tree.keyLength))
+	maxRecno := uint32(0)
+	for _, entry := range entries {
+		if entry.recno > maxRecno {
+			maxRecno = entry.recno
+		}
+	}
+	size := (bits.Len32(maxRecno) + 2*countBits + 7) / 8
+	recnoBits := size*8 - 2*countBits
+	infoEnd := 12 + len(entries)*size
+	end := len(data)
+	var previous []byte
+	for i, entry := range entries {
+		trail := 0
+		for trail < tree.keyLength && entry.key[tree.keyLength-1-trail] == tree.pad {
+			trail++
+		}
+		dup := 0
+		for dup < len(previous) && dup < tree.keyLength-trail && previous[dup] == entry.key[dup] {
+			dup++
+		}
+		length := tree.keyLength - dup - trail
+		end -= length
+		if end < infoEnd {
+			return false
+		}
+		copy(data[end:], entry.key[dup:dup+length])
+		info := uint64(entry.recno) | uint64(dup)<<recnoBits | uint64(trail)<<(recnoBits+countBits)
+		for b := 0; b < size; b++ {
+			data[12+i*size+b] = byte(info >> (8 * b))
+		}
+		previous = entry.key
+	}
+	recnoMask := uint32(math.MaxUint32)
+	if recnoBits < 32 {
+		recnoMask = 1<<recnoBits - 1
+	}
+	binary.LittleEndian.PutUint16(data[0:], uint16(end-infoEnd))
+	binary.LittleEndian.PutUint32(data[2:], recnoMask)
+	data[6] = byte(1<<countBits - 1)
+	data[7] = byte(1<<countBits - 1)
+	data[8] = byte(recnoBits)
+	data[9] = byte(countBits)
+	data[10] = byte(countBits)
+	data[11] = byte(size)
+	node.Keys = uint16(len(entries))
+	node.Data = data
+	return true
+}
+
+// allocate returns the offset of a new node at the end of the file
+func (tree *cdxTree) allocate() uint32 {
+	if rest := tree.index.size % cdxPageSize; rest != 0 {
+		tree.index.size += cdxPageSize - rest
+	}
+	offset := uint32(tree.index.size)
+	tree.index.size += cdxPageSize
+	return offset
+}
+
+func (tree *cdxTree) writeNode(offset uint32, node *cdxNode) error {
+	buf := new(bytes.Buffer)
+	err := binary.Write(buf, binary.LittleEndian, node)
+	if err != nil {
+		return NewError("failed to encode CDX node").Details(err)
+	}
+	_, err = tree.index.handle.WriteAt(buf.Bytes(), int64(offset))
+	if err != nil {
+		return NewErrorf("failed to write CDX node at %d", offset).Details(err)
+	}
+	return nil
+}
+
+func (tree *cdxTree) writeHeader() error {
+	buf := new(bytes.Buffer)
+	err := binary.Write(buf, binary.LittleEndian, tree.tag.header)
+	if err != nil {
+		return NewError("failed to encode CDX header").Details(err)
+	}
+	_, err = tree.index.handle.WriteAt(buf.Bytes(), tree.tag.offset)
+	if err != nil {
+		return NewErrorf("failed to write CDX header of tag %s", tree.tag.name).Details(err)
+	}
+	return nil
+}
+
+// touch increments the update counter of the tag, so FoxPro clients refresh their buffers
+func (tree *cdxTree) touch() error {
+	tree.tag.header.Version++
+	return tree.writeHeader()
+}
@@ -0,0 +1,257 @@
+package dbase
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// ChangeOperation is the operation of a change
+type ChangeOperation string
+
+const (
+	InsertChange ChangeOperation = "insert" // Appends a row, fails if a row with the key exists
+	UpdateChange ChangeOperation = "update" // Sets the values on the row with the key
+	DeleteChange ChangeOperation = "delete" // Marks the row with the key as deleted
+)
+
+// Change is one entry of a change stream, e.g. {"op":"update","key":{"ID":7},"values":{"NAME":"Smith"}}
+type Change struct {
+	Op     ChangeOperation        `json:"op"`     // Operation of the change
+	Key    map[string]interface{} `json:"key"`    // Values of the unique key columns identifying the row, optional for inserts
+	Values map[string]interface{} `json:"values"` // Values of an inserted row or the changed values of an updated row
+}
+
+// changeJournal keeps the state of the table before the changes were applied, so they can be rolled back
+type changeJournal struct {
+	file     *File
+	rows     map[uint32][]byte // Original data of the changed rows
+	count    uint32            // Number of rows before the changes
+	nextFree uint32            // Next free memo block before the changes, zero if the table had no memo file
+}
+
+// ApplyChanges applies a change stream read from r to the table, e.g. to replicate changes of another database
+// to a table used by legacy software. The stream contains one JSON encoded Change per line (NDJSON).
+// Keys and values are matched to columns like ImportNDJSON does and converted to the data type of the column.
+// The key identifies the first active row with the same values in the key columns, memo columns can not be keys.
+// The whole stream is read and validated before the table is changed. The changes are applied while holding the
+// file mutex and, if write locking is enabled, a table lock. If a change fails, the rows changed so far are restored
+// and appended rows, their index entries and memos are removed again, only consumed autoincrement values are not reset.
+// Returns the number of applied changes.
+func ApplyChanges(file *File, r io.Reader) (applied int, err error) {
+	changes, err := readChanges(r)
+	if err != nil {
+		return 0, WrapError(err)
+	}
+	debugf("Applying %d changes to %s", len(changes), file.config.Filename)
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	if file.config.WriteLock {
+		var unlock func() error
+		unlock, err = file.LockTable()
+		if err != nil {
+			return 0, WrapError(err)
+		}
+		defer release(unlock, &err)
+	}
+	journal := &changeJournal{file: file, rows: make(map[uint32][]byte), count: file.header.RowsCount}
+	if file.memoHeader != nil {
+		journal.nextFree = file.memoHeader.NextFree
+	}
+	for i, change := range changes {
+		err = file.applyChange(change, journal)
+		if err != nil {
+			err = NewErrorf("failed to apply change %d", i+1).Details(err)
+			if rerr := journal.rollback(); rerr != nil {
+				return 0, WrapError(err).Details(rerr)
+			}
+			return 0, err
+		}
+	}
+	return len(changes), file.commit(nil)
+}
+
+// readChanges reads and validates the changes of the stream, empty lines are skipped
+func readChanges(r io.Reader) ([]*Change, error) {
+	changes := make([]*Change, 0)
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, NewError("failed to read change stream").Details(err)
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			change := &Change{}
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			if uerr := decoder.Decode(change); uerr != nil {
+				return nil, NewErrorf("line %d: invalid change", line).Details(uerr)
+			}
+			if verr := change.validate(); verr != nil {
+				return nil, NewErrorf("line %d: invalid change", line).Details(verr)
+			}
+			changes = append(changes, change)
+		}
+		if err == io.EOF {
+			return changes, nil
+		}
+	}
+}
+
+// validate returns an error if the change can not be applied to any table
+func (change *Change) validate() error {
+	switch change.Op {
+	case InsertChange:
+		if len(change.Values) == 0 {
+			return NewError("insert without values")
+		}
+	case UpdateChange, DeleteChange:
+		if len(change.Key) == 0 {
+			return NewErrorf("%s without key", change.Op)
+		}
+	default:
+		return NewErrorf("unknown operation '%s'", change.Op)
+	}
+	return nil
+}
+
+// applyChange applies one change and records the original state of the changed rows in the journal
+func (file *File) applyChange(change *Change, journal *changeJournal) error {
+	var row *Row
+	if len(change.Key) > 0 {
+		keys, err := file.changeKeys(change.Key)
+		if err != nil {
+			return WrapError(err)
+		}
+		row, err = file.findByKeys(keys)
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	switch change.Op {
+	case InsertChange:
+		if row != nil {
+			return NewErrorf("row with key %v exists at position %d", change.Key, row.Position)
+		}
+		row = file.NewRow()
+		err := row.SetFromMap(change.Key)
+		if err != nil {
+			return WrapError(err)
+		}
+		if file.config.ReuseDeleted {
+			// Record the deleted row the write is going to overwrite
+			position, ok, err := file.deletedRow()
+			if err != nil {
+				return WrapError(err)
+			}
+			if ok {
+				err = journal.record(position)
+				if err != nil {
+					return WrapError(err)
+				}
+			}
+		}
+	case UpdateChange, DeleteChange:
+		if row == nil {
+			return NewErrorf("no row with key %v found", change.Key)
+		}
+		err := journal.record(row.Position)
+		if err != nil {
+			return WrapError(err)
+		}
+		// Memos are appended instead of overwritten in place, so the original rows still point to the original memos
+		for _, field := range row.fields {
+			field.memoPos = nil
+		}
+		row.Deleted = change.Op == DeleteChange
+	}
+	if change.Op != DeleteChange {
+		err := row.SetFromMap(change.Values)
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	debugf("Applying %s of row %d", change.Op, row.Position)
+	return row.write()
+}
+
+// changeKeys converts the key values of a change into key fields, ordered by column position
+func (file *File) changeKeys(key map[string]interface{}) ([]*Field, error) {
+	keys := make([]*Field, 0, len(key))
+	for name, val := range key {
+		pos := file.importColumnPos(name)
+		if pos < 0 {
+			return nil, NewErrorf("key column '%s' not found", name)
+		}
+		column := file.table.columns[pos]
+		value, err := file.coerceValue(column, val)
+		if err != nil {
+			return nil, NewErrorf("invalid value for key column %s", column.Name()).Details(err)
+		}
+		keys = append(keys, &Field{column: column, value: value})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return file.ColumnPos(keys[i].column) < file.ColumnPos(keys[j].column)
+	})
+	return keys, nil
+}
+
+// record keeps the original data of the row if it existed before the changes and was not recorded yet
+func (journal *changeJournal) record(position uint32) error {
+	if _, ok := journal.rows[position]; ok || position >= journal.count {
+		return nil
+	}
+	data, err := journal.file.ReadRow(position)
+	if err != nil {
+		return WrapError(err)
+	}
+	journal.rows[position] = data
+	return nil
+}
+
+// rollback restores the recorded rows and removes the appended rows, their index entries and memos
+func (journal *changeJournal) rollback() error {
+	file := journal.file
+	debugf("Rolling back changes of %s - restoring %d rows", file.config.Filename, len(journal.rows))
+	for position, data := range journal.rows {
+		err := file.writeRow(&Row{handle: file, Position: position, Deleted: Marker(data[0]) == Deleted, raw: data})
+		if err != nil {
+			return NewErrorf("failed to restore row %d", position).Details(err)
+		}
+	}
+	if file.header.RowsCount > journal.count {
+		for position := journal.count; position < file.header.RowsCount; position++ {
+			err := file.unindexRow(position)
+			if err != nil {
+				return WrapError(err)
+			}
+		}
+		file.header.RowsCount = journal.count
+		err := file.WriteHeader()
+		if err != nil {
+			return WrapError(err)
+		}
+		err = file.Truncate(file.rowsEnd())
+		if err != nil {
+			return WrapError(err)
+		}
+		err = file.writeTrailer()
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	if journal.nextFree > 0 && file.memoHeader.NextFree > journal.nextFree {
+		file.memoHeader.NextFree = journal.nextFree
+		err := file.WriteMemoHeader(0)
+		if err != nil {
+			return WrapError(err)
+		}
+		err = file.TruncateRelated(int64(journal.nextFree) * int64(file.memoHeader.BlockSize))
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package dbase
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// indexedRecnos returns the record numbers referenced by the index tag of the column
+func indexedRecnos(t *testing.T, file *File, column string) []uint32 {
+	t.Helper()
+	filename, err := file.indexFilename(CDX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := openCDX(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	tag := index.tagFor(file.table.columns[file.ColumnPosByName(column)])
+	if tag == nil {
+		t.Fatalf("no index tag for column %s", column)
+	}
+	recnos := make([]uint32, 0)
+	cursor := index.cursor(tag.header, false)
+	for {
+		entry, ok, err := cursor.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			return recnos
+		}
+		recnos = append(recnos, entry.recno)
+	}
+}
+
+func TestApplyChangesRollbackIndexes(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "INDEXED.DBF")
+	id, err := NewColumn("ID", Integer, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := NewColumn("NAME", Character, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Filename: filename, Converter: NewDefaultConverter(charmap.Windows1252), TrimSpaces: true}
+	file, err := NewTable(FoxProVar, config, []*Column{id, name}, 64, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int32(1); i <= 2; i++ {
+		row := file.NewRow()
+		err = row.SetFromMap(map[string]interface{}{"ID": i, "NAME": "ROW"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = row.Add()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = file.CreateIndex("ID", "ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	file, err = OpenTable(&Config{Filename: filename, Converter: NewDefaultConverter(charmap.Windows1252), TrimSpaces: true, MaintainIndexes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	changes := strings.Join([]string{
+		`{"op":"insert","values":{"ID":3,"NAME":"NEW"}}`,
+		`{"op":"insert","values":{"ID":4,"NAME":"NEW"}}`,
+		`{"op":"update","key":{"ID":99},"values":{"NAME":"MISSING"}}`,
+	}, "\n")
+	_, err = ApplyChanges(file, strings.NewReader(changes))
+	if err == nil {
+		t.Fatal("expected the update of a missing row to fail")
+	}
+	if file.RowsCount() != 2 {
+		t.Fatalf("expected 2 rows after the rollback, got %d", file.RowsCount())
+	}
+	recnos := indexedRecnos(t, file, "ID")
+	if len(recnos) != 2 || recnos[0] != 1 || recnos[1] != 2 {
+		t.Errorf("expected the index to reference records [1 2], got %v", recnos)
+	}
+}
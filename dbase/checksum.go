@@ -0,0 +1,175 @@
+package dbase
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checksumChunkSize is the number of bytes hashed at once
+const checksumChunkSize = 64 * 1024
+
+// ChecksumExtension is the extension of the sidecar checksum file written next to the table
+const ChecksumExtension = ".sha256"
+
+// Checksum contains the SHA-256 hashes of the content of a table and its memo file
+type Checksum struct {
+	Table string // Hex encoded hash of the DBF file, the last modification date in the header is excluded
+	Memo  string // Hex encoded hash of the memo file, empty if the table has none
+}
+
+// coreIO is implemented by the IO implementations of this package
+type coreIO interface {
+	core() ioCore
+}
+
+// Checksum computes the SHA-256 hashes of the DBF and memo file content.
+// The last modification date in the header (bytes 1 to 3) is hashed as zeros, as it changes on every write,
+// so the hash only changes if the content changes. Writes are blocked while the files are read.
+// Only the IO implementations of this package are supported.
+func (file *File) Checksum() (*Checksum, error) {
+	c, ok := file.defaults().io.(coreIO)
+	if !ok {
+		return nil, NewErrorf("checksums are not supported by IO %T", file.io)
+	}
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	file.memoMutex.Lock()
+	defer file.memoMutex.Unlock()
+	handle, err := c.core().handle(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	table, err := checksum(handle, headerDateOffset, headerRowsCountOffset)
+	if err != nil {
+		return nil, NewError("failed to hash DBF file").Details(err)
+	}
+	sum := &Checksum{Table: table}
+	if file.relatedHandle != nil {
+		related, err := c.core().related(file)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		sum.Memo, err = checksum(related, 0, 0)
+		if err != nil {
+			return nil, NewError("failed to hash memo file").Details(err)
+		}
+	}
+	debugf("Checksum of table %s: %s - memo: %s", file.config.Filename, sum.Table, sum.Memo)
+	return sum, nil
+}
+
+// WriteChecksumFile writes the checksums of the table to the sidecar file at path,
+// next to the table with the extension .sha256 if path is empty.
+// The file lists one hash and file name per line like sha256sum, but the hash of the DBF file
+// excludes the modification date, so it can only be checked with VerifyChecksumFile.
+func (file *File) WriteChecksumFile(path string) error {
+	path, err := file.checksumPath(path)
+	if err != nil {
+		return WrapError(err)
+	}
+	sum, err := file.Checksum()
+	if err != nil {
+		return WrapError(err)
+	}
+	name := filepath.Base(file.config.Filename)
+	content := fmt.Sprintf("%s  %s\n", sum.Table, name)
+	if len(sum.Memo) > 0 {
+		content += fmt.Sprintf("%s  %s\n", sum.Memo, memoFilename(name))
+	}
+	debugf("Writing checksum file: %s", path)
+	err = os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		return NewErrorf("failed to write checksum file %s", path).Details(err)
+	}
+	return nil
+}
+
+// VerifyChecksumFile compares the checksums of the table with the sidecar file at path,
+// next to the table with the extension .sha256 if path is empty.
+// Returns an error with ErrChecksumMismatch if the content of the table or memo file changed.
+func (file *File) VerifyChecksumFile(path string) error {
+	path, err := file.checksumPath(path)
+	if err != nil {
+		return WrapError(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return NewErrorf("failed to read checksum file %s", path).Details(err)
+	}
+	expected := &Checksum{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		sum, name, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "  ")
+		if !ok {
+			continue
+		}
+		ext := strings.ToUpper(filepath.Ext(name))
+		if ext == string(FPT) || ext == string(DCT) {
+			expected.Memo = strings.ToLower(sum)
+			continue
+		}
+		expected.Table = strings.ToLower(sum)
+	}
+	if len(expected.Table) == 0 {
+		return NewErrorf("checksum file %s contains no table checksum", path)
+	}
+	actual, err := file.Checksum()
+	if err != nil {
+		return WrapError(err)
+	}
+	if actual.Table != expected.Table {
+		return NewErrorf("checksum of table %s is %s instead of %s", file.config.Filename, actual.Table, expected.Table).Details(ErrChecksumMismatch)
+	}
+	if actual.Memo != expected.Memo {
+		return NewErrorf("checksum of the memo file of %s is %s instead of %s", file.config.Filename, actual.Memo, expected.Memo).Details(ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// checksumPath returns the path or the default path of the sidecar file
+func (file *File) checksumPath(path string) (string, error) {
+	if len(path) != 0 {
+		return path, nil
+	}
+	if len(file.config.Filename) == 0 {
+		return "", NewError("missing checksum file path")
+	}
+	return strings.TrimSuffix(file.config.Filename, filepath.Ext(file.config.Filename)) + ChecksumExtension, nil
+}
+
+// checksum hashes the content of the handle, the bytes in the range from start to end are hashed as zeros
+func checksum(handle fileHandle, start int64, end int64) (string, error) {
+	h := sha256.New()
+	buf := make([]byte, checksumChunkSize)
+	for offset := int64(0); ; offset += checksumChunkSize {
+		n, err := handle.ReadAt(buf, offset)
+		if n > 0 {
+			hashChunk(h, buf[:n], offset, start, end)
+		}
+		if err == io.EOF || (err == nil && n < len(buf)) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashChunk writes the chunk at offset to the hash with the bytes in the range from start to end set to zero
+func hashChunk(h hash.Hash, chunk []byte, offset int64, start int64, end int64) {
+	for i := range chunk {
+		if pos := offset + int64(i); pos >= start && pos < end {
+			chunk[i] = 0
+		}
+	}
+	h.Write(chunk)
+}
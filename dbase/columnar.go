@@ -0,0 +1,58 @@
+package dbase
+
+// Columnar reads the columns (names or external keys) of all rows into one slice per column in a single pass.
+// The slices are keyed by the requested names and the values are converted as returned by Row.ToMap,
+// the value of a row has the same index in every slice. Only the requested columns are decoded,
+// which is considerably faster than converting every row into a map, e.g. for analytics or charts.
+// Deleted rows are skipped and the internal row pointer is not moved.
+// If MaxRowsInMemory or MaxBytesInMemory is set in the config and the values exceed it, an ErrMemoryLimit error is returned.
+func (file *File) Columnar(columns []string) (map[string][]interface{}, error) {
+	positions := make([]int, len(columns))
+	for i, name := range columns {
+		pos := file.columnPosByKey(name)
+		if pos < 0 {
+			return nil, NewErrorf("column '%s' not found", name)
+		}
+		positions[i] = pos
+	}
+	debugf("Reading %d columns of %d rows into slices", len(columns), file.header.RowsCount)
+	budget := &memoryBudget{config: file.config}
+	capacity := int(file.header.RowsCount)
+	if file.config.MaxRowsInMemory > 0 && file.config.MaxRowsInMemory < capacity {
+		capacity = file.config.MaxRowsInMemory
+	}
+	result := make(map[string][]interface{}, len(columns))
+	for _, name := range columns {
+		result[name] = make([]interface{}, 0, capacity)
+	}
+	for position := uint32(0); position < file.header.RowsCount; position++ {
+		data, err := file.ReadRow(position)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		if Marker(data[0]) == Deleted {
+			continue
+		}
+		nullFlags := file.table.layout.rowNullFlags(data)
+		size := int64(0)
+		for i, pos := range positions {
+			column := file.table.columns[pos]
+			layout := file.table.layout.Columns[pos]
+			val, err := file.interpret(data[layout.Offset:layout.Offset+layout.Length], column, nullFlags)
+			if err != nil {
+				return nil, NewErrorf("failed to read column %s of row %d", column.Name(), position).Details(err)
+			}
+			val, err = file.modify(pos, file.sanitize(val))
+			if err != nil {
+				return nil, WrapError(err)
+			}
+			size += valueSize(val)
+			result[columns[i]] = append(result[columns[i]], val)
+		}
+		err = budget.add(size)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,148 @@
+package dbase
+
+import (
+	"math"
+)
+
+// ColumnOption configures a column created by NewColumnOf
+type ColumnOption func(*columnOptions)
+
+// columnOptions are the settings of a column collected from the options, zero values are not set
+type columnOptions struct {
+	length        int
+	decimals      int
+	nullable      bool
+	binary        bool
+	autoincrement bool
+	next          int64
+	step          int
+}
+
+// WithLength sets the length of a character, varchar, varbinary, numeric or float column.
+// Character columns longer than 254 bytes are created as extended column, see NewExtendedColumn.
+func WithLength(length int) ColumnOption {
+	return func(o *columnOptions) {
+		o.length = length
+	}
+}
+
+// WithDecimals sets the decimals of a numeric, float or double column
+func WithDecimals(decimals int) ColumnOption {
+	return func(o *columnOptions) {
+		o.decimals = decimals
+	}
+}
+
+// Nullable allows the column to hold null values
+func Nullable() ColumnOption {
+	return func(o *columnOptions) {
+		o.nullable = true
+	}
+}
+
+// Binary stores the values of a character or memo column without code page translation, they are read as []byte
+func Binary() ColumnOption {
+	return func(o *columnOptions) {
+		o.binary = true
+	}
+}
+
+// Autoincrement assigns the values of an integer column when rows are appended, starting at next and counting up by step
+func Autoincrement(next int64, step int) ColumnOption {
+	return func(o *columnOptions) {
+		o.autoincrement = true
+		o.next = next
+		o.step = step
+	}
+}
+
+// NewAutoincrementColumn creates an integer column whose values are assigned when rows are appended, starting at next and
+// counting up by step. The table must be created with a file version supporting autoincrement, e.g. FoxProAutoincrement.
+func NewAutoincrementColumn(name string, next int64, step int) (*Column, error) {
+	return NewColumnOf(name, Integer, Autoincrement(next, step))
+}
+
+// NewColumnOf creates a new column with the name, data type and options, e.g.
+//
+//	dbase.NewColumnOf("PRICE", dbase.Numeric, dbase.WithLength(12), dbase.WithDecimals(2), dbase.Nullable())
+//
+// Options that do not apply to the data type are rejected instead of being ignored.
+func NewColumnOf(name string, dataType DataType, opts ...ColumnOption) (*Column, error) {
+	o := &columnOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	err := o.validate(name, dataType)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if dataType == Character && o.length > MaxCharacterLength {
+		return NewExtendedColumn(name, uint16(o.length))
+	}
+	column, err := NewColumn(name, dataType, uint8(o.length), uint8(o.decimals), o.nullable)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if o.binary {
+		column.Flag |= byte(BinaryFlag)
+	}
+	if o.autoincrement {
+		column.Flag = byte(AutoincrementFlag)
+		column.Next = uint32(o.next)
+		column.Step = uint16(o.step)
+	}
+	return column, nil
+}
+
+// validate returns an error if an option does not apply to the data type or is out of range
+func (o *columnOptions) validate(name string, dataType DataType) error {
+	switch dataType {
+	case Character:
+		if o.length < 1 || o.length > MaxExtendedCharacterLength {
+			return NewErrorf("column %s: character length must be between 1 and %d, got %d", name, MaxExtendedCharacterLength, o.length)
+		}
+		if o.length > MaxCharacterLength && (o.nullable || o.binary) {
+			return NewErrorf("column %s: character columns longer than %d bytes can not be nullable or binary", name, MaxCharacterLength)
+		}
+	case Varchar, Varbinary:
+		if o.length < 1 || o.length > MaxCharacterLength {
+			return NewErrorf("column %s: %s length must be between 1 and %d, got %d", name, dataType, MaxCharacterLength, o.length)
+		}
+	case Numeric, Float:
+		if o.length < 1 || o.length > MaxNumericLength {
+			return NewErrorf("column %s: %s length must be between 1 and %d, got %d", name, dataType, MaxNumericLength, o.length)
+		}
+		// The decimals need the decimal separator and at least one digit in front of it
+		if o.decimals > 0 && o.decimals > o.length-2 {
+			return NewErrorf("column %s: %d decimals do not fit into the length %d", name, o.decimals, o.length)
+		}
+	case Integer, Memo, Logical, Currency, Date, DateTime, Double:
+		if o.length != 0 {
+			return NewErrorf("column %s: %s columns have a fixed length, the length can not be set", name, dataType)
+		}
+	default:
+		return NewErrorf("column %s: invalid data type %v specified", name, dataType)
+	}
+	if o.decimals < 0 || o.decimals > math.MaxUint8 {
+		return NewErrorf("column %s: invalid decimals %d", name, o.decimals)
+	}
+	if o.decimals > 0 && dataType != Numeric && dataType != Float && dataType != Double {
+		return NewErrorf("column %s: decimals can only be set for numeric, float and double columns", name)
+	}
+	if o.binary && dataType != Character && dataType != Memo {
+		return NewErrorf("column %s: only character and memo columns can be binary, use varbinary instead of varchar", name)
+	}
+	if o.autoincrement {
+		switch {
+		case dataType != Integer:
+			return NewErrorf("column %s: only integer columns can be autoincrement", name)
+		case o.nullable:
+			return NewErrorf("column %s: autoincrement columns can not be nullable", name)
+		case o.next < 0 || o.next > MaxIntegerValue:
+			return NewErrorf("column %s: autoincrement next value %d out of range", name, o.next)
+		case o.step < 1 || o.step > math.MaxUint8:
+			return NewErrorf("column %s: autoincrement step must be between 1 and %d, got %d", name, math.MaxUint8, o.step)
+		}
+	}
+	return nil
+}
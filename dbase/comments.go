@@ -0,0 +1,329 @@
+package dbase
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CommentsExtension is the extension of the sidecar file storing the column comments of tables outside of a database
+const CommentsExtension = ".comments.json"
+
+const (
+	dbcPropertyHeader  = 7    // Length, type and id of a property in the PROPERTY memo of the database container
+	dbcCommentProperty = 0x07 // Id of the comment property
+)
+
+// ColumnComment returns the comment of the column, empty if it has none
+func (file *File) ColumnComment(name string) string {
+	position := file.ColumnPosByName(name)
+	if position < 0 {
+		return ""
+	}
+	return file.table.comments[file.table.columns[position]]
+}
+
+// ColumnComments returns the comments of the columns by column name, columns without comment are missing
+func (file *File) ColumnComments() map[string]string {
+	comments := make(map[string]string, len(file.table.comments))
+	for column, comment := range file.table.comments {
+		comments[column.Name()] = comment
+	}
+	return comments
+}
+
+// SetColumnComment sets the human readable description of the column, e.g. its meaning and unit, an empty comment removes it.
+// The comment is stored as comment property of the field in the database container if the table was opened through a Database,
+// so it is shown by Visual FoxPro as well. Otherwise it is stored in the sidecar file next to the table with the extension .comments.json.
+func (file *File) SetColumnComment(name string, comment string) error {
+	position := file.ColumnPosByName(name)
+	if position < 0 {
+		return NewErrorf("column '%s' not found", name)
+	}
+	column := file.table.columns[position]
+	debugf("Setting comment of column %s: %s", name, comment)
+	if file.database != nil {
+		err := file.database.writeComment(file, position, comment)
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	file.setComment(column, comment)
+	if file.database != nil {
+		return nil
+	}
+	return file.writeComments()
+}
+
+// setComment sets the comment of the column without persisting it
+func (file *File) setComment(column *Column, comment string) {
+	if len(comment) == 0 {
+		delete(file.table.comments, column)
+		return
+	}
+	if file.table.comments == nil {
+		file.table.comments = make(map[*Column]string)
+	}
+	file.table.comments[column] = comment
+}
+
+// commentsPath returns the path of the sidecar file of the column comments
+func (file *File) commentsPath() string {
+	return strings.TrimSuffix(file.config.Filename, filepath.Ext(file.config.Filename)) + CommentsExtension
+}
+
+// readComments reads the column comments from the sidecar file if it exists.
+// An invalid sidecar file is recorded as warning, so the table can still be used.
+func (file *File) readComments() {
+	path := file.commentsPath()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		file.warn("failed to read the column comments %s: %v", path, err)
+		return
+	}
+	comments := make(map[string]string)
+	err = json.Unmarshal(data, &comments)
+	if err != nil {
+		file.warn("failed to parse the column comments %s: %v", path, err)
+		return
+	}
+	debugf("Read %d column comments from %s", len(comments), path)
+	for name, comment := range comments {
+		position := file.ColumnPosByName(name)
+		if position < 0 {
+			file.warn("column comments %s contain the unknown column %s", path, name)
+			continue
+		}
+		file.setComment(file.table.columns[position], comment)
+	}
+}
+
+// writeComments writes the column comments to the sidecar file, the file is removed if no column has a comment
+func (file *File) writeComments() error {
+	path := file.commentsPath()
+	if len(file.table.comments) == 0 {
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return NewErrorf("failed to remove column comments %s", path).Details(err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(file.ColumnComments(), "", "  ")
+	if err != nil {
+		return NewError("failed to marshal column comments").Details(err)
+	}
+	debugf("Writing column comments: %s", path)
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return NewErrorf("failed to write column comments %s", path).Details(err)
+	}
+	return nil
+}
+
+// dbcProperty is a property of an object in the database container
+type dbcProperty struct {
+	id    byte
+	value []byte
+}
+
+// parseDBCProperties parses the PROPERTY memo of an object in the database container.
+// Each property is stored as length of the whole property (uint32), type (uint16) and id followed by the value.
+func parseDBCProperties(data []byte) ([]dbcProperty, error) {
+	properties := make([]dbcProperty, 0)
+	for len(data) > 0 {
+		if len(data) < dbcPropertyHeader {
+			return nil, NewErrorf("truncated property of %d bytes", len(data))
+		}
+		length := binary.LittleEndian.Uint32(data)
+		if length < dbcPropertyHeader || int64(length) > int64(len(data)) {
+			return nil, NewErrorf("invalid property length %d", length)
+		}
+		properties = append(properties, dbcProperty{id: data[6], value: data[dbcPropertyHeader:length]})
+		data = data[length:]
+	}
+	return properties, nil
+}
+
+// encodeDBCProperties encodes the properties as PROPERTY memo, see parseDBCProperties
+func encodeDBCProperties(properties []dbcProperty) []byte {
+	data := make([]byte, 0)
+	for _, property := range properties {
+		header := make([]byte, dbcPropertyHeader)
+		binary.LittleEndian.PutUint32(header, uint32(dbcPropertyHeader+len(property.value)))
+		binary.LittleEndian.PutUint16(header[4:], 1)
+		header[6] = property.id
+		data = append(append(data, header...), property.value...)
+	}
+	return data
+}
+
+// fieldObjects returns the positions of the field objects of the table in the database container, in the order of the columns
+func (db *Database) fieldObjects(name string) ([]uint32, error) {
+	id, ok := db.ids[name]
+	if !ok {
+		return nil, NewErrorf("table %s not found in database", name)
+	}
+	typeField, err := db.file.NewFieldByName("OBJECTTYPE", "Field")
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	rows, err := db.file.Search(typeField, true)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	positions := make([]uint32, 0)
+	for _, row := range rows {
+		parent, err := row.ValueByName("PARENTID")
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		if parent == id && !row.Deleted {
+			positions = append(positions, row.Position)
+		}
+	}
+	return positions, nil
+}
+
+// fieldObject returns the field object of the column at the position in the database container,
+// the objects are the positions of the field objects of the table, see fieldObjects
+func (db *Database) fieldObject(table *File, objects []uint32, position int) (uint32, []byte, error) {
+	column := table.table.columns[position]
+	if position >= len(objects) {
+		return 0, nil, NewErrorf("column %s has no field in the database", column.Name())
+	}
+	object := objects[position]
+	raw, err := db.file.ReadRow(object)
+	if err != nil {
+		return 0, nil, WrapError(err)
+	}
+	layout := db.file.table.layout.Columns[db.file.ColumnPosByName("OBJECTNAME")]
+	objectName := strings.TrimSpace(string(raw[layout.Offset : layout.Offset+layout.Length]))
+	if !dbcFieldName(objectName, column) {
+		return 0, nil, NewErrorf("field %s in the database does not belong to column %s", objectName, column.Name())
+	}
+	return object, raw, nil
+}
+
+// dbcFieldName returns if the column belongs to the field with the long name in the database container.
+// The column name is the long name cut to 10 characters, if that is not unique the end is replaced by a number, e.g. EXPENSECA2.
+func dbcFieldName(name string, column *Column) bool {
+	if cdxColumnExpression(name, column) {
+		return true
+	}
+	columnName := column.Name()
+	prefix := strings.TrimRight(columnName, "0123456789")
+	return len(prefix) < len(columnName) && len(prefix) <= len(name) && strings.EqualFold(name[:len(prefix)], prefix)
+}
+
+// readComments sets the comment properties of the fields in the database container as column comments of the table
+func (db *Database) readComments(name string, table *File) error {
+	objects, err := db.fieldObjects(name)
+	if err != nil {
+		return WrapError(err)
+	}
+	property := db.file.table.layout.Columns[db.file.ColumnPosByName("PROPERTY")]
+	for position := range table.table.columns {
+		object, raw, err := db.fieldObject(table, objects, position)
+		if err != nil {
+			return WrapError(err)
+		}
+		address := raw[property.Offset : property.Offset+property.Length]
+		if blankAddress(address) {
+			continue
+		}
+		memo, _, err := db.file.readMemo(address, nil)
+		if err != nil {
+			return NewErrorf("failed to read the properties of field %d", object).Details(err)
+		}
+		properties, err := parseDBCProperties(memo)
+		if err != nil {
+			return NewErrorf("failed to parse the properties of field %d", object).Details(err)
+		}
+		for _, p := range properties {
+			if p.id != dbcCommentProperty {
+				continue
+			}
+			comment, err := decode([]byte(strings.TrimRight(string(p.value), "\x00")), db.file.config.Converter, false)
+			if err != nil {
+				return NewErrorf("failed to decode the comment of field %d", object).Details(err)
+			}
+			table.setComment(table.table.columns[position], string(comment))
+		}
+	}
+	return nil
+}
+
+// writeComment stores the comment as comment property of the field of the column in the database container
+func (db *Database) writeComment(table *File, position int, comment string) error {
+	if db.config.ReadOnly {
+		return NewError("can not write the comment to a read-only database")
+	}
+	db.mutex.Lock()
+	name := ""
+	for n, t := range db.tables {
+		if t == table {
+			name = n
+		}
+	}
+	db.mutex.Unlock()
+	if len(name) == 0 {
+		return NewError("table is not open in the database")
+	}
+	file := db.file
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	objects, err := db.fieldObjects(name)
+	if err != nil {
+		return WrapError(err)
+	}
+	object, raw, err := db.fieldObject(table, objects, position)
+	if err != nil {
+		return WrapError(err)
+	}
+	layout := file.table.layout.Columns[file.ColumnPosByName("PROPERTY")]
+	address := raw[layout.Offset : layout.Offset+layout.Length]
+	memo, text := []byte{}, true
+	if !blankAddress(address) {
+		memo, text, err = file.readMemo(address, nil)
+		if err != nil {
+			return NewErrorf("failed to read the properties of field %d", object).Details(err)
+		}
+	} else {
+		address = nil
+	}
+	properties, err := parseDBCProperties(memo)
+	if err != nil {
+		return NewErrorf("failed to parse the properties of field %d", object).Details(err)
+	}
+	kept := make([]dbcProperty, 0, len(properties)+1)
+	for _, p := range properties {
+		if p.id != dbcCommentProperty {
+			kept = append(kept, p)
+		}
+	}
+	if len(comment) > 0 {
+		encoded, err := file.config.Converter.Encode([]byte(comment))
+		if err != nil {
+			return NewError("failed to encode the comment").Details(err)
+		}
+		kept = append(kept, dbcProperty{id: dbcCommentProperty, value: append(encoded, 0x00)})
+	}
+	memo = encodeDBCProperties(kept)
+	address, err = file.WriteMemo(address, memo, text, len(memo))
+	if err != nil {
+		return NewErrorf("failed to write the properties of field %d", object).Details(err)
+	}
+	copy(raw[layout.Offset:layout.Offset+layout.Length], address)
+	err = file.writeRow(&Row{handle: file, Position: object, Deleted: Marker(raw[0]) == Deleted, raw: raw})
+	if err != nil {
+		return NewErrorf("failed to write field %d", object).Details(err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package dbase
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// compareTolerance is the relative difference up to which floating point values are considered equal
+const compareTolerance = 1e-9
+
+// Compare compares two values of the data type and returns -1 if a is less than b, 0 if they are equal and 1 if a is greater than b.
+// The comparison follows the semantics of the data type, so values read from a table, values given by the user and
+// values converted by the library compare consistently:
+//   - Character, Varchar and memo values are compared trimmed of surrounding spaces and padding zero bytes, byte by byte (machine collation).
+//     Strings and []byte are interchangeable, as values are decoded from the code page of the table when read.
+//   - Numeric values of any Go numeric type or numeric strings are compared as float64 with a small relative tolerance.
+//   - Dates are truncated to the day and datetimes to milliseconds, the precision the values are stored with.
+//   - Logical false is less than true.
+//
+// Nil is less than any other value. Values that can not be converted to the data type are compared by their text representation.
+func Compare(a, b interface{}, dt DataType) int {
+	return compareValues(a, b, dt, -1)
+}
+
+// Equal returns if the value of the field equals the other value, see Compare.
+// The other value can be a Field or a plain value. Float values are rounded to the decimals of the column.
+func (field *Field) Equal(other interface{}) bool {
+	switch o := other.(type) {
+	case *Field:
+		if o == nil {
+			return field == nil
+		}
+		other = o.value
+	case Field:
+		other = o.value
+	}
+	if field == nil || field.column == nil {
+		return false
+	}
+	decimals := -1
+	switch field.Type() {
+	case Numeric, Float:
+		decimals = int(field.column.Decimals)
+	case Currency:
+		decimals = 4
+	}
+	return compareValues(field.value, other, field.Type(), decimals) == 0
+}
+
+// compareValues compares the values of the data type, float values are rounded to the decimals unless negative
+func compareValues(a, b interface{}, dt DataType, decimals int) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch dt {
+	case Character, Varchar, Memo, General, Picture:
+		as, aok := compareText(a)
+		bs, bok := compareText(b)
+		if aok && bok {
+			return strings.Compare(as, bs)
+		}
+	case Blob, Varbinary:
+		ab, aok := a.([]byte)
+		bb, bok := b.([]byte)
+		if aok && bok {
+			return bytes.Compare(ab, bb)
+		}
+	case Numeric, Float, Double, Currency, Integer:
+		af, aok := compareFloat(a)
+		bf, bok := compareFloat(b)
+		if aok && bok {
+			return compareFloats(af, bf, decimals)
+		}
+	case Date, DateTime:
+		at, aok := compareTime(a, dt)
+		bt, bok := compareTime(b, dt)
+		if aok && bok {
+			return at.Compare(bt)
+		}
+	case Logical:
+		ab, aok := a.(bool)
+		bb, bok := b.(bool)
+		if aok && bok {
+			switch {
+			case ab == bb:
+				return 0
+			case bb:
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// compareText returns the trimmed text of a string or byte value
+func compareText(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return strings.Trim(v, " \x00"), true
+	case []byte:
+		return strings.Trim(string(v), " \x00"), true
+	}
+	return "", false
+}
+
+// compareFloat converts a numeric value or numeric string to float64
+func compareFloat(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	case []byte:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+		return f, err == nil
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case float32:
+		// Avoid the binary noise of float32 values, e.g. 0.1 becoming 0.10000000149011612
+		f, err := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+		return f, err == nil
+	}
+	f, err := aggregateFloat(val)
+	return f, err == nil
+}
+
+func compareFloats(a, b float64, decimals int) int {
+	if decimals >= 0 {
+		pow := math.Pow(10, float64(decimals))
+		a, b = math.Round(a*pow)/pow, math.Round(b*pow)/pow
+	}
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	case math.Abs(a-b) <= compareTolerance*math.Max(1, math.Max(math.Abs(a), math.Abs(b))):
+		return 0
+	case a < b:
+		return -1
+	}
+	return 1
+}
+
+// compareTime converts the value to time and truncates it to the precision of the data type
+func compareTime(val interface{}, dt DataType) (time.Time, bool) {
+	var t time.Time
+	switch v := val.(type) {
+	case time.Time:
+		t = v
+	case string:
+		parsed, err := parseRepresentationTime(strings.TrimSpace(v))
+		if err != nil {
+			return t, false
+		}
+		t = parsed
+	default:
+		return t, false
+	}
+	if dt == Date {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), true
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond()/int(time.Millisecond)*int(time.Millisecond), time.UTC), true
+}
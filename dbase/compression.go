@@ -0,0 +1,123 @@
+package dbase
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressedMemoMagic prefixes memos compressed by this library, followed by a flag byte and the zstd frame.
+// Other dBase applications see a binary memo.
+var compressedMemoMagic = []byte("\x00DBZ")
+
+// zstdFrameMagic starts every zstd frame
+var zstdFrameMagic = []byte{0x28, 0xB5, 0x2F, 0xFD}
+
+// compressedText flags a compressed memo that was a text memo
+const compressedText byte = 1
+
+var (
+	zstdOnce     sync.Once
+	zstdEncoder  *zstd.Encoder
+	zstdErr      error
+	zstdDecoders sync.Map // Decoders by the maximum decoded size, see zstdDecoder
+)
+
+// zstdCodec returns the shared encoder, it is safe for concurrent use with EncodeAll
+func zstdCodec() (*zstd.Encoder, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+	})
+	return zstdEncoder, zstdErr
+}
+
+// zstdDecoder returns a shared decoder that stops as soon as the decoded data or the window of a frame exceeds the limit,
+// so a small frame can not expand into a memo larger than the maximum memo size.
+// It is safe for concurrent use with DecodeAll.
+func zstdDecoder(limit int64) (*zstd.Decoder, error) {
+	if decoder, ok := zstdDecoders.Load(limit); ok {
+		return decoder.(*zstd.Decoder), nil
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(uint64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	shared, loaded := zstdDecoders.LoadOrStore(limit, decoder)
+	if loaded {
+		decoder.Close()
+	}
+	return shared.(*zstd.Decoder), nil
+}
+
+// SetColumnCompression enables or disables the compression of memos written to the memo column.
+// Memos are stored zstd compressed with a short prefix, if that is smaller than the memo. Compressed memos are
+// decompressed transparently when read, regardless of the setting, but can not be read by other dBase applications.
+func (file *File) SetColumnCompression(name string, compress bool) error {
+	position := file.ColumnPosByName(name)
+	if position < 0 {
+		return NewErrorf("column '%s' not found", name)
+	}
+	column := file.table.columns[position]
+	if DataType(column.DataType) != Memo {
+		return NewErrorf("column %s of type %s is not stored in the memo file, only memo columns can be compressed", name, DataType(column.DataType))
+	}
+	if !compress {
+		debugf("Disabling compression of column %s", name)
+		delete(file.table.compressed, column)
+		return nil
+	}
+	debugf("Enabling compression of column %s", name)
+	if file.table.compressed == nil {
+		file.table.compressed = make(map[*Column]bool)
+	}
+	file.table.compressed[column] = true
+	return nil
+}
+
+// compressMemo compresses the memo if compression is enabled for the column and the result is smaller.
+// Compressed memos are stored as binary memos.
+func (file *File) compressMemo(column *Column, memo []byte, text bool) ([]byte, bool, error) {
+	if !file.table.compressed[column] || len(memo) == 0 {
+		return memo, text, nil
+	}
+	encoder, err := zstdCodec()
+	if err != nil {
+		return nil, text, NewError("failed to create zstd encoder").Details(err)
+	}
+	flag := byte(0)
+	if text {
+		flag = compressedText
+	}
+	compressed := make([]byte, 0, len(compressedMemoMagic)+1+len(memo)/2)
+	compressed = append(compressed, compressedMemoMagic...)
+	compressed = encoder.EncodeAll(memo, append(compressed, flag))
+	if len(compressed) >= len(memo) {
+		return memo, text, nil
+	}
+	debugf("Compressed memo of column %s from %d to %d bytes", column.Name(), len(memo), len(compressed))
+	return compressed, false, nil
+}
+
+// decompressMemo returns the decompressed memo and whether it was a text memo if it was compressed by compressMemo
+func (file *File) decompressMemo(data []byte, text bool) ([]byte, bool, error) {
+	header := len(compressedMemoMagic) + 1
+	if text || len(data) < header+len(zstdFrameMagic) || !bytes.HasPrefix(data, compressedMemoMagic) || !bytes.HasPrefix(data[header:], zstdFrameMagic) {
+		return data, text, nil
+	}
+	limit := file.memoLimit()
+	decoder, err := zstdDecoder(limit)
+	if err != nil {
+		return nil, text, NewError("failed to create zstd decoder").Details(err)
+	}
+	// The decoder rejects a frame whose content size exceeds the limit before allocating the memo
+	memo, err := decoder.DecodeAll(data[header:], nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, text, NewErrorf("decompressed memo exceeds the maximum memo size of %d bytes", limit).Details(ErrMemoTooLarge)
+	}
+	if err != nil {
+		return nil, text, NewError("failed to decompress memo").Details(err)
+	}
+	return memo, data[len(compressedMemoMagic)] == compressedText, nil
+}
@@ -0,0 +1,71 @@
+package dbase
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressedMemo returns the memo as stored by compressMemo, streamed frames of large memos do not declare their content size
+func compressedMemo(t *testing.T, memo []byte, stream bool) []byte {
+	t.Helper()
+	data := append(append([]byte(nil), compressedMemoMagic...), 0)
+	if !stream {
+		encoder, err := zstdCodec()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoder.EncodeAll(memo, data)
+	}
+	buf := bytes.NewBuffer(data)
+	encoder, err := zstd.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = encoder.Write(memo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = encoder.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressMemoLimit(t *testing.T) {
+	file := &File{config: &Config{MaxMemoSize: 4096}}
+	for _, stream := range []bool{false, true} {
+		memo := bytes.Repeat([]byte("memo"), 1024)
+		decompressed, _, err := file.decompressMemo(compressedMemo(t, memo, stream), false)
+		if err != nil {
+			t.Fatalf("stream %v: %v", stream, err)
+		}
+		if !bytes.Equal(decompressed, memo) {
+			t.Errorf("stream %v: decompressed memo differs", stream)
+		}
+		// A few bytes expanding to 16 MB must be rejected without decoding them completely
+		bomb := compressedMemo(t, make([]byte, 16<<20), stream)
+		frame := zstd.Header{}
+		err = frame.Decode(bomb[len(compressedMemoMagic)+1:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.HasFCS == stream {
+			t.Fatalf("stream %v: frame declares content size %v", stream, frame.HasFCS)
+		}
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, _, err = file.decompressMemo(bomb, false)
+		runtime.ReadMemStats(&after)
+		if !errors.Is(err, ErrMemoTooLarge) {
+			t.Errorf("stream %v: expected ErrMemoTooLarge for a %d byte frame, got %v", stream, len(bomb), err)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4<<20 {
+			t.Errorf("stream %v: allocated %d bytes to reject the frame", stream, allocated)
+		}
+	}
+}
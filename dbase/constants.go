@@ -38,6 +38,8 @@ const (
 	DCT FileExtension = ".DCT" // Database container file extension
 	DBF FileExtension = ".DBF" // Table file extension
 	FPT FileExtension = ".FPT" // Memo file extension
+	CDX FileExtension = ".CDX" // Compound index file extension
+	MDX FileExtension = ".MDX" // Production index file extension of dBase IV
 	SCX FileExtension = ".SCX" // Form file extension
 	LBX FileExtension = ".LBX" // Label file extension
 	MNX FileExtension = ".MNX" // Menu file extension
@@ -133,6 +135,13 @@ func (t DataType) Reflect() (reflect.Type, error) {
 // wether the column has a variable length.
 var nullFlagColumn = [11]byte{0x5F, 0x4E, 0x75, 0x6C, 0x6C, 0x46, 0x6C, 0x61, 0x67, 0x73}
 
+// Keys of the virtual columns added by ToMap and ToJSON if VirtualColumns is set in the config
+const (
+	VirtualDeleted  = "_deleted"  // Whether the row is marked as deleted (bool)
+	VirtualPosition = "_position" // Position of the row, starting at 0 (uint32)
+	VirtualOffset   = "_offset"   // Offset of the row in the DBF file in bytes (int64)
+)
+
 const (
 	MaxColumnNameLength = 10
 	MaxCharacterLength  = 254
@@ -140,4 +149,14 @@ const (
 	MaxFloatLength      = 20
 	MaxIntegerValue     = math.MaxInt32
 	MinIntegerValue     = math.MinInt32
+	MaxRowLength        = math.MaxUint16
+	// Maximum length of character columns using the Clipper convention, the row also contains the deleted flag
+	MaxExtendedCharacterLength = MaxRowLength - 1
+	// Maximum number of rows of a table supported by Visual FoxPro
+	MaxRecordsPerTable = 1000000000
+	// Maximum size of a DBF file in bytes, offsets behind it can not be locked or addressed by FoxPro
+	MaxTableFileSize = math.MaxInt32
 )
+
+// DefaultMemoBlockSize is the block size of memo files created on demand, the default of Visual FoxPro
+const DefaultMemoBlockSize uint16 = 64
@@ -41,7 +41,7 @@ func julianToDate(jd int) (int, int, int) {
 // parseDate parses a date string from a byte slice and returns a time.Time
 func parseDate(raw []byte) (time.Time, error) {
 	raw = sanitizeEmptyBytes(raw)
-	if len(raw) == 0 {
+	if len(raw) == 0 || string(raw) == "00000000" {
 		return time.Time{}, nil
 	}
 	t, err := time.Parse("20060102", string(raw))
@@ -58,6 +58,9 @@ func parseDateTime(raw []byte) time.Time {
 	}
 	julDat := int(binary.LittleEndian.Uint32(raw[:4]))
 	mSec := int(binary.LittleEndian.Uint32(raw[4:]))
+	if julDat == 0 {
+		return time.Time{}
+	}
 	// Determine year, month, day
 	y, m, d := julianToDate(julDat)
 	if y < 0 || y > 9999 {
@@ -83,12 +86,16 @@ func parseNumericInt(raw []byte) (int64, error) {
 	return i, nil
 }
 
-// parseFloat parses a string as byte array to float64
+// parseFloat parses a string as byte array to float64.
+// A comma is accepted as decimal separator, as written by some European tools, if the value contains no point.
 func parseFloat(raw []byte) (float64, error) {
 	trimmed := strings.TrimSpace(string(sanitizeEmptyBytes(raw)))
 	if len(trimmed) == 0 {
 		return float64(0), nil
 	}
+	if strings.Count(trimmed, ",") == 1 && !strings.Contains(trimmed, ".") {
+		trimmed = strings.Replace(trimmed, ",", ".", 1)
+	}
 	f, err := strconv.ParseFloat(strings.TrimSpace(trimmed), 64)
 	if err != nil {
 		return f, NewError("failed to parse float").Details(err)
@@ -96,9 +103,12 @@ func parseFloat(raw []byte) (float64, error) {
 	return f, nil
 }
 
-// toUTF8String converts a byte slice to a UTF8 string using the converter
-func toUTF8String(raw []byte, converter EncodingConverter) (string, error) {
-	utf8, err := converter.Decode(raw)
+// toUTF8String converts a byte slice to a UTF8 string using the converter, strictly if set (see Config.StrictEncoding)
+func toUTF8String(raw []byte, converter EncodingConverter, strict bool) (string, error) {
+	if isASCII(raw) && asciiCompatible(converter) {
+		return string(raw), nil
+	}
+	utf8, err := decode(raw, converter, strict)
 	if err != nil {
 		return string(raw), WrapError(err)
 	}
@@ -107,6 +117,9 @@ func toUTF8String(raw []byte, converter EncodingConverter) (string, error) {
 
 // fromUTF8String converts a UTF8 string to a byte slice using the given converter
 func fromUtf8String(raw []byte, converter EncodingConverter) ([]byte, error) {
+	if isASCII(raw) && asciiCompatible(converter) {
+		return raw, nil
+	}
 	utf8, err := converter.Encode(raw)
 	if err != nil {
 		return raw, WrapError(err)
@@ -114,6 +127,28 @@ func fromUtf8String(raw []byte, converter EncodingConverter) ([]byte, error) {
 	return utf8, nil
 }
 
+// isASCII returns if the data only contains ASCII characters, checking eight bytes at once
+func isASCII(data []byte) bool {
+	i := 0
+	for ; i+8 <= len(data); i += 8 {
+		if binary.LittleEndian.Uint64(data[i:])&0x8080808080808080 != 0 {
+			return false
+		}
+	}
+	for ; i < len(data); i++ {
+		if data[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// asciiCompatible returns if the converter stores ASCII characters as they are, see ASCIICompatibleConverter
+func asciiCompatible(converter EncodingConverter) bool {
+	c, ok := converter.(ASCIICompatibleConverter)
+	return ok && c.ASCIICompatible()
+}
+
 // Convert data to binary representation
 func toBinary(data interface{}) ([]byte, error) {
 	buf := new(bytes.Buffer)
@@ -124,30 +159,6 @@ func toBinary(data interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// appendSpaces appends spaces to a byte slice until it reaches the given length
-func appendSpaces(raw []byte, length int) []byte {
-	if len(raw) < length {
-		a := make([]byte, length-len(raw))
-		for i := range a {
-			a[i] = ' '
-		}
-		return append(raw, a...)
-	}
-	return raw
-}
-
-// prependSpaces prepends spaces to a byte slice until it reaches the given length
-func prependSpaces(raw []byte, length int) []byte {
-	if len(raw) < length {
-		result := make([]byte, 0)
-		for i := 0; i < length-len(raw); i++ {
-			result = append(result, ' ')
-		}
-		return append(result, raw...)
-	}
-	return raw
-}
-
 func sanitizeEmptyBytes(raw []byte) []byte {
 	raw = bytes.ReplaceAll(raw, []byte{0x00}, []byte{})
 	raw = []byte(strings.TrimSpace(string(raw)))
@@ -161,7 +172,7 @@ func sanitizeSpaces(str string) string {
 
 // nthBit returns the nth bit of a byte slice
 func getNthBit(bytes []byte, n int) bool {
-	if n > len(bytes)*8 {
+	if n < 0 || n >= len(bytes)*8 {
 		return false
 	}
 	byteIndex := n / 8 // byte index
@@ -193,6 +204,11 @@ func setStructField(tags map[string]string, obj interface{}, name string, value
 		}
 		return nil
 	}
+	return assignStructField(structFieldValue, name, value)
+}
+
+// assignStructField sets the struct field to the value, converting it to the type of the field if possible
+func assignStructField(structFieldValue reflect.Value, name string, value interface{}) error {
 	if !structFieldValue.CanSet() {
 		return NewError("failed to set struct field value").Details(fmt.Errorf("cannot set %s field value", name))
 	}
@@ -231,6 +247,11 @@ func extractTags(structValue reflect.Value, tags map[string]string) {
 			continue
 		}
 
+		// Columns with a path tag are set using the path, see getStructPaths
+		if _, ok := field.Tag.Lookup("path"); ok {
+			continue
+		}
+
 		tag := strings.ToUpper(field.Tag.Get("dbase"))
 		if len(tag) > 0 {
 			tags[tag] = field.Name
@@ -0,0 +1,180 @@
+package dbase
+
+import (
+	"time"
+)
+
+// VersionChange describes how a column is changed by ConvertVersion
+type VersionChange struct {
+	Column  string // Name of the column
+	Dropped bool   // Whether the column is not part of the converted table
+	Reason  string // Description of the change
+}
+
+// VersionReport is the result of ConvertVersion
+type VersionReport struct {
+	Rows    int             // Number of rows written to the converted table
+	Changes []VersionChange // Columns that were changed or dropped
+}
+
+// versionColumn maps a column of the source table to a column of the converted table
+type versionColumn struct {
+	src     int                                 // Position of the column in the source table
+	column  *Column                             // Column of the converted table
+	convert func(value interface{}) interface{} // Converts the value of the source column, nil if the value is kept
+}
+
+// ConvertVersion writes the rows of src into a new table of the target version, created using dstConfig,
+// to make the data readable by consumers of older versions. Columns the target version can not store are converted:
+// varchar columns become character columns of the same length, integer, float and currency columns numeric columns,
+// datetime columns date columns, nullable and autoincrement columns plain columns. Columns of other unsupported types,
+// e.g. memo columns without memo support, are dropped. The report lists all changed and dropped columns.
+// Deleted rows are kept as deleted rows and autoincrement values are preserved.
+// If the converter of dstConfig is nil the converter of src is used. The created table is returned open.
+func ConvertVersion(src *File, target FileVersion, dstConfig *Config) (*File, *VersionReport, error) {
+	if dstConfig == nil {
+		return nil, nil, NewError("missing config of the converted table")
+	}
+	if dstConfig.Converter == nil {
+		dstConfig.Converter = src.config.Converter
+	}
+	report := &VersionReport{Changes: make([]VersionChange, 0)}
+	mapping := make([]*versionColumn, 0, len(src.table.columns))
+	columns := make([]*Column, 0, len(src.table.columns))
+	for i, column := range src.table.columns {
+		vc, change, err := versionConvertColumn(column, target)
+		if err != nil {
+			return nil, nil, WrapError(err)
+		}
+		if change != nil {
+			debugf("Converting column %s to version 0x%02x: %s", column.Name(), byte(target), change.Reason)
+			report.Changes = append(report.Changes, *change)
+		}
+		if vc == nil {
+			continue
+		}
+		vc.src = i
+		mapping = append(mapping, vc)
+		columns = append(columns, vc.column)
+	}
+	if len(columns) == 0 {
+		return nil, report, NewErrorf("file version 0x%02x supports none of the columns", byte(target))
+	}
+	blockSize := DefaultMemoBlockSize
+	if src.memoHeader != nil && src.memoHeader.BlockSize > 0 {
+		blockSize = src.memoHeader.BlockSize
+	}
+	dst, err := NewTable(target, dstConfig, columns, blockSize, dstConfig.IO)
+	if err != nil {
+		return nil, report, WrapError(err)
+	}
+	for position := uint32(0); position < src.header.RowsCount; position++ {
+		data, err := src.ReadRow(position)
+		if err != nil {
+			return dst, report, NewErrorf("failed to read row %d", position).Details(err)
+		}
+		row, err := src.BytesToRow(data)
+		if err != nil {
+			return dst, report, NewErrorf("failed to convert row %d", position).Details(err)
+		}
+		// The values are mapped to the columns of dst, the memos are written to the memo file of dst by rewriteMemoRefs
+		mapped := &Row{handle: src, Position: position, Deleted: row.Deleted, fields: make([]*Field, len(mapping))}
+		for i, vc := range mapping {
+			value := row.fields[vc.src].value
+			if vc.convert != nil {
+				value = vc.convert(value)
+			}
+			mapped.fields[i] = &Field{column: vc.column, value: value}
+		}
+		converted, err := rewriteMemoRefs(mapped, dst)
+		if err != nil {
+			return dst, report, NewErrorf("failed to convert the memos of row %d", position).Details(err)
+		}
+		// The row is written as is, so autoincrement values are not assigned again
+		raw, err := converted.ToBytes()
+		if err != nil {
+			return dst, report, NewErrorf("failed to convert row %d", position).Details(err)
+		}
+		err = dst.writeRaw(raw)
+		if err != nil {
+			return dst, report, NewErrorf("failed to write row %d", position).Details(err)
+		}
+		report.Rows++
+	}
+	return dst, report, dst.commit(nil)
+}
+
+// versionConvertColumn returns the column of the target version for the column and the change if it had to be changed.
+// The returned column is nil if the column is dropped.
+func versionConvertColumn(column *Column, target FileVersion) (*versionColumn, *VersionChange, error) {
+	name := column.Name()
+	dataType := DataType(column.DataType)
+	vc := &versionColumn{}
+	reasons := make([]string, 0)
+	if target.SupportsType(dataType) {
+		c := *column
+		vc.column = &c
+	} else {
+		var (
+			err    error
+			reason string
+		)
+		switch dataType {
+		case Varchar:
+			vc.column, err = NewColumn(name, Character, column.Length, 0, false)
+			vc.convert = func(value interface{}) interface{} {
+				if b, ok := value.([]byte); ok {
+					return string(b)
+				}
+				return value
+			}
+			reason = "varchar stored as character"
+		case Integer:
+			vc.column, err = NewColumn(name, Numeric, 11, 0, false)
+			vc.convert = func(value interface{}) interface{} {
+				if i, ok := value.(int32); ok {
+					return int64(i)
+				}
+				return value
+			}
+			reason = "integer stored as numeric"
+		case Float:
+			vc.column, err = NewColumn(name, Numeric, column.Length, column.Decimals, false)
+			reason = "float stored as numeric"
+		case Currency:
+			vc.column, err = NewColumn(name, Numeric, MaxNumericLength, 4, false)
+			reason = "currency stored as numeric"
+		case DateTime:
+			vc.column, err = NewColumn(name, Date, 8, 0, false)
+			vc.convert = func(value interface{}) interface{} {
+				if t, ok := value.(time.Time); ok {
+					return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+				}
+				return value
+			}
+			reason = "datetime stored as date, the time of day is lost"
+		default:
+			return nil, &VersionChange{Column: name, Dropped: true, Reason: "data type " + dataType.String() + " is not supported"}, nil
+		}
+		if err != nil {
+			return nil, nil, NewErrorf("failed to convert column %s", name).Details(err)
+		}
+		reasons = append(reasons, reason)
+	}
+	if vc.column.Flag == byte(AutoincrementFlag) && !target.Supports(FeatureAutoincrement) {
+		vc.column.Flag, vc.column.Next, vc.column.Step = 0, 0, 0
+		reasons = append(reasons, "autoincrement removed")
+	}
+	if vc.column.Flag&byte(NullableFlag) != 0 && !target.Supports(FeatureNullFlags) {
+		vc.column.Flag &^= byte(NullableFlag)
+		reasons = append(reasons, "null values stored as empty values")
+	}
+	if len(reasons) == 0 {
+		return vc, nil, nil
+	}
+	change := &VersionChange{Column: name, Reason: reasons[0]}
+	for _, reason := range reasons[1:] {
+		change.Reason += ", " + reason
+	}
+	return vc, change, nil
+}
@@ -0,0 +1,200 @@
+package dbase
+
+// CopyRaw appends the rows of src to dst as stored, without interpreting the values, and returns the number of copied rows.
+// This way tables with values the package can not parse can still be repaired or migrated.
+// The filter receives the position and the raw data of each row, including the deleted flag, and returns if the row is copied.
+// It may modify the data, e.g. clear a memo address to skip the memo. A nil filter copies all rows.
+// Both tables must have the same row layout. The memos of memo columns are copied to the memo file of dst,
+// which is created if needed, and the addresses in the rows are rewritten. The next values of autoincrement
+// columns of dst are raised to those of src, so rows added later do not reuse copied values.
+func CopyRaw(src, dst *File, filter func(position uint32, raw []byte) bool) (int, error) {
+	err := compatibleLayout(src, dst)
+	if err != nil {
+		return 0, WrapError(err)
+	}
+	debugf("Copying %d raw rows from %s to %s", src.header.RowsCount, src.config.Filename, dst.config.Filename)
+	copied := 0
+	for position := uint32(0); position < src.header.RowsCount; position++ {
+		data, err := src.ReadRow(position)
+		if err != nil {
+			return copied, NewErrorf("failed to read row %d", position).Details(err)
+		}
+		if filter != nil && !filter(position, data) {
+			continue
+		}
+		err = copyMemos(src, dst, data)
+		if err != nil {
+			return copied, NewErrorf("failed to copy the memos of row %d", position).Details(err)
+		}
+		err = dst.writeRaw(data)
+		if err != nil {
+			return copied, NewErrorf("failed to write row %d", position).Details(err)
+		}
+		copied++
+	}
+	err = dst.commit(copyAutoincrement(src, dst))
+	if err != nil {
+		return copied, WrapError(err)
+	}
+	return copied, nil
+}
+
+// compatibleLayout returns an error if the rows of src can not be stored in dst as they are
+func compatibleLayout(src, dst *File) error {
+	if src.header.RowLength != dst.header.RowLength {
+		return NewErrorf("row length %d differs from row length %d", src.header.RowLength, dst.header.RowLength)
+	}
+	a, b := src.table.layout, dst.table.layout
+	if len(a.Columns) != len(b.Columns) {
+		return NewErrorf("%d columns differ from %d columns", len(a.Columns), len(b.Columns))
+	}
+	for i, c := range a.Columns {
+		if c.Offset != b.Columns[i].Offset || c.Length != b.Columns[i].Length || c.Type != b.Columns[i].Type {
+			return NewErrorf("column %s differs from column %s", src.table.columns[i].Name(), dst.table.columns[i].Name())
+		}
+	}
+	if a.NullFlagOffset != b.NullFlagOffset || a.NullFlagLength != b.NullFlagLength {
+		return NewError("null flag columns differ")
+	}
+	return nil
+}
+
+// copyMemos copies the memos referenced by the row data from src to dst and rewrites the addresses,
+// including the memos of general, picture and blob columns
+func copyMemos(src, dst *File, data []byte) error {
+	for i, c := range src.table.layout.Columns {
+		if !memoPointer(c.Type) || c.Length < 4 {
+			continue
+		}
+		address := data[c.Offset : c.Offset+c.Length]
+		if blankAddress(address) {
+			continue
+		}
+		written, err := copyMemo(src, dst, address)
+		if err != nil {
+			return NewErrorf("failed to copy memo of column %s", src.table.columns[i].Name()).Details(err)
+		}
+		copy(address, written)
+	}
+	return nil
+}
+
+// copyMemo copies the memo at the address from the memo file of src to the memo file of dst and returns its new address
+func copyMemo(src, dst *File, address []byte) ([]byte, error) {
+	memo, text, err := src.readMemo(address, nil)
+	if err != nil {
+		return nil, NewError("failed to read memo").Details(err)
+	}
+	err = dst.ensureMemo()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	written, err := dst.WriteMemo(nil, memo, text, len(memo))
+	if err != nil {
+		return nil, NewError("failed to write memo").Details(err)
+	}
+	return written, nil
+}
+
+// rewriteMemoRefs returns the row as row of dst if it belongs to another table, so its memos are written to the memo file
+// of dst instead of keeping addresses into the memo file of the source table. The columns are matched by position and must
+// have the same types. Memo addresses of raw rows are rewritten by copying the memos, see copyMemos.
+func rewriteMemoRefs(row *Row, dst *File) (*Row, error) {
+	if row == nil {
+		return nil, NewError("row is nil")
+	}
+	src := row.handle
+	if src == nil || src == dst {
+		return row, nil
+	}
+	debugf("Rewriting memo references of row %d from %s to %s", row.Position, src.config.Filename, dst.config.Filename)
+	out := &Row{handle: dst, Position: row.Position, Deleted: row.Deleted}
+	if row.raw != nil {
+		err := compatibleLayout(src, dst)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		out.raw = append([]byte(nil), row.raw...)
+		err = copyMemos(src, dst, out.raw)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		return out, nil
+	}
+	if len(row.fields) != len(dst.table.columns) {
+		return nil, NewErrorf("%d fields of the row differ from %d columns of the table", len(row.fields), len(dst.table.columns))
+	}
+	out.fields = make([]*Field, len(row.fields))
+	memos := false
+	for i, field := range row.fields {
+		column := dst.table.columns[i]
+		if field.column.DataType != column.DataType {
+			return nil, NewErrorf("column %s of type %s differs from column %s of type %s", field.Name(), field.column.Type(), column.Name(), column.Type())
+		}
+		// The field has no memo address, so the memo is appended to the memo file of dst
+		out.fields[i] = &Field{column: column, value: field.value}
+		memos = memos || (DataType(column.DataType) == Memo && field.value != nil)
+	}
+	if memos {
+		err := dst.ensureMemo()
+		if err != nil {
+			return nil, WrapError(err)
+		}
+	}
+	// General, picture and blob values are the addresses of their memos in the memo file of the source table
+	for i, field := range out.fields {
+		address, ok := field.value.([]byte)
+		if !ok || DataType(field.column.DataType) == Memo || !memoPointer(DataType(field.column.DataType)) || len(address) < 4 || blankAddress(address) {
+			continue
+		}
+		copied, err := copyMemo(src, dst, address)
+		if err != nil {
+			return nil, NewErrorf("failed to copy memo of column %s", src.table.columns[i].Name()).Details(err)
+		}
+		field.value = copied
+	}
+	return out, nil
+}
+
+// blankAddress returns if the memo address does not point to a memo, because it only contains zeros or only spaces
+func blankAddress(address []byte) bool {
+	zeros, spaces := true, true
+	for _, b := range address {
+		zeros = zeros && b == 0
+		spaces = spaces && b == byte(Blank)
+	}
+	return zeros || spaces
+}
+
+// writeRaw appends the row data to the table
+func (file *File) writeRaw(data []byte) error {
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	return file.writeRow(&Row{handle: file, Position: file.header.RowsCount, Deleted: Marker(data[0]) == Deleted, raw: data})
+}
+
+// copyAutoincrement raises the next values of the autoincrement columns of dst to the values of src
+func copyAutoincrement(src, dst *File) (err error) {
+	if !dst.hasAutoincrement() {
+		return nil
+	}
+	dst.dbaseMutex.Lock()
+	defer dst.dbaseMutex.Unlock()
+	unlock, err := dst.lockAutoincrement()
+	if err != nil {
+		return WrapError(err)
+	}
+	defer release(unlock, &err)
+	changed := false
+	for i, column := range dst.table.columns {
+		if column.Flag == byte(AutoincrementFlag) && src.table.columns[i].Next > column.Next {
+			debugf("Raising next value of autoincrement column %s from %d to %d", column.Name(), column.Next, src.table.columns[i].Next)
+			column.Next = src.table.columns[i].Next
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return dst.writeAutoincrement()
+}
@@ -0,0 +1,165 @@
+package dbase_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+	"github.com/Valentin-Kaiser/go-dbase/dbase/dbasetest"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// memoSpec is the content of the source tables of the copy tests
+var memoSpec = dbasetest.Spec{
+	Seed:          7,
+	MemoBlockSize: 64,
+	DeletedRate:   0.1,
+	Columns: []dbasetest.ColumnSpec{
+		{Name: "ID", Type: dbase.Integer},
+		{Name: "MEMO", Type: dbase.Memo},
+		{Name: "NOTE", Type: dbase.Memo},
+	},
+}
+
+// generateMemoSource generates the source table and returns it with the memos of its rows
+func generateMemoSource(t *testing.T, dir string) (*dbase.File, [][]interface{}) {
+	t.Helper()
+	filename := filepath.Join(dir, "SRC.DBF")
+	generated, err := dbasetest.GenerateTable(&dbase.Config{Filename: filename}, 50, memoSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated.Close()
+	src, err := dbase.OpenTable(&dbase.Config{Filename: filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := src.Rows(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 50 {
+		t.Fatalf("%d rows read from the source table, expected 50", len(rows))
+	}
+	// Rows moves the row pointer to the end of the table
+	err = src.GoTo(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memos := make([][]interface{}, 0, len(rows))
+	for _, row := range rows {
+		memos = append(memos, []interface{}{row.Field(1).GetValue(), row.Field(2).GetValue()})
+	}
+	return src, memos
+}
+
+// newMemoDestination creates an empty table with the columns of the source table and a different memo block size
+func newMemoDestination(t *testing.T, dir string, src *dbase.File) *dbase.File {
+	t.Helper()
+	columns := make([]*dbase.Column, 0, len(src.Columns()))
+	for _, column := range src.Columns() {
+		c, err := dbase.NewColumn(column.Name(), dbase.DataType(column.DataType), 0, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		columns = append(columns, c)
+	}
+	dst, err := dbase.NewTable(dbase.FoxProVar, &dbase.Config{Filename: filepath.Join(dir, "DST.DBF"), Converter: dbase.NewDefaultConverter(charmap.Windows1252)}, columns, 32, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dst
+}
+
+// checkMemos reopens the destination table after the source table was closed, checks that every memo address
+// points into its memo file and reads the memos of the rows
+func checkMemos(t *testing.T, dir string, blockSize int64, memos [][]interface{}) {
+	t.Helper()
+	filename := filepath.Join(dir, "DST.DBF")
+	info, err := os.Stat(strings.TrimSuffix(filename, filepath.Ext(filename)) + string(dbase.FPT))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := uint32(info.Size() / blockSize)
+	dst, err := dbase.OpenTable(&dbase.Config{Filename: filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if dst.RowsCount() != uint32(len(memos)) {
+		t.Fatalf("%d rows copied, expected %d", dst.RowsCount(), len(memos))
+	}
+	for position, expected := range memos {
+		data, err := dst.ReadRow(uint32(position))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, column := range dst.Columns()[1:] {
+			block := binary.LittleEndian.Uint32(data[column.Position:])
+			if block >= blocks {
+				t.Errorf("memo %s of row %d points to block %d behind the %d blocks of the memo file", column.Name(), position, block, blocks)
+			}
+			err = dst.GoTo(uint32(position))
+			if err != nil {
+				t.Fatal(err)
+			}
+			row, err := dst.Row()
+			if err != nil {
+				t.Fatalf("failed to read row %d: %v", position, err)
+			}
+			if value := row.Field(i + 1).GetValue(); value != expected[i] {
+				t.Errorf("memo %s of row %d is %q, expected %q", column.Name(), position, value, expected[i])
+			}
+		}
+	}
+}
+
+func TestWriteRowOfOtherTable(t *testing.T) {
+	dir := t.TempDir()
+	src, memos := generateMemoSource(t, dir)
+	dst := newMemoDestination(t, dir, src)
+	rows, err := src.Rows(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		err = dst.WriteRow(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	src.Close()
+	dst.Close()
+	checkMemos(t, dir, 32, memos)
+}
+
+func TestCopyRawMemos(t *testing.T) {
+	dir := t.TempDir()
+	src, memos := generateMemoSource(t, dir)
+	dst := newMemoDestination(t, dir, src)
+	copied, err := dbase.CopyRaw(src, dst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != len(memos) {
+		t.Errorf("%d rows copied, expected %d", copied, len(memos))
+	}
+	src.Close()
+	dst.Close()
+	checkMemos(t, dir, 32, memos)
+}
+
+func TestConvertVersionMemos(t *testing.T) {
+	dir := t.TempDir()
+	src, memos := generateMemoSource(t, dir)
+	dst, _, err := dbase.ConvertVersion(src, dbase.FoxPro, &dbase.Config{Filename: filepath.Join(dir, "DST.DBF")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Close()
+	dst.Close()
+	checkMemos(t, dir, int64(memoSpec.MemoBlockSize), memos)
+}
@@ -0,0 +1,174 @@
+package dbase
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+)
+
+// CSVImportOptions control how CSV records are imported into a table
+type CSVImportOptions struct {
+	ImportOptions          // Batch size and error handling, IgnoreUnknownKeys skips header names without matching column
+	Comma         rune     // Field delimiter, defaults to ','
+	NoHeader      bool     // The first record contains values, the CSV columns are mapped to the table columns by position
+	Columns       []string // Columns the CSV columns are mapped to by position, overrides the header. Empty names skip the CSV column.
+}
+
+// ImportCSV appends the records read from r as new rows to the table, the reverse of exporting the rows with CSVSink.
+// The CSV columns are mapped to the columns by the names of the header line, matched like the keys of ImportNDJSON,
+// by the names of the Columns option or by position if there is no header. Values are converted to the data type
+// of the column, dates, times and logical values written using the Format of the config are parsed with the same format.
+// Empty values are imported as null, except in text columns that are not nullable. Invalid records are skipped and reported, unless StopOnError is set.
+// An error is only returned if the header is invalid, reading the input or writing the table fails.
+func (file *File) ImportCSV(r io.Reader, options CSVImportOptions) (*ImportReport, error) {
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultImportBatchSize
+	}
+	reader := csv.NewReader(r)
+	if options.Comma != 0 {
+		reader.Comma = options.Comma
+	}
+	reader.ReuseRecord = true
+	// Records with a different number of fields are reported by csvRow
+	reader.FieldsPerRecord = -1
+	report := &ImportReport{Errors: make([]*ImportError, 0)}
+	mapping, err := file.csvMapping(reader, options)
+	if err != nil {
+		return report, WrapError(err)
+	}
+	debugf("Importing CSV into %s - columns: %v", file.config.Filename, mapping)
+	batch := make([]*Row, 0, options.BatchSize)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, NewError("failed to read CSV data").Details(err)
+		}
+		line, _ := reader.FieldPos(0)
+		row, ierr := file.csvRow(line, record, mapping)
+		if ierr != nil {
+			report.Errors = append(report.Errors, ierr)
+			if options.StopOnError {
+				break
+			}
+			continue
+		}
+		batch = append(batch, row)
+		if len(batch) < options.BatchSize {
+			continue
+		}
+		err = file.importBatch(batch, report)
+		if err != nil {
+			return report, WrapError(err)
+		}
+		batch = batch[:0]
+	}
+	err = file.importBatch(batch, report)
+	if err != nil {
+		return report, WrapError(err)
+	}
+	return report, nil
+}
+
+// csvMapping returns the position of the column each CSV column is imported into, -1 if the CSV column is skipped.
+// The header line is consumed unless NoHeader is set.
+func (file *File) csvMapping(reader *csv.Reader, options CSVImportOptions) ([]int, error) {
+	names := options.Columns
+	if !options.NoHeader {
+		header, err := reader.Read()
+		if err == io.EOF {
+			return nil, NewError("missing CSV header")
+		}
+		if err != nil {
+			return nil, NewError("failed to read CSV header").Details(err)
+		}
+		if len(names) == 0 {
+			names = append([]string(nil), header...)
+			if len(names) > 0 {
+				names[0] = strings.TrimPrefix(names[0], "\ufeff")
+			}
+		}
+	}
+	if len(names) == 0 {
+		mapping := make([]int, len(file.table.columns))
+		for i := range mapping {
+			mapping[i] = i
+		}
+		return mapping, nil
+	}
+	mapping := make([]int, len(names))
+	for i, name := range names {
+		mapping[i] = -1
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		mapping[i] = file.importColumnPos(name)
+		if mapping[i] < 0 && !options.IgnoreUnknownKeys {
+			return nil, NewErrorf("no matching column found for CSV column %s", name)
+		}
+	}
+	return mapping, nil
+}
+
+// csvRow converts the CSV record into a new row
+func (file *File) csvRow(line int, record []string, mapping []int) (*Row, *ImportError) {
+	if len(record) != len(mapping) {
+		return nil, &ImportError{Line: line, Err: NewErrorf("%d fields, expected %d", len(record), len(mapping))}
+	}
+	row := file.NewRow()
+	for i, text := range record {
+		pos := mapping[i]
+		if pos < 0 {
+			continue
+		}
+		column := file.table.columns[pos]
+		value, err := file.csvValue(column, text)
+		if err != nil {
+			return nil, &ImportError{Line: line, Column: column.Name(), Err: err}
+		}
+		row.fields[pos].value = value
+	}
+	return row, nil
+}
+
+// csvValue converts the text of a CSV field to the value type of the column, the reverse of FormatValue
+func (file *File) csvValue(column *Column, text string) (interface{}, error) {
+	format := file.config.Format
+	switch DataType(column.DataType) {
+	case Character, Varchar, Memo:
+		// Empty text is only null in nullable columns
+		if len(text) == 0 && ColumnFlag(column.Flag)&NullableFlag != 0 {
+			return nil, nil
+		}
+		return file.coerceValue(column, text)
+	}
+	if len(text) == 0 {
+		return nil, nil
+	}
+	switch DataType(column.DataType) {
+	case Date, DateTime:
+		layout := format.DateLayout
+		if DataType(column.DataType) == DateTime {
+			layout = format.DateTimeLayout
+		}
+		if len(layout) > 0 {
+			t, err := time.Parse(layout, strings.TrimSpace(text))
+			if err != nil {
+				return nil, NewErrorf("invalid date %q, expected the layout %s", text, layout).Details(err)
+			}
+			return t, nil
+		}
+	case Logical:
+		switch {
+		case len(format.True) > 0 && text == format.True:
+			return true, nil
+		case len(format.False) > 0 && text == format.False:
+			return false, nil
+		}
+	}
+	return file.coerceValue(column, text)
+}
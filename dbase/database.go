@@ -5,16 +5,36 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 type Database struct {
-	file   *File
-	tables map[string]*File
+	file    *File
+	config  *Config
+	options DatabaseOptions
+	paths   map[string]string // Filenames of all tables of the database by table name
+	ids     map[string]int32  // Object ids of all tables in the database container by table name
+	tables  map[string]*File  // Open tables by table name
+	used    []string          // Names of the open tables, least recently used first
+	refs    map[string]int    // Number of times a table was returned by Table and not released yet, by table name
+	mutex   sync.Mutex
+}
+
+// DatabaseOptions limit the resources used by the tables of a database
+type DatabaseOptions struct {
+	Lazy          bool // If true, tables are opened on first access by Table instead of by OpenDatabaseWithOptions
+	MaxOpenTables int  // Maximum number of tables open at once, implies Lazy. The least recently used released table is closed to open another one. Zero is unlimited.
 }
 
 // OpenDatabase opens a dbase/foxpro database file and all related tables
 // The database file must be a DBC file and the tables must be DBF files and in the same directory as the database
 func OpenDatabase(config *Config) (*Database, error) {
+	return OpenDatabaseWithOptions(config, DatabaseOptions{})
+}
+
+// OpenDatabaseWithOptions opens a dbase/foxpro database file and the related tables, unless they are opened lazily.
+// The tables are opened with the settings of the config, e.g. ReadOnly, see OpenDatabase.
+func OpenDatabaseWithOptions(config *Config, options DatabaseOptions) (*Database, error) {
 	if config == nil {
 		return nil, NewError("missing dbase configuration")
 	}
@@ -24,7 +44,13 @@ func OpenDatabase(config *Config) (*Database, error) {
 	if strings.ToUpper(filepath.Ext(config.Filename)) != string(DBC) {
 		return nil, NewError("invalid dbase filename").Details(fmt.Errorf("file extension must be %v", DBC))
 	}
-	debugf("Opening database: %v", config.Filename)
+	if options.MaxOpenTables < 0 {
+		return nil, NewErrorf("invalid maximum of %d open tables", options.MaxOpenTables)
+	}
+	if options.MaxOpenTables > 0 {
+		options.Lazy = true
+	}
+	debugf("Opening database: %v - lazy: %v - max open tables: %d", config.Filename, options.Lazy, options.MaxOpenTables)
 	databaseTable, err := OpenTable(config)
 	if err != nil {
 		return nil, WrapError(err)
@@ -38,8 +64,15 @@ func OpenDatabase(config *Config) (*Database, error) {
 	if err != nil {
 		return nil, WrapError(err)
 	}
-	// Try to load the table files
-	tables := make(map[string]*File, 0)
+	db := &Database{
+		file:    databaseTable,
+		config:  config,
+		options: options,
+		paths:   make(map[string]string),
+		ids:     make(map[string]int32),
+		tables:  make(map[string]*File),
+		refs:    make(map[string]int),
+	}
 	for _, row := range rows {
 		objectName, err := row.ValueByName("OBJECTNAME")
 		if err != nil {
@@ -54,64 +87,191 @@ func OpenDatabase(config *Config) (*Database, error) {
 			continue
 		}
 		debugf("Found table: %v in database", tableName)
+		objectID, err := row.ValueByName("OBJECTID")
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		id, ok := objectID.(int32)
+		if !ok {
+			return nil, NewErrorf("object id of table %s is not an integer", tableName)
+		}
+		db.ids[tableName] = id
 		tablePath := path.Join(filepath.Dir(config.Filename), tableName+string(DBF))
 		// Replace underscores with spaces
 		if !config.DisableConvertFilenameUnderscores {
 			tablePath = path.Join(filepath.Dir(config.Filename), strings.ReplaceAll(tableName, "_", " ")+string(DBF))
 		}
-		tableConfig := &Config{
-			Filename:                          tablePath,
-			Converter:                         config.Converter,
-			Exclusive:                         config.Exclusive,
-			Untested:                          config.Untested,
-			TrimSpaces:                        config.TrimSpaces,
-			DisableConvertFilenameUnderscores: config.DisableConvertFilenameUnderscores,
-			ReadOnly:                          config.ReadOnly,
-			WriteLock:                         config.WriteLock,
-			ValidateCodePage:                  config.ValidateCodePage,
-			InterpretCodePage:                 config.InterpretCodePage,
+		db.paths[tableName] = tablePath
+		if options.Lazy {
+			continue
 		}
 		// Load the table
-		table, err := OpenTable(tableConfig)
+		_, err = db.open(tableName)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+	}
+	return db, nil
+}
+
+// tableConfig returns the config of a table of the database, a copy of the config of the database.
+// The IO is not shared, it is set to the default by each table.
+func (db *Database) tableConfig(filename string) *Config {
+	config := *db.config
+	config.Filename = filename
+	config.IO = nil
+	return &config
+}
+
+// Table returns the table with the name, opening it if it is not open yet.
+// The table is in use until it is passed to Release. If MaxOpenTables is reached,
+// the least recently used table that is not in use is closed first.
+// An ErrTooManyTables error is returned if every open table is in use.
+func (db *Database) Table(name string) (*File, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if _, ok := db.paths[name]; !ok {
+		for tableName := range db.paths {
+			if strings.EqualFold(tableName, name) {
+				name = tableName
+				break
+			}
+		}
+	}
+	table, err := db.open(name)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	db.refs[name]++
+	return table, nil
+}
+
+// Release marks the table returned by Table as no longer used, so it can be closed to open another table.
+// Each table returned by Table must be released once and must not be used afterwards.
+func (db *Database) Release(table *File) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for name, open := range db.tables {
+		if open != table {
+			continue
+		}
+		if db.refs[name] == 0 {
+			return NewErrorf("table %s is not in use", name)
+		}
+		db.refs[name]--
+		return nil
+	}
+	return NewError("table is not open in the database")
+}
+
+// open returns the open table or opens it, evicting the least recently used table not in use if the limit is reached
+func (db *Database) open(name string) (*File, error) {
+	tablePath, ok := db.paths[name]
+	if !ok {
+		return nil, NewErrorf("table %s not found in database", name)
+	}
+	if table, ok := db.tables[name]; ok {
+		db.touch(name)
+		return table, nil
+	}
+	if db.options.MaxOpenTables > 0 && len(db.tables) >= db.options.MaxOpenTables {
+		err := db.evict()
 		if err != nil {
 			return nil, WrapError(err)
 		}
-		if table != nil {
-			tables[tableName] = table
+	}
+	table, err := OpenTable(db.tableConfig(tablePath))
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	table.database = db
+	err = db.readComments(name, table)
+	if err != nil {
+		table.warn("failed to read the column comments from the database: %v", err)
+	}
+	db.tables[name] = table
+	db.used = append(db.used, name)
+	return table, nil
+}
+
+// evict closes the least recently used table that is not in use
+func (db *Database) evict() error {
+	for i, name := range db.used {
+		if db.refs[name] > 0 {
+			continue
+		}
+		debugf("Closing least recently used table %s of database", name)
+		err := db.tables[name].Close()
+		delete(db.tables, name)
+		delete(db.refs, name)
+		db.used = append(db.used[:i:i], db.used[i+1:]...)
+		if err != nil {
+			return NewErrorf("failed to close table %s", name).Details(err)
+		}
+		return nil
+	}
+	return NewErrorf("all %d open tables are in use", len(db.tables)).Details(ErrTooManyTables)
+}
+
+// touch marks the table as most recently used
+func (db *Database) touch(name string) {
+	for i, used := range db.used {
+		if used == name {
+			db.used = append(append(db.used[:i:i], db.used[i+1:]...), name)
+			return
 		}
 	}
-	return &Database{file: databaseTable, tables: tables}, nil
 }
 
-// Close the database file and all related tables
+// Close the database file and all open tables
 func (db *Database) Close() error {
-	for _, table := range db.tables {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for name, table := range db.tables {
 		if err := table.Close(); err != nil {
 			return WrapError(err)
 		}
+		delete(db.tables, name)
 	}
+	db.used = nil
+	db.refs = make(map[string]int)
 	return db.file.Close()
 }
 
-// Returns all table of the database
+// Returns the open tables of the database, all tables unless the tables are opened lazily
 func (db *Database) Tables() map[string]*File {
-	return db.tables
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	tables := make(map[string]*File, len(db.tables))
+	for name, table := range db.tables {
+		tables[name] = table
+	}
+	return tables
 }
 
 // Returns the names of every table in the database
 func (db *Database) Names() []string {
 	names := make([]string, 0)
-	for name := range db.tables {
+	for name := range db.paths {
 		names = append(names, name)
 	}
 	return names
 }
 
-// Returns the complete database schema
+// Returns the complete database schema.
+// Tables that are not open are opened without being in use, tables that can not be opened are missing in the schema.
 func (db *Database) Schema() map[string][]*Column {
 	schema := make(map[string][]*Column)
-	for name, table := range db.tables {
-		schema[name] = table.Columns()
+	for name := range db.paths {
+		db.mutex.Lock()
+		table, err := db.open(name)
+		if err == nil {
+			schema[name] = table.Columns()
+		}
+		db.mutex.Unlock()
+		if err != nil {
+			debugf("Failed to open table %s for the schema: %v", name, err)
+		}
 	}
 	return schema
 }
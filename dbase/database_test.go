@@ -0,0 +1,106 @@
+package dbase
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// nopMetrics is a metrics collector ignoring the operations
+type nopMetrics struct{}
+
+func (m *nopMetrics) Observe(Operation, int, time.Duration, error) {}
+
+func TestDatabaseTableConfig(t *testing.T) {
+	config := &Config{
+		Filename:     "DATABASE.DBC",
+		TrimSpaces:   true,
+		WriteLock:    true,
+		LockTimeout:  time.Second,
+		ReuseDeleted: true,
+		MaxMemoSize:  1024,
+		Metrics:      &nopMetrics{},
+		IO:           GenericIO{},
+	}
+	db := &Database{config: config}
+	table := db.tableConfig("TABLE.DBF")
+	if table.Filename != "TABLE.DBF" || table.IO != nil {
+		t.Errorf("expected filename TABLE.DBF and no IO, got %s and %v", table.Filename, table.IO)
+	}
+	// Every other field of the database config applies to the tables
+	expected, actual := reflect.ValueOf(*config), reflect.ValueOf(*table)
+	for i := 0; i < expected.NumField(); i++ {
+		name := expected.Type().Field(i).Name
+		if name == "Filename" || name == "IO" {
+			continue
+		}
+		if !reflect.DeepEqual(expected.Field(i).Interface(), actual.Field(i).Interface()) && !expected.Field(i).IsZero() {
+			t.Errorf("field %s of the database config is not applied to the table", name)
+		}
+	}
+}
+
+func TestDatabaseMaxOpenTables(t *testing.T) {
+	db, err := OpenDatabaseWithOptions(&Config{Filename: "../examples/test_data/database/EXPENSES.DBC", ReadOnly: true}, DatabaseOptions{MaxOpenTables: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	employees, err := db.Table("employees")
+	if err != nil {
+		t.Fatal(err)
+	}
+	categories, err := db.Table("expense_categories")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A table in use must stay readable, whatever other tables are requested
+	readable := func(step string) {
+		t.Helper()
+		err := employees.GoTo(0)
+		if err == nil {
+			_, err = employees.Row()
+		}
+		if err != nil {
+			t.Errorf("%s: the table in use is not readable: %v", step, err)
+		}
+	}
+	_, err = db.Table("expense_details")
+	if !errors.Is(err, ErrTooManyTables) {
+		t.Errorf("expected ErrTooManyTables while every table is in use, got %v", err)
+	}
+	if schema := db.Schema(); len(schema) != 2 {
+		t.Errorf("expected the schema of the 2 tables in use, got %d tables", len(schema))
+	}
+	readable("schema")
+	err = db.Release(categories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	details, err := db.Table("expense_details")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readable("open")
+	if _, open := db.Tables()["expense_categories"]; open {
+		t.Error("expected the released table to be closed")
+	}
+	err = db.Release(categories)
+	if err == nil {
+		t.Error("expected an error releasing a closed table")
+	}
+	for _, table := range []*File{employees, details} {
+		err = db.Release(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.Release(employees)
+	if err == nil {
+		t.Error("expected an error releasing a table twice")
+	}
+	if schema := db.Schema(); len(schema) != 4 {
+		t.Errorf("expected the schema of all 4 tables, got %d tables", len(schema))
+	}
+}
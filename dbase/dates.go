@@ -0,0 +1,61 @@
+package dbase
+
+import "time"
+
+// EmptyDate returns the value of an empty date or datetime field.
+// Empty dates are stored as blanks in D columns and as zero bytes in T columns and are interpreted as the zero time.
+func EmptyDate() time.Time {
+	return time.Time{}
+}
+
+// IsEmptyDate returns true if the time is the value of an empty date or datetime field
+func IsEmptyDate(t time.Time) bool {
+	return t.IsZero()
+}
+
+// AddDays adds the number of days to the date, an empty date stays empty
+func AddDays(t time.Time, days int) time.Time {
+	if IsEmptyDate(t) {
+		return t
+	}
+	return t.AddDate(0, 0, days)
+}
+
+// AddBusinessDays adds the number of business days (monday to friday) to the date, negative values go back in time.
+// A date on a weekend is first moved to the next business day when adding or the previous one when subtracting.
+// An empty date stays empty.
+func AddBusinessDays(t time.Time, days int) time.Time {
+	if IsEmptyDate(t) {
+		return t
+	}
+	step := 1
+	if days < 0 {
+		step = -1
+		days = -days
+	}
+	for isWeekend(t) {
+		t = t.AddDate(0, 0, step)
+	}
+	for days > 0 {
+		t = t.AddDate(0, 0, step)
+		if !isWeekend(t) {
+			days--
+		}
+	}
+	return t
+}
+
+// DaysBetween returns the number of calendar days from a to b, ignoring the time of day.
+// The second return value is false if one of the dates is empty.
+func DaysBetween(a, b time.Time) (int, bool) {
+	if IsEmptyDate(a) || IsEmptyDate(b) {
+		return 0, false
+	}
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return julianDate(by, int(bm), bd) - julianDate(ay, int(am), ad), true
+}
+
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
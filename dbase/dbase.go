@@ -12,12 +12,15 @@
 // that interface with dBase databases.
 package dbase
 
+import "time"
+
 // Config is a struct containing the configuration for opening a Foxpro/dbase databse or table.
 // The filename is mandatory.
 //
 // The other fields are optional and are false by default.
 // If Converter and InterpretCodePage are both not set the package will try to interpret the code page mark.
 // To open untested files set Untested to true. Tested files are defined in the constants.go file.
+// OpenTable validates the config and applies the defaults, see Validate.
 type Config struct {
 	Filename                          string            // The filename of the DBF file.
 	Converter                         EncodingConverter // The encoding converter to use.
@@ -27,16 +30,159 @@ type Config struct {
 	CollapseSpaces                    bool              // If true, any length of spaces is replaced by a single space.
 	DisableConvertFilenameUnderscores bool              // If false underscores in the table filename are converted to spaces.
 	ReadOnly                          bool              // If true the file is opened in read-only mode.
+	Preload                           bool              // If true, the DBF and memo file are read into memory when opened and closed again, all reads are served from memory. Requires ReadOnly.
 	WriteLock                         bool              // Whether or not the write operations should lock the record
+	LockTimeout                       time.Duration     // How long to retry acquiring a lock held by another process. Zero fails immediately.
+	MaxOpenRetries                    int               // How often opening a file used exclusively by another process is retried with an increasing delay.
+	ReuseDeleted                      bool              // If true, appended rows overwrite the first row marked as deleted instead of growing the file. Rows other processes delete before the last reused row are found after opening the table again.
+	SyncMode                          SyncMode          // When written data is flushed to the storage device, by default only by calling Sync.
+	MaxMemoSize                       int               // Maximum size of a memo in bytes, zero only limits to the maximum a memo block can store.
+	MaxRowsInMemory                   int               // Maximum number of rows Rows and Columnar hold in memory, zero is unlimited.
+	MaxBytesInMemory                  int64             // Maximum estimated size of the values Rows and Columnar hold in memory, zero is unlimited.
+	Format                            Format            // Formatting of dates, floating point numbers and booleans by ToJSON and FormatValue.
+	DecimalSeparator                  byte              // Decimal separator written to numeric and float columns, '.' if zero. Both '.' and ',' are read.
+	NumericOverflow                   OverflowPolicy    // How numeric and float values FoxPro marked as overflowed ('***') are read, an error by default.
+	RowLength                         RowLengthPolicy   // How a row length in the header that disagrees with the columns is handled, the header is trusted by default.
+	ExtendedCharacterColumns          bool              // If true, character columns longer than 254 bytes using the Clipper convention can be created and written, see NewExtendedColumn. They are always read.
+	MaintainIndexes                   bool              // If true, the tags of the structural CDX index are updated when rows are written. Fails to open if a tag is not supported.
+	DetectIndexes                     bool              // If true, the index flag of the header is corrected if a structural CDX or MDX index exists but is not flagged or is flagged but missing.
+	VirtualColumns                    bool              // If true, ToMap and ToJSON include the virtual columns _deleted, _position and _offset.
 	ValidateCodePage                  bool              // Whether or not the code page mark should be validated.
+	StrictEncoding                    bool              // If true, text containing bytes the code page does not define fails with ErrInvalidEncoding instead of being replaced.
 	InterpretCodePage                 bool              // Whether or not the code page mark should be interpreted. Ignores the defined converter.
 	IO                                IO                // The IO interface to use.
+	Metrics                           MetricsCollector  // Optional collector called after each file operation.
+}
+
+// Validate returns an ErrInvalidConfig error if fields of the config contradict each other or are out of range
+// and sets the defaults of unset fields. It is called by OpenTable.
+//
+// Exclusive opens the file for writing and can not be combined with ReadOnly, WriteLock, ReuseDeleted, ExtendedCharacterColumns and MaintainIndexes
+// only affect writes and are rejected on read-only files, Preload requires a read-only file. ValidateCodePage compares the code page mark with
+// the Converter, so it requires a Converter and can not be combined with InterpretCodePage, which replaces the Converter.
+//
+// Defaults: IO is set to DefaultIO if nil. If Converter is nil or InterpretCodePage is set,
+// the Converter is interpreted from the code page mark when the table is opened.
+// WriteLock places record locks on Windows and Unix systems, on other systems it has no effect.
+func (config *Config) Validate() error {
+	if config == nil {
+		return NewError("missing dbase configuration").Details(ErrInvalidConfig)
+	}
+	switch {
+	case config.Exclusive && config.ReadOnly:
+		return NewError("Exclusive opens the file for writing and can not be combined with ReadOnly").Details(ErrInvalidConfig)
+	case config.ReadOnly && config.WriteLock:
+		return NewError("WriteLock locks written rows and can not be combined with ReadOnly").Details(ErrInvalidConfig)
+	case config.ReadOnly && config.MaintainIndexes:
+		return NewError("MaintainIndexes writes the index and can not be combined with ReadOnly").Details(ErrInvalidConfig)
+	case config.ReadOnly && config.ReuseDeleted:
+		return NewError("ReuseDeleted affects appended rows and can not be combined with ReadOnly").Details(ErrInvalidConfig)
+	case config.ReadOnly && config.ExtendedCharacterColumns:
+		return NewError("ExtendedCharacterColumns affects written rows and can not be combined with ReadOnly").Details(ErrInvalidConfig)
+	case config.Preload && !config.ReadOnly:
+		return NewError("Preload serves the reads from a copy in memory and requires ReadOnly").Details(ErrInvalidConfig)
+	case config.ValidateCodePage && config.InterpretCodePage:
+		return NewError("ValidateCodePage can not be combined with InterpretCodePage, the interpreted converter always matches the code page mark").Details(ErrInvalidConfig)
+	case config.ValidateCodePage && config.Converter == nil:
+		return NewError("ValidateCodePage requires a Converter to compare the code page mark with").Details(ErrInvalidConfig)
+	case config.LockTimeout < 0:
+		return NewErrorf("negative LockTimeout %v", config.LockTimeout).Details(ErrInvalidConfig)
+	case config.MaxOpenRetries < 0:
+		return NewErrorf("negative MaxOpenRetries %d", config.MaxOpenRetries).Details(ErrInvalidConfig)
+	case config.MaxMemoSize < 0:
+		return NewErrorf("negative MaxMemoSize %d", config.MaxMemoSize).Details(ErrInvalidConfig)
+	case config.MaxRowsInMemory < 0:
+		return NewErrorf("negative MaxRowsInMemory %d", config.MaxRowsInMemory).Details(ErrInvalidConfig)
+	case config.MaxBytesInMemory < 0:
+		return NewErrorf("negative MaxBytesInMemory %d", config.MaxBytesInMemory).Details(ErrInvalidConfig)
+	case config.SyncMode < SyncNever || config.SyncMode > SyncEveryWrite:
+		return NewErrorf("unknown SyncMode %d", config.SyncMode).Details(ErrInvalidConfig)
+	case config.NumericOverflow < OverflowError || config.NumericOverflow > OverflowNaN:
+		return NewErrorf("unknown NumericOverflow policy %d", config.NumericOverflow).Details(ErrInvalidConfig)
+	case config.RowLength < RowLengthHeader || config.RowLength > RowLengthError:
+		return NewErrorf("unknown RowLength policy %d", config.RowLength).Details(ErrInvalidConfig)
+	case config.DecimalSeparator != 0 && config.DecimalSeparator != '.' && config.DecimalSeparator != ',':
+		return NewErrorf("invalid DecimalSeparator '%c', only '.' and ',' are supported", config.DecimalSeparator).Details(ErrInvalidConfig)
+	case config.Format.Binary != "" && config.Format.Binary != BinaryBase64 && config.Format.Binary != BinaryHex:
+		return NewErrorf("unknown binary encoding '%s'", config.Format.Binary).Details(ErrInvalidConfig)
+	}
+	if config.IO == nil {
+		config.IO = DefaultIO
+	}
+	return nil
 }
 
+// SyncMode controls when written data is flushed from the operating system to the storage device
+type SyncMode int
+
+const (
+	SyncNever      SyncMode = iota // Only flush when File.Sync is called, the operating system decides when data is written
+	SyncOnCommit                   // Flush after each completed write operation, e.g. Row.Write, Upsert, an import batch or CopyRaw
+	SyncEveryWrite                 // Flush after every row written, also within operations writing multiple rows
+)
+
+// OverflowPolicy controls how numeric and float values filled with asterisks are read.
+// FoxPro writes asterisks into the column if a value does not fit into its length.
+type OverflowPolicy int
+
+const (
+	OverflowError OverflowPolicy = iota // Return an error with ErrNumericOverflow
+	OverflowNull                        // Return nil
+	OverflowNaN                         // Return NaN as float64, which can not be encoded as JSON
+)
+
+// RowLengthPolicy controls how a table is opened whose row length in the header differs from the length of the deleted flag,
+// the columns and the null flag column. A mismatch is added to the warnings of the file.
+type RowLengthPolicy int
+
+const (
+	RowLengthHeader  RowLengthPolicy = iota // Read the rows with the row length of the header, fails with ErrInvalidHeader if the columns do not fit into it
+	RowLengthColumns                        // Read the rows with the length of the columns, the header is corrected when it is written the next time
+	RowLengthError                          // Fail with ErrInvalidHeader
+)
+
 // Modification allows to change the column name or value type of a column when reading the table
 // The TrimSpaces option is only used for a specific column, if the general TrimSpaces option in the config is false.
 type Modification struct {
 	TrimSpaces  bool                                   // Trim spaces from string values
 	Convert     func(interface{}) (interface{}, error) // Conversion function to convert the value
-	ExternalKey string                                 // External key to use for the column
+	ExternalKey string                                 // External key to use for the column, also used to look up the column
+	Aliases     []string                               // Additional names to look up the column by, e.g. names used by a previous version of the table
+	Padding     byte                                   // Character padding written character, numeric and float values, a space if zero
+	Align       Alignment                              // Alignment of written character, numeric and float values
+}
+
+// Alignment controls on which side written values are padded to the length of the column
+type Alignment int
+
+const (
+	AlignDefault Alignment = iota // Character values are left aligned, numeric and float values right aligned
+	AlignLeft                     // Values are padded at the end
+	AlignRight                    // Values are padded at the start
+)
+
+// Format controls how ToJSON and FormatValue render values, the zero value keeps the default representation.
+type Format struct {
+	DateLayout     string         // Layout of date values, RFC3339 if empty
+	DateTimeLayout string         // Layout of datetime values, RFC3339 with fractional seconds if empty
+	ColumnDecimals bool           // Render floating point numbers with the decimals of their column instead of full precision
+	True           string         // Representation of true logical values, a boolean if empty
+	False          string         // Representation of false logical values, a boolean if empty
+	Binary         BinaryEncoding // Encoding of binary values, base64 if empty
+	BinaryMarker   bool           // Prefix encoded binary values with the encoding, e.g. "hex:0a0b", so they can be told apart from text
+}
+
+// BinaryEncoding is the text encoding of binary values in JSON and text representations
+type BinaryEncoding string
+
+const (
+	BinaryBase64 BinaryEncoding = "base64" // Standard base64 encoding, as used by encoding/json
+	BinaryHex    BinaryEncoding = "hex"    // Lower case hexadecimal encoding
+)
+
+// StructOptions control how struct fields are mapped to the columns of a row.
+// ToStruct and RowFromStruct ignore struct fields without a matching column and map zero values.
+type StructOptions struct {
+	IgnoreMissingColumns bool // Struct fields without a matching column are skipped instead of returning an error
+	OmitEmpty            bool // Zero values are skipped, so the target keeps its current value
 }
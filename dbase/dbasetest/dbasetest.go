@@ -0,0 +1,257 @@
+// The dbasetest package generates tables with deterministic pseudo-random content for testing.
+// The same spec and seed always produce the same rows, so generated tables can be used
+// as fixtures of tests and to reproduce problems with specific data.
+package dbasetest
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// defaultMemoBlockSize is the block size of the generated memo files if not configured
+const defaultMemoBlockSize = 64
+
+// characters are the candidates for text values, characters the converter can not encode are not used
+const characters = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789 -.,äöüÄÖÜßéèàçñøåæ€"
+
+// asciiCharacters are used for varchar and memo values, which are stored without conversion
+const asciiCharacters = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789 -.,"
+
+// ColumnSpec defines a column of a generated table
+type ColumnSpec struct {
+	Name     string         // Name of the column, up to 10 characters
+	Type     dbase.DataType // Data type of the column
+	Length   uint8          // Length of character, varchar, varbinary, numeric and float columns
+	Decimals uint8          // Decimals of numeric and float columns
+	Nullable bool           // Whether the column is nullable
+}
+
+// Spec defines the content of a generated table
+type Spec struct {
+	Version       dbase.FileVersion // File version of the table, defaults to FoxProVar
+	Columns       []ColumnSpec      // Columns of the table, defaults to AllColumns
+	Seed          int64             // Seed of the pseudo-random content
+	DeletedRate   float64           // Fraction of rows marked as deleted
+	NullRate      float64           // Fraction of values of nullable varchar and varbinary columns that are null
+	MemoBlockSize uint16            // Block size of the memo file, defaults to 64
+}
+
+// AllColumns returns a column of every data type that can be created, including nullable variable length columns
+func AllColumns() []ColumnSpec {
+	return []ColumnSpec{
+		{Name: "CHAR", Type: dbase.Character, Length: 40},
+		{Name: "VARCHAR", Type: dbase.Varchar, Length: 30},
+		{Name: "VARCHARN", Type: dbase.Varchar, Length: 20, Nullable: true},
+		{Name: "VARBIN", Type: dbase.Varbinary, Length: 16},
+		{Name: "VARBINN", Type: dbase.Varbinary, Length: 16, Nullable: true},
+		{Name: "MEMO", Type: dbase.Memo},
+		{Name: "INTEGER", Type: dbase.Integer},
+		{Name: "NUMERIC", Type: dbase.Numeric, Length: 10},
+		{Name: "DECIMAL", Type: dbase.Numeric, Length: 12, Decimals: 3},
+		{Name: "FLOAT", Type: dbase.Float, Length: 14, Decimals: 4},
+		{Name: "DOUBLE", Type: dbase.Double},
+		{Name: "CURRENCY", Type: dbase.Currency},
+		{Name: "DATE", Type: dbase.Date},
+		{Name: "DATETIME", Type: dbase.DateTime},
+		{Name: "LOGICAL", Type: dbase.Logical},
+	}
+}
+
+// GenerateTable creates the table of the config with the number of rows and pseudo-random values.
+// Values cover the edge cases of each data type, like empty and full length variable length values,
+// empty dates, negative numbers and memos spanning multiple blocks. Character values contain characters
+// outside of ASCII if the converter of the config can encode them, the converter defaults to Windows-1252.
+// The returned table must be closed by the caller.
+func GenerateTable(config *dbase.Config, rows int, spec Spec) (*dbase.File, error) {
+	if config.Converter == nil {
+		config.Converter = dbase.NewDefaultConverter(charmap.Windows1252)
+	}
+	if spec.Version == 0 {
+		spec.Version = dbase.FoxProVar
+	}
+	if len(spec.Columns) == 0 {
+		spec.Columns = AllColumns()
+	}
+	if spec.MemoBlockSize == 0 {
+		spec.MemoBlockSize = defaultMemoBlockSize
+	}
+	columns := make([]*dbase.Column, 0, len(spec.Columns))
+	for _, c := range spec.Columns {
+		column, err := dbase.NewColumn(c.Name, c.Type, c.Length, c.Decimals, c.Nullable)
+		if err != nil {
+			return nil, dbase.NewErrorf("invalid column %s", c.Name).Details(err)
+		}
+		columns = append(columns, column)
+	}
+	file, err := dbase.NewTable(spec.Version, config, columns, spec.MemoBlockSize, nil)
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	g := &generator{
+		random:     rand.New(rand.NewSource(spec.Seed)),
+		spec:       spec,
+		characters: encodable(config.Converter, characters),
+		ascii:      encodable(config.Converter, asciiCharacters),
+	}
+	for i := 0; i < rows; i++ {
+		row := file.NewRow()
+		for pos, c := range spec.Columns {
+			err = row.Field(pos).SetValue(g.value(c))
+			if err != nil {
+				file.Close()
+				return nil, dbase.WrapError(err)
+			}
+		}
+		row.Deleted = g.random.Float64() < spec.DeletedRate
+		err = row.Add()
+		if err != nil {
+			file.Close()
+			return nil, dbase.NewErrorf("failed to add row %d", i).Details(err)
+		}
+	}
+	return file, nil
+}
+
+// encodedRune is a candidate character and the length of its encoded representation
+type encodedRune struct {
+	value  rune
+	length int
+}
+
+// encodable returns the characters the converter can encode with their encoded length
+func encodable(converter dbase.EncodingConverter, candidates string) []encodedRune {
+	result := make([]encodedRune, 0, len(candidates))
+	for _, r := range candidates {
+		encoded, err := converter.Encode([]byte(string(r)))
+		if err != nil || len(encoded) == 0 {
+			continue
+		}
+		result = append(result, encodedRune{value: r, length: len(encoded)})
+	}
+	return result
+}
+
+type generator struct {
+	random     *rand.Rand
+	spec       Spec
+	characters []encodedRune
+	ascii      []encodedRune
+}
+
+// value returns a pseudo-random value of the column type
+func (g *generator) value(c ColumnSpec) interface{} {
+	switch c.Type {
+	case dbase.Character:
+		return g.text(g.characters, g.random.Intn(int(c.Length)+1))
+	case dbase.Varchar:
+		if c.Nullable && g.random.Float64() < g.spec.NullRate {
+			return nil
+		}
+		return g.text(g.ascii, g.varLength(c.Length))
+	case dbase.Varbinary:
+		if c.Nullable && g.random.Float64() < g.spec.NullRate {
+			return nil
+		}
+		b := make([]byte, g.varLength(c.Length))
+		g.random.Read(b)
+		return b
+	case dbase.Memo:
+		// Memos up to three blocks, so values span block boundaries
+		return g.text(g.ascii, g.random.Intn(3*int(g.spec.MemoBlockSize)))
+	case dbase.Integer:
+		return int32(g.random.Int63n(math.MaxUint32+1) + math.MinInt32)
+	case dbase.Numeric:
+		if c.Decimals == 0 {
+			return g.whole(c)
+		}
+		return g.decimal(c)
+	case dbase.Float:
+		return g.decimal(c)
+	case dbase.Double:
+		return g.random.NormFloat64() * 1e6
+	case dbase.Currency:
+		return float64(g.random.Int63n(2e12)-1e12) / 10000
+	case dbase.Date:
+		if g.random.Intn(10) == 0 {
+			return dbase.EmptyDate()
+		}
+		return g.date()
+	case dbase.DateTime:
+		if g.random.Intn(10) == 0 {
+			return dbase.EmptyDate()
+		}
+		return g.date().Add(time.Duration(g.random.Int63n(int64(24*time.Hour/time.Millisecond))) * time.Millisecond)
+	case dbase.Logical:
+		return g.random.Intn(2) == 1
+	}
+	return nil
+}
+
+// text returns a string of up to length encoded bytes
+func (g *generator) text(candidates []encodedRune, length int) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	runes := make([]rune, 0, length)
+	for length > 0 {
+		r := candidates[g.random.Intn(len(candidates))]
+		if r.length > length {
+			break
+		}
+		runes = append(runes, r.value)
+		length -= r.length
+	}
+	return string(runes)
+}
+
+// varLength returns the length of a variable length value, preferring the empty and full length edge cases.
+// Values are at least one byte long, null values are generated by NullRate.
+func (g *generator) varLength(length uint8) int {
+	switch g.random.Intn(4) {
+	case 0:
+		return 1
+	case 1:
+		return int(length)
+	default:
+		return 1 + g.random.Intn(int(length))
+	}
+}
+
+// whole returns an integer that fits into the numeric column including the sign
+func (g *generator) whole(c ColumnSpec) int64 {
+	digits := int(c.Length) - 1
+	if digits > 18 {
+		digits = 18
+	}
+	if digits <= 0 {
+		return int64(g.random.Intn(10))
+	}
+	limit := int64(math.Pow10(digits))
+	return g.random.Int63n(2*limit-1) - (limit - 1)
+}
+
+// decimal returns a number with the decimals of the column that fits into the column including the sign
+func (g *generator) decimal(c ColumnSpec) float64 {
+	digits := int(c.Length) - int(c.Decimals) - 2
+	if c.Decimals == 0 {
+		digits = int(c.Length) - 1
+	}
+	if digits+int(c.Decimals) > 15 {
+		digits = 15 - int(c.Decimals)
+	}
+	if digits <= 0 {
+		return 0
+	}
+	scale := math.Pow10(int(c.Decimals))
+	limit := int64(math.Pow10(digits) * scale)
+	return float64(g.random.Int63n(2*limit-1)-(limit-1)) / scale
+}
+
+// date returns a date between 1900 and 2100
+func (g *generator) date() time.Time {
+	return time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, g.random.Intn(73000))
+}
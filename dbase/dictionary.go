@@ -0,0 +1,67 @@
+package dbase
+
+// Dictionary is a character column encoded as its distinct values and the index of the value of each row,
+// as used by the dictionary encoding of columnar export formats.
+type Dictionary struct {
+	Values  []string // Distinct values in the order of their first occurrence
+	Indexes []int    // Index into Values for each row, -1 for null values
+}
+
+// DictionaryEncode reads the character column (name or external key) of all rows and encodes it as a dictionary.
+// Returns false if the column has more than maxValues distinct values, so a plain encoding should be used instead.
+// The scan stops as soon as the limit is exceeded, so high-cardinality columns are detected early.
+// Values are converted as returned by Row.ToMap, deleted rows are skipped and the internal row pointer is not moved.
+func (file *File) DictionaryEncode(column string, maxValues int) (*Dictionary, bool, error) {
+	pos := file.columnPosByKey(column)
+	if pos < 0 {
+		return nil, false, NewErrorf("column '%s' not found", column)
+	}
+	c := file.table.columns[pos]
+	if DataType(c.DataType) != Character && DataType(c.DataType) != Varchar {
+		return nil, false, NewErrorf("column %s is not a character column", c.Name())
+	}
+	if maxValues <= 0 {
+		return nil, false, NewErrorf("invalid maximum number of values %d", maxValues)
+	}
+	debugf("Dictionary encoding column %s of %d rows with up to %d values", c.Name(), file.header.RowsCount, maxValues)
+	layout := file.table.layout.Columns[pos]
+	dictionary := &Dictionary{Values: make([]string, 0), Indexes: make([]int, 0, file.header.RowsCount)}
+	index := make(map[string]int)
+	for position := uint32(0); position < file.header.RowsCount; position++ {
+		data, err := file.ReadRow(position)
+		if err != nil {
+			return nil, false, WrapError(err)
+		}
+		if Marker(data[0]) == Deleted {
+			continue
+		}
+		val, err := file.interpret(data[layout.Offset:layout.Offset+layout.Length], c, file.table.layout.rowNullFlags(data))
+		if err != nil {
+			return nil, false, NewErrorf("failed to read column %s of row %d", c.Name(), position).Details(err)
+		}
+		val, err = file.modify(pos, file.sanitize(val))
+		if err != nil {
+			return nil, false, WrapError(err)
+		}
+		if val == nil {
+			dictionary.Indexes = append(dictionary.Indexes, -1)
+			continue
+		}
+		str, ok := val.(string)
+		if !ok {
+			return nil, false, NewErrorf("column %s of row %d is converted to %T instead of string", c.Name(), position, val)
+		}
+		i, ok := index[str]
+		if !ok {
+			if len(dictionary.Values) >= maxValues {
+				debugf("Column %s has more than %d distinct values", c.Name(), maxValues)
+				return nil, false, nil
+			}
+			i = len(dictionary.Values)
+			index[str] = i
+			dictionary.Values = append(dictionary.Values, str)
+		}
+		dictionary.Indexes = append(dictionary.Indexes, i)
+	}
+	return dictionary, true, nil
+}
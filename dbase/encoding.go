@@ -19,6 +19,13 @@ type EncodingConverter interface {
 	CodePage() byte
 }
 
+// ASCIICompatibleConverter is an optional interface of an EncodingConverter whose encoding stores ASCII characters
+// as the same single bytes. Values only consisting of ASCII characters are then used as they are
+// instead of being decoded or encoded, which avoids the conversion and its allocations.
+type ASCIICompatibleConverter interface {
+	ASCIICompatible() bool
+}
+
 type DefaultConverter struct {
 	encoding encoding.Encoding
 }
@@ -38,6 +45,70 @@ func (c DefaultConverter) Decode(in []byte) ([]byte, error) {
 	return data, nil
 }
 
+// StrictDecoder is an optional interface of an EncodingConverter used if StrictEncoding is set in the config.
+// DecodeStrict returns an error with ErrInvalidEncoding if the data contains bytes the encoding does not define.
+type StrictDecoder interface {
+	DecodeStrict(in []byte) ([]byte, error)
+}
+
+// DecodeStrict decodes the data like Decode, but fails at the first byte the encoding does not define.
+// Data that is valid UTF-8 is decoded as well instead of being returned as it is.
+func (c DefaultConverter) DecodeStrict(in []byte) ([]byte, error) {
+	if cm, ok := c.encoding.(*charmap.Charmap); ok {
+		out := make([]byte, 0, len(in))
+		for i, b := range in {
+			r := cm.DecodeByte(b)
+			if r == utf8.RuneError {
+				return nil, invalidEncodingError(in, i, c.CodePage())
+			}
+			out = utf8.AppendRune(out, r)
+		}
+		return out, nil
+	}
+	out, _, err := transform.Bytes(c.encoding.NewDecoder(), in)
+	if err != nil {
+		return nil, NewError("decoding with default converter failed").Details(err)
+	}
+	if i := bytes.IndexRune(out, utf8.RuneError); i >= 0 {
+		// The offset of the invalid byte is the length of the valid data before it
+		prefix, _, err := transform.Bytes(c.encoding.NewEncoder(), out[:i])
+		if err != nil {
+			return nil, invalidEncodingError(in, 0, c.CodePage())
+		}
+		return nil, invalidEncodingError(in, len(prefix), c.CodePage())
+	}
+	return out, nil
+}
+
+// decode decodes the data using the converter, strictly if set and the converter supports it.
+// Converters without StrictDecoder fail if the decoded data contains replacement characters.
+func decode(in []byte, converter EncodingConverter, strict bool) ([]byte, error) {
+	if !strict {
+		return converter.Decode(in)
+	}
+	if s, ok := converter.(StrictDecoder); ok {
+		return s.DecodeStrict(in)
+	}
+	out, err := converter.Decode(in)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range string(out) {
+		if r == utf8.RuneError {
+			return nil, NewErrorf("decoded value contains an invalid character at offset %d", i).Details(ErrInvalidEncoding)
+		}
+	}
+	return out, nil
+}
+
+// invalidEncodingError returns the error for the invalid byte at the offset of the data
+func invalidEncodingError(in []byte, offset int, codePage byte) error {
+	if offset >= len(in) {
+		offset = len(in) - 1
+	}
+	return NewErrorf("invalid byte 0x%02X at offset %d for code page 0x%02X", in[offset], offset, codePage).Details(ErrInvalidEncoding)
+}
+
 // Decode decodes a UTF8 byte slice to the specified encoding byte slice
 func (c DefaultConverter) Encode(in []byte) ([]byte, error) {
 	out := make([]byte, len(in))
@@ -49,6 +120,18 @@ func (c DefaultConverter) Encode(in []byte) ([]byte, error) {
 	return out[:nDst], nil
 }
 
+// ASCIICompatible returns true for the encodings of the supported code pages, which all store ASCII characters as they are.
+// Encodings registered by RegisterCustomEncoding are converted as usual.
+func (c DefaultConverter) ASCIICompatible() bool {
+	switch c.encoding {
+	case charmap.CodePage437, charmap.CodePage850, charmap.CodePage852, charmap.CodePage865, charmap.CodePage866,
+		charmap.Windows874, charmap.Windows1250, charmap.Windows1251, charmap.Windows1252, charmap.Windows1253,
+		charmap.Windows1254, charmap.Windows1255, charmap.Windows1256, simplifiedchinese.GBK:
+		return true
+	}
+	return false
+}
+
 // CodePageMark returns corresponding code page mark for the encoding
 func (c DefaultConverter) CodePage() byte {
 	switch c.encoding {
@@ -9,7 +9,7 @@ import (
 var (
 	// Returned when the end of a dBase database file is reached
 	ErrEOF = errors.New("EOF")
-	// Returned when the row pointer is attempted to be moved before the first row
+	// Returned by Skip when the row pointer is attempted to be moved before the first row
 	ErrBOF = errors.New("BOF")
 	// Returned when the read of a row or column did not finish
 	ErrIncomplete = errors.New("INCOMPLETE")
@@ -18,15 +18,43 @@ var (
 	ErrNoDBF = errors.New("DBF_FILE_NOT_FOUND")
 	// Returned when an invalid column position is used (x<1 or x>number of columns)
 	ErrInvalidPosition = errors.New("INVALID_POSITION")
+	// Returned when StrictEncoding is set and a value contains bytes the code page does not define
 	ErrInvalidEncoding = errors.New("INVALID_ENCODING")
+	// Returned when a region of the file is locked by another process and the lock timeout expired
+	ErrLocked = errors.New("LOCKED")
+	// Returned when a memo exceeds the maximum memo size
+	ErrMemoTooLarge = errors.New("MEMO_TOO_LARGE")
+	// Returned when reading more rows into memory than MaxRowsInMemory or MaxBytesInMemory of the config allow
+	ErrMemoryLimit = errors.New("MEMORY_LIMIT")
+	// Returned when a numeric value is marked as overflowed
+	ErrNumericOverflow = errors.New("NUMERIC_OVERFLOW")
+	// Returned when a memo address points outside of the memo file or beyond the blocks a memo file can address
+	ErrInvalidMemoAddress = errors.New("INVALID_MEMO_ADDRESS")
+	// Returned when the checksum of a table does not match the checksum file
+	ErrChecksumMismatch = errors.New("CHECKSUM_MISMATCH")
+	// Returned when the fields of a config contradict each other or are out of range
+	ErrInvalidConfig = errors.New("INVALID_CONFIG")
 	// Returned when an invalid data type is used
 	ErrUnknownDataType = errors.New("UNKNOWN_DATA_TYPE")
+	// Returned when the header of a DBF file can not be read
+	ErrInvalidHeader = errors.New("INVALID_HEADER")
+	// Returned when the file version is not tested and Untested is not set
+	ErrUnsupportedVersion = errors.New("UNSUPPORTED_VERSION")
+	// Returned when the column definitions of a DBF file can not be read
+	ErrInvalidColumns = errors.New("INVALID_COLUMNS")
+	// Returned when the code page mark does not match the converter and ValidateCodePage is set
+	ErrCodePageMismatch = errors.New("CODE_PAGE_MISMATCH")
+	// Returned when appending a row would exceed MaxRecordsPerTable or MaxTableFileSize
+	ErrTableFull = errors.New("TABLE_FULL")
+	// Returned when MaxOpenTables of a database is reached and every open table is in use
+	ErrTooManyTables = errors.New("TOO_MANY_TABLES")
 )
 
 // Error is a wrapper for errors that occur in the dbase package
 type Error struct {
 	trace   []string
 	details []error
+	cause   error // Wrapped error of another type, see WrapError
 	msg     string
 }
 
@@ -56,6 +84,14 @@ func (e Error) Details(err error) Error {
 	return e
 }
 
+// Unwrap returns the details of the error, so errors.Is and errors.As find the causes, e.g. ErrEOF
+func (e Error) Unwrap() []error {
+	if e.cause == nil {
+		return e.details
+	}
+	return append([]error{e.cause}, e.details...)
+}
+
 func (e Error) Error() string {
 	details := ""
 	for _, d := range e.details {
@@ -89,6 +125,7 @@ func WrapError(err error) Error {
 		msg:     err.Error(),
 		trace:   make([]string, 0),
 		details: make([]error, 0),
+		cause:   err,
 	}
 	e.trace = trace(e)
 	return e
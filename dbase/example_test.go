@@ -0,0 +1,197 @@
+package dbase_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+	"github.com/Valentin-Kaiser/go-dbase/dbase/dbasetest"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// exampleSpec is the content of the tables the examples are run against
+var exampleSpec = dbasetest.Spec{
+	Seed: 1,
+	Columns: []dbasetest.ColumnSpec{
+		{Name: "ID", Type: dbase.Integer},
+		{Name: "NAME", Type: dbase.Character, Length: 20},
+		{Name: "PRICE", Type: dbase.Numeric, Length: 8, Decimals: 2},
+	},
+}
+
+// exampleNames are the product names of the example rows, repeated in this order
+var exampleNames = []string{"Apple", "Banana", "Cherry", "Date", "Elderberry"}
+
+// generateExample generates the example table in a temporary directory and returns its filename.
+// The generated names are replaced by the product names.
+func generateExample(rows int) (string, func()) {
+	dir, err := os.MkdirTemp("", "dbase-example")
+	if err != nil {
+		panic(err)
+	}
+	filename := filepath.Join(dir, "PRODUCTS.DBF")
+	file, err := dbasetest.GenerateTable(&dbase.Config{Filename: filename}, rows, exampleSpec)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	for i := 0; i < rows; i++ {
+		err = file.GoTo(uint32(i))
+		if err != nil {
+			panic(err)
+		}
+		row, err := file.Row()
+		if err != nil {
+			panic(err)
+		}
+		err = row.FieldByName("NAME").SetValue(exampleNames[i%len(exampleNames)])
+		if err != nil {
+			panic(err)
+		}
+		err = row.Write()
+		if err != nil {
+			panic(err)
+		}
+	}
+	return filename, func() { os.RemoveAll(dir) }
+}
+
+func ExampleOpenTable() {
+	filename, cleanup := generateExample(3)
+	defer cleanup()
+
+	table, err := dbase.OpenTable(&dbase.Config{
+		Filename:   filename,
+		TrimSpaces: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer table.Close()
+
+	fmt.Printf("Columns: %v Rows: %v\n", table.Header().ColumnsCount(), table.Header().RecordsCount())
+	for _, column := range table.Columns() {
+		fmt.Printf("Name: %v - Type: %v\n", column.Name(), column.Type())
+	}
+	for !table.EOF() {
+		row, err := table.Next()
+		if err != nil {
+			panic(err)
+		}
+		name, err := row.ValueByName("NAME")
+		if err != nil {
+			panic(err)
+		}
+		price, err := row.ValueByName("PRICE")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Row %v: %v %v\n", row.Position, name, price)
+	}
+	// Output:
+	// Columns: 3 Rows: 3
+	// Name: ID - Type: I
+	// Name: NAME - Type: C
+	// Name: PRICE - Type: N
+	// Row 0: Apple 5775.46
+	// Row 1: Banana 9353.37
+	// Row 2: Cherry -5791.93
+}
+
+func ExampleFile_Search() {
+	filename, cleanup := generateExample(20)
+	defer cleanup()
+
+	table, err := dbase.OpenTable(&dbase.Config{Filename: filename, TrimSpaces: true})
+	if err != nil {
+		panic(err)
+	}
+	defer table.Close()
+
+	field, err := table.NewFieldByName("NAME", "Cherry")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := table.Search(field, true)
+	if err != nil {
+		panic(err)
+	}
+	for _, row := range rows {
+		name, err := row.ValueByName("NAME")
+		if err != nil {
+			panic(err)
+		}
+		price, err := row.ValueByName("PRICE")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Row %v: %v %v\n", row.Position, name, price)
+	}
+	// Output:
+	// Row 2: Cherry -5791.93
+	// Row 7: Cherry 2062.29
+	// Row 12: Cherry -4163.59
+	// Row 17: Cherry -7853.97
+}
+
+func ExampleNewTable() {
+	dir, err := os.MkdirTemp("", "dbase-example")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	id, err := dbase.NewColumn("ID", dbase.Integer, 0, 0, false)
+	if err != nil {
+		panic(err)
+	}
+	name, err := dbase.NewColumn("Name", dbase.Character, 20, 0, false)
+	if err != nil {
+		panic(err)
+	}
+	memo, err := dbase.NewColumn("Memo", dbase.Memo, 0, 0, false)
+	if err != nil {
+		panic(err)
+	}
+	table, err := dbase.NewTable(
+		dbase.FoxProVar,
+		&dbase.Config{
+			Filename:   filepath.Join(dir, "TEST.DBF"),
+			Converter:  dbase.NewDefaultConverter(charmap.Windows1250),
+			TrimSpaces: true,
+		},
+		[]*dbase.Column{id, name, memo},
+		64,
+		nil,
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer table.Close()
+
+	row, err := table.RowFromStruct(&struct {
+		ID   int32  `dbase:"ID"`
+		Name string `dbase:"NAME"`
+		Memo string `dbase:"MEMO"`
+	}{ID: 1, Name: "Test", Memo: "Memo"})
+	if err != nil {
+		panic(err)
+	}
+	err = row.Add()
+	if err != nil {
+		panic(err)
+	}
+
+	row, err = table.Row()
+	if err != nil {
+		panic(err)
+	}
+	values, err := row.ToMap()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Rows: %v ID: %v Name: %v Memo: %s\n", table.RowsCount(), values["ID"], values["NAME"], values["MEMO"])
+	// Output:
+	// Rows: 1 ID: 1 Name: Test Memo: Memo
+}
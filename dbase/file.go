@@ -1,25 +1,54 @@
 package dbase
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
+	"unsafe"
 )
 
 // File is the main struct to handle a dBase file.
 // Each file type is basically a Table or a Memo file.
 type File struct {
-	config         *Config     // The config used when working with the DBF file.
-	handle         interface{} // DBase file handle.
-	relatedHandle  interface{} // Memo file handle.
-	io             IO          // The IO interface used to work with the DBF file.
-	header         *Header     // DBase file header containing relevant information.
-	memoHeader     *MemoHeader // Memo file header containing relevant information.
-	dbaseMutex     *sync.Mutex // Mutex locks for concurrent writing access to the DBF file.
-	memoMutex      *sync.Mutex // Mutex locks for concurrent writing access to the FPT file.
-	table          *Table      // Containing the columns and internal row pointer.
-	nullFlagColumn *Column     // The column containing the null flag column (if varchar or varbinary field exists).
+	config         *Config             // The config used when working with the DBF file.
+	handle         interface{}         // DBase file handle.
+	relatedHandle  interface{}         // Memo file handle.
+	io             IO                  // The IO interface used to work with the DBF file.
+	header         *Header             // DBase file header containing relevant information.
+	memoHeader     *MemoHeader         // Memo file header containing relevant information.
+	dbaseMutex     *sync.Mutex         // Mutex locks for concurrent writing access to the DBF file.
+	memoMutex      *sync.Mutex         // Mutex locks for concurrent writing access to the FPT file.
+	locks          *fileLocks          // Regions of the DBF file locked through this file.
+	table          *Table              // Containing the columns and internal row pointer.
+	nullFlagColumn *Column             // The column containing the null flag column (if varchar or varbinary field exists).
+	warnings       []string            // Anomalies of the file that were tolerated when opening it.
+	prefetched     map[uint32]MemoData // Memos of the rows buffered by the row iterator, see rowIterator.prefetch.
+	indexes        *cdxIndexes         // Tags of the compound index maintained when rows are written, see Config.MaintainIndexes.
+	eofMarker      bool                // Whether the rows are followed by the end of file marker.
+	trailing       []byte              // Data behind the rows and the end of file marker, preserved when rows are appended.
+	database       *Database           // Database the table was opened through, stores the column comments.
+	reusable       uint32              // Rows before the position are not marked as deleted, see deletedRow.
+}
+
+// Warnings returns the anomalies of the file that were tolerated when opening it, e.g. a missing column terminator
+func (file *File) Warnings() []string {
+	return append([]string(nil), file.warnings...)
+}
+
+// warn records a tolerated anomaly of the file, each anomaly is only recorded once
+func (file *File) warn(format string, args ...interface{}) {
+	warning := fmt.Sprintf(format, args...)
+	for _, w := range file.warnings {
+		if w == warning {
+			return
+		}
+	}
+	debugf("Warning: %s", warning)
+	file.warnings = append(file.warnings, warning)
 }
 
 func (file *File) TableName() string {
@@ -31,12 +60,12 @@ func (file *File) EOF() bool {
 	return file.table.rowPointer >= file.header.RowsCount
 }
 
-// Returns if the internal row pointer is before first row
+// Returns if the internal row pointer is at the first row
 func (file *File) BOF() bool {
 	return file.table.rowPointer == 0
 }
 
-// Returns the current row pointer position
+// Returns the current row pointer position, starting at 0
 func (file *File) Pointer() uint32 {
 	return file.table.rowPointer
 }
@@ -46,6 +75,28 @@ func (file *File) Header() *Header {
 	return file.header
 }
 
+// Returns the code page mark of the table
+func (file *File) CodePage() byte {
+	return file.header.CodePage
+}
+
+// SetCodePage changes the code page mark of the table and writes the header.
+// If the code page is interpreted, the converter is changed to match the new code page mark.
+func (file *File) SetCodePage(cp byte) error {
+	debugf("Changing code page mark: 0x%02x => 0x%02x", file.header.CodePage, cp)
+	previous := file.header.CodePage
+	file.header.CodePage = cp
+	err := file.WriteHeader()
+	if err != nil {
+		file.header.CodePage = previous
+		return WrapError(err)
+	}
+	if file.config.InterpretCodePage {
+		file.config.Converter = ConverterFromCodePage(cp)
+	}
+	return nil
+}
+
 // returns the number of rows
 func (file *File) RowsCount() uint32 {
 	return file.header.RowsCount
@@ -118,6 +169,40 @@ func (file *File) SetColumnModificationByName(name string, mod *Modification) er
 	return nil
 }
 
+// SetColumnConverter overrides the converter of the table for the character or memo column,
+// e.g. if a column of a legacy table is stored in a different encoding than the other columns.
+// The converter is used when reading and writing the column, text memos of the column are also encoded with it.
+// A nil converter removes the override.
+func (file *File) SetColumnConverter(name string, converter EncodingConverter) error {
+	position := file.ColumnPosByName(name)
+	if position < 0 {
+		return NewErrorf("column '%s' not found", name)
+	}
+	column := file.table.columns[position]
+	if DataType(column.DataType) != Character && DataType(column.DataType) != Memo {
+		return NewErrorf("column %s of type %s has no encoding, only character and memo columns are supported", name, DataType(column.DataType))
+	}
+	if converter == nil {
+		debugf("Removing converter of column %s", name)
+		delete(file.table.converters, column)
+		return nil
+	}
+	debugf("Converter set for column %s - code page: 0x%02x", name, converter.CodePage())
+	if file.table.converters == nil {
+		file.table.converters = make(map[*Column]EncodingConverter)
+	}
+	file.table.converters[column] = converter
+	return nil
+}
+
+// converter returns the converter of the column, the converter of the config if it is not overridden
+func (file *File) converter(column *Column) EncodingConverter {
+	if converter, ok := file.table.converters[column]; ok {
+		return converter
+	}
+	return file.config.Converter
+}
+
 // Returns the column modification for a column at the given position
 func (file *File) GetColumnModification(position int) *Modification {
 	return file.table.mods[position]
@@ -147,11 +232,54 @@ func (file *File) Init() error {
 	return nil
 }
 
+// ensureMemo creates the memo file with the default block size if the table has none yet
+// and sets the memo flag in the header, so a memo can be written to a table created without memo columns.
+func (file *File) ensureMemo() error {
+	file.memoMutex.Lock()
+	defer file.memoMutex.Unlock()
+	if file.relatedHandle != nil && file.memoHeader != nil {
+		return nil
+	}
+	if file.config.ReadOnly {
+		return NewError("can not create the memo file of a read-only table").Details(ErrNoFPT)
+	}
+	if version := FileVersion(file.header.FileType); !version.Supports(FeatureMemo) {
+		return NewErrorf("file version 0x%02x does not support memo files", byte(version)).Details(ErrNoFPT)
+	}
+	debugf("Creating memo file on demand - block size: %d", DefaultMemoBlockSize)
+	err := file.CreateRelated()
+	if err != nil {
+		return WrapError(err)
+	}
+	file.memoHeader = &MemoHeader{BlockSize: DefaultMemoBlockSize}
+	file.memoHeader.NextFree = nextMemoBlock(file.memoHeader)
+	err = file.WriteMemoHeader(0)
+	if err != nil {
+		return WrapError(err)
+	}
+	file.header.TableFlags |= byte(MemoFlag)
+	err = file.WriteHeader()
+	if err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
 // Returns all rows as a slice
+// If MaxRowsInMemory or MaxBytesInMemory is set in the config and the rows exceed it, an ErrMemoryLimit error is returned.
 func (file *File) Rows(skipInvalid bool, skipDeleted bool) ([]*Row, error) {
+	budget := &memoryBudget{config: file.config}
+	// All rows are collected if deleted rows are kept, so the row limit can be checked before reading
+	if !skipDeleted {
+		err := budget.check(int(file.header.RowsCount), 0)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+	}
 	rows := make([]*Row, 0)
+	it := &rowIterator{file: file}
 	for !file.EOF() {
-		row, err := file.Next()
+		row, err := it.next()
 		if err != nil {
 			if skipInvalid {
 				continue
@@ -163,19 +291,160 @@ func (file *File) Rows(skipInvalid bool, skipDeleted bool) ([]*Row, error) {
 		if row.Deleted && skipDeleted {
 			continue
 		}
+		size := int64(0)
+		for _, field := range row.fields {
+			size += valueSize(field.value)
+		}
+		err = budget.add(size)
+		if err != nil {
+			return nil, WrapError(err)
+		}
 		rows = append(rows, row)
 	}
 	return rows, nil
 }
 
+// memoryBudget enforces MaxRowsInMemory and MaxBytesInMemory of the config while rows are collected
+type memoryBudget struct {
+	config *Config
+	rows   int
+	bytes  int64
+}
+
+// add accounts for a collected row with the estimated size of its values
+func (b *memoryBudget) add(size int64) error {
+	b.rows++
+	b.bytes += size
+	return b.check(b.rows, b.bytes)
+}
+
+// check returns an ErrMemoryLimit error if the rows or bytes exceed the limits
+func (b *memoryBudget) check(rows int, bytes int64) error {
+	if b.config.MaxRowsInMemory > 0 && rows > b.config.MaxRowsInMemory {
+		return NewErrorf("%d rows exceed the limit of %d rows in memory, read the rows one by one using Next instead", rows, b.config.MaxRowsInMemory).Details(ErrMemoryLimit)
+	}
+	if b.config.MaxBytesInMemory > 0 && bytes > b.config.MaxBytesInMemory {
+		return NewErrorf("%d bytes exceed the limit of %d bytes in memory, read the rows one by one using Next instead", bytes, b.config.MaxBytesInMemory).Details(ErrMemoryLimit)
+	}
+	return nil
+}
+
+// valueSize estimates the memory used by a value read from the table
+func valueSize(val interface{}) int64 {
+	switch v := val.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case time.Time:
+		return int64(unsafe.Sizeof(v))
+	}
+	return 8
+}
+
 // Reads the row and increments the row pointer by one
 func (file *File) Next() (*Row, error) {
 	row, err := file.Row()
-	file.Skip(1)
+	skipErr := file.Skip(1)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if skipErr != nil {
+		return nil, WrapError(skipErr)
+	}
+	return row, nil
+}
+
+// rowsBatchSize is the number of rows read at once when iterating over the rows of the table
+const rowsBatchSize = 512
+
+// rowIterator reads the rows from the row pointer on in batches instead of one read per row.
+// The memos of the buffered rows are prefetched, so memo heavy tables are not read memo by memo.
+type rowIterator struct {
+	file  *File
+	start uint32              // Position of the first buffered row
+	rows  [][]byte            // Raw data of the buffered rows
+	memos map[uint32]MemoData // Prefetched memos of the buffered rows
+}
+
+// next reads the row at the row pointer and increments the row pointer by one like File.Next.
+// If the batch containing the row can not be read, the row is read on its own.
+func (it *rowIterator) next() (*Row, error) {
+	file := it.file
+	pointer := file.table.rowPointer
+	if pointer < it.start || pointer-it.start >= uint32(len(it.rows)) {
+		rows, err := file.ReadRows(pointer, rowsBatchSize)
+		if err != nil {
+			it.rows = nil
+			return file.Next()
+		}
+		it.start, it.rows = pointer, rows
+		it.prefetch()
+	}
+	file.prefetched = it.memos
+	row, err := file.BytesToRow(it.rows[pointer-it.start])
+	file.prefetched = nil
+	skipErr := file.Skip(1)
 	if err != nil {
 		return nil, WrapError(err)
 	}
-	return row, err
+	if skipErr != nil {
+		return nil, WrapError(skipErr)
+	}
+	return row, nil
+}
+
+// prefetch reads the memos of the buffered rows with few large reads, sorted by their position in the memo file.
+// If prefetching fails, the memos are read one by one.
+func (it *rowIterator) prefetch() {
+	file := it.file
+	it.memos = nil
+	if file.memoHeader == nil {
+		return
+	}
+	offsets := make([]int, 0)
+	for i, column := range file.table.columns {
+		if DataType(column.DataType) == Memo && column.Length >= 4 {
+			offsets = append(offsets, file.table.layout.Columns[i].Offset)
+		}
+	}
+	if len(offsets) == 0 {
+		return
+	}
+	seen := make(map[uint32]bool)
+	blocks := make([]uint32, 0, len(it.rows)*len(offsets))
+	for _, data := range it.rows {
+		for _, offset := range offsets {
+			if offset+4 > len(data) {
+				continue
+			}
+			block := binary.LittleEndian.Uint32(data[offset:])
+			if block != 0 && !seen[block] {
+				seen[block] = true
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	if len(blocks) == 0 {
+		return
+	}
+	memos, err := file.ReadMemos(blocks)
+	if err != nil {
+		debugf("Prefetching memos failed: %v", err)
+		return
+	}
+	it.memos = memos
+}
+
+// prefetchedMemo returns the memo at the address if it was prefetched by the row iterator
+func (file *File) prefetchedMemo(address []byte) (MemoData, bool) {
+	if file.prefetched == nil || len(address) < 4 {
+		return MemoData{}, false
+	}
+	memo, ok := file.prefetched[binary.LittleEndian.Uint32(address)]
+	return memo, ok
 }
 
 // Returns the requested row at file.rowPointer.
@@ -187,11 +456,11 @@ func (file *File) Row() (*Row, error) {
 	return file.BytesToRow(data)
 }
 
-// Returns a new Row struct with the same column structure as the dbf and the next row pointer
+// Returns a new Row struct with the same column structure as the dbf and the position of the next row
 func (file *File) NewRow() *Row {
 	row := &Row{
 		handle:   file,
-		Position: file.header.RowsCount + 1,
+		Position: file.header.RowsCount,
 		Deleted:  false,
 		fields:   make([]*Field, 0),
 	}
@@ -214,9 +483,9 @@ func (file *File) NewField(pos int, value interface{}) (*Field, error) {
 	return &Field{column: column, value: value}, nil
 }
 
-// Creates a new field with the given value and column specified by name
+// Creates a new field with the given value and column specified by name, external key or alias of a modification
 func (file *File) NewFieldByName(name string, value interface{}) (*Field, error) {
-	pos := file.ColumnPosByName(name)
+	pos := file.columnPosByKey(name)
 	if pos < 0 {
 		return nil, NewErrorf("column '%s' not found", name)
 	}
@@ -226,51 +495,102 @@ func (file *File) NewFieldByName(name string, value interface{}) (*Field, error)
 // Converts raw row data to a Row struct
 // If the data points to a memo (FPT) file this file is also read
 func (file *File) BytesToRow(data []byte) (*Row, error) {
-	debugf("Converting row data (%d bytes) to row struct...", len(data))
 	rec := &Row{}
-	rec.Position = file.table.rowPointer
-	rec.handle = file
-	rec.fields = make([]*Field, 0)
+	err := file.bytesIntoRow(data, rec)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return rec, nil
+}
+
+// Reads the row into the given row and increments the row pointer by one.
+// The row and its fields are reused instead of allocating new ones for each row,
+// which reduces the load on the garbage collector when scanning large tables.
+// Values of a previous row are overwritten, so they must be copied if they are needed after the next call.
+func (file *File) NextInto(row *Row) error {
+	if row == nil {
+		return NewError("row is nil")
+	}
+	data, err := file.ReadRow(file.table.rowPointer)
+	if err == nil {
+		err = file.bytesIntoRow(data, row)
+	}
+	skipErr := file.Skip(1)
+	if err != nil {
+		return WrapError(err)
+	}
+	if skipErr != nil {
+		return WrapError(skipErr)
+	}
+	return nil
+}
+
+// bytesIntoRow converts the raw row data into the row, reusing its fields
+func (file *File) bytesIntoRow(data []byte, rec *Row) error {
+	debugf("Converting row data (%d bytes) to row struct...", len(data))
 	if len(data) < int(file.header.RowLength) {
-		return nil, NewErrorf("invalid row data size %v Bytes < %v Bytes", len(data), int(file.header.RowLength))
+		return NewErrorf("invalid row data size %v Bytes < %v Bytes", len(data), int(file.header.RowLength))
+	}
+	if len(data) < file.table.layout.Length {
+		return NewErrorf("invalid row data size %v Bytes < %v Bytes of the columns", len(data), file.table.layout.Length)
 	}
 	// a row should start with te delete flag, a space ACTIVE(0x20) or DELETED(0x2A)
+	if Marker(data[0]) != Deleted && Marker(data[0]) != Active {
+		return NewError("invalid row data, no delete flag found at beginning of row")
+	}
+	rec.Position = file.table.rowPointer
+	rec.ByteOffset = 0
+	rec.handle = file
+	rec.raw = nil
 	rec.Deleted = Marker(data[0]) == Deleted
-	if !rec.Deleted && Marker(data[0]) != Active {
-		return nil, NewError("invalid row data, no delete flag found at beginning of row")
-	}
-	// deleted flag already read
-	offset := uint16(1)
-	for i := 0; i < int(file.ColumnsCount()); i++ {
-		column := file.table.columns[i]
-		val, err := file.Interpret(data[offset:offset+uint16(column.Length)], file.table.columns[i])
+	if cap(rec.fields) < len(file.table.columns) {
+		rec.fields = make([]*Field, len(file.table.columns))
+	}
+	rec.fields = rec.fields[:len(file.table.columns)]
+	nullFlags := file.table.layout.rowNullFlags(data)
+	for i, column := range file.table.columns {
+		c := file.table.layout.Columns[i]
+		val, err := file.interpret(data[c.Offset:c.Offset+c.Length], column, nullFlags)
 		if err != nil {
-			return nil, WrapError(err)
+			return NewErrorf("failed to read column %s of row %d", column.Name(), rec.Position).Details(err)
 		}
-		if file.config.TrimSpaces {
-			if str, ok := val.(string); ok {
-				val = strings.TrimSpace(str)
-			}
+		field := rec.fields[i]
+		if field == nil {
+			field = &Field{}
+			rec.fields[i] = field
+		}
+		field.column = column
+		field.value = file.sanitize(val)
+		field.memoPos = field.memoPos[:0]
+		if column.DataType == byte(Memo) {
+			field.memoPos = append(field.memoPos, data[c.Offset:c.Offset+c.Length]...)
+		}
+	}
+	return nil
+}
 
-			if bslice, ok := val.([]byte); ok {
-				val = sanitizeEmptyBytes(bslice)
-			}
+// sanitize trims and collapses the spaces of string values if configured
+func (file *File) sanitize(val interface{}) interface{} {
+	if file.config.TrimSpaces {
+		if str, ok := val.(string); ok {
+			val = strings.TrimSpace(str)
 		}
-		if file.config.CollapseSpaces {
-			if str, ok := val.(string); ok {
-				val = sanitizeSpaces(str)
-			}
+
+		if bslice, ok := val.([]byte); ok {
+			val = sanitizeEmptyBytes(bslice)
 		}
-		rec.fields = append(rec.fields, &Field{
-			column: column,
-			value:  val,
-		})
-		offset += uint16(column.Length)
 	}
-	return rec, nil
+	if file.config.CollapseSpaces {
+		if str, ok := val.(string); ok {
+			val = sanitizeSpaces(str)
+		}
+	}
+	return val
 }
 
 // Converts a map of interfaces into the row representation
+// The values are keyed by external key, column name or alias of a modification, in this order of precedence.
+// The row is only created in memory, autoincrement values are assigned when the row is added.
 func (file *File) RowFromMap(m map[string]interface{}) (*Row, error) {
 	debugf("Converting map to row...")
 	row := file.NewRow()
@@ -290,17 +610,22 @@ func (file *File) RowFromMap(m map[string]interface{}) (*Row, error) {
 		}
 		if val, ok := m[field.Name()]; ok {
 			field.value = val
+		} else if i < len(file.table.mods) && file.table.mods[i] != nil {
+			for _, alias := range file.table.mods[i].Aliases {
+				if val, ok := m[alias]; ok {
+					debugf("Resolving alias %v for field %v due to modification", alias, field.Name())
+					field.value = val
+					break
+				}
+			}
 		}
 		row.fields[i] = field
 	}
-	err := row.Increment()
-	if err != nil {
-		return nil, WrapError(err)
-	}
 	return row, nil
 }
 
 // Converts a JSON-encoded row into the row representation
+// Strings of binary columns are decoded like ToJSON encodes them, see Format.Binary.
 func (file *File) RowFromJSON(j []byte) (*Row, error) {
 	debugf("Converting JSON to row...")
 	m := make(map[string]interface{})
@@ -312,14 +637,52 @@ func (file *File) RowFromJSON(j []byte) (*Row, error) {
 	if err != nil {
 		return nil, WrapError(err)
 	}
+	for _, field := range row.fields {
+		s, ok := field.value.(string)
+		if !ok || !binaryColumn(field.column) {
+			continue
+		}
+		field.value, err = file.decodeBinary(s)
+		if err != nil {
+			return nil, NewErrorf("invalid value for column %s", field.Name()).Details(err)
+		}
+	}
 	return row, nil
 }
 
+// binaryColumn returns if the values of the column are binary data
+func binaryColumn(column *Column) bool {
+	switch DataType(column.DataType) {
+	case Blob, Varbinary, General, Picture:
+		return true
+	}
+	return false
+}
+
 // Converts a struct into the row representation
 // The struct must have the same field names as the columns in the table or the dbase tag must be set.
 // The dbase tag can be used to name the field. For example: `dbase:"my_field_name"`
 func (file *File) RowFromStruct(v interface{}) (*Row, error) {
+	return file.RowFromStructWithOptions(v, StructOptions{IgnoreMissingColumns: true})
+}
+
+// RowFromStructWithOptions converts a struct into the row representation using the given options.
+// Columns without a struct field, or with a skipped zero value, are left empty.
+func (file *File) RowFromStructWithOptions(v interface{}, options StructOptions) (*Row, error) {
 	debugf("Converting struct to row...")
+	m, err := file.structToMap(v, options)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	row, err := file.RowFromMap(m)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return row, nil
+}
+
+// structToMap converts the exported fields of a struct to a map keyed by the dbase tag or the field name
+func (file *File) structToMap(v interface{}, options StructOptions) (map[string]interface{}, error) {
 	m := make(map[string]interface{})
 	rt := reflect.TypeOf(v)
 	if rt.Kind() == reflect.Ptr {
@@ -329,17 +692,53 @@ func (file *File) RowFromStruct(v interface{}) (*Row, error) {
 	if rv.Kind() == reflect.Ptr {
 		rv = rv.Elem()
 	}
+	if rt.Kind() != reflect.Struct {
+		return nil, NewErrorf("expected struct, got %v", rt.Kind())
+	}
+	paths := make([]structPath, 0)
+	err := extractPaths(rt, &paths)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	roots := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		roots[path.segments[0].name] = true
+	}
 	for i := 0; i < rt.NumField(); i++ {
 		field := rt.Field(i)
+		if len(field.PkgPath) != 0 || roots[field.Name] {
+			continue
+		}
+		if _, ok := field.Tag.Lookup("path"); ok {
+			continue
+		}
 		tag := field.Tag.Get("dbase")
 		if len(tag) == 0 {
 			tag = field.Name
 		}
+		if _, err := file.mapKey(tag); err != nil {
+			if options.IgnoreMissingColumns {
+				continue
+			}
+			return nil, NewErrorf("no column found for struct field %v", field.Name).Details(err)
+		}
+		if options.OmitEmpty && rv.Field(i).IsZero() {
+			continue
+		}
 		m[tag] = rv.Field(i).Interface()
 	}
-	row, err := file.RowFromMap(m)
-	if err != nil {
-		return nil, WrapError(err)
+	for _, path := range paths {
+		if _, err := file.mapKey(path.column); err != nil {
+			if options.IgnoreMissingColumns {
+				continue
+			}
+			return nil, NewErrorf("no column found for path %s", path.path).Details(err)
+		}
+		value, ok := getStructPath(rv, path)
+		if !ok || (options.OmitEmpty && value.IsZero()) {
+			continue
+		}
+		m[path.column] = value.Interface()
 	}
-	return row, nil
+	return m, nil
 }
@@ -0,0 +1,102 @@
+package dbase
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dirCacheTTL is how long a directory listing is used to resolve file names before the directory is read again.
+// Only names found in the listing are taken from the cache, a name missing in it causes the directory to be read again.
+const dirCacheTTL = 5 * time.Second
+
+// dirCache caches the file names of the directories scanned by findFile
+var dirCache = struct {
+	sync.Mutex
+	listings map[string]dirListing
+}{listings: make(map[string]dirListing)}
+
+type dirListing struct {
+	names   []string
+	expires time.Time
+}
+
+// findFile returns the path of the file, the file name is matched case insensitive.
+// The name as given and its common case variants are checked first, only if none of them exists
+// the directory is scanned. Returns an empty string if the file does not exist.
+func findFile(f string) (string, error) {
+	for _, candidate := range caseVariants(f) {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	dir := filepath.Dir(f)
+	base := filepath.Base(f)
+	// A cached listing may miss a file created since or contain a removed file, so only an existing match is used
+	for _, refresh := range []bool{false, true} {
+		names, cached, err := listDir(dir, refresh)
+		if err != nil {
+			return "", NewErrorf("failed to read directory %s", dir).Details(err)
+		}
+		for _, name := range names {
+			if !strings.EqualFold(name, base) {
+				continue
+			}
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+				return filepath.Join(dir, name), nil
+			}
+		}
+		if !cached {
+			break
+		}
+	}
+	return "", nil
+}
+
+// caseVariants returns the file name as given, with upper and lower case extension and completely in upper and lower case
+func caseVariants(f string) []string {
+	dir, base := filepath.Split(f)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	variants := make([]string, 0, 5)
+	seen := make(map[string]bool, 5)
+	for _, v := range []string{base, name + strings.ToUpper(ext), name + strings.ToLower(ext), strings.ToUpper(base), strings.ToLower(base)} {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		variants = append(variants, dir+v)
+	}
+	return variants
+}
+
+// listDir returns the file names of the directory and whether they were taken from the cache.
+// Listings are cached for dirCacheTTL, refresh reads the directory again.
+func listDir(dir string, refresh bool) ([]string, bool, error) {
+	dirCache.Lock()
+	defer dirCache.Unlock()
+	now := time.Now()
+	if listing, ok := dirCache.listings[dir]; ok && !refresh && now.Before(listing.expires) {
+		return listing.names, true, nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, false, err
+	}
+	defer d.Close()
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return nil, false, err
+	}
+	debugf("Read %d file names of directory %s", len(names), dir)
+	// Drop expired listings, so the cache does not grow with every directory ever opened
+	for k, listing := range dirCache.listings {
+		if !now.Before(listing.expires) {
+			delete(dirCache.listings, k)
+		}
+	}
+	dirCache.listings[dir] = dirListing{names: names, expires: now.Add(dirCacheTTL)}
+	return names, false, nil
+}
@@ -0,0 +1,49 @@
+package dbase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindFileCache(t *testing.T) {
+	dir := t.TempDir()
+	lookup := filepath.Join(dir, "mixed.dbf")
+	create := func(name string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, nil, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	found, err := findFile(lookup)
+	if err != nil || found != "" {
+		t.Fatalf("expected no file, got %q: %v", found, err)
+	}
+	// The file is created while the listing without it is cached
+	created := create("MiXed.Dbf")
+	found, err = findFile(lookup)
+	if err != nil || found != created {
+		t.Fatalf("expected %q, got %q: %v", created, found, err)
+	}
+	// The file is renamed while the listing with the old name is cached
+	err = os.Remove(created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed := create("MIXed.dBF")
+	found, err = findFile(lookup)
+	if err != nil || found != renamed {
+		t.Fatalf("expected %q, got %q: %v", renamed, found, err)
+	}
+	err = os.Remove(renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err = findFile(lookup)
+	if err != nil || found != "" {
+		t.Fatalf("expected no file after removing it, got %q: %v", found, err)
+	}
+}
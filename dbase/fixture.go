@@ -0,0 +1,148 @@
+package dbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// FixtureMismatch is a difference between a fixture table and its expected JSON found by VerifyFixture
+type FixtureMismatch struct {
+	Row      int         // Position of the row, -1 if the number of rows differs
+	Column   string      // Column name, or VirtualDeleted for the deleted flag
+	Expected interface{} // Expected value as decoded from the JSON
+	Actual   interface{} // Value read from the table, as rendered by Row.ToJSON
+}
+
+func (m FixtureMismatch) String() string {
+	if m.Row < 0 {
+		return fmt.Sprintf("expected %v rows, got %v", m.Expected, m.Actual)
+	}
+	return fmt.Sprintf("row %d column %s: expected %v, got %v", m.Row, m.Column, m.Expected, m.Actual)
+}
+
+// fixtureConfig returns the config fixtures are opened with, values are trimmed and the deleted flag is added
+func fixtureConfig(path string) *Config {
+	return &Config{
+		Filename:       path,
+		ReadOnly:       true,
+		Untested:       true,
+		TrimSpaces:     true,
+		VirtualColumns: true,
+	}
+}
+
+// FixtureJSON returns the rows of the table as JSON array in the form VerifyFixture expects, e.g. to record the expectation
+// of a table created by Visual FoxPro. Values are trimmed and rendered like Row.ToJSON, the deleted flag is included as VirtualDeleted.
+// The expectation shows how go-dbase reads the table, so check it against the values Visual FoxPro shows before relying on it.
+func FixtureJSON(path string) ([]byte, error) {
+	file, err := OpenTable(fixtureConfig(path))
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	defer file.Close()
+	rows, err := fixtureRows(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	b, err := json.MarshalIndent(rows, "", "  ")
+	if err != nil {
+		return nil, NewError("failed to marshal fixture").Details(err)
+	}
+	return b, nil
+}
+
+// VerifyFixture reads the table at the path and compares it with the expected JSON array of rows, see FixtureJSON.
+// Only the columns present in an expected row are compared, so an expectation can be limited to the types and flags
+// a user relies on. Numbers are compared by value. Returns the mismatches, none if the table matches.
+func VerifyFixture(path string, expectedJSON []byte) ([]FixtureMismatch, error) {
+	decoder := json.NewDecoder(bytes.NewReader(expectedJSON))
+	decoder.UseNumber()
+	expected := make([]map[string]interface{}, 0)
+	err := decoder.Decode(&expected)
+	if err != nil {
+		return nil, NewError("failed to decode expected JSON").Details(err)
+	}
+	file, err := OpenTable(fixtureConfig(path))
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	defer file.Close()
+	actual, err := fixtureRows(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	mismatches := make([]FixtureMismatch, 0)
+	if len(expected) != len(actual) {
+		mismatches = append(mismatches, FixtureMismatch{Row: -1, Expected: len(expected), Actual: len(actual)})
+	}
+	for i := 0; i < len(expected) && i < len(actual); i++ {
+		columns := make([]string, 0, len(expected[i]))
+		for column := range expected[i] {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		for _, column := range columns {
+			want := expected[i][column]
+			got, ok := actual[i][column]
+			if !ok {
+				mismatches = append(mismatches, FixtureMismatch{Row: i, Column: column, Expected: want, Actual: "missing column"})
+				continue
+			}
+			if !fixtureEqual(want, got) {
+				mismatches = append(mismatches, FixtureMismatch{Row: i, Column: column, Expected: want, Actual: got})
+			}
+		}
+	}
+	debugf("Verified fixture %s - rows: %d - mismatches: %d", path, len(actual), len(mismatches))
+	return mismatches, nil
+}
+
+// fixtureRows returns all rows of the table as rendered by Row.ToJSON, including deleted rows
+func fixtureRows(file *File) ([]map[string]interface{}, error) {
+	rows := make([]map[string]interface{}, 0, file.header.RowsCount)
+	for position := uint32(0); position < file.header.RowsCount; position++ {
+		data, err := file.ReadRow(position)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		row, err := file.BytesToRow(data)
+		if err != nil {
+			return nil, NewErrorf("failed to convert row %d", position).Details(err)
+		}
+		row.Position = position
+		b, err := row.ToJSON()
+		if err != nil {
+			return nil, NewErrorf("failed to convert row %d", position).Details(err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+		m := make(map[string]interface{})
+		err = decoder.Decode(&m)
+		if err != nil {
+			return nil, NewErrorf("failed to decode row %d", position).Details(err)
+		}
+		// The offset and position are given by the order of the rows
+		delete(m, VirtualOffset)
+		delete(m, VirtualPosition)
+		rows = append(rows, m)
+	}
+	return rows, nil
+}
+
+// fixtureEqual compares two decoded JSON values, numbers are compared by value
+func fixtureEqual(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, aerr := an.Float64()
+		bf, berr := bn.Float64()
+		if aerr == nil && berr == nil {
+			return af == bf
+		}
+		return an == bn
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,105 @@
+//go:build fixtures
+
+package dbase_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+)
+
+// fixtureCorpus is the directory of the example tables and their expectations.
+// Each table TABLE.DBF is verified against TABLE.fixture.json next to it.
+//
+// The expectations were recorded with FixtureJSON, not by Visual FoxPro, and some rows of the tables were written
+// by go-dbase. So the fixtures detect regressions of how the tables are read, they do not prove that the values
+// match what Visual FoxPro 9 reads. That requires tables and expectations recorded by Visual FoxPro 9 itself.
+const fixtureCorpus = "../examples/test_data"
+
+// fixtureCoverage are the type and column flag combinations the corpus covers, in the form type/flag
+var fixtureCoverage = []string{
+	"B/04", "C/00", "D/00", "F/00", "I/04", "I/0c", "L/00", "M/00",
+	"N/00", "Q/06", "T/04", "V/00", "V/02", "W/04", "Y/04",
+}
+
+// fixtureGaps are combinations of supported types and flags no table of the corpus covers:
+// general and picture columns, binary character, memo and varchar columns and all other nullable columns
+var fixtureGaps = []string{
+	"G/04", "P/04", "W/06", "C/04", "C/06", "C/02", "M/04", "V/06", "Q/04", "Q/02",
+	"B/06", "D/02", "F/02", "I/06", "L/02", "M/06", "N/02", "T/06", "Y/06",
+}
+
+// fixtures returns the tables of the corpus mapped to their expectations
+func fixtures(t *testing.T) map[string]string {
+	t.Helper()
+	expectations, err := filepath.Glob(filepath.Join(fixtureCorpus, "*", "*.fixture.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expectations) == 0 {
+		t.Fatalf("no fixtures found in %s", fixtureCorpus)
+	}
+	tables := make(map[string]string, len(expectations))
+	for _, expectation := range expectations {
+		base := strings.TrimSuffix(expectation, ".fixture.json")
+		table := ""
+		for _, ext := range []string{".DBF", ".dbf"} {
+			if _, err := os.Stat(base + ext); err == nil {
+				table = base + ext
+				break
+			}
+		}
+		if table == "" {
+			t.Fatalf("no table found for fixture %s", expectation)
+		}
+		tables[table] = expectation
+	}
+	return tables
+}
+
+func TestVerifyFixtures(t *testing.T) {
+	for table, expectation := range fixtures(t) {
+		table, expectation := table, expectation
+		t.Run(filepath.Base(table), func(t *testing.T) {
+			expected, err := os.ReadFile(expectation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mismatches, err := dbase.VerifyFixture(table, expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, mismatch := range mismatches {
+				t.Error(mismatch)
+			}
+		})
+	}
+}
+
+func TestFixtureCoverage(t *testing.T) {
+	covered := make(map[string]bool)
+	for table := range fixtures(t) {
+		file, err := dbase.OpenTable(&dbase.Config{Filename: table, ReadOnly: true, Untested: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, column := range file.Columns() {
+			covered[fmt.Sprintf("%s/%02x", column.Type(), column.Flag)] = true
+		}
+		file.Close()
+	}
+	for _, combination := range fixtureCoverage {
+		if !covered[combination] {
+			t.Errorf("no fixture covers the column type and flag %s", combination)
+		}
+	}
+	for _, combination := range fixtureGaps {
+		if covered[combination] {
+			t.Errorf("a fixture covers the column type and flag %s, move it from the gaps to the coverage", combination)
+		}
+	}
+}
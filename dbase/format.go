@@ -0,0 +1,146 @@
+package dbase
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatValue returns the text representation of a value of the column using the format of the config,
+// e.g. to export rows as CSV or SQL. Null values are returned as an empty string and binary data encoded
+// as configured by the binary format, base64 by default.
+func (file *File) FormatValue(column *Column, value interface{}) string {
+	switch v := file.formatValue(column, value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case json.Number:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatValue applies the format of the config to the value, numbers with fixed decimals are returned as json.Number
+func (file *File) formatValue(column *Column, value interface{}) interface{} {
+	format := file.config.Format
+	switch v := value.(type) {
+	case time.Time:
+		layout := format.DateLayout
+		if DataType(column.DataType) == DateTime {
+			layout = format.DateTimeLayout
+		}
+		if len(layout) == 0 {
+			return v
+		}
+		if IsEmptyDate(v) {
+			return ""
+		}
+		return v.Format(layout)
+	case float64:
+		if !format.ColumnDecimals {
+			return v
+		}
+		return json.Number(strconv.FormatFloat(v, 'f', columnDecimals(column), 64))
+	case float32:
+		if !format.ColumnDecimals {
+			return v
+		}
+		return json.Number(strconv.FormatFloat(float64(v), 'f', columnDecimals(column), 32))
+	case bool:
+		if len(format.True) == 0 && len(format.False) == 0 {
+			return v
+		}
+		if v {
+			return format.True
+		}
+		return format.False
+	case []byte:
+		if v == nil {
+			return nil
+		}
+		return file.encodeBinary(v)
+	}
+	return value
+}
+
+// encodeBinary encodes binary data as text using the binary format of the config
+func (file *File) encodeBinary(data []byte) string {
+	format := file.config.Format
+	encoding := format.Binary
+	if encoding != BinaryHex {
+		encoding = BinaryBase64
+	}
+	text := base64.StdEncoding.EncodeToString(data)
+	if encoding == BinaryHex {
+		text = hex.EncodeToString(data)
+	}
+	if format.BinaryMarker {
+		return string(encoding) + ":" + text
+	}
+	return text
+}
+
+// decodeBinary decodes binary data encoded as text, the reverse of encodeBinary. Text prefixed with
+// an encoding marker is decoded using the encoding of the marker, other text using the binary encoding of the config.
+func (file *File) decodeBinary(text string) ([]byte, error) {
+	encoding := file.config.Format.Binary
+	if encoding != BinaryHex {
+		encoding = BinaryBase64
+	}
+	for _, e := range []BinaryEncoding{BinaryBase64, BinaryHex} {
+		if strings.HasPrefix(text, string(e)+":") {
+			encoding, text = e, strings.TrimPrefix(text, string(e)+":")
+			break
+		}
+	}
+	if encoding == BinaryHex {
+		data, err := hex.DecodeString(text)
+		if err != nil {
+			return nil, NewError("invalid hex encoded binary data").Details(err)
+		}
+		return data, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return nil, NewError("invalid base64 encoded binary data").Details(err)
+	}
+	return data, nil
+}
+
+// format applies the format of the config to the values of the row in the map returned by ToMap
+func (row *Row) format(m map[string]interface{}) {
+	if row.handle.config.Format == (Format{}) {
+		return
+	}
+	for i, field := range row.fields {
+		key := field.Name()
+		if i < len(row.handle.table.mods) && row.handle.table.mods[i] != nil && len(row.handle.table.mods[i].ExternalKey) != 0 {
+			key = row.handle.table.mods[i].ExternalKey
+		}
+		if value, ok := m[key]; ok {
+			m[key] = row.handle.formatValue(field.column, value)
+		}
+	}
+}
+
+// columnDecimals returns the decimals of floating point values of the column, currency values have four
+func columnDecimals(column *Column) int {
+	if DataType(column.DataType) == Currency {
+		return 4
+	}
+	return int(column.Decimals)
+}
@@ -1,6 +1,24 @@
 package dbase
 
-import "time"
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Layout of the DBF header in the file, the column definitions follow at offset 32.
+// The header is encoded using these offsets, so the field order of the Header struct does not matter.
+const (
+	headerSize             = 32 // Size of the header in bytes
+	headerFileTypeOffset   = 0  // File type flag (1 byte)
+	headerDateOffset       = 1  // Last update year, month and day (3 bytes)
+	headerRowsCountOffset  = 4  // Number of rows (4 bytes)
+	headerFirstRowOffset   = 8  // Position of the first row (2 bytes)
+	headerRowLengthOffset  = 10 // Length of one row (2 bytes)
+	headerReservedOffset   = 12 // Reserved (16 bytes)
+	headerTableFlagsOffset = 28 // Table flags (1 byte)
+	headerCodePageOffset   = 29 // Code page mark (1 byte)
+	headerReserved2Offset  = 30 // Reserved (2 bytes)
+)
 
 // Containing DBF header information like dBase FileType, last change and rows count.
 // https://docs.microsoft.com/en-us/previous-versions/visualstudio/foxpro/st4a0s68(v=vs.80)#table-header-record-structure
@@ -15,6 +33,46 @@ type Header struct {
 	Reserved   [16]byte // Reserved
 	TableFlags byte     // Table flags
 	CodePage   byte     // Code page mark
+	Reserved2  [2]byte  // Reserved, the last two bytes of the header
+}
+
+// decodeHeader parses the header from its 32 byte representation.
+// Integers in table files are stored with the least significant byte first.
+func decodeHeader(b []byte) (*Header, error) {
+	if len(b) < headerSize {
+		return nil, NewErrorf("invalid header size %d bytes, expected %d bytes", len(b), headerSize).Details(ErrIncomplete)
+	}
+	h := &Header{
+		FileType:   b[headerFileTypeOffset],
+		Year:       b[headerDateOffset],
+		Month:      b[headerDateOffset+1],
+		Day:        b[headerDateOffset+2],
+		RowsCount:  binary.LittleEndian.Uint32(b[headerRowsCountOffset:]),
+		FirstRow:   binary.LittleEndian.Uint16(b[headerFirstRowOffset:]),
+		RowLength:  binary.LittleEndian.Uint16(b[headerRowLengthOffset:]),
+		TableFlags: b[headerTableFlagsOffset],
+		CodePage:   b[headerCodePageOffset],
+	}
+	copy(h.Reserved[:], b[headerReservedOffset:headerTableFlagsOffset])
+	copy(h.Reserved2[:], b[headerReserved2Offset:headerSize])
+	return h, nil
+}
+
+// encode returns the 32 byte representation of the header
+func (h *Header) encode() []byte {
+	b := make([]byte, headerSize)
+	b[headerFileTypeOffset] = h.FileType
+	b[headerDateOffset] = h.Year
+	b[headerDateOffset+1] = h.Month
+	b[headerDateOffset+2] = h.Day
+	binary.LittleEndian.PutUint32(b[headerRowsCountOffset:], h.RowsCount)
+	binary.LittleEndian.PutUint16(b[headerFirstRowOffset:], h.FirstRow)
+	binary.LittleEndian.PutUint16(b[headerRowLengthOffset:], h.RowLength)
+	copy(b[headerReservedOffset:headerTableFlagsOffset], h.Reserved[:])
+	b[headerTableFlagsOffset] = h.TableFlags
+	b[headerCodePageOffset] = h.CodePage
+	copy(b[headerReserved2Offset:headerSize], h.Reserved2[:])
+	return b
 }
 
 // The raw header of the Memo file.
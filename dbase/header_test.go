@@ -0,0 +1,133 @@
+package dbase
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+// The fields of the Header struct are in the order and at the offsets of the file layout.
+// Each assertion fails to compile if the offset of the field differs from the layout.
+var (
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.FileType)-headerFileTypeOffset]
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.Year)-headerDateOffset]
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.Month)-(headerDateOffset+1)]
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.Day)-(headerDateOffset+2)]
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.RowsCount)-headerRowsCountOffset]
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.FirstRow)-headerFirstRowOffset]
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.RowLength)-headerRowLengthOffset]
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.Reserved)-headerReservedOffset]
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.TableFlags)-headerTableFlagsOffset]
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.CodePage)-headerCodePageOffset]
+	_ = [1]struct{}{}[unsafe.Offsetof(Header{}.Reserved2)-headerReserved2Offset]
+	_ = [1]struct{}{}[unsafe.Sizeof(Header{})-headerSize]
+)
+
+func TestHeaderLayout(t *testing.T) {
+	offsets := map[string]int{
+		"headerSize":             headerSize,
+		"headerFileTypeOffset":   headerFileTypeOffset,
+		"headerDateOffset":       headerDateOffset,
+		"headerRowsCountOffset":  headerRowsCountOffset,
+		"headerFirstRowOffset":   headerFirstRowOffset,
+		"headerRowLengthOffset":  headerRowLengthOffset,
+		"headerReservedOffset":   headerReservedOffset,
+		"headerTableFlagsOffset": headerTableFlagsOffset,
+		"headerCodePageOffset":   headerCodePageOffset,
+		"headerReserved2Offset":  headerReserved2Offset,
+	}
+	expected := map[string]int{
+		"headerSize":             32,
+		"headerFileTypeOffset":   0,
+		"headerDateOffset":       1,
+		"headerRowsCountOffset":  4,
+		"headerFirstRowOffset":   8,
+		"headerRowLengthOffset":  10,
+		"headerReservedOffset":   12,
+		"headerTableFlagsOffset": 28,
+		"headerCodePageOffset":   29,
+		"headerReserved2Offset":  30,
+	}
+	for name, offset := range expected {
+		if offsets[name] != offset {
+			t.Errorf("%s is %d, expected %d", name, offsets[name], offset)
+		}
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	// Every byte of the header has a different value, so a field read from the wrong offset is detected
+	raw := make([]byte, headerSize)
+	for i := range raw {
+		raw[i] = byte(0xA0 + i)
+	}
+	header, err := decodeHeader(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Header{
+		FileType:   0xA0,
+		Year:       0xA1,
+		Month:      0xA2,
+		Day:        0xA3,
+		RowsCount:  0xA7A6A5A4,
+		FirstRow:   0xA9A8,
+		RowLength:  0xABAA,
+		Reserved:   [16]byte{0xAC, 0xAD, 0xAE, 0xAF, 0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5, 0xB6, 0xB7, 0xB8, 0xB9, 0xBA, 0xBB},
+		TableFlags: 0xBC,
+		CodePage:   0xBD,
+		Reserved2:  [2]byte{0xBE, 0xBF},
+	}
+	if !reflect.DeepEqual(header, expected) {
+		t.Errorf("decoded header %+v, expected %+v", header, expected)
+	}
+	if encoded := header.encode(); !bytes.Equal(encoded, raw) {
+		t.Errorf("encoded header % x, expected % x", encoded, raw)
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	header := &Header{
+		FileType:   byte(FoxProVar),
+		Year:       24,
+		Month:      12,
+		Day:        31,
+		RowsCount:  0x01020304,
+		FirstRow:   0x0506,
+		RowLength:  0x0708,
+		TableFlags: byte(StructuralFlag | MemoFlag),
+		CodePage:   0x03,
+	}
+	header.Reserved[0], header.Reserved[15] = 0x11, 0x22
+	header.Reserved2[0], header.Reserved2[1] = 0x33, 0x44
+	encoded := header.encode()
+	expected := []byte{
+		0x32, 24, 12, 31, // File type and date
+		0x04, 0x03, 0x02, 0x01, // Rows count
+		0x06, 0x05, // First row
+		0x08, 0x07, // Row length
+		0x11, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x22, // Reserved
+		0x03,       // Table flags
+		0x03,       // Code page
+		0x33, 0x44, // Reserved
+	}
+	if !bytes.Equal(encoded, expected) {
+		t.Errorf("encoded header % x, expected % x", encoded, expected)
+	}
+	decoded, err := decodeHeader(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, header) {
+		t.Errorf("decoded header %+v, expected %+v", decoded, header)
+	}
+}
+
+func TestDecodeHeaderIncomplete(t *testing.T) {
+	_, err := decodeHeader(make([]byte, headerSize-2))
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("decoding a header of 30 bytes returned %v, expected ErrIncomplete", err)
+	}
+}
@@ -0,0 +1,400 @@
+package dbase
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultImportBatchSize is the number of rows written at once if no batch size is configured
+const defaultImportBatchSize = 1000
+
+// ImportOptions control how records are imported into a table
+type ImportOptions struct {
+	BatchSize         int  // Number of rows written while holding the file mutex, defaults to 1000
+	StopOnError       bool // Stop at the first invalid record instead of skipping it
+	IgnoreUnknownKeys bool // Keys without a matching column are ignored instead of rejecting the record
+}
+
+// ImportError describes why a record could not be imported
+type ImportError struct {
+	Line   int    // Line of the record in NDJSON or CSV input or the position of the element in a JSON array, starting at 1
+	Column string // Name of the column that could not be set, empty if the whole record is invalid
+	Err    error  // Cause of the error
+}
+
+func (e *ImportError) Error() string {
+	if len(e.Column) == 0 {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d column %s: %v", e.Line, e.Column, e.Err)
+}
+
+// ImportReport contains the result of an import
+type ImportReport struct {
+	Imported int            // Number of rows appended to the table
+	Errors   []*ImportError // Records that were skipped
+}
+
+// ImportNDJSON appends the JSON objects read from r as new rows to the table.
+// The input is either newline delimited JSON (one object per line) or a JSON array of objects.
+// Keys are matched to columns by column name, external key of a modification or case insensitive column name,
+// values are converted to the data type of the column and binary values are decoded as ToJSON encodes them.
+// Invalid records are skipped and reported, unless StopOnError is set. An error is only returned if reading the input or writing the table fails.
+func (file *File) ImportNDJSON(r io.Reader, options ImportOptions) (*ImportReport, error) {
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultImportBatchSize
+	}
+	report := &ImportReport{Errors: make([]*ImportError, 0)}
+	batch := make([]*Row, 0, options.BatchSize)
+	add := func(line int, record map[string]interface{}, derr error) (bool, error) {
+		if derr != nil {
+			report.Errors = append(report.Errors, &ImportError{Line: line, Err: NewError("invalid JSON record").Details(derr)})
+			return !options.StopOnError, nil
+		}
+		row, ierr := file.importRow(line, record, options)
+		if ierr != nil {
+			report.Errors = append(report.Errors, ierr)
+			return !options.StopOnError, nil
+		}
+		batch = append(batch, row)
+		if len(batch) < options.BatchSize {
+			return true, nil
+		}
+		err := file.importBatch(batch, report)
+		batch = batch[:0]
+		return err == nil, err
+	}
+	reader := bufio.NewReader(r)
+	first, err := peekNonSpace(reader)
+	if err != nil && err != io.EOF {
+		return report, NewError("failed to read import data").Details(err)
+	}
+	if first == '[' {
+		err = importJSONArray(reader, add)
+	} else {
+		err = importNDJSON(reader, add)
+	}
+	if err != nil {
+		return report, WrapError(err)
+	}
+	err = file.importBatch(batch, report)
+	if err != nil {
+		return report, WrapError(err)
+	}
+	return report, nil
+}
+
+// importNDJSON decodes one JSON object per line, empty lines are skipped
+func importNDJSON(reader *bufio.Reader, add func(int, map[string]interface{}, error) (bool, error)) error {
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return NewError("failed to read import data").Details(err)
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			record, uerr := decodeRecord(data)
+			next, aerr := add(line, record, uerr)
+			if aerr != nil || !next {
+				return aerr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// importJSONArray decodes the elements of a JSON array, a syntax error stops the import
+func importJSONArray(reader *bufio.Reader, add func(int, map[string]interface{}, error) (bool, error)) error {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	if _, err := decoder.Token(); err != nil {
+		return NewError("failed to read JSON array").Details(err)
+	}
+	for line := 1; decoder.More(); line++ {
+		var element interface{}
+		if err := decoder.Decode(&element); err != nil {
+			return NewErrorf("failed to decode element %d of JSON array", line).Details(err)
+		}
+		record, _ := element.(map[string]interface{})
+		next, err := add(line, record, nil)
+		if err != nil || !next {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeRecord decodes a JSON object, numbers are kept as json.Number so integers above 2^53 are not rounded
+func decodeRecord(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	record := make(map[string]interface{})
+	err := decoder.Decode(&record)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, NewError("unexpected data behind the JSON object")
+	}
+	return record, nil
+}
+
+// peekNonSpace returns the first byte of the input that is not white space without consuming it
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b[0])) {
+			return b[0], nil
+		}
+		if _, err := reader.Discard(1); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// importRow converts the record into a new row
+func (file *File) importRow(line int, record map[string]interface{}, options ImportOptions) (*Row, *ImportError) {
+	if record == nil {
+		return nil, &ImportError{Line: line, Err: NewError("record is not a JSON object")}
+	}
+	row := file.NewRow()
+	for key, val := range record {
+		pos := file.importColumnPos(key)
+		if pos < 0 {
+			if options.IgnoreUnknownKeys {
+				continue
+			}
+			return nil, &ImportError{Line: line, Column: key, Err: NewError("no matching column found")}
+		}
+		column := file.table.columns[pos]
+		value, err := file.coerceValue(column, val)
+		if err != nil {
+			return nil, &ImportError{Line: line, Column: column.Name(), Err: err}
+		}
+		row.fields[pos].value = value
+	}
+	return row, nil
+}
+
+// SetFromMap sets the values of the row, keyed by column name, external key or case insensitive column name.
+// Decoded JSON values are converted to the data type of the column like ImportNDJSON does,
+// e.g. numbers to integers and RFC3339 strings to dates. Columns without a value keep their current value.
+func (row *Row) SetFromMap(values map[string]interface{}) error {
+	for key, val := range values {
+		pos := row.handle.importColumnPos(key)
+		if pos < 0 {
+			return NewErrorf("column '%s' not found", key)
+		}
+		column := row.handle.table.columns[pos]
+		value, err := row.handle.coerceValue(column, val)
+		if err != nil {
+			return NewErrorf("invalid value for column %s", column.Name()).Details(err)
+		}
+		row.fields[pos].value = value
+	}
+	return nil
+}
+
+// importColumnPos returns the position of the column by name, external key or case insensitive name or -1 if not found
+func (file *File) importColumnPos(key string) int {
+	if pos := file.columnPosByKey(key); pos >= 0 {
+		return pos
+	}
+	for i, column := range file.table.columns {
+		if strings.EqualFold(column.Name(), key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// importBatch appends the rows while holding the file mutex
+func (file *File) importBatch(rows []*Row, report *ImportReport) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	debugf("Importing batch of %d rows", len(rows))
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	for _, row := range rows {
+		row.Position = file.header.RowsCount
+		err := row.write()
+		if err != nil {
+			return NewErrorf("failed to import row %d", report.Imported+1).Details(err)
+		}
+		report.Imported++
+	}
+	return file.commit(nil)
+}
+
+// coerceValue converts a decoded JSON or text value to the value type of the column.
+// Strings of binary columns are decoded like ToJSON encodes them, base64 unless configured otherwise by Format.Binary.
+func (file *File) coerceValue(column *Column, val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+	s, isString := val.(string)
+	switch DataType(column.DataType) {
+	case Character, Varchar, Memo:
+		if !isString {
+			if f, ok := val.(float64); ok {
+				s = strconv.FormatFloat(f, 'f', -1, 64)
+			} else {
+				s = fmt.Sprint(val)
+			}
+		}
+		if DataType(column.DataType) == Memo {
+			return s, nil
+		}
+		// The length is checked encoded, characters of multi-byte code pages take several bytes
+		encoded := []byte(s)
+		if !column.Binary() {
+			var err error
+			encoded, err = fromUtf8String(encoded, file.converter(column))
+			if err != nil {
+				return nil, NewError("failed to encode value").Details(err)
+			}
+		}
+		if len(encoded) > column.Size() {
+			return nil, NewErrorf("value of %d bytes exceeds the column size of %d bytes", len(encoded), column.Size())
+		}
+		return s, nil
+	case Blob, Varbinary, General, Picture:
+		if b, ok := val.([]byte); ok {
+			return b, nil
+		}
+		if isString {
+			return file.decodeBinary(s)
+		}
+	case Numeric:
+		// Strings of numeric columns read as strings keep their leading zeros
+		if isString && file.table.numericStrings[column] {
+			s = strings.TrimSpace(s)
+			if len(s) > 0 && !isNumericString(s) {
+				return nil, NewErrorf("invalid value %q, expected digits", s)
+			}
+			if len(s) > int(column.Length) {
+				return nil, NewErrorf("value of %d digits exceeds the column length %d", len(s), column.Length)
+			}
+			return s, nil
+		}
+		if column.Decimals > 0 {
+			return coerceFloat(val)
+		}
+		return coerceInt(val)
+	case Float, Double, Currency:
+		return coerceFloat(val)
+	case Integer:
+		i, err := coerceInt(val)
+		if err != nil {
+			return nil, err
+		}
+		if i < MinIntegerValue || i > MaxIntegerValue {
+			return nil, NewErrorf("invalid value %v, expected a 32 bit integer", val)
+		}
+		return int32(i), nil
+	case Logical:
+		if b, ok := val.(bool); ok {
+			return b, nil
+		}
+		if isString {
+			switch strings.ToUpper(strings.TrimSpace(s)) {
+			case "T", "Y", "TRUE", "YES", "1":
+				return true, nil
+			case "F", "N", "FALSE", "NO", "0", "":
+				return false, nil
+			}
+		}
+	case Date, DateTime:
+		if t, ok := val.(time.Time); ok {
+			return t, nil
+		}
+		if isString {
+			return coerceTime(s)
+		}
+	default:
+		return nil, NewErrorf("unsupported data type %q", column.DataType).Details(ErrUnknownDataType)
+	}
+	return nil, NewErrorf("invalid value of type %T for data type %v", val, DataType(column.DataType))
+}
+
+// coerceFloat converts a number or numeric string to float64
+func coerceFloat(val interface{}) (float64, error) {
+	switch v := val.(type) {
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, NewErrorf("invalid number %q", v).Details(err)
+		}
+		return f, nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, NewErrorf("invalid number %q", v).Details(err)
+		}
+		return f, nil
+	default:
+		f, err := aggregateFloat(val)
+		if err != nil {
+			return 0, WrapError(err)
+		}
+		return f, nil
+	}
+}
+
+// coerceInt converts a number or numeric string to int64, integers are parsed exactly instead of through float64
+func coerceInt(val interface{}) (int64, error) {
+	switch v := val.(type) {
+	case int64:
+		return v, nil
+	case int32:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return i, nil
+		}
+	case string:
+		if i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			return i, nil
+		}
+	}
+	// Numbers in exponent notation or with a zero fraction, e.g. 1e3 or 3.0
+	f, err := coerceFloat(val)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, NewErrorf("invalid value %v, expected an integer", val)
+	}
+	return int64(f), nil
+}
+
+// importTimeLayouts are the accepted formats of date and time strings
+var importTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02", "20060102"}
+
+// coerceTime parses a date or time string in one of the import time layouts
+func coerceTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, NewErrorf("invalid date %q, expected RFC 3339 or YYYY-MM-DD", s)
+}
@@ -0,0 +1,174 @@
+package dbase
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// newImportTable creates a table with a big numeric, an integer and a short character column
+func newImportTable(t *testing.T) *File {
+	t.Helper()
+	big, err := NewColumn("BIG", Numeric, 20, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := NewColumn("ID", Integer, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := NewColumn("NAME", Character, 5, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Filename: filepath.Join(t.TempDir(), "IMPORT.DBF"), Converter: NewDefaultConverter(charmap.Windows1252), TrimSpaces: true}
+	file, err := NewTable(FoxProVar, config, []*Column{big, id, name}, 64, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func TestImportNDJSONLargeIntegers(t *testing.T) {
+	for _, input := range []string{
+		`{"BIG": 9007199254740993, "ID": 2147483647}`,
+		`[{"BIG": 9007199254740993, "ID": 2147483647}]`,
+	} {
+		file := newImportTable(t)
+		report, err := file.ImportNDJSON(strings.NewReader(input), ImportOptions{StopOnError: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Imported != 1 {
+			t.Fatalf("expected 1 imported row, got %d: %v", report.Imported, report.Errors)
+		}
+		err = file.GoTo(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row, err := file.Row()
+		if err != nil {
+			t.Fatal(err)
+		}
+		big, err := row.ValueByName("BIG")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if big != int64(9007199254740993) {
+			t.Errorf("%s: expected BIG 9007199254740993, got %v", input, big)
+		}
+		id, err := row.ValueByName("ID")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != int32(2147483647) {
+			t.Errorf("%s: expected ID 2147483647, got %v", input, id)
+		}
+	}
+}
+
+func TestImportNDJSONErrors(t *testing.T) {
+	file := newImportTable(t)
+	input := strings.Join([]string{
+		`{"ID": 1, "NAME": "ABCDE"}`,
+		`{"ID": 2, "NAME" "X"}`,
+		`{"ID": 3, "NAME": "ABCDEF"}`,
+		`{"ID": 1.5}`,
+	}, "\n")
+	report, err := file.ImportNDJSON(strings.NewReader(input), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 1 {
+		t.Errorf("expected 1 imported row, got %d", report.Imported)
+	}
+	if len(report.Errors) != 3 {
+		t.Fatalf("expected 3 errors, got %v", report.Errors)
+	}
+	var syntax *json.SyntaxError
+	if report.Errors[0].Line != 2 || !errors.As(report.Errors[0].Err, &syntax) {
+		t.Errorf("expected the JSON syntax error of line 2, got %v", report.Errors[0])
+	}
+	if report.Errors[1].Line != 3 || report.Errors[1].Column != "NAME" {
+		t.Errorf("expected the length error of line 3, got %v", report.Errors[1])
+	}
+	if report.Errors[2].Line != 4 || report.Errors[2].Column != "ID" {
+		t.Errorf("expected the integer error of line 4, got %v", report.Errors[2])
+	}
+}
+
+func TestImportNDJSONColumnSize(t *testing.T) {
+	long, err := NewColumn("LONG", Character, 254, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Clipper stores the high byte of the length of character columns longer than 255 bytes in the decimals
+	long.Length, long.Decimals = 44, 1
+	text, err := NewColumn("TEXT", Character, 4, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := NewColumn("CODE", Numeric, 5, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Filename: filepath.Join(t.TempDir(), "SIZE.DBF"), Converter: NewDefaultConverter(charmap.Windows1252), TrimSpaces: true, ExtendedCharacterColumns: true}
+	file, err := NewTable(FoxBasePlus, config, []*Column{long, text, code}, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	err = file.SetColumnConverter("TEXT", NewDefaultConverter(simplifiedchinese.GBK))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.SetNumericAsString("CODE", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{
+		`{"LONG": "` + strings.Repeat("L", 300) + `", "TEXT": "中文", "CODE": "00123"}`,
+		`{"LONG": "` + strings.Repeat("L", 301) + `"}`,
+		`{"TEXT": "中文字"}`,
+		`{"CODE": "12A"}`,
+	}, "\n")
+	report, err := file.ImportNDJSON(strings.NewReader(input), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 1 {
+		t.Errorf("expected 1 imported row, got %d", report.Imported)
+	}
+	if len(report.Errors) != 3 {
+		t.Fatalf("expected 3 errors, got %v", report.Errors)
+	}
+	for i, column := range []string{"LONG", "TEXT", "CODE"} {
+		if report.Errors[i].Line != i+2 || report.Errors[i].Column != column {
+			t.Errorf("expected the error of column %s in line %d, got %v", column, i+2, report.Errors[i])
+		}
+	}
+	err = file.GoTo(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := file.Row()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := row.ToMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"LONG": strings.Repeat("L", 300), "TEXT": "中文", "CODE": "00123"}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("expected %s %q, got %q", name, value, values[name])
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package dbase
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The structural compound index (CDX) of a table has the name of the table and is opened with it by FoxPro.
+// The header flag StructuralFlag marks that a table has a structural index, FoxPro refuses to open a flagged table whose index is missing.
+
+// Options of the tag directory of a compound index file
+const cdxTagDirectory = 0x80
+
+// IndexTag describes a tag of the structural compound index of a table, see File.Indexes
+type IndexTag struct {
+	Name       string // Name of the tag
+	Expression string // Key expression
+	Filter     string // Expression of the FOR clause, empty if the tag contains all rows
+	Descending bool   // The keys are ordered descending
+	Unique     bool   // Only the first row of each key is contained (UNIQUE keyword)
+	Candidate  bool   // The tag is the primary or a candidate key of a table of a database
+	IgnoreCase bool   // The keys are converted to upper case
+	KeyLength  int    // Length of the keys in bytes
+}
+
+// Indexes returns the tags of the structural compound index in the order of the tag directory, nil if the table has no structural index.
+// Only the index flag of the header is checked for an index, see Config.DetectIndexes to detect an index of a table without flag.
+// The tags of a dBase IV production index (MDX) can not be read.
+func (file *File) Indexes() ([]IndexTag, error) {
+	if !StructuralFlag.Defined(file.header.TableFlags) || len(file.config.Filename) == 0 {
+		return nil, nil
+	}
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	if file.indexes != nil {
+		return indexTags(file.indexes.index), nil
+	}
+	filename, err := file.indexFilename(CDX)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if len(filename) == 0 {
+		mdx, err := file.indexFilename(MDX)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		if len(mdx) > 0 {
+			return nil, NewErrorf("the tags of the production index %s can not be read, only CDX files are supported", filepath.Base(mdx))
+		}
+		return nil, nil
+	}
+	index, err := openCDX(filename)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	defer index.Close()
+	return indexTags(index), nil
+}
+
+// indexTags returns the description of the tags of the index
+func indexTags(index *cdxIndex) []IndexTag {
+	tags := make([]IndexTag, 0, len(index.tags))
+	for _, tag := range index.tags {
+		tags = append(tags, IndexTag{
+			Name:       tag.name,
+			Expression: tag.expression,
+			Filter:     tag.filter,
+			Descending: tag.header.Descending != 0,
+			Unique:     tag.header.Options&cdxUnique != 0,
+			Candidate:  tag.header.Options&cdxCandidate != 0,
+			IgnoreCase: tag.header.IgnoreCase != 0,
+			KeyLength:  int(tag.header.KeyLength),
+		})
+	}
+	return tags
+}
+
+// indexFilename returns the path of the index file with the extension and the name of the table, empty if it does not exist
+func (file *File) indexFilename(extension FileExtension) (string, error) {
+	filename := filepath.Clean(file.config.Filename)
+	return findFile(strings.TrimSuffix(filename, filepath.Ext(filename)) + string(extension))
+}
+
+// detectIndexes corrects the index flag of the header if it disagrees with the presence of the structural index, see Config.DetectIndexes.
+// The header is only written if the table is writable.
+func (file *File) detectIndexes() error {
+	if len(file.config.Filename) == 0 {
+		return nil
+	}
+	filename, err := file.indexFilename(CDX)
+	if err != nil {
+		return WrapError(err)
+	}
+	if len(filename) == 0 {
+		filename, err = file.indexFilename(MDX)
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	flagged := StructuralFlag.Defined(file.header.TableFlags)
+	switch {
+	case len(filename) > 0 && !flagged:
+		file.warn("structural index %s found, but the index flag of the header is not set", filepath.Base(filename))
+		file.header.TableFlags |= byte(StructuralFlag)
+	case len(filename) == 0 && flagged:
+		file.warn("the index flag of the header is set, but the structural index is missing")
+		file.header.TableFlags &^= byte(StructuralFlag)
+	default:
+		debugf("Detected structural index of %s: %q", file.config.Filename, filename)
+		return nil
+	}
+	if file.config.ReadOnly {
+		return nil
+	}
+	return file.WriteHeader()
+}
+
+// CreateIndex adds a tag with the name ordering the rows by the column to the structural compound index of the table.
+// The index file is created and the index flag of the header is set if the table has no structural index yet.
+// Only tags that can be maintained are supported, see Config.MaintainIndexes, the key expression is the column name.
+// The keys of all rows are added, including deleted rows like FoxPro does. The tag is only updated when rows
+// are written if MaintainIndexes is set, otherwise it is outdated by the next write.
+func (file *File) CreateIndex(name string, column string) (err error) {
+	if file.config.ReadOnly {
+		return NewError("can not create an index of a read-only table")
+	}
+	if len(file.config.Filename) == 0 {
+		return NewError("indexes of tables without file are not supported")
+	}
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if len(name) == 0 || len(name) > MaxColumnNameLength || strings.ContainsAny(name, " \x00") {
+		return NewErrorf("invalid index tag name '%s', the name must be between 1 and 10 characters long without spaces", name)
+	}
+	pos := file.ColumnPosByName(column)
+	if pos < 0 {
+		return NewErrorf("column '%s' not found", column)
+	}
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	index, err := file.indexForWriting(true)
+	if err != nil {
+		return WrapError(err)
+	}
+	defer file.releaseIndex(index, &err)
+	for _, tag := range index.tags {
+		if tag.name == name {
+			return NewErrorf("index tag %s already exists", name)
+		}
+	}
+	tree, err := file.addTag(index, name, file.table.columns[pos])
+	if err != nil {
+		return WrapError(err)
+	}
+	if file.config.MaintainIndexes {
+		if file.indexes == nil {
+			file.indexes = &cdxIndexes{index: index}
+		}
+		file.indexes.trees = append(file.indexes.trees, tree)
+	}
+	if StructuralFlag.Defined(file.header.TableFlags) {
+		return nil
+	}
+	file.header.TableFlags |= byte(StructuralFlag)
+	return file.WriteHeader()
+}
+
+// RemoveIndex removes the tag with the name from the structural compound index of the table.
+// The nodes of the tag are not reclaimed, FoxPro reclaims them with REINDEX.
+// If the last tag is removed, the index file is deleted and the index flag of the header is cleared.
+func (file *File) RemoveIndex(name string) (err error) {
+	if file.config.ReadOnly {
+		return NewError("can not remove an index of a read-only table")
+	}
+	name = strings.ToUpper(strings.TrimSpace(name))
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	index, err := file.indexForWriting(false)
+	if err != nil {
+		return WrapError(err)
+	}
+	if index == nil {
+		return NewErrorf("index tag %s not found, the table has no structural index", name)
+	}
+	err = file.removeTag(index, name)
+	if err != nil || len(index.tags) > 0 {
+		file.releaseIndex(index, &err)
+		return err
+	}
+	// Without tags the index file is deleted, like FoxPro does with DELETE TAG ALL
+	if file.indexes != nil && file.indexes.index == index {
+		file.indexes = nil
+	}
+	filename := index.handle.Name()
+	err = index.Close()
+	if err != nil {
+		return NewError("failed to close CDX file").Details(err)
+	}
+	err = os.Remove(filename)
+	if err != nil {
+		return NewError("failed to remove CDX file").Details(err)
+	}
+	file.header.TableFlags &^= byte(StructuralFlag)
+	return file.WriteHeader()
+}
+
+// removeTag removes the tag from the tag directory and stops maintaining it
+func (file *File) removeTag(index *cdxIndex, name string) error {
+	pos := -1
+	for i, tag := range index.tags {
+		if tag.name == name {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		return NewErrorf("index tag %s not found", name)
+	}
+	tag := index.tags[pos]
+	directory := index.directoryTree()
+	err := directory.remove(cdxTagKey(index, name), uint32(tag.offset))
+	if err != nil {
+		return NewErrorf("failed to remove index tag %s from the tag directory", name).Details(err)
+	}
+	err = directory.touch()
+	if err != nil {
+		return WrapError(err)
+	}
+	index.tags = append(index.tags[:pos], index.tags[pos+1:]...)
+	if file.indexes != nil {
+		for i, tree := range file.indexes.trees {
+			if tree.tag == tag {
+				file.indexes.trees = append(file.indexes.trees[:i], file.indexes.trees[i+1:]...)
+				break
+			}
+		}
+	}
+	debugf("Removed index tag %s - remaining tags: %d", name, len(index.tags))
+	return nil
+}
+
+// indexForWriting returns the structural compound index opened for writing, the maintained index if it is open.
+// If create is set and the index file does not exist, an index without tags is created, otherwise nil is returned.
+// The index must be released with releaseIndex.
+func (file *File) indexForWriting(create bool) (*cdxIndex, error) {
+	if file.indexes != nil {
+		return file.indexes.index, nil
+	}
+	filename, err := file.indexFilename(CDX)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if len(filename) > 0 {
+		return openCDXFile(filename, os.O_RDWR)
+	}
+	if !create {
+		return nil, nil
+	}
+	// The extension has the case of the extension of the table file
+	filename, err = findFile(filepath.Clean(file.config.Filename))
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if len(filename) == 0 {
+		filename = filepath.Clean(file.config.Filename)
+	}
+	extension := filepath.Ext(filename)
+	filename = strings.TrimSuffix(filename, extension) + string(CDX)
+	if extension == strings.ToLower(extension) {
+		filename = strings.TrimSuffix(filename, string(CDX)) + strings.ToLower(string(CDX))
+	}
+	return createCDX(filename)
+}
+
+// releaseIndex closes the index unless it is maintained
+func (file *File) releaseIndex(index *cdxIndex, err *error) {
+	if index == nil || (file.indexes != nil && file.indexes.index == index) {
+		return
+	}
+	if cerr := index.Close(); cerr != nil && *err == nil {
+		*err = NewError("failed to close CDX file").Details(cerr)
+	}
+}
+
+// createCDX creates a compound index file without tags
+func createCDX(filename string) (*cdxIndex, error) {
+	debugf("Creating compound index file: %s", filename)
+	handle, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, NewError("creating CDX file failed").Details(err)
+	}
+	index := &cdxIndex{
+		handle: handle,
+		directory: &cdxHeader{
+			KeyLength:  MaxColumnNameLength,
+			Options:    cdxCompact | cdxCompound | cdxTagDirectory,
+			Signature:  1,
+			ForPos:     1,
+			ForLength:  1,
+			ExprLength: 1,
+		},
+	}
+	directory := index.directoryTree()
+	directory.allocate() // Header
+	directory.allocate() // Expression pool
+	index.directory.Root = directory.allocate()
+	err = writeCDXTag(directory, "")
+	if err != nil {
+		handle.Close()
+		os.Remove(filename)
+		return nil, WrapError(err)
+	}
+	return index, nil
+}
+
+// writeCDXTag writes the header, the expression pool and an empty root node of the tag
+func writeCDXTag(tree *cdxTree, expression string) error {
+	err := tree.writeHeader()
+	if err != nil {
+		return WrapError(err)
+	}
+	pool := make([]byte, cdxPageSize)
+	copy(pool, expression)
+	_, err = tree.index.handle.WriteAt(pool, tree.tag.offset+cdxPageSize)
+	if err != nil {
+		return NewErrorf("failed to write the expression of tag %s", tree.tag.name).Details(err)
+	}
+	root := &cdxNode{Attributes: cdxRootNode | cdxLeafNode, Left: cdxNoPage, Right: cdxNoPage}
+	tree.encode(root, nil)
+	return tree.writeNode(tree.tag.header.Root, root)
+}
+
+// addTag writes a tag on the column containing the keys of all rows and adds it to the tag directory
+func (file *File) addTag(index *cdxIndex, name string, column *Column) (*cdxTree, error) {
+	length, _, err := cdxKeyLength(column)
+	if err != nil {
+		return nil, NewErrorf("index tag %s can not be maintained", name).Details(err)
+	}
+	expression := column.Name()
+	tag := &cdxTag{
+		name:       name,
+		expression: expression,
+		header: &cdxHeader{
+			KeyLength:  uint16(length),
+			Options:    cdxCompact | cdxCompound,
+			Signature:  1,
+			ExprLength: uint16(len(expression) + 1),
+			ForPos:     uint16(len(expression) + 1),
+			ForLength:  1,
+		},
+	}
+	tree, err := file.cdxTree(index, tag)
+	if err != nil {
+		return nil, NewErrorf("index tag %s can not be maintained", name).Details(err)
+	}
+	tag.offset = int64(tree.allocate())
+	tree.allocate() // Expression pool
+	tag.header.Root = tree.allocate()
+	err = writeCDXTag(tree, expression)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	entries := make([]cdxEntry, 0, file.header.RowsCount)
+	for position := uint32(0); position < file.header.RowsCount; position++ {
+		data, err := file.ReadRow(position)
+		if err != nil {
+			return nil, NewErrorf("failed to read row %d", position).Details(err)
+		}
+		key, err := tree.key(file, data)
+		if err != nil {
+			return nil, NewErrorf("failed to compute the key of row %d", position).Details(err)
+		}
+		entries = append(entries, cdxEntry{key: key, recno: position + 1})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return compareCdxEntry(entries[i], entries[j].key, entries[j].recno) < 0
+	})
+	for _, entry := range entries {
+		err = tree.insert(entry.key, entry.recno)
+		if err != nil {
+			return nil, NewErrorf("failed to add row %d to index tag %s", entry.recno-1, name).Details(err)
+		}
+	}
+	directory := index.directoryTree()
+	err = directory.insert(cdxTagKey(index, name), uint32(tag.offset))
+	if err != nil {
+		return nil, NewErrorf("failed to add index tag %s to the tag directory", name).Details(err)
+	}
+	err = directory.touch()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	index.tags = append(index.tags, tag)
+	sort.Slice(index.tags, func(i, j int) bool {
+		return index.tags[i].name < index.tags[j].name
+	})
+	debugf("Created index tag %s on column %s with %d keys", name, column.Name(), len(entries))
+	return tree, nil
+}
+
+// directoryTree returns the tree of the tag directory, the keys are the tag names and the record numbers the offsets of the tag headers
+func (index *cdxIndex) directoryTree() *cdxTree {
+	return &cdxTree{
+		index:     index,
+		tag:       &cdxTag{header: index.directory},
+		keyLength: int(index.directory.KeyLength),
+		pad:       byte(Blank),
+	}
+}
+
+// cdxTagKey returns the key of the tag name in the tag directory
+func cdxTagKey(index *cdxIndex, name string) []byte {
+	key := bytes.Repeat([]byte{byte(Blank)}, int(index.directory.KeyLength))
+	copy(key, name)
+	return key
+}
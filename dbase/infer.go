@@ -0,0 +1,157 @@
+package dbase
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// DefaultInferenceSampleSize is the number of rows sampled by InferColumnTypes if no sample size is set
+const DefaultInferenceSampleSize = 1000
+
+// DefaultInferenceMaxDigits is the number of digits a float64 stores exactly, longer values are demoted by InferColumnTypes
+const DefaultInferenceMaxDigits = 15
+
+// InferenceOptions control how InferColumnTypes samples the values
+type InferenceOptions struct {
+	SampleSize int  // Maximum number of rows sampled, spread evenly over the table, DefaultInferenceSampleSize if zero
+	MaxDigits  int  // Values with more digits demote the column, as typed targets may not store them exactly. DefaultInferenceMaxDigits if zero
+	Apply      bool // Read demoted columns as strings, see SetNumericAsString
+}
+
+// ColumnInference is the result of InferColumnTypes for a numeric column without decimals
+type ColumnInference struct {
+	Column       string      // Name of the column
+	Samples      int         // Number of sampled values that are not empty
+	Histogram    map[int]int // Number of sampled values by their number of digits
+	LeadingZeros int         // Number of sampled values with leading zeros
+	Demote       bool        // Whether the values are identifiers that should be read as strings
+	Reason       string      // Why the column is demoted
+}
+
+// InferColumnTypes samples the numeric columns without decimals and reports which of them hold identifiers rather than numbers,
+// e.g. article numbers with leading zeros, that would be mangled when exported as integers to typed targets like Parquet or SQL.
+// A column is demoted if a sampled value has leading zeros or more than MaxDigits digits.
+// If Apply is set the demoted columns are read as strings afterwards. Deleted rows are not sampled
+// and the internal row pointer is not moved.
+func (file *File) InferColumnTypes(options InferenceOptions) ([]*ColumnInference, error) {
+	if options.SampleSize <= 0 {
+		options.SampleSize = DefaultInferenceSampleSize
+	}
+	if options.MaxDigits <= 0 {
+		options.MaxDigits = DefaultInferenceMaxDigits
+	}
+	positions := make([]int, 0)
+	inferences := make([]*ColumnInference, 0)
+	for i, column := range file.table.columns {
+		if DataType(column.DataType) != Numeric || column.Decimals != 0 {
+			continue
+		}
+		positions = append(positions, i)
+		inferences = append(inferences, &ColumnInference{Column: column.Name(), Histogram: make(map[int]int)})
+	}
+	if len(positions) == 0 {
+		return inferences, nil
+	}
+	step := uint32(1)
+	if file.header.RowsCount > uint32(options.SampleSize) {
+		step = file.header.RowsCount / uint32(options.SampleSize)
+	}
+	debugf("Sampling %d numeric columns of every %d. row", len(positions), step)
+	for position := uint32(0); position < file.header.RowsCount; position += step {
+		data, err := file.ReadRow(position)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		if Marker(data[0]) == Deleted {
+			continue
+		}
+		for i, pos := range positions {
+			layout := file.table.layout.Columns[pos]
+			inferences[i].add(data[layout.Offset : layout.Offset+layout.Length])
+		}
+	}
+	for _, inference := range inferences {
+		inference.infer(options.MaxDigits)
+		if inference.Demote && options.Apply {
+			err := file.SetNumericAsString(inference.Column, true)
+			if err != nil {
+				return nil, WrapError(err)
+			}
+		}
+		debugf("Inferred column %s - samples: %d - leading zeros: %d - demote: %v", inference.Column, inference.Samples, inference.LeadingZeros, inference.Demote)
+	}
+	return inferences, nil
+}
+
+// add counts the raw value of the column
+func (c *ColumnInference) add(raw []byte) {
+	digits := bytes.TrimSpace(raw)
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || !isNumericString(string(digits)) {
+		return
+	}
+	c.Samples++
+	c.Histogram[len(digits)]++
+	if len(digits) > 1 && digits[0] == '0' {
+		c.LeadingZeros++
+	}
+}
+
+// infer decides whether the column is demoted using the histogram
+func (c *ColumnInference) infer(maxDigits int) {
+	longest := 0
+	for digits := range c.Histogram {
+		if digits > longest {
+			longest = digits
+		}
+	}
+	switch {
+	case c.LeadingZeros > 0:
+		c.Demote = true
+		c.Reason = fmt.Sprintf("%d of %d values have leading zeros", c.LeadingZeros, c.Samples)
+	case longest > maxDigits:
+		c.Demote = true
+		c.Reason = fmt.Sprintf("values have up to %d digits", longest)
+	}
+}
+
+// SetNumericAsString reads the values of the numeric column without decimals as strings of the stored digits instead of int64,
+// so identifiers keep their leading zeros. String values are accepted when writing the column.
+func (file *File) SetNumericAsString(name string, enabled bool) error {
+	position := file.columnPosByKey(name)
+	if position < 0 {
+		return NewErrorf("column '%s' not found", name)
+	}
+	column := file.table.columns[position]
+	if DataType(column.DataType) != Numeric || column.Decimals != 0 {
+		return NewErrorf("column %s is not a numeric column without decimals", column.Name())
+	}
+	debugf("Reading column %s as string: %v", column.Name(), enabled)
+	if !enabled {
+		delete(file.table.numericStrings, column)
+		return nil
+	}
+	if file.table.numericStrings == nil {
+		file.table.numericStrings = make(map[*Column]bool)
+	}
+	file.table.numericStrings[column] = true
+	return nil
+}
+
+// isNumericString returns if the value only consists of digits, optionally with a leading sign
+func isNumericString(s string) bool {
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -1,10 +1,12 @@
 package dbase
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,6 +22,7 @@ import (
 // | ----------- | ---------------- | ----------- |
 // | B | Double | float64 |
 // | C | Character | string |
+// | C | Character (Binary) | []byte |
 // | D | Date | time.Time |
 // | F | Float | float64 |
 // | I | Integer | int32 |
@@ -33,6 +36,12 @@ import (
 //
 // Not all available column types have been implemented because we don't use them in our DBFs
 func (file *File) Interpret(raw []byte, column *Column) (interface{}, error) {
+	return file.interpret(raw, column, nil)
+}
+
+// interpret converts the raw column data using the _NullFlags data of the row.
+// If nullFlags is nil the null flags of variable length columns are read from the file at the row pointer.
+func (file *File) interpret(raw []byte, column *Column, nullFlags []byte) (interface{}, error) {
 	var funcs = map[DataType]func([]byte, *Column) (interface{}, error){
 		// M values contain the address in the FPT file from where to read data
 		Memo: file.parseMemo,
@@ -57,16 +66,20 @@ func (file *File) Interpret(raw []byte, column *Column) (interface{}, error) {
 		// N values are stored as string values, if no decimals return as int64, if decimals treat as float64
 		Numeric: file.parseNumeric,
 		// V and Q values just return the raw value
-		Varchar:   file.parseVarchar,
-		Varbinary: file.parseVarbinary,
+		Varchar: func(raw []byte, column *Column) (interface{}, error) {
+			return file.parseVarchar(raw, column, nullFlags)
+		},
+		Varbinary: func(raw []byte, column *Column) (interface{}, error) {
+			return file.parseVarbinary(raw, column, nullFlags)
+		},
 		// W, P and G values just return the raw value
 		Blob:    file.parseRaw,
 		Picture: file.parseRaw,
 		General: file.parseRaw,
 	}
 
-	if len(raw) != int(column.Length) {
-		return nil, NewErrorf("invalid length %v Bytes != %v Bytes at column field: %v", len(raw), column.Length, column.Name())
+	if len(raw) != column.Size() {
+		return nil, NewErrorf("invalid length %v Bytes != %v Bytes at column field: %v", len(raw), column.Size(), column.Name())
 	}
 
 	f, ok := funcs[DataType(column.DataType)]
@@ -113,7 +126,10 @@ func (file *File) Represent(field *Field, padding bool) ([]byte, error) {
 	}
 
 	if field.GetValue() == nil {
-		return make([]byte, field.column.Length), nil
+		if DataType(field.column.DataType) == Date {
+			return bytes.Repeat([]byte(" "), field.column.Size()), nil
+		}
+		return make([]byte, field.column.Size()), nil
 	}
 
 	f, ok := funcs[DataType(field.column.DataType)]
@@ -127,17 +143,22 @@ func (file *File) Represent(field *Field, padding bool) ([]byte, error) {
 // Returns the value from the memo file as string or []byte
 func (file *File) parseMemo(raw []byte, column *Column) (interface{}, error) {
 	// M values contain the address in the FPT file from where to read data
-	memo, isText, err := file.ReadMemo(raw)
+	converter := file.converter(column)
+	if column.Binary() {
+		converter = nil
+	}
+	memo, isText, err := file.readMemo(raw, converter)
 	if err != nil {
 		return nil, NewErrorf("parsing memo failed at column field: %v failed", column.Name()).Details(err)
 	}
-	if isText {
+	if isText && !column.Binary() {
 		return string(memo), nil
 	}
 	return memo, nil
 }
 
-// Saves the value to the memo file and returns the address in the FPT file
+// Saves the value to the memo file and returns the address in the FPT file.
+// The memo the field was read from is overwritten if the value fits into its blocks.
 func (file *File) getMemoRepresentation(field *Field, _ bool) ([]byte, error) {
 	memo := make([]byte, 0)
 	txt := false
@@ -149,15 +170,40 @@ func (file *File) getMemoRepresentation(field *Field, _ bool) ([]byte, error) {
 	m, ok := field.value.([]byte)
 	if ok {
 		memo = m
-		txt = false
+		// Binary memo columns store their memos as text without code page conversion, like Visual FoxPro
+		txt = field.column.Binary()
 	}
 	if !ok && !sok {
 		return nil, NewErrorf("invalid type for memo field: %T", field.value)
 	}
-	address, err := file.WriteMemo(memo, txt, len(memo))
+	// Empty memos are not stored, the address is left blank
+	if len(memo) == 0 {
+		field.memoPos = nil
+		if field.column.Length > 4 {
+			return bytes.Repeat([]byte{byte(Blank)}, int(field.column.Length)), nil
+		}
+		return make([]byte, field.column.Length), nil
+	}
+	if converter, override := file.table.converters[field.column]; override && txt && !field.column.Binary() && !(isASCII(memo) && asciiCompatible(converter)) {
+		encoded, err := converter.Encode(memo)
+		if err != nil {
+			return nil, NewErrorf("encoding memo failed at column field: %v", field.Name()).Details(err)
+		}
+		memo = encoded
+	}
+	memo, txt, err := file.compressMemo(field.column, memo, txt)
+	if err != nil {
+		return nil, NewErrorf("compressing memo failed at column field: %v", field.Name()).Details(err)
+	}
+	err = file.ensureMemo()
 	if err != nil {
 		return nil, WrapError(err)
 	}
+	address, err := file.WriteMemo(field.memoPos, memo, txt, len(memo))
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	field.memoPos = address
 	return address, nil
 }
 
@@ -166,11 +212,15 @@ func (file *File) parseCharacter(raw []byte, column *Column) (interface{}, error
 	if len(raw) == 0 {
 		return "", nil
 	}
-	if len(raw) > MaxCharacterLength {
-		return NewErrorf("invalid length %v bytes > %v bytes at column field: %v", len(raw), MaxCharacterLength, column.Name()), nil
+	if len(raw) > column.Size() {
+		return nil, NewErrorf("invalid length %v bytes > %v bytes at column field: %v", len(raw), column.Size(), column.Name())
+	}
+	// Binary C values are returned as stored
+	if column.Binary() {
+		return append([]byte(nil), raw...), nil
 	}
 	// C values are stored as strings, the returned string is not trimmed
-	str, err := toUTF8String(raw, file.config.Converter)
+	str, err := toUTF8String(raw, file.converter(column), file.config.StrictEncoding)
 	if err != nil {
 		return str, NewErrorf("parsing to utf8 string failed at column field: %v failed", column.Name()).Details(err)
 	}
@@ -181,24 +231,35 @@ func (file *File) parseCharacter(raw []byte, column *Column) (interface{}, error
 func (file *File) getCharacterRepresentation(field *Field, skipSpacing bool) ([]byte, error) {
 	// C values are stored as strings, the returned string is not trimmed
 	c, ok := field.value.(string)
+	if b, isBytes := field.value.([]byte); isBytes && field.column.Binary() {
+		c, ok = string(b), true
+	}
 	if !ok {
 		return nil, NewErrorf("invalid data type %T, expected string on column field: %v", field.value, field.Name())
 	}
-	if len(c) > MaxCharacterLength {
-		return nil, NewErrorf("invalid length %v bytes > %v bytes at column field: %v", len(c), MaxCharacterLength, field.Name())
+	limit := MaxCharacterLength
+	if field.column.Size() > limit {
+		limit = field.column.Size()
 	}
-	raw := make([]byte, field.column.Length)
-	bin, err := fromUtf8String([]byte(c), file.config.Converter)
-	if err != nil {
-		return nil, NewErrorf("parsing from utf8 string at column field: %v failed", field.Name()).Details(err)
+	if len(c) > limit {
+		return nil, NewErrorf("invalid length %v bytes > %v bytes at column field: %v", len(c), limit, field.Name())
+	}
+	raw := make([]byte, field.column.Size())
+	bin := []byte(c)
+	if !field.column.Binary() {
+		var err error
+		bin, err = fromUtf8String(bin, file.converter(field.column))
+		if err != nil {
+			return nil, NewErrorf("parsing from utf8 string at column field: %v failed", field.Name()).Details(err)
+		}
 	}
 	if skipSpacing {
 		return bin, nil
 	}
-	bin = appendSpaces(bin, int(field.column.Length))
+	bin = file.pad(field, bin, false)
 	copy(raw, bin)
-	if len(raw) > int(field.column.Length) {
-		return nil, NewErrorf("invalid length %v bytes > %v bytes at column field: %v", len(raw), field.column.Length, field.Name())
+	if len(raw) > field.column.Size() {
+		return nil, NewErrorf("invalid length %v bytes > %v bytes at column field: %v", len(raw), field.column.Size(), field.Name())
 	}
 	return raw, nil
 }
@@ -261,6 +322,9 @@ func (file *File) getCurrencyRepresentation(field *Field, _ bool) ([]byte, error
 
 // Returns the value as float64
 func (file *File) parseFloat(raw []byte, column *Column) (interface{}, error) {
+	if val, ok, err := file.overflow(raw, column); ok {
+		return val, err
+	}
 	f, err := parseFloat(raw)
 	if err != nil {
 		return f, NewErrorf("parsing float at column field: %v failed", column.Name()).Details(err)
@@ -281,12 +345,63 @@ func (file *File) getFloatRepresentation(field *Field, skipSpacing bool) ([]byte
 	} else {
 		// if the value is a float, store as float
 		expression := fmt.Sprintf("%%.%df", field.column.Decimals)
-		bin = []byte(fmt.Sprintf(expression, field.value))
+		bin = file.decimalSeparator([]byte(fmt.Sprintf(expression, field.value)))
 	}
 	if skipSpacing {
 		return bin, nil
 	}
-	return prependSpaces(bin, int(field.column.Length)), nil
+	return file.pad(field, bin, true), nil
+}
+
+// overflow returns the value of the overflow policy and true if the raw value only consists of asterisks
+func (file *File) overflow(raw []byte, column *Column) (interface{}, bool, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || len(bytes.Trim(trimmed, "*")) != 0 {
+		return nil, false, nil
+	}
+	switch file.config.NumericOverflow {
+	case OverflowNull:
+		return nil, true, nil
+	case OverflowNaN:
+		return math.NaN(), true, nil
+	}
+	return nil, true, NewErrorf("overflowed value '%s' at column field: %v", trimmed, column.Name()).Details(ErrNumericOverflow)
+}
+
+// decimalSeparator replaces the decimal point of a formatted number with the separator of the config
+func (file *File) decimalSeparator(number []byte) []byte {
+	if file.config.DecimalSeparator == 0 || file.config.DecimalSeparator == '.' {
+		return number
+	}
+	return bytes.Replace(number, []byte{'.'}, []byte{file.config.DecimalSeparator}, 1)
+}
+
+// pad pads the value to the length of the column with the padding and alignment of the column modification.
+// Right aligns if the modification does not set an alignment and right is true. The sign of right aligned numbers
+// padded with zeros stays in front, e.g. -0042.
+func (file *File) pad(field *Field, bin []byte, right bool) []byte {
+	padding := byte(' ')
+	if pos := file.ColumnPos(field.column); pos >= 0 && file.table.mods[pos] != nil {
+		mod := file.table.mods[pos]
+		if mod.Padding != 0 {
+			padding = mod.Padding
+		}
+		if mod.Align != AlignDefault {
+			right = mod.Align == AlignRight
+		}
+	}
+	length := field.column.Size()
+	if len(bin) >= length {
+		return bin
+	}
+	fill := bytes.Repeat([]byte{padding}, length-len(bin))
+	if !right {
+		return append(bin, fill...)
+	}
+	if padding == '0' && len(bin) > 0 && (bin[0] == '-' || bin[0] == '+') && DataType(field.column.DataType) != Character {
+		return append(append([]byte{bin[0]}, fill...), bin[1:]...)
+	}
+	return append(fill, bin...)
 }
 
 // Returns the value as float64
@@ -330,12 +445,16 @@ func (file *File) getDateRepresentation(field *Field, _ bool) ([]byte, error) {
 		if !ok {
 			return nil, NewErrorf("invalid data type %T, expected time.Time at column field: %v", field.value, field.Name())
 		}
-		t, err := time.Parse(time.RFC3339, s)
+		t, err := parseRepresentationTime(s)
 		if err != nil {
 			return nil, NewErrorf("parsing time failed at column field: %v failed", field.Name()).Details(err)
 		}
 		d = t
 	}
+	// Empty dates are stored as blanks
+	if IsEmptyDate(d) {
+		return bytes.Repeat([]byte(" "), int(field.column.Length)), nil
+	}
 	raw := make([]byte, field.column.Length)
 	bin := []byte(d.Format("20060102"))
 	copy(raw, bin)
@@ -358,13 +477,17 @@ func (file *File) getDateTimeRepresentation(field *Field, _ bool) ([]byte, error
 		if !ok {
 			return nil, NewErrorf("invalid data type %T, expected time.Time at column field: %v", field.value, field.Name())
 		}
-		parsedTime, err := time.Parse(time.RFC3339, s)
+		parsedTime, err := parseRepresentationTime(s)
 		if err != nil {
 			return nil, NewErrorf("parsing time failed at column field: %v failed", field.Name()).Details(err)
 		}
 		t = parsedTime
 	}
 	raw := make([]byte, 8)
+	// Empty datetimes are stored as zero bytes
+	if IsEmptyDate(t) {
+		return raw, nil
+	}
 	i := julianDate(t.Year(), int(t.Month()), t.Day())
 	date, err := toBinary(uint64(i))
 	if err != nil {
@@ -383,6 +506,14 @@ func (file *File) getDateTimeRepresentation(field *Field, _ bool) ([]byte, error
 	return raw, nil
 }
 
+// parseRepresentationTime parses a RFC 3339 date string, a blank string is an empty date
+func parseRepresentationTime(s string) (time.Time, error) {
+	if len(strings.TrimSpace(s)) == 0 {
+		return EmptyDate(), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
 // Return the value (T or F) as bool
 func (file *File) parseLogical(raw []byte, _ *Column) (interface{}, error) {
 	return string(raw) == "T", nil
@@ -421,6 +552,12 @@ func (file *File) getRawRepresentation(field *Field, _ bool) ([]byte, error) {
 
 // Returns the value as integer or float64
 func (file *File) parseNumeric(raw []byte, column *Column) (interface{}, error) {
+	if val, ok, err := file.overflow(raw, column); ok {
+		return val, err
+	}
+	if column.Decimals == 0 && file.table.numericStrings[column] {
+		return string(bytes.TrimSpace(raw)), nil
+	}
 	if column.Decimals == 0 {
 		i, err := parseNumericInt(raw)
 		if err != nil {
@@ -438,43 +575,67 @@ func (file *File) getNumericRepresentation(field *Field, skipSpacing bool) ([]by
 	bin := make([]byte, 0)
 	f, fok := field.value.(float64)
 	if fok {
-		if f == float64(int64(f)) {
-			// if the value has no decimals, store as integer
+		if field.column.Decimals == 0 && f == float64(int64(f)) {
+			// if the column and the value have no decimals, store as integer
 			bin = []byte(strconv.FormatInt(int64(f), 10))
 		} else {
 			// if the value is a float, store as float
 			expression := fmt.Sprintf("%%.%df", field.column.Decimals)
-			bin = []byte(fmt.Sprintf(expression, field.value))
+			bin = file.decimalSeparator([]byte(fmt.Sprintf(expression, field.value)))
 		}
 	}
 	_, iok := field.value.(int64)
 	if iok {
 		bin = []byte(fmt.Sprintf("%d", field.value))
 	}
+	if s, ok := field.value.(string); ok && file.table.numericStrings[field.column] {
+		s = strings.TrimSpace(s)
+		if len(s) > 0 && !isNumericString(s) {
+			return nil, NewErrorf("invalid value '%s', expected digits at column field: %v", s, field.Name())
+		}
+		bin, iok = []byte(s), true
+	}
 	if !iok && !fok {
 		return nil, NewErrorf("invalid data type %T, expected int64 or float64 at column field: %v", field.value, field.Name())
 	}
 	if skipSpacing {
 		return bin, nil
 	}
-	return prependSpaces(bin, int(field.column.Length)), nil
+	return file.pad(field, bin, true), nil
 }
 
-func (file *File) parseVarchar(raw []byte, column *Column) (interface{}, error) {
-	varlen, null, err := file.ReadNullFlag(uint64(file.table.rowPointer), column)
+func (file *File) parseVarchar(raw []byte, column *Column, nullFlags []byte) (interface{}, error) {
+	varlen, null, err := file.nullFlags(column, nullFlags)
 	if err != nil {
 		return nil, NewErrorf("reading null flag at column field: %v failed", column.Name()).Details(err)
 	}
 	if null {
-		return []byte{}, nil
+		return nil, nil
 	}
-	if varlen {
-		length := raw[len(raw)-1]
+	if varlen && len(raw) > 0 {
+		length := int(raw[len(raw)-1])
+		if length >= len(raw) {
+			return nil, NewErrorf("invalid variable length %d at column field: %v", length, column.Name())
+		}
 		raw = raw[:length]
 	}
 	return string(raw), nil
 }
 
+// nullFlags returns the variable length and null flag of the column from the _NullFlags data of the row.
+// Without the row data the flags are read from the file at the row pointer.
+func (file *File) nullFlags(column *Column, nullFlags []byte) (bool, bool, error) {
+	if nullFlags == nil {
+		return file.ReadNullFlag(uint64(file.table.rowPointer), column)
+	}
+	layout := file.table.layout.column(column)
+	if layout == nil {
+		return false, false, NewErrorf("column %s is not part of the table", column.Name())
+	}
+	varlen, null := layout.nullFlags(nullFlags)
+	return varlen, null, nil
+}
+
 func (file *File) getVarcharRepresentation(field *Field, _ bool) ([]byte, error) {
 	s, ok := field.value.(string)
 	if ok {
@@ -487,16 +648,19 @@ func (file *File) getVarcharRepresentation(field *Field, _ bool) ([]byte, error)
 	return nil, NewErrorf("invalid data type %T, expected string at column field: %v", field.value, field.Name())
 }
 
-func (file *File) parseVarbinary(raw []byte, column *Column) (interface{}, error) {
-	varlen, null, err := file.ReadNullFlag(uint64(file.table.rowPointer), column)
+func (file *File) parseVarbinary(raw []byte, column *Column, nullFlags []byte) (interface{}, error) {
+	varlen, null, err := file.nullFlags(column, nullFlags)
 	if err != nil {
 		return nil, NewErrorf("reading null flag at column field: %v failed", column.Name()).Details(err)
 	}
 	if null {
-		return []byte{}, nil
+		return nil, nil
 	}
-	if varlen {
-		length := raw[len(raw)-1]
+	if varlen && len(raw) > 0 {
+		length := int(raw[len(raw)-1])
+		if length >= len(raw) {
+			return nil, NewErrorf("invalid variable length %d at column field: %v", length, column.Name())
+		}
 		raw = raw[:length]
 	}
 	return raw, nil
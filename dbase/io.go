@@ -1,5 +1,7 @@
 package dbase
 
+import "time"
+
 // IO is the interface to work with the DBF file.
 // Three implementations are available:
 // - WindowsIO (for direct file access with Windows)
@@ -9,6 +11,7 @@ type IO interface {
 	OpenTable(config *Config) (*File, error)
 	Close(file *File) error
 	Create(file *File) error
+	CreateRelated(file *File) error
 	ReadHeader(file *File) error
 	WriteHeader(file *File) error
 	ReadColumns(file *File) ([]*Column, *Column, error)
@@ -16,86 +19,301 @@ type IO interface {
 	ReadMemoHeader(file *File) error
 	WriteMemoHeader(file *File, size int) error
 	ReadMemo(file *File, address []byte) ([]byte, bool, error)
-	WriteMemo(file *File, raw []byte, text bool, length int) ([]byte, error)
+	ReadMemos(file *File, blocks []uint32) (map[uint32]MemoData, error)
+	WriteMemo(file *File, address []byte, raw []byte, text bool, length int) ([]byte, error)
 	ReadNullFlag(file *File, position uint64, column *Column) (bool, bool, error)
 	ReadRow(file *File, position uint32) ([]byte, error)
+	ReadRows(file *File, position uint32, count int) ([][]byte, error)
 	WriteRow(file *File, row *Row) error
-	Search(file *File, field *Field, exactMatch bool) ([]*Row, error)
+	Search(file *File, field *Field, exactMatch bool, options SearchOptions) ([]*Row, error)
 	GoTo(file *File, row uint32) error
 	Skip(file *File, offset int64)
 	Deleted(file *File) (bool, error)
+	LockHeader(file *File) (func() error, error)
+	LockRow(file *File, position uint32) (func() error, error)
+	LockTable(file *File) (func() error, error)
+	Truncate(file *File, size int64) error
+	Sync(file *File) error
+	TruncateRelated(file *File, size int64) error
 }
 
 // Opens a dBase database file (and the memo file if needed).
 // The config parameter is required to specify the file path, encoding, file handles (IO) and others.
 // If IO is nil, the default implementation is used depending on the OS.
+// The config is validated first, see Config.Validate.
+// Errors are returned as *OpenError with the reason the table could not be opened.
 func OpenTable(config *Config) (*File, error) {
-	if config.IO == nil {
-		config.IO = DefaultIO
+	err := config.Validate()
+	if err != nil {
+		return nil, newOpenError(config, err)
+	}
+	start := time.Now()
+	file, err := config.IO.OpenTable(config)
+	config.observe(OpenOperation, 0, start, err)
+	if err != nil {
+		return nil, newOpenError(config, err)
+	}
+	if config.Preload {
+		err = file.preload()
+		if err != nil {
+			file.Close()
+			return nil, newOpenError(config, err)
+		}
+	}
+	if config.DetectIndexes {
+		err = file.detectIndexes()
+		if err != nil {
+			file.Close()
+			return nil, newOpenError(config, err)
+		}
 	}
-	return config.IO.OpenTable(config)
+	err = file.openIndexes()
+	if err != nil {
+		file.Close()
+		return nil, newOpenError(config, err)
+	}
+	file.readComments()
+	return file, nil
 }
 
 // Closes all file handlers.
 func (file *File) Close() error {
-	return file.defaults().io.Close(file)
+	start := time.Now()
+	indexErr := file.closeIndexes()
+	err := file.defaults().io.Close(file)
+	file.config.observe(CloseOperation, 0, start, err)
+	if err != nil {
+		return err
+	}
+	return indexErr
 }
 
 // Creates a new dBase database file (and the memo file if needed).
 func (file *File) Create() error {
-	return file.defaults().io.Create(file)
+	start := time.Now()
+	err := file.defaults().io.Create(file)
+	file.config.observe(CreateOperation, 0, start, err)
+	return err
+}
+
+// CreateRelated creates the memo file of a table that was created or opened without one.
+func (file *File) CreateRelated() error {
+	start := time.Now()
+	err := file.defaults().io.CreateRelated(file)
+	file.config.observe(CreateOperation, 0, start, err)
+	return err
 }
 
 // Reads the DBF header from the file handle.
 func (file *File) ReadHeader() error {
-	return file.defaults().io.ReadHeader(file)
+	start := time.Now()
+	err := file.defaults().io.ReadHeader(file)
+	file.config.observe(ReadHeaderOperation, headerSize, start, err)
+	return err
 }
 
 // WriteHeader writes the header to the dbase file.
 func (file *File) WriteHeader() error {
-	return file.defaults().io.WriteHeader(file)
+	start := time.Now()
+	err := file.defaults().io.WriteHeader(file)
+	file.config.observe(WriteHeaderOperation, headerSize, start, err)
+	return err
 }
 
 // ReadColumns reads from DBF header, starting at pos 32, until it finds the Header row terminator END_OF_COLUMN(0x0D).
 func (file *File) ReadColumns() ([]*Column, *Column, error) {
-	return file.defaults().io.ReadColumns(file)
+	start := time.Now()
+	columns, nullFlag, err := file.defaults().io.ReadColumns(file)
+	bytes := 32*len(columns) + 1
+	if nullFlag != nil {
+		bytes += 32
+	}
+	file.config.observe(ReadColumnsOperation, bytes, start, err)
+	return columns, nullFlag, err
 }
 
 // WriteColumns writes the columns at the end of header in dbase file
 func (file *File) WriteColumns() error {
-	return file.defaults().io.WriteColumns(file)
+	start := time.Now()
+	err := file.defaults().io.WriteColumns(file)
+	file.config.observe(WriteColumnsOperation, int(file.header.FirstRow)-32, start, err)
+	return err
 }
 
 // ReadMemoHeader reads the memo header from the given file handle.
 func (file *File) ReadMemoHeader() error {
-	return file.defaults().io.ReadMemoHeader(file)
+	start := time.Now()
+	err := file.defaults().io.ReadMemoHeader(file)
+	file.config.observe(ReadMemoHeaderOperation, 8, start, err)
+	return err
 }
 
 // WriteMemoHeader writes the memo header to the memo file.
 // Size is the number of blocks the new memo data will take up.
 func (file *File) WriteMemoHeader(size int) error {
-	return file.defaults().io.WriteMemoHeader(file, size)
+	start := time.Now()
+	err := file.defaults().io.WriteMemoHeader(file, size)
+	file.config.observe(WriteMemoHeaderOperation, memoHeaderSize, start, err)
+	return err
 }
 
 // Reads raw row data of one row at rowPosition
 func (file *File) ReadRow(position uint32) ([]byte, error) {
-	return file.defaults().io.ReadRow(file, position)
+	start := time.Now()
+	data, err := file.defaults().io.ReadRow(file, position)
+	file.config.observe(ReadRowOperation, len(data), start, err)
+	return data, err
+}
+
+// Reads the raw data of up to count consecutive rows starting at position with a single read.
+// Fewer rows are returned if the table ends before. The rows share one buffer.
+func (file *File) ReadRows(position uint32, count int) ([][]byte, error) {
+	start := time.Now()
+	rows, err := file.defaults().io.ReadRows(file, position, count)
+	file.config.observe(ReadRowsOperation, len(rows)*int(file.header.RowLength), start, err)
+	return rows, err
 }
 
-// WriteRow writes a raw row data to the given row position
+// WriteRow writes a raw row data to the given row position.
+// A row of another table with the same column types is written with its values, its memos are written to the memo file of this table.
 func (file *File) WriteRow(row *Row) error {
-	return file.defaults().io.WriteRow(file, row)
+	row, err := rewriteMemoRefs(row, file)
+	if err != nil {
+		return WrapError(err)
+	}
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	return file.commit(file.writeRow(row))
+}
+
+// writeRow writes the row, the caller must hold the file mutex
+func (file *File) writeRow(row *Row) error {
+	if !file.config.ExtendedCharacterColumns {
+		for _, column := range file.table.columns {
+			if column.extended() {
+				return NewErrorf("column %s is longer than %d bytes, set ExtendedCharacterColumns to write it", column.Name(), MaxCharacterLength)
+			}
+		}
+	}
+	keys, err := file.indexKeys(row.Position)
+	if err != nil {
+		return WrapError(err)
+	}
+	rows := file.header.RowsCount
+	start := time.Now()
+	err = file.defaults().io.WriteRow(file, row)
+	file.config.observe(WriteRowOperation, int(file.header.RowLength), start, err)
+	if err != nil {
+		return err
+	}
+	if row.Deleted && row.Position < file.reusable {
+		file.reusable = row.Position
+	}
+	if file.header.RowsCount > rows {
+		err = file.writeTrailer()
+		if err != nil {
+			return WrapError(err)
+		}
+	}
+	err = file.updateIndexes(row.Position, keys)
+	if err != nil {
+		return WrapError(err)
+	}
+	return file.syncIf(SyncEveryWrite)
+}
+
+// Sync flushes the written data of the DBF and the memo file to the storage device,
+// so acknowledged writes survive a crash of the system.
+func (file *File) Sync() error {
+	start := time.Now()
+	err := file.defaults().io.Sync(file)
+	file.config.observe(SyncOperation, 0, start, err)
+	return err
+}
+
+// syncIf flushes the files if the sync mode of the config is mode
+func (file *File) syncIf(mode SyncMode) error {
+	if file.config.SyncMode != mode {
+		return nil
+	}
+	return file.Sync()
+}
+
+// commit flushes the files after a completed write operation if SyncOnCommit is configured
+func (file *File) commit(err error) error {
+	if err != nil {
+		return err
+	}
+	return file.syncIf(SyncOnCommit)
 }
 
 // Reads one or more blocks from the FPT file, called for each memo column.
 // the return value is the raw data and true if the data read is text (false is RAW binary data).
+// Text is decoded using the converter of the config.
 func (file *File) ReadMemo(address []byte) ([]byte, bool, error) {
-	return file.defaults().io.ReadMemo(file, address)
+	return file.readMemo(address, file.config.Converter)
+}
+
+// MemoData is the raw data of a memo read by ReadMemos
+type MemoData struct {
+	Data []byte // Data as stored, text is not decoded
+	Text bool   // Whether the memo is text
+}
+
+// ReadMemos reads the memos at the blocks with few large reads instead of one read per memo.
+// The blocks are sorted and blocks close to each other are read at once. Memos that can not be read
+// this way, e.g. because they are larger than the read or the block is invalid, are missing in the result.
+func (file *File) ReadMemos(blocks []uint32) (map[uint32]MemoData, error) {
+	start := time.Now()
+	memos, err := file.defaults().io.ReadMemos(file, blocks)
+	size := 0
+	for _, memo := range memos {
+		size += len(memo.Data)
+	}
+	file.config.observe(ReadMemosOperation, size, start, err)
+	return memos, err
+}
+
+// readMemo reads the memo at the address, decompresses it and decodes text using the converter, if it is not nil.
+// Memos prefetched by the row iterator are taken from the prefetched memos instead.
+func (file *File) readMemo(address []byte, converter EncodingConverter) ([]byte, bool, error) {
+	var (
+		data []byte
+		text bool
+		err  error
+	)
+	memo, ok := file.prefetchedMemo(address)
+	if ok {
+		data, text = memo.Data, memo.Text
+	} else {
+		start := time.Now()
+		data, text, err = file.defaults().io.ReadMemo(file, address)
+		file.config.observe(ReadMemoOperation, len(data), start, err)
+	}
+	if err == nil {
+		data, text, err = file.decompressMemo(data, text)
+	}
+	if err != nil || !text || converter == nil {
+		return data, text, err
+	}
+	if isASCII(data) && asciiCompatible(converter) {
+		return data, text, nil
+	}
+	decoded, err := decode(data, converter, file.config.StrictEncoding)
+	if err != nil {
+		return decoded, text, WrapError(err)
+	}
+	return decoded, text, nil
 }
 
 // WriteMemo writes a memo to the memo file and returns the address of the memo.
-func (file *File) WriteMemo(data []byte, text bool, length int) ([]byte, error) {
-	return file.defaults().io.WriteMemo(file, data, text, length)
+// If the address points to a memo with enough blocks for the data, the memo is overwritten in place,
+// otherwise (or if the address is nil) the data is appended to the memo file.
+func (file *File) WriteMemo(address []byte, data []byte, text bool, length int) ([]byte, error) {
+	start := time.Now()
+	address, err := file.defaults().io.WriteMemo(file, address, data, text, length)
+	file.config.observe(WriteMemoOperation, length, start, err)
+	return address, err
 }
 
 // Read the nullFlag field at the end of the row
@@ -104,31 +322,99 @@ func (file *File) WriteMemo(data []byte, text bool, length int) ([]byte, error)
 // If varlength is false, we read the complete field
 // If the field is null, we return true as second return value
 func (file *File) ReadNullFlag(position uint64, column *Column) (bool, bool, error) {
-	return file.defaults().io.ReadNullFlag(file, position, column)
+	start := time.Now()
+	varlen, null, err := file.defaults().io.ReadNullFlag(file, position, column)
+	bytes := 0
+	if file.nullFlagColumn != nil {
+		bytes = int(file.nullFlagColumn.Length)
+	}
+	file.config.observe(ReadNullFlagOperation, bytes, start, err)
+	return varlen, null, err
 }
 
 // Search searches for a row with the given value in the given field
 func (file *File) Search(field *Field, exactMatch bool) ([]*Row, error) {
-	return file.defaults().io.Search(file, field, exactMatch)
+	return file.SearchWithOptions(field, exactMatch, SearchOptions{})
 }
 
-// GoTo sets the internal row pointer to row rowNumber
-// Returns and EOF error if at EOF and positions the pointer at lastRow+1
+// SearchWithOptions searches for rows with the given value in the given field.
+// The options limit the returned rows, so the search stops once enough rows are found,
+// and split the scan over multiple workers. The internal row pointer is not moved.
+func (file *File) SearchWithOptions(field *Field, exactMatch bool, options SearchOptions) ([]*Row, error) {
+	start := time.Now()
+	rows, err := file.defaults().io.Search(file, field, exactMatch, options)
+	file.config.observe(SearchOperation, int(file.header.RowsCount)*field.column.Size(), start, err)
+	return rows, err
+}
+
+// GoTo sets the internal row pointer to the row, rows are numbered starting at 0.
+// Returns an ErrEOF error if the row does not exist and positions the pointer at the end of the file
 func (file *File) GoTo(row uint32) error {
 	return file.defaults().io.GoTo(file, row)
 }
 
 // Skip adds offset to the internal row pointer
-// If at end of file positions the pointer at lastRow+1
-// If the row pointer would be become negative positions the pointer at 0
+// If the pointer would move behind the last row it is positioned at the end of the file (EOF() is true)
+// If the row pointer would become negative it is positioned at the first row (0) and an ErrBOF error is returned
 // Does not skip deleted rows
-func (file *File) Skip(offset int64) {
+func (file *File) Skip(offset int64) error {
+	target := int64(file.table.rowPointer) + offset
 	file.defaults().io.Skip(file, offset)
+	if target < 0 {
+		return NewErrorf("out of range, skip %d rows from row %d", offset, target-offset).Details(ErrBOF)
+	}
+	return nil
 }
 
 // Returns if the row at internal row pointer is deleted
 func (file *File) Deleted() (bool, error) {
-	return file.defaults().io.Deleted(file)
+	start := time.Now()
+	deleted, err := file.defaults().io.Deleted(file)
+	file.config.observe(DeletedOperation, 1, start, err)
+	return deleted, err
+}
+
+// LockHeader places a header lock on the table using the Visual FoxPro locking scheme.
+// The returned function releases the lock. Header writes are not locked again while the lock is held.
+func (file *File) LockHeader() (func() error, error) {
+	start := time.Now()
+	unlock, err := file.defaults().io.LockHeader(file)
+	file.config.observe(LockOperation, 0, start, err)
+	return unlock, err
+}
+
+// LockRow places a record lock on the row at position using the Visual FoxPro locking scheme.
+// The returned function releases the lock. Writes to the row are not locked again while the lock is held.
+func (file *File) LockRow(position uint32) (func() error, error) {
+	start := time.Now()
+	unlock, err := file.defaults().io.LockRow(file, position)
+	file.config.observe(LockOperation, 0, start, err)
+	return unlock, err
+}
+
+// LockTable places a file lock on the table using the Visual FoxPro locking scheme.
+// The returned function releases the lock. Row locks and writes are not locked again while the lock is held.
+func (file *File) LockTable() (func() error, error) {
+	start := time.Now()
+	unlock, err := file.defaults().io.LockTable(file)
+	file.config.observe(LockOperation, 0, start, err)
+	return unlock, err
+}
+
+// Truncate changes the size of the DBF file to size bytes
+func (file *File) Truncate(size int64) error {
+	start := time.Now()
+	err := file.defaults().io.Truncate(file, size)
+	file.config.observe(TruncateOperation, 0, start, err)
+	return err
+}
+
+// TruncateRelated changes the size of the memo file to size bytes
+func (file *File) TruncateRelated(size int64) error {
+	start := time.Now()
+	err := file.defaults().io.TruncateRelated(file, size)
+	file.config.observe(TruncateOperation, 0, start, err)
+	return err
 }
 
 // Returns the used IO implementation
@@ -157,7 +443,7 @@ func ValidateFileVersion(version byte, untested bool) error {
 	debugf("Validating file version: %d", version)
 	switch version {
 	default:
-		return NewErrorf("untested DBF file version: %d (0x%x)", version, version)
+		return NewErrorf("untested DBF file version: %d (0x%x)", version, version).Details(ErrUnsupportedVersion)
 	case byte(FoxPro), byte(FoxProAutoincrement), byte(FoxProVar):
 		return nil
 	}
@@ -0,0 +1,844 @@
+package dbase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileHandle is the low level file access the shared IO implementation is built on.
+// Each IO implementation wraps its native DBF and memo file handles in a fileHandle,
+// so the file format logic exists only once and behaves the same on all platforms.
+type fileHandle interface {
+	ReadAt(p []byte, off int64) (int, error)
+	WriteAt(p []byte, off int64) (int, error)
+	// Lock exclusively locks the region and returns a function releasing the lock.
+	// It does not wait, ErrLocked is returned if the region is locked by another process.
+	Lock(off int64, length int64) (func() error, error)
+	Truncate(size int64) error
+	// Sync flushes the written data to the storage device
+	Sync() error
+	// Size returns the current size of the file in bytes
+	Size() (int64, error)
+}
+
+// ioCore implements the IO operations shared by all IO implementations.
+// The handle functions resolve the fileHandle of the DBF and the memo file.
+type ioCore struct {
+	handle  func(file *File) (fileHandle, error)
+	related func(file *File) (fileHandle, error)
+}
+
+// initTable reads the header and columns of an opened DBF file and sets up the table
+func (c ioCore) initTable(file *File, filename string) error {
+	err := file.ReadHeader()
+	if err != nil {
+		return WrapError(err).Details(ErrInvalidHeader)
+	}
+	// Check if the fileversion flag is expected, expand validFileVersion if needed
+	if err := ValidateFileVersion(file.header.FileType, file.config.Untested); err != nil {
+		return WrapError(err)
+	}
+	columns, nullFlag, err := file.ReadColumns()
+	if err != nil {
+		return WrapError(err).Details(ErrInvalidColumns)
+	}
+	file.nullFlagColumn = nullFlag
+	file.table = &Table{
+		name:    strings.TrimSuffix(strings.ToUpper(filepath.Base(filename)), strings.ToUpper(filepath.Ext(filename))),
+		columns: columns,
+		mods:    make([]*Modification, len(columns)),
+		layout:  newRowLayout(columns, nullFlag, file.header.RowLength),
+	}
+	err = file.checkRowLength()
+	if err != nil {
+		return WrapError(err).Details(ErrInvalidHeader)
+	}
+	err = c.readTrailer(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	// Interpret the code page mark if needed
+	if file.config.InterpretCodePage || file.config.Converter == nil {
+		if file.config.Converter == nil {
+			debugf("No encoding converter defined, falling back to default (interpreting)")
+		}
+		debugf("Interpreting code page mark...")
+		file.config.Converter = ConverterFromCodePage(file.header.CodePage)
+		debugf("Code page: 0x%02x => interpreted: 0x%02x", file.header.CodePage, file.config.Converter.CodePage())
+	}
+	// Check if the code page mark is matchin the converter
+	if file.config.ValidateCodePage && file.header.CodePage != file.config.Converter.CodePage() {
+		return NewErrorf("code page mark mismatch: %d != %d", file.header.CodePage, file.config.Converter.CodePage()).Details(ErrCodePageMismatch)
+	}
+	return nil
+}
+
+// memoFilename returns the name of the memo file belonging to the table file
+func memoFilename(filename string) string {
+	ext := FPT
+	if strings.EqualFold(filepath.Ext(filename), string(DBC)) {
+		ext = DCT
+	}
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + string(ext)
+}
+
+func (c ioCore) ReadHeader(file *File) error {
+	debugf("Reading header...")
+	handle, err := c.handle(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	b := make([]byte, headerSize)
+	err = readAt(handle, b, 0)
+	if err != nil {
+		return NewError("failed to read header").Details(err)
+	}
+	h, err := decodeHeader(b)
+	if err != nil {
+		return NewError("failed to read header").Details(err)
+	}
+	file.header = h
+	return nil
+}
+
+func (c ioCore) WriteHeader(file *File) (err error) {
+	debugf("Writing header - exclusive writing: %v", file.config.WriteLock)
+	handle, err := c.handle(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	// Change the last modification date to the current date
+	file.header.Year = uint8(time.Now().Year() - 2000)
+	file.header.Month = uint8(time.Now().Month())
+	file.header.Day = uint8(time.Now().Day())
+	debugf("Writing header: %+v", file.header)
+	unlock, err := c.lock(file, handle, headerLock(file), 1)
+	if err != nil {
+		return WrapError(err)
+	}
+	defer release(unlock, &err)
+	err = writeAt(handle, file.header.encode(), 0)
+	if err != nil {
+		return NewError("failed to write header").Details(err)
+	}
+	return nil
+}
+
+// ReadColumns reads the column definitions, starting at offset 32, until the column terminator (0x0D)
+func (c ioCore) ReadColumns(file *File) ([]*Column, *Column, error) {
+	debugf("Reading columns...")
+	handle, err := c.handle(file)
+	if err != nil {
+		return nil, nil, WrapError(err)
+	}
+	var nullFlag *Column
+	columns := make([]*Column, 0)
+	offset := int64(headerSize)
+	buf := make([]byte, 32)
+	// Some generators omit the terminator and the rows start directly after the columns
+	firstRow := int64(file.header.FirstRow)
+	for {
+		n, err := handle.ReadAt(buf, offset)
+		if n > 0 && Marker(buf[0]) == ColumnEnd {
+			break
+		}
+		// Without a terminator the byte in front of the first row already belongs to a column
+		if firstRow > headerSize && offset >= firstRow-1 {
+			file.warn("column terminator missing, the columns end at the first row at offset %d", firstRow)
+			break
+		}
+		if n < len(buf) {
+			if err == nil || err == io.EOF {
+				err = ErrIncomplete
+			}
+			return nil, nil, NewErrorf("failed to read column at offset %d", offset).Details(err)
+		}
+		column := &Column{}
+		err = binary.Read(bytes.NewReader(buf), binary.LittleEndian, column)
+		if err != nil {
+			return nil, nil, NewErrorf("failed to read column at offset %d", offset).Details(err)
+		}
+		offset += 32
+		if column.Name() == "_NullFlags" {
+			debugf("Found null flag column: %s", column.Name())
+			nullFlag = column
+			continue
+		}
+		debugf("Found column %v of type %v at offset: %d", column.Name(), column.Type(), offset-32)
+		columns = append(columns, column)
+	}
+	return columns, nullFlag, nil
+}
+
+// WriteColumns writes the column definitions, the column terminator and fills the rest of the header with zeros
+func (c ioCore) WriteColumns(file *File) (err error) {
+	debugf("Writing columns - exclusive writing: %v", file.config.WriteLock)
+	handle, err := c.handle(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	buf := new(bytes.Buffer)
+	for _, column := range file.table.columns {
+		debugf("Writing column: %+v", column)
+		err = binary.Write(buf, binary.LittleEndian, column)
+		if err != nil {
+			return NewErrorf("failed to write column %s", column.Name()).Details(err)
+		}
+	}
+	if file.nullFlagColumn != nil {
+		debugf("Writing null flag column: %s", file.nullFlagColumn.Name())
+		err = binary.Write(buf, binary.LittleEndian, file.nullFlagColumn)
+		if err != nil {
+			return NewError("failed to write null flag column").Details(err)
+		}
+	}
+	buf.WriteByte(byte(ColumnEnd))
+	// Write null till the end of the header
+	if end := int(file.header.FirstRow) - headerSize; end > buf.Len() {
+		buf.Write(make([]byte, end-buf.Len()))
+	}
+	offset, length := tableLock(file)
+	unlock, err := c.lock(file, handle, offset, length)
+	if err != nil {
+		return WrapError(err)
+	}
+	defer release(unlock, &err)
+	err = writeAt(handle, buf.Bytes(), headerSize)
+	if err != nil {
+		return NewError("failed to write columns").Details(err)
+	}
+	return nil
+}
+
+func (c ioCore) ReadMemoHeader(file *File) error {
+	debugf("Reading memo header...")
+	handle, err := c.related(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	b := make([]byte, 8)
+	err = readAt(handle, b, 0)
+	if err != nil {
+		return NewError("failed to read memo header").Details(err)
+	}
+	h := &MemoHeader{}
+	err = binary.Read(bytes.NewReader(b), binary.BigEndian, h)
+	if err != nil {
+		return NewError("failed to read memo header").Details(err)
+	}
+	debugf("Memo header: %+v", h)
+	file.memoHeader = h
+	return nil
+}
+
+// WriteMemoHeader increases the next free block by size and writes the memo header
+func (c ioCore) WriteMemoHeader(file *File, size int) (err error) {
+	handle, err := c.related(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	// Calculate the next free block
+	file.memoHeader.NextFree += uint32(size)
+	debugf("Writing memo header - next free: %d, block size: %d", file.memoHeader.NextFree, file.memoHeader.BlockSize)
+	buf := make([]byte, memoHeaderSize)
+	binary.BigEndian.PutUint32(buf[:4], file.memoHeader.NextFree)
+	binary.BigEndian.PutUint16(buf[6:8], file.memoHeader.BlockSize)
+	unlock, err := c.lockRelated(file, handle, 0, memoHeaderSize)
+	if err != nil {
+		return WrapError(err)
+	}
+	defer release(unlock, &err)
+	err = writeAt(handle, buf, 0)
+	if err != nil {
+		return NewError("failed to write memo header").Details(err)
+	}
+	return nil
+}
+
+// ReadMemo reads the memo block at the address, text memos are returned as stored and decoded by File.ReadMemo
+func (c ioCore) ReadMemo(file *File, address []byte) ([]byte, bool, error) {
+	handle, err := c.related(file)
+	if err != nil {
+		return nil, false, WrapError(err)
+	}
+	if len(address) < 4 {
+		return nil, false, NewErrorf("invalid memo address %v", address)
+	}
+	// Determine the block number
+	block := binary.LittleEndian.Uint32(address)
+	if block == 0 {
+		return []byte{}, false, nil
+	}
+	// The position in the file is blocknumber*blocksize
+	position := int64(file.memoHeader.BlockSize) * int64(block)
+	debugf("Reading memo block %d at position %d", block, position)
+	if block < nextMemoBlock(&MemoHeader{BlockSize: file.memoHeader.BlockSize}) {
+		return nil, false, NewErrorf("memo block %d points into the memo header", block).Details(ErrInvalidMemoAddress)
+	}
+	// Validate the address against the file size, so invalid addresses do not read data of other memos or allocate huge buffers
+	size, err := handle.Size()
+	if err != nil {
+		return nil, false, NewError("failed to get the memo file size").Details(err)
+	}
+	if position+8 > size {
+		return nil, false, NewErrorf("memo block %d at position %d exceeds the memo file size of %d bytes", block, position, size).Details(ErrInvalidMemoAddress)
+	}
+	// Read the memo block header, instead of reading into a struct using binary.Read we just read the two
+	// uints in one buffer and then convert, this saves seconds for large DBF files with many memo columns
+	// as it avoids using the reflection in binary.Read
+	hbuf := make([]byte, 8)
+	err = readAt(handle, hbuf, position)
+	if err != nil {
+		return nil, false, NewError("failed to read memo block header").Details(err)
+	}
+	sign := binary.BigEndian.Uint32(hbuf[:4])
+	leng := binary.BigEndian.Uint32(hbuf[4:])
+	debugf("Memo block header => text: %v, length: %d", sign == 1, leng)
+	if leng == 0 {
+		// No data according to block header? Not sure if this should be an error instead
+		return []byte{}, sign == 1, nil
+	}
+	err = file.checkMemoSize(int64(leng))
+	if err != nil {
+		return nil, sign == 1, WrapError(err)
+	}
+	if position+8+int64(leng) > size {
+		return nil, sign == 1, NewErrorf("memo of %d bytes at block %d exceeds the memo file size of %d bytes", leng, block, size).Details(ErrInvalidMemoAddress)
+	}
+	// Now read the actual data
+	buf := make([]byte, leng)
+	err = readAt(handle, buf, position+8)
+	if err != nil {
+		return buf, sign == 1, NewError("failed to read memo block data").Details(err)
+	}
+	return buf, sign == 1, nil
+}
+
+// ReadMemos reads the memos at the blocks with few large reads. Blocks closer than memoPrefetchGap are read at once
+// up to memoPrefetchSpan bytes per read, memos not contained in a read are left to ReadMemo.
+func (c ioCore) ReadMemos(file *File, blocks []uint32) (map[uint32]MemoData, error) {
+	handle, err := c.related(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	size, err := handle.Size()
+	if err != nil {
+		return nil, NewError("failed to get the memo file size").Details(err)
+	}
+	blockSize := int64(file.memoHeader.BlockSize)
+	if blockSize == 0 {
+		return map[uint32]MemoData{}, nil
+	}
+	first := nextMemoBlock(&MemoHeader{BlockSize: file.memoHeader.BlockSize})
+	positions := make([]int64, 0, len(blocks))
+	for _, block := range blocks {
+		position := blockSize * int64(block)
+		if block >= first && position+8 <= size {
+			positions = append(positions, position)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+	memos := make(map[uint32]MemoData, len(positions))
+	for i := 0; i < len(positions); {
+		// Extend the span while the next memo is close enough
+		j := i + 1
+		for j < len(positions) && positions[j]-positions[j-1] <= memoPrefetchGap && positions[j]-positions[i] < memoPrefetchSpan {
+			j++
+		}
+		start := positions[i]
+		end := positions[j-1] + memoPrefetchTail
+		if end > size {
+			end = size
+		}
+		debugf("Prefetching %d memos from position %d to %d", j-i, start, end)
+		buf := make([]byte, end-start)
+		err = readAt(handle, buf, start)
+		if err != nil {
+			return nil, NewErrorf("failed to read memos from position %d", start).Details(err)
+		}
+		for _, position := range positions[i:j] {
+			offset := position - start
+			sign := binary.BigEndian.Uint32(buf[offset : offset+4])
+			length := int64(binary.BigEndian.Uint32(buf[offset+4 : offset+8]))
+			if offset+8+length > int64(len(buf)) || file.checkMemoSize(length) != nil {
+				continue
+			}
+			data := make([]byte, length)
+			copy(data, buf[offset+8:offset+8+length])
+			memos[uint32(position/blockSize)] = MemoData{Data: data, Text: sign == 1}
+		}
+		i = j
+	}
+	return memos, nil
+}
+
+// WriteMemo writes the data as memo block and returns the address of the block.
+// The memo at the address is overwritten if its blocks can hold the data, otherwise the data is appended.
+func (c ioCore) WriteMemo(file *File, address []byte, raw []byte, text bool, length int) (_ []byte, err error) {
+	file.memoMutex.Lock()
+	defer file.memoMutex.Unlock()
+	handle, err := c.related(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if length < 0 || length > len(raw) {
+		return nil, NewErrorf("invalid memo length %d for %d bytes of data", length, len(raw))
+	}
+	err = file.checkMemoSize(int64(length))
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	header, blocks, err := memoBlock(text, length, file.memoHeader.BlockSize)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	err = c.refreshNextFree(file, handle)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	// Get the block position, reuse the blocks of the current memo if the data fits
+	blockPosition, ok := c.memoBlocks(file, handle, address, blocks)
+	if !ok {
+		blockPosition = nextMemoBlock(file.memoHeader)
+		if int64(blockPosition)+int64(blocks) > math.MaxUint32 {
+			return nil, NewErrorf("memo of %d blocks at block %d exceeds the maximum block number %d", blocks, blockPosition, uint32(math.MaxUint32)).Details(ErrInvalidMemoAddress)
+		}
+		file.memoHeader.NextFree = blockPosition
+		// Write the memo header
+		err = file.WriteMemoHeader(blocks)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+	}
+	position := int64(blockPosition) * int64(file.memoHeader.BlockSize)
+	size := int64(blocks) * int64(file.memoHeader.BlockSize)
+	debugf("Writing memo block %d at position %d", blockPosition, position)
+	unlock, err := c.lockRelated(file, handle, position, size)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	defer release(unlock, &err)
+	err = writeAt(handle, header, position)
+	if err != nil {
+		return nil, NewError("failed to write memo block header").Details(err)
+	}
+	// Write the data in chunks, so large memos are not copied into one buffer
+	for offset := 0; offset < length; offset += memoChunkSize {
+		end := offset + memoChunkSize
+		if end > length {
+			end = length
+		}
+		err = writeAt(handle, raw[offset:end], position+8+int64(offset))
+		if err != nil {
+			return nil, NewError("failed to write memo block data").Details(err)
+		}
+	}
+	// Pad the last block with zeros
+	padding := size - 8 - int64(length)
+	if ok {
+		// A memo overwritten in place does not grow the file, the last memo of a file may not fill its last block
+		var end int64
+		end, err = handle.Size()
+		if err != nil {
+			return nil, NewError("failed to get the size of the memo file").Details(err)
+		}
+		if rest := end - position - 8 - int64(length); rest < padding {
+			padding = rest
+		}
+	}
+	if padding > 0 {
+		err = writeAt(handle, make([]byte, padding), position+8+int64(length))
+		if err != nil {
+			return nil, NewError("failed to write memo block padding").Details(err)
+		}
+	}
+	// Convert the block number to []byte
+	address, err = toBinary(blockPosition)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return address, nil
+}
+
+// refreshNextFree reads the next free block from the memo header before a memo is written.
+// Another File of the same memo file may have appended memos meanwhile, which would otherwise be overwritten
+// by the next appended memo, so reading them returned the wrong content. The stored value is only used if it is ahead.
+func (c ioCore) refreshNextFree(file *File, handle fileHandle) error {
+	buf := make([]byte, 4)
+	err := readAt(handle, buf, 0)
+	if err != nil {
+		return NewError("failed to read memo header").Details(err)
+	}
+	next := binary.BigEndian.Uint32(buf)
+	if next > file.memoHeader.NextFree {
+		debugf("Next free memo block moved from %d to %d", file.memoHeader.NextFree, next)
+		file.memoHeader.NextFree = next
+	}
+	return nil
+}
+
+// memoBlocks returns the block of the memo at the address if it occupies at least the number of blocks.
+// Returns false if the address is empty or does not point to a valid memo, so the memo has to be appended.
+func (c ioCore) memoBlocks(file *File, handle fileHandle, address []byte, blocks int) (uint32, bool) {
+	if len(address) < 4 {
+		return 0, false
+	}
+	block := binary.LittleEndian.Uint32(address)
+	if block == 0 || block < nextMemoBlock(&MemoHeader{BlockSize: file.memoHeader.BlockSize}) || block >= file.memoHeader.NextFree {
+		return 0, false
+	}
+	hbuf := make([]byte, 8)
+	err := readAt(handle, hbuf, int64(block)*int64(file.memoHeader.BlockSize))
+	if err != nil {
+		debugf("Failed to read memo block header of block %d, appending memo: %v", block, err)
+		return 0, false
+	}
+	_, used, err := memoBlock(false, int(binary.BigEndian.Uint32(hbuf[4:])), file.memoHeader.BlockSize)
+	if err != nil || used < blocks || int64(block)+int64(used) > int64(file.memoHeader.NextFree) {
+		return 0, false
+	}
+	debugf("Overwriting memo block %d in place (%d of %d blocks)", block, blocks, used)
+	return block, true
+}
+
+// ReadNullFlag reads the _NullFlags column of the row and returns the variable length and null flag of the column
+func (c ioCore) ReadNullFlag(file *File, position uint64, column *Column) (bool, bool, error) {
+	handle, err := c.handle(file)
+	if err != nil {
+		return false, false, WrapError(err)
+	}
+	if file.nullFlagColumn == nil || (column.DataType != byte(Varchar) && column.DataType != byte(Varbinary)) {
+		return false, false, NewError("null flag column missing or not a varchar/varbinary field")
+	}
+	layout := file.table.layout.column(column)
+	if layout == nil {
+		return false, false, NewErrorf("column %s is not part of the table", column.Name())
+	}
+	offset := int64(file.header.FirstRow) + int64(position)*int64(file.header.RowLength) + int64(file.table.layout.NullFlagOffset)
+	buf := make([]byte, file.nullFlagColumn.Length)
+	err = readAt(handle, buf, offset)
+	if err != nil {
+		return false, false, NewError("failed to read null flag").Details(err)
+	}
+	varlen, null := layout.nullFlags(buf)
+	debugf("Read _NullFlag for column %s => varlength: %v - null: %v", column.Name(), varlen, null)
+	return varlen, null, nil
+}
+
+func (c ioCore) ReadRow(file *File, position uint32) ([]byte, error) {
+	handle, err := c.handle(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if position >= file.header.RowsCount {
+		return nil, NewErrorf("row %d out of range, rows count %d", position, file.header.RowsCount).Details(ErrEOF)
+	}
+	offset := int64(file.header.FirstRow) + (int64(position) * int64(file.header.RowLength))
+	debugf("Reading row: %d at offset: %v", position, offset)
+	buf := make([]byte, file.header.RowLength)
+	err = readAt(handle, buf, offset)
+	if err != nil {
+		return buf, NewErrorf("failed to read row %d", position).Details(err)
+	}
+	return buf, nil
+}
+
+// ReadRows reads up to count rows starting at position into one buffer and splits it into the rows
+func (c ioCore) ReadRows(file *File, position uint32, count int) ([][]byte, error) {
+	handle, err := c.handle(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if position >= file.header.RowsCount {
+		return nil, NewErrorf("row %d out of range, rows count %d", position, file.header.RowsCount).Details(ErrEOF)
+	}
+	if count <= 0 {
+		return nil, NewErrorf("invalid row count %d", count)
+	}
+	if remaining := file.header.RowsCount - position; uint32(count) > remaining {
+		count = int(remaining)
+	}
+	length := int(file.header.RowLength)
+	offset := int64(file.header.FirstRow) + (int64(position) * int64(length))
+	debugf("Reading %d rows from row: %d at offset: %v", count, position, offset)
+	buf := make([]byte, count*length)
+	err = readAt(handle, buf, offset)
+	if err != nil {
+		return nil, NewErrorf("failed to read %d rows from row %d", count, position).Details(err)
+	}
+	rows := make([][]byte, count)
+	for i := range rows {
+		rows[i] = buf[i*length : (i+1)*length : (i+1)*length]
+	}
+	return rows, nil
+}
+
+func (c ioCore) WriteRow(file *File, row *Row) (err error) {
+	debugf("Writing row: %d ...", row.Position)
+	handle, err := c.handle(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	// Convert the row to raw bytes
+	r, err := row.ToBytes()
+	if err != nil {
+		return WrapError(err)
+	}
+	// Rows behind the last row are appended
+	appended := row.Position >= row.handle.header.RowsCount
+	if appended {
+		err = checkCapacity(row.handle.header, 1)
+		if err != nil {
+			return WrapError(err)
+		}
+		row.Position = row.handle.header.RowsCount
+	}
+	// The row is locked before the header counts it, so no other process reads the row before it is written
+	unlock, err := c.lock(file, handle, rowLock(row.handle, row.Position), 1)
+	if err != nil {
+		return WrapError(err)
+	}
+	defer release(unlock, &err)
+	position := int64(row.handle.header.FirstRow) + (int64(row.Position) * int64(row.handle.header.RowLength))
+	debugf("Writing row: %d at offset: %v", row.Position, position)
+	err = writeAt(handle, r, position)
+	if err != nil {
+		return NewErrorf("failed to write row %d", row.Position).Details(err)
+	}
+	// Update the header
+	if appended {
+		row.handle.header.RowsCount++
+	}
+	err = row.handle.WriteHeader()
+	if err != nil {
+		if appended {
+			// The row count is restored, so the header does not count a row that was not completely added
+			row.handle.header.RowsCount--
+		}
+		return WrapError(err)
+	}
+	return nil
+}
+
+// Search reads the field of every row and returns the rows containing the value
+func (c ioCore) Search(file *File, field *Field, exactMatch bool, options SearchOptions) ([]*Row, error) {
+	if field.column.DataType == byte(Memo) {
+		return nil, NewError("searching memo fields is not supported")
+	}
+	handle, err := c.handle(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	layout := file.table.layout.column(field.column)
+	if layout == nil {
+		return nil, NewErrorf("column %s is not part of the table", field.column.Name())
+	}
+	debugf("Searching for value: %v in field: %s", field.GetValue(), field.column.Name())
+	// convert the value to bytes
+	val, err := file.Represent(field, !exactMatch)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	// Search for the value
+	failed := &searchErrors{max: options.MaxErrors}
+	positions, err := searchScan(file, handle, layout, func(buf []byte) bool {
+		return bytes.Contains(buf, val)
+	}, options, failed)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	err = failed.err()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	rows := make([]*Row, 0, len(positions))
+	for _, position := range positions {
+		data, err := file.ReadRow(position)
+		if err == nil {
+			var row *Row
+			row, err = file.BytesToRow(data)
+			if err == nil {
+				row.Position = position
+				rows = append(rows, row)
+				continue
+			}
+		}
+		if !failed.add(NewErrorf("failed to read row %d", position).Details(err)) {
+			return nil, WrapError(failed.err())
+		}
+	}
+	failed.warn(file)
+	return rows, nil
+}
+
+func (c ioCore) GoTo(file *File, row uint32) error {
+	if row >= file.header.RowsCount {
+		file.table.rowPointer = file.header.RowsCount
+		return NewErrorf("out of range, go to %v with %v rows", row, file.header.RowsCount).Details(ErrEOF)
+	}
+	debugf("Going to row: %d", row)
+	file.table.rowPointer = row
+	return nil
+}
+
+func (c ioCore) Skip(file *File, offset int64) {
+	newval := int64(file.table.rowPointer) + offset
+	if newval > int64(file.header.RowsCount) {
+		newval = int64(file.header.RowsCount)
+	}
+	if newval < 0 {
+		newval = 0
+	}
+	file.table.rowPointer = uint32(newval)
+	debugf("Skipping %d row/s, new position: %d", offset, file.table.rowPointer)
+}
+
+func (c ioCore) Deleted(file *File) (bool, error) {
+	if file.table.rowPointer >= file.header.RowsCount {
+		return false, WrapError(ErrEOF)
+	}
+	handle, err := c.handle(file)
+	if err != nil {
+		return false, WrapError(err)
+	}
+	position := int64(file.header.FirstRow) + (int64(file.table.rowPointer) * int64(file.header.RowLength))
+	buf := make([]byte, 1)
+	err = readAt(handle, buf, position)
+	if err != nil {
+		return false, NewErrorf("failed to read deleted flag at position %d", position).Details(err)
+	}
+	return Marker(buf[0]) == Deleted, nil
+}
+
+// LockHeader places a FoxPro header lock on the table
+func (c ioCore) LockHeader(file *File) (func() error, error) {
+	handle, err := c.handle(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return acquire(file, handle, file.locks, headerLock(file), 1)
+}
+
+// LockRow places a FoxPro record lock on the row
+func (c ioCore) LockRow(file *File, position uint32) (func() error, error) {
+	handle, err := c.handle(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if position >= file.header.RowsCount {
+		return nil, NewErrorf("row %d out of range, rows count %d", position, file.header.RowsCount).Details(ErrEOF)
+	}
+	return acquire(file, handle, file.locks, rowLock(file, position), 1)
+}
+
+// LockTable places a FoxPro file lock on the table
+func (c ioCore) LockTable(file *File) (func() error, error) {
+	handle, err := c.handle(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	offset, length := tableLock(file)
+	return acquire(file, handle, file.locks, offset, length)
+}
+
+// Truncate changes the size of the DBF file
+func (c ioCore) Truncate(file *File, size int64) error {
+	handle, err := c.handle(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	return c.truncate(handle, size)
+}
+
+// TruncateRelated changes the size of the memo file
+func (c ioCore) TruncateRelated(file *File, size int64) error {
+	handle, err := c.related(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	return c.truncate(handle, size)
+}
+
+// Sync flushes the DBF file and, if opened, the memo file to the storage device
+func (c ioCore) Sync(file *File) error {
+	debugf("Syncing file %s", file.config.Filename)
+	if file.handle != nil {
+		handle, err := c.handle(file)
+		if err != nil {
+			return WrapError(err)
+		}
+		err = handle.Sync()
+		if err != nil {
+			return NewError("failed to sync DBF file").Details(err)
+		}
+	}
+	if file.relatedHandle != nil {
+		related, err := c.related(file)
+		if err != nil {
+			return WrapError(err)
+		}
+		err = related.Sync()
+		if err != nil {
+			return NewError("failed to sync memo file").Details(err)
+		}
+	}
+	return nil
+}
+
+func (c ioCore) truncate(handle fileHandle, size int64) error {
+	if size < 0 {
+		return NewErrorf("invalid file size %d", size)
+	}
+	debugf("Truncating file to %d bytes", size)
+	err := handle.Truncate(size)
+	if err != nil {
+		return NewErrorf("failed to truncate file to %d bytes", size).Details(err)
+	}
+	return nil
+}
+
+// lock locks the region of the DBF file if write locking is enabled
+func (c ioCore) lock(file *File, handle fileHandle, offset int64, length int64) (func() error, error) {
+	if !file.config.WriteLock {
+		return func() error { return nil }, nil
+	}
+	return acquire(file, handle, file.locks, offset, length)
+}
+
+// lockRelated locks the region of the memo file if write locking is enabled
+func (c ioCore) lockRelated(file *File, handle fileHandle, offset int64, length int64) (func() error, error) {
+	if !file.config.WriteLock {
+		return func() error { return nil }, nil
+	}
+	return acquire(file, handle, nil, offset, length)
+}
+
+// readAt reads exactly len(buf) bytes at the offset, a short read is reported as ErrIncomplete
+func readAt(handle fileHandle, buf []byte, offset int64) error {
+	n, err := handle.ReadAt(buf, offset)
+	if n == len(buf) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		return NewErrorf("read %d bytes, expected %d", n, len(buf)).Details(ErrIncomplete)
+	}
+	return err
+}
+
+// writeAt writes buf at the offset, a short write is reported as ErrIncomplete
+func writeAt(handle fileHandle, buf []byte, offset int64) error {
+	n, err := handle.WriteAt(buf, offset)
+	if err != nil {
+		return err
+	}
+	if n != len(buf) {
+		return NewErrorf("wrote %d bytes, expected %d", n, len(buf)).Details(ErrIncomplete)
+	}
+	return nil
+}
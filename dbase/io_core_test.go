@@ -0,0 +1,160 @@
+package dbase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// writeColumnsTable writes a table with one character column and one row, the terminator is followed by gap bytes
+func writeColumnsTable(t *testing.T, terminator bool, gap int) string {
+	t.Helper()
+	column, err := NewColumn("NAME", Character, 5, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	column.Position = 1
+	columns := new(bytes.Buffer)
+	err = binary.Write(columns, binary.LittleEndian, column)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terminator {
+		columns.WriteByte(byte(ColumnEnd))
+	}
+	columns.Write(make([]byte, gap))
+	header := &Header{
+		FileType:  byte(FoxBasePlus),
+		RowsCount: 1,
+		FirstRow:  uint16(headerSize + columns.Len()),
+		RowLength: 6,
+	}
+	data := append(header.encode(), columns.Bytes()...)
+	data = append(data, []byte(" HELLO")...)
+	data = append(data, byte(EOFMarker))
+	filename := filepath.Join(t.TempDir(), "COLUMNS.DBF")
+	err = os.WriteFile(filename, data, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestReadColumnsTerminator(t *testing.T) {
+	tests := []struct {
+		name       string
+		terminator bool
+		gap        int
+		warning    bool
+	}{
+		{name: "BeforeFirstRow", terminator: true},
+		{name: "Backlink", terminator: true, gap: 263},
+		{name: "Missing", warning: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := OpenTable(&Config{Filename: writeColumnsTable(t, tt.terminator, tt.gap), Untested: true, TrimSpaces: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			if len(file.Columns()) != 1 {
+				t.Fatalf("expected 1 column, got %d", len(file.Columns()))
+			}
+			if warned := len(file.Warnings()) > 0; warned != tt.warning {
+				t.Errorf("expected warning %v, got %v", tt.warning, file.Warnings())
+			}
+			row, err := file.Row()
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := row.ValueByName("NAME")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != "HELLO" {
+				t.Errorf("expected HELLO, got %v", value)
+			}
+		})
+	}
+}
+
+// failingHandle fails to lock or write the rows of the table, the header is written through the IO of the table
+type failingHandle struct {
+	fileHandle
+	first int64 // Offset of the first row
+	lock  bool  // Report the rows as locked by another process
+	write bool  // Fail writing the rows
+}
+
+func (h failingHandle) Lock(offset int64, length int64) (func() error, error) {
+	if h.lock {
+		return nil, ErrLocked
+	}
+	return h.fileHandle.Lock(offset, length)
+}
+
+func (h failingHandle) WriteAt(p []byte, offset int64) (int, error) {
+	if h.write && offset >= h.first {
+		return 0, errors.New("write failed")
+	}
+	return h.fileHandle.WriteAt(p, offset)
+}
+
+func TestWriteRowFailureRowsCount(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		lock bool
+	}{
+		{name: "Locked", lock: true},
+		{name: "WriteFailed"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			column, err := NewColumn("NAME", Character, 5, 0, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filename := filepath.Join(t.TempDir(), "APPEND.DBF")
+			file, err := NewTable(FoxBasePlus, &Config{Filename: filename, Converter: NewDefaultConverter(charmap.Windows1252), WriteLock: true}, []*Column{column}, 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			handle := failingHandle{
+				fileHandle: genericHandle{file.handle.(*os.File)},
+				first:      int64(file.header.FirstRow),
+				lock:       tt.lock,
+				write:      !tt.lock,
+			}
+			core := ioCore{handle: func(*File) (fileHandle, error) { return handle, nil }}
+			row := file.NewRow()
+			err = row.FieldByName("NAME").SetValue("ABC")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = core.WriteRow(file, row)
+			if err == nil {
+				t.Fatal("expected the row not to be written")
+			}
+			if tt.lock && !errors.Is(err, ErrLocked) {
+				t.Errorf("expected ErrLocked, got %v", err)
+			}
+			if file.header.RowsCount != 0 {
+				t.Errorf("expected 0 rows, got %d", file.header.RowsCount)
+			}
+			// The header in the file must not count the row either
+			err = file.ReadHeader()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.header.RowsCount != 0 {
+				t.Errorf("expected 0 rows in the file header, got %d", file.header.RowsCount)
+			}
+		})
+	}
+}
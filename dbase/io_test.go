@@ -0,0 +1,105 @@
+package dbase
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestSkip(t *testing.T) {
+	file, err := OpenTable(&Config{Filename: "../examples/test_data/table/TEST.DBF", ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	err = file.Skip(2)
+	if err != nil || file.Pointer() != 2 {
+		t.Fatalf("expected row 2, got row %d: %v", file.Pointer(), err)
+	}
+	err = file.Skip(-2)
+	if err != nil || !file.BOF() {
+		t.Fatalf("expected the first row, got row %d: %v", file.Pointer(), err)
+	}
+	err = file.Skip(-1)
+	if !errors.Is(err, ErrBOF) || !file.BOF() {
+		t.Errorf("expected ErrBOF at the first row, got row %d: %v", file.Pointer(), err)
+	}
+	err = file.Skip(10)
+	if err != nil || !file.EOF() {
+		t.Errorf("expected the end of the file, got row %d: %v", file.Pointer(), err)
+	}
+}
+
+// TestNext reads a table with an invalid row, the row pointer moves behind every row read, even if it can not be converted
+func TestNext(t *testing.T) {
+	column, err := NewColumn("NAME", Character, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Filename: filepath.Join(t.TempDir(), "NEXT.DBF"), Converter: NewDefaultConverter(charmap.Windows1252), TrimSpaces: true}
+	file, err := NewTable(FoxPro, config, []*Column{column}, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"FIRST", "INVALID", "LAST"} {
+		row := file.NewRow()
+		err = row.FieldByName("NAME").SetValue(name)
+		if err == nil {
+			err = row.Add()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	offset := int64(file.header.FirstRow) + int64(file.header.RowLength)
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := os.OpenFile(config.Filename, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = handle.WriteAt([]byte{'X'}, offset)
+	handle.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err = OpenTable(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	next := map[string]func() (*Row, error){
+		"Next": file.Next,
+		"NextInto": func() (*Row, error) {
+			row := &Row{}
+			return row, file.NextInto(row)
+		},
+	}
+	for name, read := range next {
+		err = file.GoTo(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for position, valid := range []bool{true, false, true} {
+			row, err := read()
+			if valid && (err != nil || row.Field(0).GetValue() == "INVALID") {
+				t.Errorf("%s: expected row %d, got %v", name, position, err)
+			}
+			if !valid && err == nil {
+				t.Errorf("%s: expected an error for the invalid row %d", name, position)
+			}
+			if file.Pointer() != uint32(position+1) {
+				t.Errorf("%s: expected the row pointer at %d, got %d", name, position+1, file.Pointer())
+			}
+		}
+		_, err = read()
+		if !errors.Is(err, ErrEOF) || !file.EOF() {
+			t.Errorf("%s: expected ErrEOF at the end of the file, got row %d: %v", name, file.Pointer(), err)
+		}
+	}
+}
@@ -4,15 +4,13 @@
 package dbase
 
 import (
-	"bytes"
-	"encoding/binary"
+	"errors"
+	"io"
 	"os"
-	"path"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
-	"time"
 
 	"golang.org/x/sys/windows"
 )
@@ -27,16 +25,19 @@ func (w WindowsIO) OpenTable(config *Config) (*File, error) {
 		return nil, NewError("missing dbase configuration or filename")
 	}
 	debugf("Opening table: %s - Read-only: %v - Exclusive: %v - Untested: %v - Trim spaces: %v - Write lock: %v - ValidateCodepage: %v - InterpretCodepage: %v", config.Filename, config.ReadOnly, config.Exclusive, config.Untested, config.TrimSpaces, config.WriteLock, config.ValidateCodePage, config.InterpretCodePage)
-	var err error
-	config.Filename, err = findFile(filepath.Clean(config.Filename))
+	fileName, err := findFile(filepath.Clean(config.Filename))
 	if err != nil {
 		return nil, WrapError(err)
 	}
+	if len(fileName) == 0 {
+		return nil, NewErrorf("file %s not found", config.Filename).Details(ErrNoDBF)
+	}
+	config.Filename = fileName
 	file, err := w.initFile(config)
 	if err != nil {
 		return nil, WrapError(err)
 	}
-	err = w.initTable(config, file)
+	err = w.core().initTable(file, config.Filename)
 	if err != nil {
 		return nil, WrapError(err)
 	}
@@ -48,7 +49,11 @@ func (w WindowsIO) OpenTable(config *Config) (*File, error) {
 }
 
 func (w WindowsIO) initFile(config *Config) (*File, error) {
-	fd, err := windows.Open(config.Filename, w.fileMode(config), 0644)
+	var fd windows.Handle
+	err := openRetry(config, config.Filename, func() (err error) {
+		fd, err = windows.Open(config.Filename, w.fileMode(config), 0644)
+		return err
+	})
 	if err != nil {
 		return nil, NewErrorf("opening DBF file %v failed", config.Filename).Details(err)
 	}
@@ -58,53 +63,28 @@ func (w WindowsIO) initFile(config *Config) (*File, error) {
 		handle:     &fd,
 		dbaseMutex: &sync.Mutex{},
 		memoMutex:  &sync.Mutex{},
+		locks:      &fileLocks{},
 	}, nil
 }
 
-func (w WindowsIO) initTable(config *Config, file *File) error {
-	err := file.ReadHeader()
-	if err != nil {
-		return WrapError(err)
-	}
-	// Check if the fileversion flag is expected, expand validFileVersion if needed
-	if err := ValidateFileVersion(file.header.FileType, config.Untested); err != nil {
-		return WrapError(err)
-	}
-	columns, nullFlag, err := file.ReadColumns()
-	if err != nil {
-		return WrapError(err)
-	}
-	file.nullFlagColumn = nullFlag
-	file.table = &Table{
-		name:    strings.TrimSuffix(strings.ToUpper(filepath.Base(config.Filename)), filepath.Ext(config.Filename)),
-		columns: columns,
-		mods:    make([]*Modification, len(columns)),
-	}
-	// Interpret the code page mark if needed
-	if config.InterpretCodePage || config.Converter == nil {
-		debugf("Interpreting code page mark...")
-		file.config.Converter = ConverterFromCodePage(file.header.CodePage)
-		debugf("Code page: 0x%02x => interpreted: 0x%02x", file.header.CodePage, file.config.Converter.CodePage())
-	}
-	// Check if the code page mark is matchin the converter
-	if config.ValidateCodePage && file.header.CodePage != file.config.Converter.CodePage() {
-		return NewErrorf("code page mark mismatch: %d != %d", file.header.CodePage, file.config.Converter.CodePage())
-	}
-	return nil
-}
-
 // Check if there is an FPT according to the header.
 // If there is we will try to open it in the same dir (using the same filename and case).
 // If the FPT file does not exist an error is returned.
 func (w WindowsIO) initRelated(config *Config, file *File) error {
 	if MemoFlag.Defined(file.header.TableFlags) {
-		ext := FPT
-		if strings.ToUpper(filepath.Ext(config.Filename)) == string(DBC) {
-			ext = DCT
+		relatedFile, err := findFile(memoFilename(config.Filename))
+		if err != nil {
+			return WrapError(err)
+		}
+		if len(relatedFile) == 0 {
+			return NewErrorf("memo file %s not found", memoFilename(config.Filename)).Details(ErrNoFPT)
 		}
-		relatedFile := strings.TrimSuffix(config.Filename, path.Ext(config.Filename)) + string(ext)
 		debugf("Opening related file: %s\n", relatedFile)
-		relatedFD, err := windows.Open(relatedFile, w.fileMode(config), 0644)
+		var relatedFD windows.Handle
+		err = openRetry(config, relatedFile, func() (err error) {
+			relatedFD, err = windows.Open(relatedFile, w.fileMode(config), 0644)
+			return err
+		})
 		if err != nil {
 			return NewErrorf("opening related file %v failed", relatedFile).Details(err)
 		}
@@ -194,591 +174,132 @@ func (w WindowsIO) Create(file *File) error {
 	return nil
 }
 
-func (w WindowsIO) ReadHeader(file *File) error {
-	debugf("Reading header...")
-	handle, err := w.getHandle(file)
+// CreateRelated creates the memo file next to the DBF file, an existing memo file is not overwritten
+func (w WindowsIO) CreateRelated(file *File) error {
+	relatedFile := memoFilename(file.config.Filename)
+	fptname, err := windows.UTF16FromString(relatedFile)
 	if err != nil {
-		return WrapError(err)
-	}
-	h := &Header{}
-	if _, err := windows.Seek(*handle, 0, 0); err != nil {
-		return NewErrorf("seeking to the beginning of the file failed").Details(err)
-	}
-	b := make([]byte, 30)
-	n, err := windows.Read(*handle, b)
-	if err != nil {
-		return NewErrorf("reading header failed").Details(err)
+		return NewErrorf("converting filename to UTF16 failed").Details(err)
 	}
-	// LittleEndian - Integers in table files are stored with the least significant byte first.
-	err = binary.Read(bytes.NewReader(b[:n]), binary.LittleEndian, h)
+	debugf("Creating related file: %s", relatedFile)
+	fd, err := windows.CreateFile(&fptname[0], windows.GENERIC_READ|windows.GENERIC_WRITE, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE, nil, windows.CREATE_NEW, windows.FILE_ATTRIBUTE_NORMAL, 0)
 	if err != nil {
-		return NewErrorf("reading header failed").Details(err)
+		return NewErrorf("creating FPT file failed").Details(err)
 	}
-	file.header = h
+	file.relatedHandle = &fd
 	return nil
 }
 
-func (w WindowsIO) WriteHeader(file *File) (err error) {
-	debugf("Writing header - exclusive writing: %v", file.config.WriteLock)
-	handle, err := w.getHandle(file)
-	if err != nil {
-		return WrapError(err)
-	}
-	// Lock the block we are writing to
-	position := uint32(0)
-	o := &windows.Overlapped{
-		Offset:     position,
-		OffsetHigh: position + uint32(file.header.FirstRow),
-	}
-	// Lock the block we are writing to
-	if file.config.WriteLock {
-		err = windows.LockFileEx(*handle, windows.LOCKFILE_EXCLUSIVE_LOCK, 0, position, position+uint32(file.header.FirstRow), o)
-		if err != nil {
-			return NewErrorf("locking file for writing failed").Details(err)
-		}
-		defer func() {
-			ulockErr := windows.UnlockFileEx(*handle, 0, position, position+uint32(file.header.FirstRow), o)
-			if err != nil {
-				err = NewErrorf("unlocking file after writing failed").Details(ulockErr)
-			}
-		}()
-	}
-	// Seek to the beginning of the file
-	_, err = windows.Seek(*handle, 0, 0)
-	if err != nil {
-		return NewErrorf("seeking to the beginning of the file failed").Details(err)
-	}
-	// Change the last modification date to the current date
-	file.header.Year = uint8(time.Now().Year() - 2000)
-	file.header.Month = uint8(time.Now().Month())
-	file.header.Day = uint8(time.Now().Day())
-	debugf("Writing header: %+v", file.header)
-	// Write the header
-	buf := new(bytes.Buffer)
-	err = binary.Write(buf, binary.LittleEndian, file.header)
-	if err != nil {
-		return NewErrorf("writing header failed").Details(err)
-	}
-	_, err = windows.Write(*handle, buf.Bytes())
-	if err != nil {
-		return NewErrorf("writing header failed").Details(err)
-	}
-	return nil
+func (w WindowsIO) ReadHeader(file *File) error {
+	return w.core().ReadHeader(file)
+}
+
+func (w WindowsIO) WriteHeader(file *File) error {
+	return w.core().WriteHeader(file)
 }
 
 func (w WindowsIO) ReadColumns(file *File) ([]*Column, *Column, error) {
-	debugf("Reading columns...")
-	handle, err := w.getHandle(file)
-	if err != nil {
-		return nil, nil, WrapError(err)
-	}
-	var nullFlag *Column
-	columns := make([]*Column, 0)
-	offset := int64(32)
-	b := make([]byte, 1)
-	for {
-		// Check if we are at 0x0D by reading one byte ahead
-		if _, err := windows.Seek(*handle, offset, 0); err != nil {
-			return nil, nil, NewErrorf("seeking to the beginning of the file failed").Details(err)
-		}
-		if _, err := windows.Read(*handle, b); err != nil {
-			return nil, nil, NewErrorf("reading column failed").Details(err)
-		}
-		if Marker(b[0]) == ColumnEnd {
-			break
-		}
-		// Position back one byte and read the column
-		if _, err := windows.Seek(*handle, -1, 1); err != nil {
-			return nil, nil, NewErrorf("seeking back one byte failed").Details(err)
-		}
-		buf := make([]byte, 33)
-		n, err := windows.Read(*handle, buf)
-		if err != nil {
-			return nil, nil, NewErrorf("reading column failed").Details(err)
-		}
-		column := &Column{}
-		err = binary.Read(bytes.NewReader(buf[:n]), binary.LittleEndian, column)
-		if err != nil {
-			return nil, nil, NewErrorf("reading column failed").Details(err)
-		}
-		if column.Name() == "_NullFlags" {
-			debugf("Found null flag column: %s", column.Name())
-			nullFlag = column
-			offset += 32
-			continue
-		}
-		debugf("Found column %v of type %v at offset: %d", column.Name(), column.Type(), offset)
-		columns = append(columns, column)
-		offset += 32
-	}
-	return columns, nullFlag, nil
+	return w.core().ReadColumns(file)
 }
 
-func (w WindowsIO) WriteColumns(file *File) (err error) {
-	debugf("Writing columns - exclusive writing: %v", file.config.WriteLock)
-	handle, err := w.getHandle(file)
-	if err != nil {
-		return WrapError(err)
-	}
-	// Lock the block we are writing to
-	position := uint32(32)
-	o := &windows.Overlapped{
-		Offset:     position,
-		OffsetHigh: position + uint32(file.header.FirstRow),
-	}
-	// Lock the block we are writing to
-	if file.config.WriteLock {
-		err = windows.LockFileEx(*handle, windows.LOCKFILE_EXCLUSIVE_LOCK, 0, position, position+uint32(file.header.FirstRow), o)
-		if err != nil {
-			return NewErrorf("locking file for writing failed").Details(err)
-		}
-		defer func() {
-			ulockErr := windows.UnlockFileEx(*handle, 0, position, position+uint32(file.header.FirstRow), o)
-			if err != nil {
-				err = NewErrorf("unlocking file after writing failed").Details(ulockErr)
-			}
-		}()
-	}
-	// Seek to the beginning of the file
-	_, err = windows.Seek(*handle, 32, 0)
-	if err != nil {
-		return NewErrorf("seeking to the beginning of the file failed").Details(err)
-	}
-	// Write the columns
-	buf := new(bytes.Buffer)
-	for _, column := range file.table.columns {
-		debugf("Writing column: %+v", column)
-		err = binary.Write(buf, binary.LittleEndian, column)
-		if err != nil {
-			return NewErrorf("writing column failed").Details(err)
-		}
-	}
-	if file.nullFlagColumn != nil {
-		debugf("Writing null flag column: %s", file.nullFlagColumn.Name())
-		err = binary.Write(buf, binary.LittleEndian, file.nullFlagColumn)
-		if err != nil {
-			return NewErrorf("writing null flag column failed").Details(err)
-		}
-	}
-	_, err = windows.Write(*handle, buf.Bytes())
-	if err != nil {
-		return NewErrorf("writing columns failed").Details(err)
-	}
-	// Write the column terminator
-	_, err = windows.Write(*handle, []byte{byte(ColumnEnd)})
-	if err != nil {
-		return NewErrorf("writing column terminator failed").Details(err)
-	}
-	// Write null till the end of the header
-	pos := file.header.FirstRow - uint16(len(file.table.columns)*32) - 33
-	if file.nullFlagColumn != nil {
-		pos -= 32
-	}
-	_, err = windows.Write(*handle, make([]byte, pos))
-	if err != nil {
-		return NewErrorf("writing null till the end of the header failed").Details(err)
-	}
-	return nil
+func (w WindowsIO) WriteColumns(file *File) error {
+	return w.core().WriteColumns(file)
 }
 
 func (w WindowsIO) ReadNullFlag(file *File, position uint64, column *Column) (bool, bool, error) {
-	handle, err := w.getHandle(file)
-	if err != nil {
-		return false, false, WrapError(err)
-	}
-	if file.nullFlagColumn == nil || (column.DataType != byte(Varchar) && column.DataType != byte(Varbinary)) {
-		return false, false, NewErrorf("null flag column is nil or column is not varchar or varbinary")
-	}
-	nullFlagPosition := file.table.nullFlagPosition(column)
-	pos := uint64(file.header.FirstRow) + position*uint64(file.header.RowLength) + uint64(file.nullFlagColumn.Position)
-	_, err = windows.Seek(*handle, int64(pos), 0)
-	if err != nil {
-		return false, false, NewErrorf("seeking to the beginning of the file failed").Details(err)
-	}
-	buf := make([]byte, file.nullFlagColumn.Length)
-	n, err := windows.Read(*handle, buf)
-	if err != nil {
-		return false, false, NewErrorf("reading null flag failed").Details(err)
-	}
-	if n != int(file.nullFlagColumn.Length) {
-		return false, false, NewErrorf("read %d bytes, expected %d", n, file.nullFlagColumn.Length)
-	}
-	if column.Flag == byte(NullableFlag) || column.Flag == byte(NullableFlag|BinaryFlag) {
-		debugf("Read _NullFlag for column %s => varlength: %v - null: %v", column.Name(), getNthBit(buf, nullFlagPosition), getNthBit(buf, nullFlagPosition+1))
-		return getNthBit(buf, nullFlagPosition), getNthBit(buf, nullFlagPosition+1), nil
-	}
-	debugf("Read _NullFlag for column %s => varlength: %v ", column.Name(), getNthBit(buf, nullFlagPosition))
-	return getNthBit(buf, nullFlagPosition), false, nil
+	return w.core().ReadNullFlag(file, position, column)
 }
 
 func (w WindowsIO) ReadMemoHeader(file *File) error {
-	debugf("Reading memo header...")
-	relatedHandle, err := w.getRelatedHandle(file)
-	if err != nil {
-		return WrapError(err)
-	}
-	if _, err := windows.Seek(*relatedHandle, 0, 0); err != nil {
-		return NewErrorf("seeking to the beginning of the file failed").Details(err)
-	}
-	b := make([]byte, 8)
-	n, err := windows.Read(*relatedHandle, b)
-	if err != nil {
-		return NewErrorf("reading memo header failed").Details(err)
-	}
-	h := &MemoHeader{}
-	err = binary.Read(bytes.NewReader(b[:n]), binary.BigEndian, h)
-	if err != nil {
-		return NewErrorf("reading memo header failed").Details(err)
-	}
-	debugf("Memo header: %+v", h)
-	file.relatedHandle = relatedHandle
-	file.memoHeader = h
-	return nil
+	return w.core().ReadMemoHeader(file)
 }
 
 func (w WindowsIO) ReadMemo(file *File, address []byte) ([]byte, bool, error) {
-	if file.relatedHandle == nil {
-		return nil, false, NewErrorf("no FPT file found")
-	}
-	relatedHandle, err := w.getRelatedHandle(file)
-	if err != nil {
-		return nil, false, WrapError(err)
-	}
-	// Determine the block number
-	block := binary.LittleEndian.Uint32(address)
-	if block == 0 {
-		return []byte{}, false, nil
-	}
-	position := int64(file.memoHeader.BlockSize) * int64(block)
-	debugf("Reading memo block %d at position %d", block, position)
-	// The position in the file is blocknumber*blocksize
-	_, err = windows.Seek(*relatedHandle, position, 0)
-	if err != nil {
-		return nil, false, NewErrorf("seeking to the beginning of the file failed").Details(err)
-	}
-	// Read the memo block header, instead of reading into a struct using binary.Read we just read the two
-	// uints in one buffer and then convert, this saves seconds for large DBF files with many memo columns
-	// as it avoids using the reflection in binary.Read
-	hbuf := make([]byte, 8)
-	_, err = windows.Read(*relatedHandle, hbuf)
-	if err != nil {
-		return nil, false, NewErrorf("reading memo block header failed").Details(err)
-	}
-	sign := binary.BigEndian.Uint32(hbuf[:4])
-	leng := binary.BigEndian.Uint32(hbuf[4:])
-	debugf("Memo block header => text: %v, length: %d", sign == 1, leng)
-	if leng == 0 {
-		// No data according to block header? Not sure if this should be an error instead
-		return []byte{}, sign == 1, nil
-	}
-	// Now read the actual data
-	buf := make([]byte, leng)
-	read, err := windows.Read(*relatedHandle, buf)
-	if err != nil {
-		return buf, sign == 1, NewErrorf("reading memo block data failed").Details(err)
-	}
-	if read != int(leng) {
-		return buf, sign == 1, NewErrorf("read %d bytes, expected %d", read, leng)
-	}
-	if sign == 1 {
-		buf, err = file.config.Converter.Decode(buf)
-		if err != nil {
-			return buf, sign == 1, WrapError(err)
-		}
-	}
-	return buf, sign == 1, nil
+	return w.core().ReadMemo(file, address)
 }
 
-func (w WindowsIO) WriteMemo(file *File, raw []byte, text bool, length int) ([]byte, error) {
-	file.memoMutex.Lock()
-	defer file.memoMutex.Unlock()
-	relatedHandle, err := w.getRelatedHandle(file)
-	if err != nil {
-		return nil, WrapError(err)
-	}
-	blocks := 1
-	blockPosition := file.memoHeader.NextFree
-	if length > 0 && file.memoHeader.BlockSize > 0 {
-		blocks = length / int(file.memoHeader.BlockSize)
-		if length%int(file.memoHeader.BlockSize) > 0 {
-			blocks++
-		}
-	}
-	// Write the memo header
-	err = file.WriteMemoHeader(blocks)
-	if err != nil {
-		return nil, WrapError(err)
-	}
-	// Put the block data together
-	data := make([]byte, 8)
-	// The first 4 bytes are the signature, 1 for text, 0 for binary(image)
-	if text {
-		binary.BigEndian.PutUint32(data[:4], 1)
-	} else {
-		binary.BigEndian.PutUint32(data[:4], 0)
-	}
-	// The next 4 bytes are the length of the data
-	binary.BigEndian.PutUint32(data[4:8], uint32(length))
-	// The rest is the data
-	data = append(data, raw...)
-	// Lock the block we are writing to
-	if file.config.WriteLock {
-		o := &windows.Overlapped{
-			Offset:     blockPosition,
-			OffsetHigh: blockPosition + uint32(file.memoHeader.BlockSize),
-		}
-		err = windows.LockFileEx(*relatedHandle, windows.LOCKFILE_EXCLUSIVE_LOCK, 0, blockPosition, blockPosition+uint32(file.memoHeader.BlockSize), o)
-		if err != nil {
-			return nil, NewErrorf("locking file for writing failed").Details(err)
-		}
-		defer func() {
-			ulockErr := windows.UnlockFileEx(*relatedHandle, 0, blockPosition, blockPosition+uint32(file.memoHeader.BlockSize), o)
-			if err != nil {
-				err = NewErrorf("unlocking file after writing failed").Details(ulockErr)
-			}
-		}()
-	}
-	position := int64(blockPosition) * int64(file.memoHeader.BlockSize)
-	debugf("Writing memo block %d at position %d", blockPosition, position)
-	// Seek to new the next free block
-	_, err = windows.Seek(*relatedHandle, position, 0)
-	if err != nil {
-		return nil, NewErrorf("seeking to the beginning of the file failed").Details(err)
-	}
-	// Write the memo data
-	_, err = windows.Write(*relatedHandle, data)
-	if err != nil {
-		return nil, NewErrorf("writing memo data failed").Details(err)
-	}
-	// Convert the block number to []byte
-	address, err := toBinary(blockPosition)
-	if err != nil {
-		return nil, WrapError(err)
-	}
-	return address, nil
+func (w WindowsIO) WriteMemo(file *File, address []byte, raw []byte, text bool, length int) ([]byte, error) {
+	return w.core().WriteMemo(file, address, raw, text, length)
 }
 
-func (w WindowsIO) WriteMemoHeader(file *File, size int) (err error) {
-	relatedHandle, err := w.getRelatedHandle(file)
-	if err != nil {
-		return WrapError(err)
-	}
-	debugf("Writing memo header...")
-	// Lock the block we are writing to
-	o := &windows.Overlapped{
-		Offset:     0,
-		OffsetHigh: uint32(file.header.FirstRow),
-	}
-	if file.config.WriteLock {
-		err = windows.LockFileEx(*relatedHandle, windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 0, uint32(file.header.FirstRow), o)
-		if err != nil {
-			return NewErrorf("locking file for writing failed").Details(err)
-		}
-		defer func() {
-			ulockErr := windows.UnlockFileEx(*relatedHandle, 0, 0, uint32(file.header.FirstRow), o)
-			if err != nil {
-				err = NewErrorf("unlocking file after writing failed").Details(ulockErr)
-			}
-		}()
-	}
-	// Seek to the beginning of the file
-	_, err = windows.Seek(*relatedHandle, 0, 0)
-	if err != nil {
-		return NewErrorf("seeking to the beginning of the file failed").Details(err)
-	}
-	// Calculate the next free block
-	file.memoHeader.NextFree += uint32(size)
-	// Write the memo header
-	buf := make([]byte, 8)
-	binary.BigEndian.PutUint32(buf[:4], file.memoHeader.NextFree)
-	binary.BigEndian.PutUint16(buf[6:8], file.memoHeader.BlockSize)
-	debugf("Writing memo header - next free: %d, block size: %d", file.memoHeader.NextFree, file.memoHeader.BlockSize)
-	_, err = windows.Write(*relatedHandle, buf)
-	if err != nil {
-		return NewErrorf("writing memo header failed").Details(err)
-	}
-	// Write null till end of header
-	_, err = windows.Write(*relatedHandle, make([]byte, 512-8))
-	if err != nil {
-		return NewErrorf("writing null till end of header failed").Details(err)
-	}
-	return nil
+func (w WindowsIO) WriteMemoHeader(file *File, size int) error {
+	return w.core().WriteMemoHeader(file, size)
 }
 
 func (w WindowsIO) ReadRow(file *File, position uint32) ([]byte, error) {
-	handle, err := w.getHandle(file)
-	if err != nil {
-		return nil, WrapError(err)
-	}
-	if position >= file.header.RowsCount {
-		return nil, NewErrorf("reading row %d failed", position).Details(ErrEOF)
-	}
-	pos := int64(file.header.FirstRow) + (int64(position) * int64(file.header.RowLength))
-	debugf("Reading row: %d at offset: %v", position, pos)
-	buf := make([]byte, file.header.RowLength)
-	_, err = windows.Seek(*handle, pos, 0)
-	if err != nil {
-		return buf, NewErrorf("seeking to position %d failed", pos).Details(err)
-	}
-	read, err := windows.Read(*handle, buf)
-	if err != nil {
-		return buf, NewErrorf("reading row %d failed", position).Details(err)
-	}
-	if read != int(file.header.RowLength) {
-		return buf, NewErrorf("read %d bytes, expected %d", read, file.header.RowLength)
-	}
-	return buf, nil
+	return w.core().ReadRow(file, position)
 }
 
-// writeRow writes raw row data to the given row position
-func (w WindowsIO) WriteRow(file *File, row *Row) (err error) {
-	debugf("Writing row: %d ...", row.Position)
-	row.handle.dbaseMutex.Lock()
-	defer row.handle.dbaseMutex.Unlock()
-	handle, err := w.getHandle(file)
-	if err != nil {
-		return WrapError(err)
-	}
-	// Convert the row to raw bytes
-	r, err := row.ToBytes()
-	if err != nil {
-		return WrapError(err)
-	}
-	// Update the header
-	position := int64(row.handle.header.FirstRow) + (int64(row.Position) * int64(row.handle.header.RowLength))
-	if row.Position >= row.handle.header.RowsCount {
-		position = int64(row.handle.header.FirstRow) + (int64(row.Position-1) * int64(row.handle.header.RowLength))
-		row.handle.header.RowsCount++
-	}
-	err = row.handle.WriteHeader()
-	if err != nil {
-		return WrapError(err)
-	}
-	// Lock the block we are writing to
-	if row.handle.config.WriteLock {
-		o := &windows.Overlapped{
-			Offset:     uint32(position),
-			OffsetHigh: uint32(position + int64(row.handle.header.RowLength)),
-		}
-		err = windows.LockFileEx(*handle, windows.LOCKFILE_EXCLUSIVE_LOCK, 0, uint32(position), uint32(position+int64(row.handle.header.RowLength)), o)
-		if err != nil {
-			return NewErrorf("locking file for writing failed").Details(err)
-		}
-		defer func() {
-			ulockErr := windows.UnlockFileEx(*handle, 0, uint32(position), uint32(position+int64(row.handle.header.RowLength)), o)
-			if err != nil {
-				err = NewErrorf("unlocking file after writing failed").Details(ulockErr)
-			}
-		}()
-	}
-	debugf("Writing row: %d at offset: %v", row.Position, position)
-	// Seek to the correct position
-	_, err = windows.Seek(*handle, position, 0)
-	if err != nil {
-		return NewErrorf("seeking to position %d failed", position).Details(err)
-	}
-	// Write the row
-	_, err = windows.Write(*handle, r)
-	if err != nil {
-		return NewErrorf("writing row %d failed", row.Position).Details(err)
-	}
-	return nil
+func (w WindowsIO) ReadMemos(file *File, blocks []uint32) (map[uint32]MemoData, error) {
+	return w.core().ReadMemos(file, blocks)
 }
 
-func (w WindowsIO) Search(file *File, field *Field, exactMatch bool) ([]*Row, error) {
-	if field.column.DataType == 'M' {
-		return nil, NewErrorf("searching memo fields is not supported")
-	}
-	handle, err := w.getHandle(file)
-	if err != nil {
-		return nil, WrapError(err)
-	}
-	debugf("Searching for value: %v in field: %s", field.GetValue(), field.column.Name())
-	// convert the value to bytes
-	val, err := file.Represent(field, !exactMatch)
-	if err != nil {
-		return nil, WrapError(err)
-	}
-	// Search for the value
-	rows := make([]*Row, 0)
-	position := uint64(file.header.FirstRow)
-	for i := uint32(0); i < file.header.RowsCount; i++ {
-		// Read the field value
-		p := int64(position) + int64(field.column.Position)
-		debugf("Searching at position: %d", p)
-		_, err := windows.Seek(*handle, p, 0)
-		position += uint64(file.header.RowLength)
-		if err != nil {
-			continue
-		}
-		buf := make([]byte, field.column.Length)
-		read, err := windows.Read(*handle, buf)
-		if err != nil {
-			continue
-		}
-		if read != int(field.column.Length) {
-			continue
-		}
-		// Check if the value matches
-		if bytes.Contains(buf, val) {
-			debugf("Found matching field at position: %d - Record %v position: %v ", p, i+1, p-int64(field.column.Position))
-			err := file.GoTo(i)
-			if err != nil {
-				continue
-			}
-			row, err := file.Row()
-			if err != nil {
-				continue
-			}
-			rows = append(rows, row)
-		}
-	}
-	return rows, nil
+func (w WindowsIO) ReadRows(file *File, position uint32, count int) ([][]byte, error) {
+	return w.core().ReadRows(file, position, count)
+}
+
+func (w WindowsIO) WriteRow(file *File, row *Row) error {
+	return w.core().WriteRow(file, row)
+}
+
+func (w WindowsIO) Search(file *File, field *Field, exactMatch bool, options SearchOptions) ([]*Row, error) {
+	return w.core().Search(file, field, exactMatch, options)
 }
 
 func (w WindowsIO) GoTo(file *File, row uint32) error {
-	if row > file.header.RowsCount {
-		file.table.rowPointer = file.header.RowsCount
-		return NewErrorf("go to %v > %v", row, file.header.RowsCount).Details(ErrEOF)
-	}
-	debugf("Going to row: %d", row)
-	file.table.rowPointer = row
-	return nil
+	return w.core().GoTo(file, row)
 }
 
 func (w WindowsIO) Skip(file *File, offset int64) {
-	newval := int64(file.table.rowPointer) + offset
-	if newval >= int64(file.header.RowsCount) {
-		file.table.rowPointer = file.header.RowsCount
-	}
-	if newval < 0 {
-		file.table.rowPointer = 0
-	}
-	file.table.rowPointer = uint32(newval)
-	debugf("Skipping %d row/s, new position: %d", offset, file.table.rowPointer)
+	w.core().Skip(file, offset)
 }
 
 func (w WindowsIO) Deleted(file *File) (bool, error) {
-	if file.table.rowPointer >= file.header.RowsCount {
-		return false, WrapError(ErrEOF)
-	}
-	handle, err := w.getHandle(file)
-	if err != nil {
-		return false, WrapError(err)
-	}
-	_, err = windows.Seek(*handle, int64(file.header.FirstRow)+(int64(file.table.rowPointer)*int64(file.header.RowLength)), 0)
-	if err != nil {
-		return false, NewErrorf("seeking to the beginning of the file failed").Details(err)
-	}
-	buf := make([]byte, 1)
-	read, err := windows.Read(*handle, buf)
-	if err != nil {
-		return false, NewErrorf("reading deleted flag failed").Details(err)
-	}
-	if read != 1 {
-		return false, NewErrorf("read %d bytes, expected 1", read).Details(ErrIncomplete)
+	return w.core().Deleted(file)
+}
+
+func (w WindowsIO) LockHeader(file *File) (func() error, error) {
+	return w.core().LockHeader(file)
+}
+
+func (w WindowsIO) LockRow(file *File, position uint32) (func() error, error) {
+	return w.core().LockRow(file, position)
+}
+
+func (w WindowsIO) LockTable(file *File) (func() error, error) {
+	return w.core().LockTable(file)
+}
+
+func (w WindowsIO) Truncate(file *File, size int64) error {
+	return w.core().Truncate(file, size)
+}
+
+func (w WindowsIO) Sync(file *File) error {
+	return w.core().Sync(file)
+}
+
+func (w WindowsIO) TruncateRelated(file *File, size int64) error {
+	return w.core().TruncateRelated(file, size)
+}
+
+// core returns the shared IO implementation working on the windows handles
+func (w WindowsIO) core() ioCore {
+	return ioCore{
+		handle: func(file *File) (fileHandle, error) {
+			handle, err := w.getHandle(file)
+			if err != nil {
+				return nil, WrapError(err)
+			}
+			return windowsHandle{*handle}, nil
+		},
+		related: func(file *File) (fileHandle, error) {
+			handle, err := w.getRelatedHandle(file)
+			if err != nil {
+				return nil, WrapError(err)
+			}
+			return windowsHandle{*handle}, nil
+		},
 	}
-	return Marker(buf[0]) == Deleted, nil
 }
 
 func (w WindowsIO) getHandle(file *File) (*windows.Handle, error) {
@@ -802,3 +323,72 @@ func (w WindowsIO) getRelatedHandle(file *File) (*windows.Handle, error) {
 	}
 	return handle, nil
 }
+
+// windowsHandle implements positioned reads, writes and region locks using overlapped structures
+type windowsHandle struct {
+	windows.Handle
+}
+
+func (h windowsHandle) ReadAt(p []byte, offset int64) (int, error) {
+	var done uint32
+	err := windows.ReadFile(h.Handle, p, &done, overlapped(offset))
+	if err == windows.ERROR_HANDLE_EOF {
+		return int(done), io.EOF
+	}
+	if err != nil {
+		return int(done), err
+	}
+	if int(done) < len(p) {
+		return int(done), io.EOF
+	}
+	return int(done), nil
+}
+
+func (h windowsHandle) WriteAt(p []byte, offset int64) (int, error) {
+	var done uint32
+	err := windows.WriteFile(h.Handle, p, &done, overlapped(offset))
+	return int(done), err
+}
+
+// sharingViolation returns if opening a file failed because another process opened it without sharing access
+func sharingViolation(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
+
+func (h windowsHandle) Lock(offset int64, length int64) (func() error, error) {
+	err := windows.LockFileEx(h.Handle, windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, uint32(length), uint32(length>>32), overlapped(offset))
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		return windows.UnlockFileEx(h.Handle, 0, uint32(length), uint32(length>>32), overlapped(offset))
+	}, nil
+}
+
+func (h windowsHandle) Truncate(size int64) error {
+	return windows.Ftruncate(h.Handle, size)
+}
+
+func (h windowsHandle) Size() (int64, error) {
+	var info windows.ByHandleFileInformation
+	err := windows.GetFileInformationByHandle(h.Handle, &info)
+	if err != nil {
+		return 0, err
+	}
+	return int64(info.FileSizeHigh)<<32 | int64(info.FileSizeLow), nil
+}
+
+func (h windowsHandle) Sync() error {
+	return windows.FlushFileBuffers(h.Handle)
+}
+
+// overlapped returns an overlapped structure pointing to the offset
+func overlapped(offset int64) *windows.Overlapped {
+	return &windows.Overlapped{
+		Offset:     uint32(offset),
+		OffsetHigh: uint32(offset >> 32),
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package dbase
+
+import "testing"
+
+// platformBackend is the IO implementation of the platform the tests run on
+func platformBackend() ioBackend {
+	return ioBackend{
+		name: "windows",
+		io:   func(*testing.T, string, bool) IO { return WindowsIO{} },
+	}
+}
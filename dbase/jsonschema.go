@@ -0,0 +1,106 @@
+package dbase
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+)
+
+// jsonSchemaDraft is the JSON Schema dialect of the generated documents
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the JSON Schema document describing a row of the table
+type jsonSchema struct {
+	Schema     string                         `json:"$schema"`
+	Title      string                         `json:"title"`
+	Type       string                         `json:"type"`
+	Properties map[string]*jsonSchemaProperty `json:"properties"`
+}
+
+// jsonSchemaProperty describes the value of a single column
+type jsonSchemaProperty struct {
+	Type        interface{} `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Format      string      `json:"format,omitempty"`
+	MaxLength   *int        `json:"maxLength,omitempty"`
+	Minimum     *int64      `json:"minimum,omitempty"`
+	Maximum     *int64      `json:"maximum,omitempty"`
+	ReadOnly    bool        `json:"readOnly,omitempty"`
+}
+
+// JSONSchema returns a JSON Schema document describing a row of the table as accepted by RowFromJSON.
+// Each column is a property with the JSON type of the column, the maximum length of character columns
+// and the integer range of integer columns. Nullable columns also accept null.
+// Properties are named like the keys of Row.ToMap, so external keys of modifications are used.
+func (file *File) JSONSchema() ([]byte, error) {
+	schema := &jsonSchema{
+		Schema:     jsonSchemaDraft,
+		Title:      file.table.name,
+		Type:       "object",
+		Properties: make(map[string]*jsonSchemaProperty, len(file.table.columns)),
+	}
+	for i, column := range file.table.columns {
+		property, err := column.jsonSchema(file.table.numericStrings[column])
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		name := column.Name()
+		if mod := file.table.mods[i]; mod != nil && len(mod.ExternalKey) != 0 {
+			name = mod.ExternalKey
+			property.Description = column.Name()
+		}
+		if comment := file.table.comments[column]; len(comment) > 0 {
+			property.Description = strings.TrimPrefix(property.Description+": "+comment, ": ")
+		}
+		schema.Properties[name] = property
+	}
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, NewError("unable to marshal JSON schema").Details(err)
+	}
+	return b, nil
+}
+
+// jsonSchema returns the JSON Schema property describing the values of the column,
+// numeric columns read as strings (see SetNumericAsString) are described as strings
+func (c *Column) jsonSchema(numericString bool) (*jsonSchemaProperty, error) {
+	property := &jsonSchemaProperty{}
+	var typ string
+	switch DataType(c.DataType) {
+	case Character, Varchar:
+		typ = "string"
+		length := c.Size()
+		property.MaxLength = &length
+	case Memo, General, Picture, Blob, Varbinary:
+		typ = "string"
+	case Numeric:
+		typ = "number"
+		if numericString {
+			typ = "string"
+			length := int(c.Length)
+			property.MaxLength = &length
+		} else if c.Decimals == 0 {
+			typ = "integer"
+		}
+	case Float, Double, Currency:
+		typ = "number"
+	case Integer:
+		typ = "integer"
+		min, max := int64(math.MinInt32), int64(math.MaxInt32)
+		property.Minimum = &min
+		property.Maximum = &max
+	case Logical:
+		typ = "boolean"
+	case Date, DateTime:
+		typ = "string"
+		property.Format = "date-time"
+	default:
+		return nil, NewErrorf("unsupported data type %q at column %s", c.DataType, c.Name()).Details(ErrUnknownDataType)
+	}
+	property.ReadOnly = ColumnFlag(c.Flag)&AutoincrementFlag == AutoincrementFlag
+	property.Type = typ
+	if ColumnFlag(c.Flag)&NullableFlag != 0 {
+		property.Type = []string{typ, "null"}
+	}
+	return property, nil
+}
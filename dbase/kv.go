@@ -0,0 +1,165 @@
+package dbase
+
+import (
+	"math"
+	"strings"
+	"sync"
+)
+
+// KV is a read-only key-value view of a table, created by AsKV.
+// The rows are looked up by the value of the key column using an index kept in memory,
+// so only the requested row is read from the file.
+type KV struct {
+	file      *File
+	column    *Column
+	pos       int
+	mutex     sync.RWMutex
+	index     map[string]uint32 // Row position by normalized key
+	keys      []interface{}     // Keys in the order of the table
+	positions []uint32          // Row positions in the order of the table
+}
+
+// AsKV returns a read-only key-value view of the table using the column (name or external key) as key.
+// The index is built by reading the key column of all rows once. Deleted rows are skipped and
+// if multiple rows have the same key, the first row in the table is used.
+// String keys are compared without leading and trailing spaces and whole numbers match regardless of their type.
+// Changes to the table are only visible after calling Reload.
+func AsKV(file *File, keyColumn string) (*KV, error) {
+	pos := file.columnPosByKey(keyColumn)
+	if pos < 0 {
+		return nil, NewErrorf("column '%s' not found", keyColumn)
+	}
+	column := file.table.columns[pos]
+	if DataType(column.DataType) == Memo {
+		return nil, NewErrorf("memo column %s can not be used as key", column.Name())
+	}
+	kv := &KV{file: file, column: column, pos: pos}
+	err := kv.Reload()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return kv, nil
+}
+
+// Reload rebuilds the index from the current content of the table
+func (kv *KV) Reload() error {
+	debugf("Building key-value index of column %s", kv.column.Name())
+	layout := kv.file.table.layout.Columns[kv.pos]
+	index := make(map[string]uint32)
+	keys := make([]interface{}, 0)
+	positions := make([]uint32, 0)
+	for position := uint32(0); position < kv.file.header.RowsCount; position++ {
+		data, err := kv.file.ReadRow(position)
+		if err != nil {
+			return WrapError(err)
+		}
+		if Marker(data[0]) == Deleted {
+			continue
+		}
+		key, err := kv.file.interpret(data[layout.Offset:layout.Offset+layout.Length], kv.column, kv.file.table.layout.rowNullFlags(data))
+		if err != nil {
+			return NewErrorf("failed to read key of row %d", position).Details(err)
+		}
+		normalized := kvKey(key)
+		if _, ok := index[normalized]; ok {
+			debugf("Skipping duplicate key %v in row %d", key, position)
+			continue
+		}
+		index[normalized] = position
+		keys = append(keys, key)
+		positions = append(positions, position)
+	}
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+	kv.index = index
+	kv.keys = keys
+	kv.positions = positions
+	return nil
+}
+
+// Get returns the row with the key, false if there is none
+func (kv *KV) Get(key interface{}) (*Row, bool, error) {
+	kv.mutex.RLock()
+	position, ok := kv.index[kvKey(key)]
+	kv.mutex.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	row, err := kv.row(position)
+	if err != nil {
+		return nil, false, WrapError(err)
+	}
+	return row, true, nil
+}
+
+// Scan calls fn with the key and row of every entry in the order of the table until fn returns false
+func (kv *KV) Scan(fn func(key interface{}, row *Row) bool) error {
+	kv.mutex.RLock()
+	keys, positions := kv.keys, kv.positions
+	kv.mutex.RUnlock()
+	for i, position := range positions {
+		row, err := kv.row(position)
+		if err != nil {
+			return WrapError(err)
+		}
+		if !fn(keys[i], row) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Len returns the number of keys
+func (kv *KV) Len() int {
+	kv.mutex.RLock()
+	defer kv.mutex.RUnlock()
+	return len(kv.positions)
+}
+
+// row reads the row at position without moving the internal row pointer
+func (kv *KV) row(position uint32) (*Row, error) {
+	data, err := kv.file.ReadRow(position)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	row, err := kv.file.BytesToRow(data)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	row.Position = position
+	return row, nil
+}
+
+// kvKey normalizes a key, so values of the table and looked up values of a similar type match
+func kvKey(key interface{}) string {
+	switch v := key.(type) {
+	case string:
+		key = strings.TrimSpace(v)
+	case int8:
+		key = int64(v)
+	case int16:
+		key = int64(v)
+	case int32:
+		key = int64(v)
+	case uint8:
+		key = int64(v)
+	case uint16:
+		key = int64(v)
+	case uint32:
+		key = int64(v)
+	case uint:
+		if uint64(v) <= math.MaxInt64 {
+			key = int64(v)
+		}
+	case uint64:
+		if v <= math.MaxInt64 {
+			key = int64(v)
+		}
+	case float32:
+		key = float64(v)
+	}
+	if f, ok := key.(float64); ok && f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+		key = int64(f)
+	}
+	return string(sortKey(key))
+}
@@ -0,0 +1,152 @@
+package dbase
+
+// RowLayout describes where each column is stored inside the raw row data.
+// It is computed once when a table is opened or created, so converting rows does not need to iterate the columns again.
+type RowLayout struct {
+	Length         int                       // Length of a row in bytes, including the deleted flag and the null flag column
+	Columns        []*ColumnLayout           // Layout of each column in the order of the table columns
+	NullFlagOffset int                       // Offset of the _NullFlags column in the row or -1 if there is none
+	NullFlagLength int                       // Length of the _NullFlags column in bytes
+	byColumn       map[*Column]*ColumnLayout // Lookup of the column layout by column
+}
+
+// ColumnLayout describes the position of a single column inside the raw row data
+type ColumnLayout struct {
+	Offset       int      // Offset of the column in the row, the deleted flag is at offset 0
+	Length       int      // Length of the column in bytes
+	Type         DataType // Data type of the column
+	VarLengthBit int      // Bit in the _NullFlags column set if the value is shorter than the column or -1
+	NullBit      int      // Bit in the _NullFlags column set if the value is null or -1
+}
+
+// newRowLayout calculates the layout of the columns.
+// The stored column positions are used, so gaps between columns (e.g. removed system columns) are skipped.
+// If the positions are not set or inconsistent, the columns are expected to follow each other.
+// Variable length columns get one bit in the null flag column and nullable variable length columns a second bit.
+func newRowLayout(columns []*Column, nullFlag *Column, rowLength uint16) *RowLayout {
+	layout := &RowLayout{
+		Columns:        make([]*ColumnLayout, len(columns)),
+		NullFlagOffset: -1,
+		byColumn:       make(map[*Column]*ColumnLayout, len(columns)),
+	}
+	positioned := validPositions(columns, nullFlag, rowLength)
+	if !positioned {
+		debugf("Column positions are not set or inconsistent, using cumulative offsets")
+	}
+	offset := 1
+	bit := 0
+	for i, column := range columns {
+		if positioned {
+			offset = int(column.Position)
+		}
+		c := &ColumnLayout{
+			Offset:       offset,
+			Length:       column.Size(),
+			Type:         DataType(column.DataType),
+			VarLengthBit: -1,
+			NullBit:      -1,
+		}
+		if c.Type == Varchar || c.Type == Varbinary {
+			c.VarLengthBit = bit
+			bit++
+			if column.Flag == byte(NullableFlag) || column.Flag == byte(NullableFlag|BinaryFlag) {
+				c.NullBit = bit
+				bit++
+			}
+		}
+		layout.Columns[i] = c
+		layout.byColumn[column] = c
+		offset += c.Length
+		if offset > layout.Length {
+			layout.Length = offset
+		}
+	}
+	if nullFlag != nil {
+		if positioned {
+			offset = int(nullFlag.Position)
+		}
+		layout.NullFlagOffset = offset
+		layout.NullFlagLength = int(nullFlag.Length)
+		offset += layout.NullFlagLength
+		if offset > layout.Length {
+			layout.Length = offset
+		}
+	}
+	if layout.Length == 0 {
+		layout.Length = offset
+	}
+	return layout
+}
+
+// validPositions returns true if every column has a position behind the deleted flag,
+// the columns do not overlap and fit into the row length
+func validPositions(columns []*Column, nullFlag *Column, rowLength uint16) bool {
+	all := columns
+	if nullFlag != nil {
+		all = append(append(make([]*Column, 0, len(columns)+1), columns...), nullFlag)
+	}
+	used := make([]bool, rowLength)
+	for _, column := range all {
+		start := int64(column.Position)
+		end := start + int64(column.Size())
+		if start < 1 || end > int64(rowLength) {
+			return false
+		}
+		for i := start; i < end; i++ {
+			if used[i] {
+				return false
+			}
+			used[i] = true
+		}
+	}
+	return true
+}
+
+// Layout returns the precomputed layout of the columns inside a row
+func (file *File) Layout() *RowLayout {
+	return file.table.layout
+}
+
+// column returns the layout of the column or nil if the column does not belong to the table
+func (layout *RowLayout) column(column *Column) *ColumnLayout {
+	return layout.byColumn[column]
+}
+
+// nullFlags returns the variable length and the null bit of the column from the _NullFlags column data
+func (c *ColumnLayout) nullFlags(flags []byte) (bool, bool) {
+	return c.VarLengthBit >= 0 && getNthBit(flags, c.VarLengthBit), c.NullBit >= 0 && getNthBit(flags, c.NullBit)
+}
+
+// rowNullFlags returns the _NullFlags column data of the raw row or nil if the table has no null flag column
+func (layout *RowLayout) rowNullFlags(data []byte) []byte {
+	if layout.NullFlagOffset < 0 || len(data) < layout.NullFlagOffset+layout.NullFlagLength {
+		return nil
+	}
+	return data[layout.NullFlagOffset : layout.NullFlagOffset+layout.NullFlagLength]
+}
+
+// checkRowLength compares the row length of the header with the length of the columns and applies the RowLength policy of the config.
+// The columns length is the sum of the deleted flag, the column lengths and the null flag column length, or the end of the
+// last column if the columns are positioned.
+func (file *File) checkRowLength() error {
+	columns := file.table.layout.Length
+	header := int(file.header.RowLength)
+	if columns == header {
+		return nil
+	}
+	switch {
+	case file.config.RowLength == RowLengthError:
+		return NewErrorf("row length %d of the header differs from the length %d of the columns", header, columns)
+	case file.config.RowLength == RowLengthColumns:
+		if columns > MaxRowLength {
+			return NewErrorf("length %d of the columns exceeds the maximum row length %d", columns, MaxRowLength)
+		}
+		file.warn("row length %d of the header differs from the length %d of the columns, using the length of the columns", header, columns)
+		file.header.RowLength = uint16(columns)
+		return nil
+	case columns > header:
+		return NewErrorf("columns of %d bytes do not fit into the row length %d of the header, use RowLengthColumns to read the rows with the length of the columns", columns, header)
+	}
+	file.warn("row length %d of the header exceeds the length %d of the columns, %d bytes at the end of each row are ignored", header, columns, header-columns)
+	return nil
+}
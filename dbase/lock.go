@@ -0,0 +1,178 @@
+package dbase
+
+import (
+	"sync"
+	"time"
+)
+
+// FoxPro does not lock the data itself but single bytes far behind the end of the file. The offsets of the
+// two schemes are those of the DB_DBFLOCK_VFPX and DB_DBFLOCK_VFP schemes of the Harbour DBF driver (include/hbdbf.h).
+//
+// Visual FoxPro tables lock the header at 0x7FFFFFFE and row n (counted from 1) at 0x7FFFFFFE-n,
+// a table lock covers the row locks below the header lock.
+//
+// Older FoxPro tables lock the header at 0x40000000 and a row at 0x40000000 plus the file offset of the row,
+// a table lock covers the range of 0x3FFFFFFD bytes behind the header lock.
+const (
+	vfpLockOffset    = 0x7FFFFFFE         // Offset of the Visual FoxPro header lock, row locks are placed below it
+	vfpTableLockSize = MaxRecordsPerTable // Length of a Visual FoxPro table lock, ending before the header lock
+	foxLockOffset    = 0x40000000         // Offset of the FoxPro 2.x header lock, row locks are placed behind it
+	foxTableLockSize = 0x3FFFFFFD         // Length of a FoxPro 2.x table lock, starting behind the header lock
+)
+
+const (
+	lockRetryDelay    = 10 * time.Millisecond  // Delay before the first retry of a lock held by another process
+	lockMaxRetryDelay = 500 * time.Millisecond // Maximum delay between two lock attempts
+)
+
+// lockRegion is a locked byte range of a file
+type lockRegion struct {
+	offset int64
+	length int64
+}
+
+// fileLocks keeps track of the regions locked through a file.
+// A region covered by a lock the file already holds is not locked again,
+// so writing a row of a locked table does not conflict with the own table lock.
+type fileLocks struct {
+	mutex   sync.Mutex
+	regions []lockRegion
+}
+
+// holds returns if the region is covered by a lock held by the file
+func (l *fileLocks) holds(offset int64, length int64) bool {
+	if l == nil {
+		return false
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, r := range l.regions {
+		if offset >= r.offset && offset+length <= r.offset+r.length {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *fileLocks) add(offset int64, length int64) {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.regions = append(l.regions, lockRegion{offset: offset, length: length})
+}
+
+func (l *fileLocks) remove(offset int64, length int64) {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i, r := range l.regions {
+		if r.offset == offset && r.length == length {
+			l.regions = append(l.regions[:i], l.regions[i+1:]...)
+			return
+		}
+	}
+}
+
+// vfpLocking returns if the table uses the Visual FoxPro locking scheme
+func vfpLocking(file *File) bool {
+	switch FileVersion(file.header.FileType) {
+	case FoxPro, FoxProAutoincrement, FoxProVar:
+		return true
+	}
+	return false
+}
+
+// headerLock returns the offset of the header lock of the table
+func headerLock(file *File) int64 {
+	if vfpLocking(file) {
+		return vfpLockOffset
+	}
+	return foxLockOffset
+}
+
+// rowLock returns the offset of the lock of the row at the position
+func rowLock(file *File, position uint32) int64 {
+	if vfpLocking(file) {
+		return vfpLockOffset - int64(position) - 1
+	}
+	return foxLockOffset + int64(file.header.FirstRow) + int64(position)*int64(file.header.RowLength)
+}
+
+// tableLock returns the region of the table lock, it conflicts with every row lock but not with the header lock
+func tableLock(file *File) (int64, int64) {
+	if vfpLocking(file) {
+		return vfpLockOffset - vfpTableLockSize, vfpTableLockSize
+	}
+	return foxLockOffset + 1, foxTableLockSize
+}
+
+// acquire locks the region, a region locked by another process is retried with an increasing delay until the lock timeout expires.
+// Regions are tracked in locks if it is not nil.
+func acquire(file *File, handle fileHandle, locks *fileLocks, offset int64, length int64) (func() error, error) {
+	if locks.holds(offset, length) {
+		debugf("Region of %d bytes at offset %d is already locked", length, offset)
+		return func() error { return nil }, nil
+	}
+	debugf("Locking %d bytes at offset %d", length, offset)
+	deadline := time.Now().Add(file.config.LockTimeout)
+	delay := lockRetryDelay
+	for {
+		unlock, err := handle.Lock(offset, length)
+		if err == nil {
+			locks.add(offset, length)
+			return func() error {
+				locks.remove(offset, length)
+				return unlock()
+			}, nil
+		}
+		if err != ErrLocked {
+			return nil, NewErrorf("failed to lock %d bytes at offset %d", length, offset).Details(err)
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, NewErrorf("failed to lock %d bytes at offset %d within %v", length, offset, file.config.LockTimeout).Details(ErrLocked)
+		}
+		if delay > remaining {
+			delay = remaining
+		}
+		debugf("Region at offset %d is locked, retrying in %v", offset, delay)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > lockMaxRetryDelay {
+			delay = lockMaxRetryDelay
+		}
+	}
+}
+
+// openRetry calls open until it succeeds or fails for another reason than a sharing violation.
+// Sharing violations, e.g. a table opened exclusively by a FoxPro application, are retried up to
+// MaxOpenRetries times with an increasing delay and then reported as ErrLocked.
+func openRetry(config *Config, filename string, open func() error) error {
+	delay := lockRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := open()
+		if err == nil || !sharingViolation(err) {
+			return err
+		}
+		if attempt >= config.MaxOpenRetries {
+			return NewErrorf("file %s is in use by another process, close it there or open it in shared mode and retry, or configure MaxOpenRetries", filename).Details(ErrLocked).Details(err)
+		}
+		debugf("File %s is in use by another process, retrying in %v", filename, delay)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > lockMaxRetryDelay {
+			delay = lockMaxRetryDelay
+		}
+	}
+}
+
+// release releases a lock and reports an unlock error if no other error occurred
+func release(unlock func() error, err *error) {
+	if uerr := unlock(); uerr != nil && *err == nil {
+		*err = NewError("failed to release file lock").Details(uerr)
+	}
+}
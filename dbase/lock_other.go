@@ -0,0 +1,16 @@
+//go:build !windows && !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !windows,!aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package dbase
+
+import "os"
+
+// sharingViolation is always false on systems without file sharing restrictions
+func sharingViolation(_ error) bool {
+	return false
+}
+
+// lockFile is a no-op on systems without record locking
+func lockFile(_ *os.File, _ int64, _ int64) (func() error, error) {
+	return func() error { return nil }, nil
+}
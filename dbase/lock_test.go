@@ -0,0 +1,45 @@
+package dbase
+
+import "testing"
+
+func TestLockOffsets(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  FileVersion
+		header   int64
+		row      [2]int64 // Lock offsets of the first and third row
+		table    int64
+		tableLen int64
+		last     uint32 // Last position the table lock has to cover
+	}{
+		{name: "VisualFoxPro", version: FoxProVar, header: 0x7FFFFFFE, row: [2]int64{0x7FFFFFFD, 0x7FFFFFFB}, table: 0x7FFFFFFE - MaxRecordsPerTable, tableLen: MaxRecordsPerTable, last: MaxRecordsPerTable - 1},
+		{name: "FoxPro2", version: FoxPro2Memo, header: 0x40000000, row: [2]int64{0x40000000 + 296, 0x40000000 + 296 + 2*10}, table: 0x40000001, tableLen: 0x3FFFFFFD, last: (0x3FFFFFFD-296)/10 - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &File{header: &Header{FileType: byte(tt.version), FirstRow: 296, RowLength: 10}}
+			if got := headerLock(file); got != tt.header {
+				t.Errorf("header lock at 0x%X, expected 0x%X", got, tt.header)
+			}
+			for i, position := range []uint32{0, 2} {
+				if got := rowLock(file, position); got != tt.row[i] {
+					t.Errorf("lock of row %d at 0x%X, expected 0x%X", position, got, tt.row[i])
+				}
+			}
+			offset, length := tableLock(file)
+			if offset != tt.table || length != tt.tableLen {
+				t.Errorf("table lock of %d bytes at 0x%X, expected %d bytes at 0x%X", length, offset, tt.tableLen, tt.table)
+			}
+			locks := &fileLocks{}
+			locks.add(offset, length)
+			if locks.holds(headerLock(file), 1) {
+				t.Error("table lock covers the header lock")
+			}
+			for _, position := range []uint32{0, tt.last} {
+				if !locks.holds(rowLock(file, position), 1) {
+					t.Errorf("table lock does not cover the lock of row %d", position)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package dbase
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// sharingViolation returns if opening a file failed because another process holds a conflicting lease on it
+func sharingViolation(err error) bool {
+	return errors.Is(err, unix.EWOULDBLOCK)
+}
+
+// lockFile places an exclusive fcntl record lock on the region, ErrLocked is returned if another process holds a lock
+func lockFile(handle *os.File, offset int64, length int64) (func() error, error) {
+	conn, err := handle.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	flock := func(typ int16) error {
+		lock := &unix.Flock_t{
+			Type:   typ,
+			Whence: io.SeekStart,
+			Start:  offset,
+			Len:    length,
+		}
+		var lockErr error
+		err := conn.Control(func(fd uintptr) {
+			lockErr = unix.FcntlFlock(fd, unix.F_SETLK, lock)
+		})
+		if err != nil {
+			return err
+		}
+		return lockErr
+	}
+	err = flock(unix.F_WRLCK)
+	if err == unix.EAGAIN || err == unix.EACCES {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() error { return flock(unix.F_UNLCK) }, nil
+}
@@ -0,0 +1,217 @@
+package dbase
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+const (
+	memoHeaderSize = 512     // Size of the memo file header, the first memo block follows the header
+	memoChunkSize  = 1 << 20 // Maximum number of bytes written at once when writing memo data
+
+	memoPrefetchGap  = 64 << 10 // Maximum distance between memos read at once by ReadMemos
+	memoPrefetchSpan = 4 << 20  // Maximum distance between the first and last memo read at once by ReadMemos
+	memoPrefetchTail = 16 << 10 // Bytes read after the last memo of a read, larger memos are read on their own
+)
+
+// memoBlock returns the header of a memo block and the number of blocks the memo occupies, used by all IO implementations.
+// The block starts with the signature (1 for text, 0 for binary) and the length of the data, followed by the data.
+// The block is padded with zeros to a multiple of the block size, so the next memo starts at a block boundary.
+func memoBlock(text bool, length int, blockSize uint16) ([]byte, int, error) {
+	if blockSize == 0 {
+		return nil, 0, NewError("invalid memo block size 0")
+	}
+	if length < 0 || int64(length) > math.MaxUint32 {
+		return nil, 0, NewErrorf("invalid memo length %d", length)
+	}
+	size := 8 + int64(length)
+	blocks := size / int64(blockSize)
+	if size%int64(blockSize) > 0 {
+		blocks++
+	}
+	header := make([]byte, 8)
+	if text {
+		binary.BigEndian.PutUint32(header[:4], 1)
+	}
+	binary.BigEndian.PutUint32(header[4:8], uint32(length))
+	return header, int(blocks), nil
+}
+
+// nextMemoBlock returns the block the next memo is written to.
+// The blocks occupied by the memo header are skipped if the header does not point behind them.
+func nextMemoBlock(header *MemoHeader) uint32 {
+	if header.BlockSize == 0 {
+		return header.NextFree
+	}
+	first := uint32(memoHeaderSize / int(header.BlockSize))
+	if memoHeaderSize%int(header.BlockSize) > 0 {
+		first++
+	}
+	if header.NextFree < first {
+		return first
+	}
+	return header.NextFree
+}
+
+// memoLimit returns the maximum memo size in bytes, the configured maximum memo size
+// or the maximum length a memo block can store
+func (file *File) memoLimit() int64 {
+	limit := int64(math.MaxUint32)
+	if file.config.MaxMemoSize > 0 && int64(file.config.MaxMemoSize) < limit {
+		limit = int64(file.config.MaxMemoSize)
+	}
+	return limit
+}
+
+// checkMemoSize returns an error if the memo length exceeds the configured maximum memo size
+// or the maximum length a memo block can store
+func (file *File) checkMemoSize(length int64) error {
+	limit := file.memoLimit()
+	if length > limit {
+		return NewErrorf("memo of %d bytes exceeds the maximum memo size of %d bytes", length, limit).Details(ErrMemoTooLarge)
+	}
+	return nil
+}
+
+// SetMemoBlockSize rebuilds the memo file with the block size, e.g. to reduce the space wasted by a large block size for short texts.
+// The memos referenced by the memo, general, picture and blob columns of the rows, including deleted rows, are copied to a temporary memo file and written back one after another,
+// then the addresses in the rows are rewritten. Memos no row references are dropped. The rebuild is not atomic,
+// if it fails while the memos are written back the memo file is incomplete, so the table should be backed up before.
+func (file *File) SetMemoBlockSize(blockSize uint16) (err error) {
+	if blockSize == 0 {
+		return NewError("invalid memo block size 0")
+	}
+	if file.config.ReadOnly {
+		return NewError("can not rebuild the memo file of a read-only table")
+	}
+	if file.memoHeader == nil {
+		return NewError("table has no memo file").Details(ErrNoFPT)
+	}
+	if file.memoHeader.BlockSize == blockSize {
+		return nil
+	}
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	if file.config.WriteLock {
+		var unlock func() error
+		unlock, err = file.LockTable()
+		if err != nil {
+			return WrapError(err)
+		}
+		defer release(unlock, &err)
+	}
+	debugf("Rebuilding memo file with block size %d, previous block size %d", blockSize, file.memoHeader.BlockSize)
+	dir, err := os.MkdirTemp("", "dbase-memo-*")
+	if err != nil {
+		return NewError("failed to create temporary directory").Details(err)
+	}
+	defer os.RemoveAll(dir)
+	temp, err := CreateMemo(filepath.Join(dir, "MEMO"+string(FPT)), blockSize, &Config{MaxMemoSize: file.config.MaxMemoSize, SyncMode: SyncNever})
+	if err != nil {
+		return WrapError(err)
+	}
+	defer temp.Close()
+	// Copy the referenced memos to the temporary memo file, memos referenced more than once are copied once
+	tempBlocks := make(map[uint32]uint32)
+	order := make([]uint32, 0)
+	err = file.eachMemoAddress(func(position uint32, address []byte) error {
+		block := binary.LittleEndian.Uint32(address)
+		if _, ok := tempBlocks[block]; ok {
+			return nil
+		}
+		data, text, err := file.readMemo(address, nil)
+		if err != nil {
+			return NewErrorf("failed to read memo of row %d", position).Details(err)
+		}
+		written, err := temp.file.WriteMemo(nil, data, text, len(data))
+		if err != nil {
+			return NewErrorf("failed to copy memo of row %d", position).Details(err)
+		}
+		tempBlocks[block] = binary.LittleEndian.Uint32(written)
+		order = append(order, tempBlocks[block])
+		return nil
+	}, nil)
+	if err != nil {
+		return WrapError(err)
+	}
+	// Replace the memo file by the memos of the temporary memo file
+	err = file.TruncateRelated(0)
+	if err != nil {
+		return NewError("failed to truncate the memo file").Details(err)
+	}
+	file.memoHeader = &MemoHeader{BlockSize: blockSize}
+	file.memoHeader.NextFree = nextMemoBlock(file.memoHeader)
+	err = file.WriteMemoHeader(0)
+	if err != nil {
+		return WrapError(err)
+	}
+	blocks := make(map[uint32]uint32, len(order))
+	for _, block := range order {
+		data, text, err := temp.file.readMemo(memoAddress(block), nil)
+		if err != nil {
+			return NewErrorf("failed to read memo of block %d from the temporary memo file", block).Details(err)
+		}
+		written, err := file.WriteMemo(nil, data, text, len(data))
+		if err != nil {
+			return NewErrorf("failed to write memo of block %d", block).Details(err)
+		}
+		blocks[block] = binary.LittleEndian.Uint32(written)
+	}
+	// Rewrite the addresses of the rows
+	err = file.eachMemoAddress(func(_ uint32, address []byte) error {
+		copy(address, memoAddress(blocks[tempBlocks[binary.LittleEndian.Uint32(address)]]))
+		return nil
+	}, func(position uint32, data []byte) error {
+		return file.writeRow(&Row{handle: file, Position: position, Deleted: Marker(data[0]) == Deleted, raw: data})
+	})
+	if err != nil {
+		return WrapError(err)
+	}
+	debugf("Rebuilt memo file with %d memos, next free block %d", len(order), file.memoHeader.NextFree)
+	return file.commit(nil)
+}
+
+// memoPointer returns if columns of the data type store the block address of their value in the memo file,
+// besides memo columns the general, picture and blob columns of Visual FoxPro
+func memoPointer(dataType DataType) bool {
+	switch dataType {
+	case Memo, General, Picture, Blob:
+		return true
+	}
+	return false
+}
+
+// eachMemoAddress calls fn with the address of every memo referenced by the rows, the address can be changed in place.
+// If write is not nil, it is called with the data of each row after the addresses of the row were visited.
+func (file *File) eachMemoAddress(fn func(position uint32, address []byte) error, write func(position uint32, data []byte) error) error {
+	for position := uint32(0); position < file.header.RowsCount; position++ {
+		data, err := file.ReadRow(position)
+		if err != nil {
+			return NewErrorf("failed to read row %d", position).Details(err)
+		}
+		changed := false
+		for _, column := range file.table.layout.Columns {
+			if !memoPointer(column.Type) || column.Length < 4 {
+				continue
+			}
+			address := data[column.Offset : column.Offset+column.Length]
+			if blankAddress(address) {
+				continue
+			}
+			err = fn(position, address)
+			if err != nil {
+				return WrapError(err)
+			}
+			changed = true
+		}
+		if changed && write != nil {
+			err = write(position, data)
+			if err != nil {
+				return NewErrorf("failed to write row %d", position).Details(err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package dbase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ioBackend creates the IO used to create or open the table with the filename
+type ioBackend struct {
+	name string
+	io   func(t *testing.T, filename string, create bool) IO
+}
+
+// ioBackends returns the IO implementation of the platform and GenericIO on file handles
+func ioBackends() []ioBackend {
+	return []ioBackend{
+		platformBackend(),
+		{name: "generic", io: genericFileIO},
+	}
+}
+
+// genericFileIO returns a GenericIO on the DBF and FPT file of the table
+func genericFileIO(t *testing.T, filename string, create bool) IO {
+	flag := os.O_RDWR
+	if create {
+		flag |= os.O_CREATE | os.O_EXCL
+	}
+	handle, err := os.OpenFile(filename, flag, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	related, err := os.OpenFile(strings.TrimSuffix(filename, filepath.Ext(filename))+string(FPT), flag, 0600)
+	if err != nil {
+		handle.Close()
+		t.Fatal(err)
+	}
+	return GenericIO{Handle: handle, RelatedHandle: related}
+}
+
+func TestWriteMemoBlocks(t *testing.T) {
+	const blockSize = 64
+	lengths := []int{blockSize - 1, blockSize, blockSize + 1}
+	for _, backend := range ioBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "MEMO.DBF")
+			column, err := NewColumn("MEMO", Memo, 0, 0, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := &Config{Filename: filename, Converter: NewDefaultConverter(charmap.Windows1252)}
+			file, err := NewTable(FoxProVar, config, []*Column{column}, blockSize, backend.io(t, filename, true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			values := make([]string, 0, len(lengths))
+			for i, length := range lengths {
+				next := file.memoHeader.NextFree
+				value := strings.Repeat(string(rune('a'+i)), length)
+				values = append(values, value)
+				row := file.NewRow()
+				err = row.FieldByName("MEMO").SetValue(value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = row.Add()
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := file.ReadRow(row.Position)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if block := binary.LittleEndian.Uint32(data[column.Position:]); block != next {
+					t.Errorf("memo of %d bytes written to block %d, expected the next free block %d", length, block, next)
+				}
+				blocks := uint32((8 + length + blockSize - 1) / blockSize)
+				if file.memoHeader.NextFree != next+blocks {
+					t.Errorf("next free block %d after a memo of %d bytes, expected %d", file.memoHeader.NextFree, length, next+blocks)
+				}
+				info, err := os.Stat(strings.TrimSuffix(filename, filepath.Ext(filename)) + string(FPT))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Size() != int64(file.memoHeader.NextFree)*blockSize {
+					t.Errorf("memo file of %d bytes after a memo of %d bytes, expected %d blocks of %d bytes", info.Size(), length, file.memoHeader.NextFree, blockSize)
+				}
+			}
+			next := file.memoHeader.NextFree
+			err = file.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file, err = OpenTable(&Config{Filename: filename, IO: backend.io(t, filename, false)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			if file.memoHeader.NextFree != next {
+				t.Errorf("next free block %d read from the memo header, expected %d", file.memoHeader.NextFree, next)
+			}
+			for i, value := range values {
+				row, err := file.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := row.ValueByName("MEMO")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != value {
+					t.Errorf("memo %d read as %q, expected %q", i, got, value)
+				}
+			}
+		})
+	}
+}
+
+// newMemoTable creates a table with a memo column in a temporary directory and returns its filename
+func newMemoTable(t *testing.T) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "MEMO.DBF")
+	column, err := NewColumn("MEMO", Memo, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := NewTable(FoxProVar, &Config{Filename: filename, Converter: NewDefaultConverter(charmap.Windows1252)}, []*Column{column}, 64, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// writeMemo writes the memo into the row at the position, the row is appended if the position is the rows count
+func writeMemo(t *testing.T, file *File, position uint32, value string) {
+	t.Helper()
+	row := file.NewRow()
+	if position < file.header.RowsCount {
+		// The existing row contains the address of the memo, so its blocks can be reused
+		err := file.GoTo(position)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row, err = file.Row()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := row.FieldByName("MEMO").SetValue(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = row.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readMemo reads the memo of the row at the position
+func readMemo(t *testing.T, file *File, position uint32) interface{} {
+	t.Helper()
+	err := file.GoTo(position)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := file.Row()
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := row.ValueByName("MEMO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestMemoReadAfterWriteStaleNextFree(t *testing.T) {
+	filename := newMemoTable(t)
+	first, err := OpenTable(&Config{Filename: filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	writeMemo(t, first, 0, "")
+	writeMemo(t, first, 1, "")
+	second, err := OpenTable(&Config{Filename: filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	// The first file moves the next free block, the cached next free block of the second file is outdated
+	writeMemo(t, first, 0, strings.Repeat("a", 100))
+	if second.memoHeader.NextFree >= first.memoHeader.NextFree {
+		t.Fatalf("next free block %d of the second file is not outdated", second.memoHeader.NextFree)
+	}
+	writeMemo(t, second, 1, strings.Repeat("b", 100))
+	if got := readMemo(t, second, 1); got != strings.Repeat("b", 100) {
+		t.Errorf("memo read after writing it is %q", got)
+	}
+	if got := readMemo(t, second, 0); got != strings.Repeat("a", 100) {
+		t.Errorf("memo written by the first file was overwritten with %q", got)
+	}
+	if got := readMemo(t, first, 1); got != strings.Repeat("b", 100) {
+		t.Errorf("memo written by the second file read as %q by the first file", got)
+	}
+}
+
+func TestMemoReadAfterWriteInPlace(t *testing.T) {
+	filename := newMemoTable(t)
+	file, err := OpenTable(&Config{Filename: filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writeMemo(t, file, 0, strings.Repeat("a", 100))
+	writeMemo(t, file, 1, "second")
+	next := file.memoHeader.NextFree
+	// The new memo fits into the blocks of the previous memo and is overwritten in place
+	writeMemo(t, file, 0, strings.Repeat("c", 90))
+	if file.memoHeader.NextFree != next {
+		t.Errorf("next free block moved from %d to %d, expected the memo to be overwritten in place", next, file.memoHeader.NextFree)
+	}
+	if got := readMemo(t, file, 0); got != strings.Repeat("c", 90) {
+		t.Errorf("memo read after overwriting it in place is %q", got)
+	}
+	if got := readMemo(t, file, 1); got != "second" {
+		t.Errorf("memo of the next row read as %q after overwriting the previous memo", got)
+	}
+	// A shorter memo is also written in place and does not return the rest of the previous memo
+	writeMemo(t, file, 0, "short")
+	if got := readMemo(t, file, 0); got != "short" {
+		t.Errorf("memo read after overwriting it with a shorter memo is %q", got)
+	}
+}
+
+// newBlobTable creates a table with a memo and a blob column, the rows reference memos of both columns
+func newBlobTable(t *testing.T, memos []string, blobs [][]byte) *File {
+	t.Helper()
+	memo, err := NewColumn("MEMO", Memo, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NewColumn does not create blob columns, they are defined like Visual FoxPro does: 4 bytes with the binary flag
+	blob, err := NewColumn("BLOB", Memo, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob.DataType = byte(Blob)
+	blob.Flag = byte(BinaryFlag)
+	filename := filepath.Join(t.TempDir(), "BLOB.DBF")
+	file, err := NewTable(FoxProVar, &Config{Filename: filename, Converter: NewDefaultConverter(charmap.Windows1252)}, []*Column{memo, blob}, 64, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range memos {
+		// Blob values are the addresses of the memos, so the blob is written to the memo file first
+		address, err := file.WriteMemo(nil, blobs[i], false, len(blobs[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		row := file.NewRow()
+		err = row.FieldByName("MEMO").SetValue(memos[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = row.FieldByName("BLOB").SetValue(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = row.Add()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return file
+}
+
+// readBlob reads the memo the blob column of the row at the position references
+func readBlob(t *testing.T, file *File, position uint32) []byte {
+	t.Helper()
+	err := file.GoTo(position)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := file.Row()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := row.ValueByName("BLOB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, _, err := file.readMemo(address.([]byte), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+func TestSetMemoBlockSizeBlob(t *testing.T) {
+	memos := []string{"first memo", strings.Repeat("long memo ", 20)}
+	blobs := [][]byte{bytes.Repeat([]byte{0xB1}, 100), {0x00, 0x01, 0x02}}
+	file := newBlobTable(t, memos, blobs)
+	defer file.Close()
+	err := file.SetMemoBlockSize(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range memos {
+		if value := readMemo(t, file, uint32(i)); value != memos[i] {
+			t.Errorf("row %d: expected memo %q, got %q", i, memos[i], value)
+		}
+		if blob := readBlob(t, file, uint32(i)); !bytes.Equal(blob, blobs[i]) {
+			t.Errorf("row %d: expected blob %v, got %v", i, blobs[i], blob)
+		}
+	}
+}
+
+func TestCopyBlobMemos(t *testing.T) {
+	memos := []string{"first memo", strings.Repeat("long memo ", 20)}
+	blobs := [][]byte{bytes.Repeat([]byte{0xB1}, 100), {0x00, 0x01, 0x02}}
+	src := newBlobTable(t, memos, blobs)
+	defer src.Close()
+	columns := make([]*Column, 0, len(src.table.columns))
+	for _, column := range src.table.columns {
+		c := *column
+		columns = append(columns, &c)
+	}
+	config := &Config{Filename: filepath.Join(t.TempDir(), "RAW.DBF"), Converter: src.config.Converter}
+	raw, err := NewTable(FoxProVar, config, columns, 16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	_, err = CopyRaw(src, raw, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, _, err := ConvertVersion(src, FoxProVar, &Config{Filename: filepath.Join(t.TempDir(), "CONVERT.DBF")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer converted.Close()
+	for _, dst := range []*File{raw, converted} {
+		for i := range memos {
+			if value := readMemo(t, dst, uint32(i)); value != memos[i] {
+				t.Errorf("%s row %d: expected memo %q, got %q", dst.config.Filename, i, memos[i], value)
+			}
+			if blob := readBlob(t, dst, uint32(i)); !bytes.Equal(blob, blobs[i]) {
+				t.Errorf("%s row %d: expected blob %v, got %v", dst.config.Filename, i, blobs[i], blob)
+			}
+		}
+	}
+}
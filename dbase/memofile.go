@@ -0,0 +1,203 @@
+package dbase
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoFile is a memo file (FPT) opened without the table it belongs to.
+// The memos are addressed by their block number, as stored in the memo columns of the table.
+type MemoFile struct {
+	file   *File      // File without a table, only the related memo handle is used
+	handle fileHandle // Handle of the memo file
+}
+
+// MemoBlock is a memo read from a memo file
+type MemoBlock struct {
+	Block  uint32 // Number of the first block of the memo
+	Blocks int    // Number of blocks the memo occupies
+	Text   bool   // Whether the memo contains text, otherwise it contains binary data
+	Data   []byte // Data of the memo, text is decoded using the converter
+}
+
+// OpenMemo opens the memo file at path without its table.
+// The config is optional, only the converter used for text memos and ReadOnly, Exclusive, WriteLock,
+// MaxMemoSize, SyncMode and Metrics are used. If no converter is set the default converter is used.
+func OpenMemo(path string, config *Config) (*MemoFile, error) {
+	config = memoConfig(path, config)
+	filename, err := findFile(filepath.Clean(path))
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if len(filename) == 0 {
+		return nil, NewErrorf("memo file %s not found", path).Details(ErrNoFPT)
+	}
+	mode := os.O_RDWR
+	if config.ReadOnly {
+		mode = os.O_RDONLY
+	}
+	if config.Exclusive {
+		mode |= os.O_EXCL
+	}
+	debugf("Opening memo file: %s - Read-only: %v - Exclusive: %v", filename, config.ReadOnly, config.Exclusive)
+	var handle *os.File
+	err = openRetry(config, filename, func() (err error) {
+		handle, err = os.OpenFile(filename, mode, 0600)
+		return err
+	})
+	if err != nil {
+		return nil, NewError("opening FPT file failed").Details(err)
+	}
+	memo, err := newMemoFile(config, handle)
+	if err != nil {
+		handle.Close()
+		return nil, WrapError(err)
+	}
+	err = memo.file.ReadMemoHeader()
+	if err != nil {
+		handle.Close()
+		return nil, WrapError(err)
+	}
+	if memo.file.memoHeader.BlockSize == 0 {
+		handle.Close()
+		return nil, NewErrorf("invalid memo block size 0 in %s", filename)
+	}
+	return memo, nil
+}
+
+// CreateMemo creates a new empty memo file at path with the block size
+func CreateMemo(path string, blockSize uint16, config *Config) (*MemoFile, error) {
+	if blockSize == 0 {
+		return nil, NewError("invalid memo block size 0")
+	}
+	config = memoConfig(path, config)
+	debugf("Creating memo file: %s - block size: %d", path, blockSize)
+	handle, err := os.OpenFile(filepath.Clean(path), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, NewError("creating FPT file failed").Details(err)
+	}
+	memo, err := newMemoFile(config, handle)
+	if err != nil {
+		handle.Close()
+		return nil, WrapError(err)
+	}
+	memo.file.memoHeader = &MemoHeader{BlockSize: blockSize}
+	memo.file.memoHeader.NextFree = nextMemoBlock(memo.file.memoHeader)
+	err = memo.file.WriteMemoHeader(0)
+	if err != nil {
+		handle.Close()
+		return nil, WrapError(err)
+	}
+	return memo, nil
+}
+
+// memoConfig returns a copy of the config for the memo file with the defaults set
+func memoConfig(path string, config *Config) *Config {
+	c := &Config{}
+	if config != nil {
+		*c = *config
+	}
+	c.Filename = path
+	c.IO = nil
+	if c.Converter == nil {
+		c.Converter = ConverterFromCodePage(0)
+	}
+	return c
+}
+
+func newMemoFile(config *Config, handle *os.File) (*MemoFile, error) {
+	g := GenericIO{RelatedHandle: handle}
+	file := &File{
+		config:        config,
+		io:            g,
+		relatedHandle: handle,
+		header:        &Header{},
+		dbaseMutex:    &sync.Mutex{},
+		memoMutex:     &sync.Mutex{},
+		locks:         &fileLocks{},
+	}
+	related, err := g.core().related(file)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return &MemoFile{file: file, handle: related}, nil
+}
+
+// Close closes the memo file
+func (memo *MemoFile) Close() error {
+	return memo.file.Close()
+}
+
+// Header returns the header of the memo file
+func (memo *MemoFile) Header() *MemoHeader {
+	return memo.file.memoHeader
+}
+
+// Read returns the memo starting at the block and true if the memo contains text
+func (memo *MemoFile) Read(block uint32) ([]byte, bool, error) {
+	if block == 0 {
+		return nil, false, NewError("invalid memo block 0")
+	}
+	return memo.file.ReadMemo(memoAddress(block))
+}
+
+// Write writes the memo and returns the block it was written to.
+// If block is not zero and the memo at the block has enough blocks for the data, it is overwritten,
+// otherwise the memo is appended to the file.
+func (memo *MemoFile) Write(block uint32, data []byte, text bool) (uint32, error) {
+	var address []byte
+	if block != 0 {
+		address = memoAddress(block)
+	}
+	address, err := memo.file.WriteMemo(address, data, text, len(data))
+	if err != nil {
+		return 0, WrapError(err)
+	}
+	if memo.file.config.SyncMode != SyncNever {
+		err = memo.file.Sync()
+		if err != nil {
+			return 0, WrapError(err)
+		}
+	}
+	return binary.LittleEndian.Uint32(address), nil
+}
+
+// Scan reads the memos in the order of their blocks and calls fn for each memo until fn returns false.
+// The memos are expected to follow each other, padded to the block size, as written by FoxPro and this package.
+// Returns an error with the block number if a block header is invalid.
+func (memo *MemoFile) Scan(fn func(block *MemoBlock) bool) error {
+	header := memo.file.memoHeader
+	block := nextMemoBlock(&MemoHeader{BlockSize: header.BlockSize})
+	for block < header.NextFree {
+		hbuf := make([]byte, 8)
+		err := readAt(memo.handle, hbuf, int64(block)*int64(header.BlockSize))
+		if err != nil {
+			return NewErrorf("failed to read memo block header of block %d", block).Details(err)
+		}
+		_, blocks, err := memoBlock(false, int(binary.BigEndian.Uint32(hbuf[4:])), header.BlockSize)
+		if err != nil {
+			return NewErrorf("invalid memo block header of block %d", block).Details(err)
+		}
+		if int64(block)+int64(blocks) > int64(header.NextFree) {
+			return NewErrorf("memo of block %d exceeds the next free block %d", block, header.NextFree)
+		}
+		data, text, err := memo.Read(block)
+		if err != nil {
+			return NewErrorf("failed to read memo of block %d", block).Details(err)
+		}
+		if !fn(&MemoBlock{Block: block, Blocks: blocks, Text: text, Data: data}) {
+			return nil
+		}
+		block += uint32(blocks)
+	}
+	return nil
+}
+
+// memoAddress returns the address of the block as stored in a memo column
+func memoAddress(block uint32) []byte {
+	address := make([]byte, 4)
+	binary.LittleEndian.PutUint32(address, block)
+	return address
+}
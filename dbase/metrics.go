@@ -0,0 +1,55 @@
+package dbase
+
+import "time"
+
+// Operation identifies the file operation reported to a MetricsCollector
+type Operation string
+
+const (
+	OpenOperation            Operation = "open"
+	CloseOperation           Operation = "close"
+	CreateOperation          Operation = "create"
+	ReadHeaderOperation      Operation = "read_header"
+	WriteHeaderOperation     Operation = "write_header"
+	ReadColumnsOperation     Operation = "read_columns"
+	WriteColumnsOperation    Operation = "write_columns"
+	ReadMemoHeaderOperation  Operation = "read_memo_header"
+	WriteMemoHeaderOperation Operation = "write_memo_header"
+	ReadMemoOperation        Operation = "read_memo"
+	ReadMemosOperation       Operation = "read_memos"
+	WriteMemoOperation       Operation = "write_memo"
+	ReadNullFlagOperation    Operation = "read_null_flag"
+	ReadRowOperation         Operation = "read_row"
+	ReadRowsOperation        Operation = "read_rows"
+	WriteRowOperation        Operation = "write_row"
+	SearchOperation          Operation = "search"
+	DeletedOperation         Operation = "deleted"
+	LockOperation            Operation = "lock"
+	TruncateOperation        Operation = "truncate"
+	SyncOperation            Operation = "sync"
+)
+
+// MetricsCollector is called after each file operation with the number of bytes read or written,
+// the duration of the operation and the error if the operation failed.
+// It is called synchronously, so implementations should only record the values, e.g. as Prometheus metrics.
+type MetricsCollector interface {
+	Observe(op Operation, bytes int, duration time.Duration, err error)
+}
+
+// MetricsFunc allows to use an ordinary function as MetricsCollector
+type MetricsFunc func(op Operation, bytes int, duration time.Duration, err error)
+
+func (f MetricsFunc) Observe(op Operation, bytes int, duration time.Duration, err error) {
+	f(op, bytes, duration, err)
+}
+
+// observe reports the operation to the metrics collector of the config, if there is one
+func (c *Config) observe(op Operation, bytes int, start time.Time, err error) {
+	if c == nil || c.Metrics == nil {
+		return
+	}
+	if err != nil {
+		bytes = 0
+	}
+	c.Metrics.Observe(op, bytes, time.Since(start), err)
+}
@@ -0,0 +1,100 @@
+package dbase
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConvertFunc is the conversion function of a Modification
+type ConvertFunc func(interface{}) (interface{}, error)
+
+// DateFormat returns a conversion for Modification.Convert formatting date and datetime values using the layout of time.Format,
+// e.g. "20060102" for YYYYMMDD or "02.01.2006" for DD.MM.YYYY. Empty dates are converted to an empty string, null values stay nil.
+func DateFormat(layout string) ConvertFunc {
+	return func(value interface{}) (interface{}, error) {
+		switch v := value.(type) {
+		case nil:
+			return nil, nil
+		case time.Time:
+			if IsEmptyDate(v) {
+				return "", nil
+			}
+			return v.Format(layout), nil
+		}
+		return nil, NewErrorf("invalid data type %T, expected time.Time", value)
+	}
+}
+
+// ISOWeekDate returns a conversion for Modification.Convert formatting date and datetime values as ISO 8601 week date, e.g. 2024-W01-1.
+// Empty dates are converted to an empty string, null values stay nil.
+func ISOWeekDate() ConvertFunc {
+	return func(value interface{}) (interface{}, error) {
+		switch v := value.(type) {
+		case nil:
+			return nil, nil
+		case time.Time:
+			if IsEmptyDate(v) {
+				return "", nil
+			}
+			year, week := v.ISOWeek()
+			weekday := int(v.Weekday())
+			if weekday == 0 {
+				weekday = 7
+			}
+			return fmt.Sprintf("%04d-W%02d-%d", year, week, weekday), nil
+		}
+		return nil, NewErrorf("invalid data type %T, expected time.Time", value)
+	}
+}
+
+// NumberFormat returns a conversion for Modification.Convert formatting numeric values with the decimals and separators,
+// e.g. NumberFormat(2, ",", ".") formats 1234.5 as "1.234,50". If decimals is negative, the smallest number of decimals
+// necessary to represent the value is used. Numeric strings are formatted as well, null values stay nil.
+func NumberFormat(decimals int, decimalSeparator string, thousandsSeparator string) ConvertFunc {
+	return func(value interface{}) (interface{}, error) {
+		if value == nil {
+			return nil, nil
+		}
+		f, err := aggregateFloat(value)
+		if s, ok := value.(string); ok {
+			f, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+		}
+		if err != nil {
+			return nil, NewErrorf("invalid data type %T, expected a numeric value", value)
+		}
+		s := strconv.FormatFloat(f, 'f', decimals, 64)
+		sign := ""
+		if strings.HasPrefix(s, "-") {
+			sign, s = "-", s[1:]
+		}
+		integer, fraction, hasFraction := strings.Cut(s, ".")
+		if len(thousandsSeparator) > 0 && len(integer) > 3 {
+			groups := make([]string, 0, len(integer)/3+1)
+			first := len(integer) % 3
+			if first > 0 {
+				groups = append(groups, integer[:first])
+			}
+			for i := first; i < len(integer); i += 3 {
+				groups = append(groups, integer[i:i+3])
+			}
+			integer = strings.Join(groups, thousandsSeparator)
+		}
+		if hasFraction {
+			return sign + integer + decimalSeparator + fraction, nil
+		}
+		return sign + integer, nil
+	}
+}
+
+// TrimAndUpper returns a conversion for Modification.Convert trimming spaces from text values and converting them to upper case.
+// Other values are returned unchanged.
+func TrimAndUpper() ConvertFunc {
+	return func(value interface{}) (interface{}, error) {
+		if s, ok := value.(string); ok {
+			return strings.ToUpper(strings.TrimSpace(s)), nil
+		}
+		return value, nil
+	}
+}
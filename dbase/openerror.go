@@ -0,0 +1,110 @@
+package dbase
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OpenReason is the machine readable reason why OpenTable failed
+type OpenReason string
+
+const (
+	OpenFailed             OpenReason = "failed"              // Any other error, e.g. a failed read of the memo file
+	OpenInvalidConfig      OpenReason = "invalid_config"      // The config is invalid, see Config.Validate
+	OpenNotFound           OpenReason = "not_found"           // The DBF file does not exist
+	OpenInUse              OpenReason = "in_use"              // The file is used exclusively by another process
+	OpenTruncatedHeader    OpenReason = "truncated_header"    // The file is shorter than the header
+	OpenInvalidHeader      OpenReason = "invalid_header"      // The header can not be read
+	OpenUnsupportedVersion OpenReason = "unsupported_version" // The file version is not tested and Untested is not set
+	OpenInvalidColumns     OpenReason = "invalid_columns"     // The column definitions can not be read
+	OpenCodePageMismatch   OpenReason = "code_page_mismatch"  // The code page mark does not match the converter
+	OpenMissingMemo        OpenReason = "missing_memo"        // The header references a memo file that does not exist
+)
+
+// OpenError is returned by OpenTable, so tools processing many files can triage failures by reason.
+// Unwrap returns the cause, so errors.Is still finds the causes, e.g. ErrNoDBF.
+type OpenError struct {
+	Reason   OpenReason // Reason the table could not be opened
+	Filename string     // Filename of the config
+	Header   *Header    // Header of the DBF file if it could be read, e.g. to report the version of unsupported files
+	Err      error      // Cause of the failure
+}
+
+func (e *OpenError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OpenError) Unwrap() error {
+	return e.Err
+}
+
+// newOpenError returns the OpenError of a failed OpenTable with the reason derived from the cause
+func newOpenError(config *Config, err error) *OpenError {
+	e := &OpenError{Reason: OpenFailed, Err: err}
+	if config != nil {
+		e.Filename = config.Filename
+	}
+	switch {
+	case errors.Is(err, ErrInvalidConfig):
+		e.Reason = OpenInvalidConfig
+	case errors.Is(err, ErrNoDBF):
+		e.Reason = OpenNotFound
+	case errors.Is(err, ErrLocked):
+		e.Reason = OpenInUse
+	case errors.Is(err, ErrInvalidHeader) && errors.Is(err, ErrIncomplete):
+		e.Reason = OpenTruncatedHeader
+	case errors.Is(err, ErrInvalidHeader):
+		e.Reason = OpenInvalidHeader
+	case errors.Is(err, ErrUnsupportedVersion):
+		e.Reason = OpenUnsupportedVersion
+	case errors.Is(err, ErrInvalidColumns):
+		e.Reason = OpenInvalidColumns
+	case errors.Is(err, ErrCodePageMismatch):
+		e.Reason = OpenCodePageMismatch
+	case errors.Is(err, ErrNoFPT):
+		e.Reason = OpenMissingMemo
+	}
+	switch e.Reason {
+	case OpenInvalidConfig, OpenNotFound, OpenInUse, OpenTruncatedHeader:
+	default:
+		e.Header = headerSnapshot(config)
+	}
+	debugf("Opening table %s failed - reason: %s", e.Filename, e.Reason)
+	return e
+}
+
+// headerSnapshot reads the header of the DBF file of the config again, nil if it can not be read
+func headerSnapshot(config *Config) *Header {
+	var handle fileHandle
+	if g, ok := config.IO.(GenericIO); ok {
+		if g.Handle == nil {
+			return nil
+		}
+		handle = genericHandle{g.Handle}
+	} else {
+		if len(strings.TrimSpace(config.Filename)) == 0 {
+			return nil
+		}
+		filename, err := findFile(filepath.Clean(config.Filename))
+		if err != nil || len(filename) == 0 {
+			return nil
+		}
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		handle = genericHandle{f}
+	}
+	b := make([]byte, headerSize)
+	if readAt(handle, b, 0) != nil {
+		return nil
+	}
+	header, err := decodeHeader(b)
+	if err != nil {
+		return nil
+	}
+	return header
+}
@@ -0,0 +1,62 @@
+package dbase
+
+// Partition streams the rows of the table into the tables returned by dst for the value of the column (name or external key),
+// e.g. to split a large table by year. The value is converted as returned by Row.ToMap, rows for which dst returns nil are skipped.
+// The destination tables must have the same row layout as the table, the rows are copied as stored like by CopyRaw,
+// including their memos. Deleted rows are skipped and the internal row pointer is not moved.
+// Returns the number of copied rows.
+func (file *File) Partition(column string, dst func(value interface{}) *File) (int, error) {
+	pos := file.columnPosByKey(column)
+	if pos < 0 {
+		return 0, NewErrorf("column '%s' not found", column)
+	}
+	c := file.table.columns[pos]
+	layout := file.table.layout.Columns[pos]
+	debugf("Partitioning %d rows by column %s", file.header.RowsCount, c.Name())
+	targets := make(map[*File]bool)
+	copied := 0
+	for position := uint32(0); position < file.header.RowsCount; position++ {
+		data, err := file.ReadRow(position)
+		if err != nil {
+			return copied, NewErrorf("failed to read row %d", position).Details(err)
+		}
+		if Marker(data[0]) == Deleted {
+			continue
+		}
+		value, err := file.interpret(data[layout.Offset:layout.Offset+layout.Length], c, file.table.layout.rowNullFlags(data))
+		if err != nil {
+			return copied, NewErrorf("failed to read column %s of row %d", c.Name(), position).Details(err)
+		}
+		value, err = file.modify(pos, file.sanitize(value))
+		if err != nil {
+			return copied, WrapError(err)
+		}
+		target := dst(value)
+		if target == nil {
+			continue
+		}
+		if !targets[target] {
+			err = compatibleLayout(file, target)
+			if err != nil {
+				return copied, NewErrorf("invalid destination table %s for value %v", target.config.Filename, value).Details(err)
+			}
+			targets[target] = true
+		}
+		err = copyMemos(file, target, data)
+		if err != nil {
+			return copied, NewErrorf("failed to copy the memos of row %d", position).Details(err)
+		}
+		err = target.writeRaw(data)
+		if err != nil {
+			return copied, NewErrorf("failed to write row %d", position).Details(err)
+		}
+		copied++
+	}
+	for target := range targets {
+		err := target.commit(copyAutoincrement(file, target))
+		if err != nil {
+			return copied, WrapError(err)
+		}
+	}
+	return copied, nil
+}
@@ -0,0 +1,348 @@
+package dbase
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// RecordField is a value of a record passing through a pipeline
+type RecordField struct {
+	Key    string      // Key of the value, the column name or external key unless renamed
+	Column *Column     // Column the value was read from, nil if the field was added by a stage
+	Value  interface{} // Value as returned by Row.ToMap
+}
+
+// Record is a row passing through a pipeline, the fields keep the order of the columns
+type Record struct {
+	file     *File
+	Position uint32         // Position of the row in the file
+	Deleted  bool           // Whether the row is marked as deleted
+	Fields   []*RecordField // Values of the row
+}
+
+// Get returns the value of the field with the key
+func (r *Record) Get(key string) (interface{}, bool) {
+	for _, field := range r.Fields {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set changes the value of the field with the key, a new field is appended if there is none
+func (r *Record) Set(key string, value interface{}) {
+	for _, field := range r.Fields {
+		if field.Key == key {
+			field.Value = value
+			return
+		}
+	}
+	r.Fields = append(r.Fields, &RecordField{Key: key, Value: value})
+}
+
+// Remove removes the field with the key
+func (r *Record) Remove(key string) {
+	for i, field := range r.Fields {
+		if field.Key == key {
+			r.Fields = append(r.Fields[:i], r.Fields[i+1:]...)
+			return
+		}
+	}
+}
+
+// ToMap returns the values of the record keyed by their keys
+func (r *Record) ToMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(r.Fields))
+	for _, field := range r.Fields {
+		m[field.Key] = field.Value
+	}
+	return m
+}
+
+// Stage is a step of a pipeline. It returns the record passed on to the next stage, nil to drop the record.
+type Stage interface {
+	Process(record *Record) (*Record, error)
+}
+
+// StageFunc adapts a function to a Stage
+type StageFunc func(record *Record) (*Record, error)
+
+func (f StageFunc) Process(record *Record) (*Record, error) {
+	return f(record)
+}
+
+// Sink receives the records at the end of a pipeline. Close is called once all records are written.
+type Sink interface {
+	Write(record *Record) error
+	Close() error
+}
+
+// RowPipeline streams the rows of a table through stages into a sink, see Pipeline
+type RowPipeline struct {
+	file           *File
+	stages         []Stage
+	includeDeleted bool
+}
+
+// Pipeline returns a pipeline streaming the rows of the table, e.g.
+//
+//	dbase.Pipeline(file).Filter(f).Map(m).RenameColumns(r).To(dbase.CSVSink(w))
+//
+// Values are read as returned by Row.ToMap, so column modifications are applied. The stages are run in the order they were added.
+// Deleted rows are skipped unless IncludeDeleted is set.
+func Pipeline(file *File) *RowPipeline {
+	return &RowPipeline{file: file}
+}
+
+// IncludeDeleted passes deleted rows through the pipeline as well
+func (p *RowPipeline) IncludeDeleted() *RowPipeline {
+	p.includeDeleted = true
+	return p
+}
+
+// Stage adds a custom stage
+func (p *RowPipeline) Stage(stage Stage) *RowPipeline {
+	p.stages = append(p.stages, stage)
+	return p
+}
+
+// Filter drops the records the function returns false for
+func (p *RowPipeline) Filter(f func(record *Record) bool) *RowPipeline {
+	return p.Stage(StageFunc(func(record *Record) (*Record, error) {
+		if !f(record) {
+			return nil, nil
+		}
+		return record, nil
+	}))
+}
+
+// Map changes the records using the function, e.g. to convert values or add computed fields
+func (p *RowPipeline) Map(m func(record *Record) error) *RowPipeline {
+	return p.Stage(StageFunc(func(record *Record) (*Record, error) {
+		err := m(record)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		return record, nil
+	}))
+}
+
+// RenameColumns changes the keys of the fields, the map is keyed by the current keys
+func (p *RowPipeline) RenameColumns(names map[string]string) *RowPipeline {
+	return p.Stage(StageFunc(func(record *Record) (*Record, error) {
+		for _, field := range record.Fields {
+			if name, ok := names[field.Key]; ok {
+				field.Key = name
+			}
+		}
+		return record, nil
+	}))
+}
+
+// Select keeps only the fields with the keys, in the given order
+func (p *RowPipeline) Select(keys ...string) *RowPipeline {
+	return p.Stage(StageFunc(func(record *Record) (*Record, error) {
+		fields := make([]*RecordField, 0, len(keys))
+		for _, key := range keys {
+			for _, field := range record.Fields {
+				if field.Key == key {
+					fields = append(fields, field)
+					break
+				}
+			}
+		}
+		record.Fields = fields
+		return record, nil
+	}))
+}
+
+// To streams the rows through the stages into the sink and closes the sink afterwards.
+// Returns the number of records written. The internal row pointer is restored afterwards.
+func (p *RowPipeline) To(sink Sink) (written int, err error) {
+	file := p.file
+	pointer := file.table.rowPointer
+	defer func() { file.table.rowPointer = pointer }()
+	defer func() {
+		closeErr := sink.Close()
+		if err == nil && closeErr != nil {
+			err = NewError("failed to close sink").Details(closeErr)
+		}
+	}()
+	debugf("Running pipeline with %d stages over %d rows", len(p.stages), file.header.RowsCount)
+	file.table.rowPointer = 0
+	it := &rowIterator{file: file}
+	for !file.EOF() {
+		position := file.table.rowPointer
+		row, err := it.next()
+		if err != nil {
+			return written, NewErrorf("failed to read row %d", position).Details(err)
+		}
+		if row.Deleted && !p.includeDeleted {
+			continue
+		}
+		record, err := file.newRecord(row)
+		if err != nil {
+			return written, NewErrorf("failed to convert row %d", position).Details(err)
+		}
+		record.Position = position
+		for _, stage := range p.stages {
+			record, err = stage.Process(record)
+			if err != nil {
+				return written, NewErrorf("pipeline failed at row %d", position).Details(err)
+			}
+			if record == nil {
+				break
+			}
+		}
+		if record == nil {
+			continue
+		}
+		err = sink.Write(record)
+		if err != nil {
+			return written, NewErrorf("failed to write row %d", position).Details(err)
+		}
+		written++
+	}
+	return written, nil
+}
+
+// newRecord converts the row into a record, keyed like Row.ToMap
+func (file *File) newRecord(row *Row) (*Record, error) {
+	record := &Record{file: file, Position: row.Position, Deleted: row.Deleted, Fields: make([]*RecordField, len(row.fields))}
+	for i, field := range row.fields {
+		val, err := file.modify(i, field.GetValue())
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		key := field.Name()
+		if mod := file.table.mods[i]; mod != nil && len(mod.ExternalKey) != 0 {
+			key = mod.ExternalKey
+		}
+		record.Fields[i] = &RecordField{Key: key, Column: field.column, Value: val}
+	}
+	return record, nil
+}
+
+// format returns the text representation of the value of the field using the format of the config
+func (r *Record) format(field *RecordField) string {
+	column := field.Column
+	if column == nil {
+		column = &Column{}
+	}
+	return r.file.FormatValue(column, field.Value)
+}
+
+type csvSink struct {
+	writer *csv.Writer
+	header []string
+}
+
+// CSVSink writes the records as CSV with a header line. The columns are the keys of the first record,
+// values are formatted using FormatValue and missing values are written empty.
+func CSVSink(w io.Writer) Sink {
+	return &csvSink{writer: csv.NewWriter(w)}
+}
+
+func (s *csvSink) Write(record *Record) error {
+	if s.header == nil {
+		s.header = make([]string, len(record.Fields))
+		for i, field := range record.Fields {
+			s.header[i] = field.Key
+		}
+		err := s.writer.Write(s.header)
+		if err != nil {
+			return NewError("failed to write CSV header").Details(err)
+		}
+	}
+	line := make([]string, len(s.header))
+	for i, key := range s.header {
+		for _, field := range record.Fields {
+			if field.Key == key {
+				line[i] = record.format(field)
+				break
+			}
+		}
+	}
+	err := s.writer.Write(line)
+	if err != nil {
+		return NewError("failed to write CSV line").Details(err)
+	}
+	return nil
+}
+
+func (s *csvSink) Close() error {
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+type jsonSink struct {
+	encoder *json.Encoder
+}
+
+// JSONLinesSink writes every record as JSON object on its own line, values are formatted like Row.ToJSON
+func JSONLinesSink(w io.Writer) Sink {
+	return &jsonSink{encoder: json.NewEncoder(w)}
+}
+
+func (s *jsonSink) Write(record *Record) error {
+	m := make(map[string]interface{}, len(record.Fields))
+	for _, field := range record.Fields {
+		if field.Column == nil {
+			m[field.Key] = field.Value
+			continue
+		}
+		m[field.Key] = record.file.formatValue(field.Column, field.Value)
+	}
+	err := s.encoder.Encode(m)
+	if err != nil {
+		return NewError("failed to write JSON line").Details(err)
+	}
+	return nil
+}
+
+func (s *jsonSink) Close() error {
+	return nil
+}
+
+type teeSink struct {
+	sinks []Sink
+}
+
+// TeeSink writes every record to all sinks, so one pass over the table feeds several outputs, e.g.
+//
+//	dbase.Pipeline(file).To(dbase.TeeSink(dbase.CSVSink(csvFile), dbase.JSONLinesSink(jsonFile), dbase.StatsSink(stats)))
+//
+// The sinks receive the same record and must not change it. Writing stops at the first error, all sinks are closed.
+func TeeSink(sinks ...Sink) Sink {
+	return &teeSink{sinks: sinks}
+}
+
+func (s *teeSink) Write(record *Record) error {
+	for i, sink := range s.sinks {
+		err := sink.Write(record)
+		if err != nil {
+			return NewErrorf("sink %d failed", i).Details(err)
+		}
+	}
+	return nil
+}
+
+func (s *teeSink) Close() error {
+	errs := make([]error, 0)
+	for i, sink := range s.sinks {
+		err := sink.Close()
+		if err != nil {
+			errs = append(errs, NewErrorf("sink %d failed", i).Details(err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	err := NewErrorf("failed to close %d of %d sinks", len(errs), len(s.sinks))
+	for _, e := range errs {
+		err = err.Details(e)
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package dbase
+
+// preloadedIO serves the reads of a preloaded table from memory, see Config.Preload.
+// It differs from GenericIO only in type, so the table is still treated as file on disk, e.g. for the statistics sidecar.
+type preloadedIO struct {
+	GenericIO
+}
+
+// preload reads the DBF and memo file into memory, closes the files and serves all further reads from memory
+func (file *File) preload() error {
+	c, ok := file.io.(coreIO)
+	if !ok {
+		return NewErrorf("preloading is not supported by IO %T", file.io)
+	}
+	handle, err := c.core().handle(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	dbf, err := readAll(handle)
+	if err != nil {
+		return NewError("failed to preload DBF file").Details(err)
+	}
+	memoryIO := preloadedIO{GenericIO{Handle: dbf}}
+	if file.relatedHandle != nil {
+		related, err := c.core().related(file)
+		if err != nil {
+			return WrapError(err)
+		}
+		memo, err := readAll(related)
+		if err != nil {
+			return NewError("failed to preload memo file").Details(err)
+		}
+		memoryIO.RelatedHandle = memo
+	}
+	debugf("Preloaded %s - DBF: %d bytes", file.config.Filename, len(dbf.data))
+	err = file.io.Close(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	file.io = memoryIO
+	file.handle = memoryIO.Handle
+	if memoryIO.RelatedHandle != nil {
+		file.relatedHandle = memoryIO.RelatedHandle
+	}
+	return nil
+}
+
+// readAll reads the whole file into a memory handle
+func readAll(handle fileHandle) (*memoryHandle, error) {
+	size, err := handle.Size()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	data := make([]byte, size)
+	err = readAt(handle, data, 0)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return &memoryHandle{data: data}, nil
+}
@@ -0,0 +1,227 @@
+package dbase
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// columnSample collects the properties of the values of one key
+type columnSample struct {
+	kind     DataType // Data type of the values so far, zero if only null values were seen
+	mixed    bool     // Values of different types were seen, the column is stored as text
+	length   int      // Maximum length of the values as text
+	digits   int      // Maximum number of integer digits of numeric values
+	decimals int      // Maximum number of decimals of numeric values
+	negative bool     // A negative number was seen
+	integer  bool     // All numbers are whole numbers within the range of an integer column
+	clock    bool     // A time value has a time of day
+}
+
+// SuggestSchema inspects the sample rows and proposes a column for each key, suitable for NewTable.
+// Strings become character columns with the maximum length of the values or memo columns if they exceed
+// 254 bytes, whole numbers in the 32 bit range integer columns, other numbers numeric columns with the
+// required length and decimals, booleans logical columns, times date or datetime columns (if a time of day is set)
+// and byte slices memo columns. Keys with values of different types become character or memo columns.
+// Column names are the upper case keys, truncated to 10 characters and made unique, ordered by name.
+func SuggestSchema(rows []map[string]interface{}) []*Column {
+	samples := make(map[string]*columnSample)
+	for _, row := range rows {
+		for key, value := range row {
+			sample, ok := samples[key]
+			if !ok {
+				sample = &columnSample{integer: true}
+				samples[key] = sample
+			}
+			sample.add(value)
+		}
+	}
+	keys := make([]string, 0, len(samples))
+	for key := range samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	columns := make([]*Column, 0, len(keys))
+	names := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		name := suggestColumnName(key, names)
+		dataType, length, decimals := samples[key].column()
+		column, err := NewColumn(name, dataType, length, decimals, false)
+		if err != nil {
+			debugf("Skipping suggested column %s for key %s: %v", name, key, err)
+			continue
+		}
+		debugf("Suggesting column %s for key %s - type: %v - length: %d - decimals: %d", name, key, dataType, length, decimals)
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// add updates the sample with the value, null values are ignored
+func (s *columnSample) add(value interface{}) {
+	if value == nil {
+		return
+	}
+	var kind DataType
+	switch v := value.(type) {
+	case string:
+		kind = Character
+		s.fit(len(v))
+	case []byte:
+		kind = Memo
+		s.fit(len(v))
+	case bool:
+		kind = Logical
+		s.fit(1)
+	case time.Time:
+		kind = Date
+		if v.Hour() != 0 || v.Minute() != 0 || v.Second() != 0 || v.Nanosecond() != 0 {
+			s.clock = true
+		}
+		s.fit(len(v.Format(time.RFC3339Nano)))
+	case float32:
+		kind = Numeric
+		s.number(float64(v))
+	case float64:
+		kind = Numeric
+		s.number(v)
+	default:
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			kind = Numeric
+			s.whole(rv.Int() < 0, strconv.FormatInt(rv.Int(), 10), rv.Int() >= MinIntegerValue && rv.Int() <= MaxIntegerValue)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			kind = Numeric
+			s.whole(false, strconv.FormatUint(rv.Uint(), 10), rv.Uint() <= MaxIntegerValue)
+		default:
+			kind = Character
+			s.fit(len(fmt.Sprint(value)))
+		}
+	}
+	if s.kind != 0 && s.kind != kind {
+		s.mixed = true
+	}
+	s.kind = kind
+}
+
+// number updates the digits and decimals of the sample with the floating point number
+func (s *columnSample) number(f float64) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		s.mixed = true
+		return
+	}
+	text := strconv.FormatFloat(math.Abs(f), 'f', -1, 64)
+	whole, fraction, _ := strings.Cut(text, ".")
+	s.whole(f < 0, whole, f == math.Trunc(f) && f >= MinIntegerValue && f <= MaxIntegerValue)
+	if len(fraction) > s.decimals {
+		s.decimals = len(fraction)
+	}
+	s.fit(len(strconv.FormatFloat(f, 'f', -1, 64)))
+}
+
+// whole updates the sample with the integer digits of a number
+func (s *columnSample) whole(negative bool, digits string, integer bool) {
+	digits = strings.TrimPrefix(digits, "-")
+	if len(digits) > s.digits {
+		s.digits = len(digits)
+	}
+	s.negative = s.negative || negative
+	s.integer = s.integer && integer
+	s.fit(len(digits) + 1)
+}
+
+func (s *columnSample) fit(length int) {
+	if length > s.length {
+		s.length = length
+	}
+}
+
+// column returns the data type, length and decimals of the column for the sample
+func (s *columnSample) column() (DataType, uint8, uint8) {
+	text := func() (DataType, uint8, uint8) {
+		if s.length > MaxCharacterLength {
+			return Memo, 0, 0
+		}
+		if s.length == 0 {
+			return Character, 1, 0
+		}
+		return Character, uint8(s.length), 0
+	}
+	if s.mixed || s.kind == 0 {
+		return text()
+	}
+	switch s.kind {
+	case Character:
+		return text()
+	case Memo:
+		return Memo, 0, 0
+	case Logical:
+		return Logical, 1, 0
+	case Date:
+		if s.clock {
+			return DateTime, 8, 0
+		}
+		return Date, 8, 0
+	case Numeric:
+		if s.integer && s.decimals == 0 {
+			return Integer, 4, 0
+		}
+		length := s.digits
+		if length == 0 {
+			length = 1
+		}
+		if s.negative {
+			length++
+		}
+		decimals := s.decimals
+		// Reduce the decimals if the number does not fit into the maximum length
+		if decimals > 0 && length+decimals+1 > MaxNumericLength {
+			decimals = MaxNumericLength - length - 1
+			// Not even one decimal fits, the fraction can only be kept as text
+			if decimals <= 0 {
+				return text()
+			}
+		}
+		if decimals > 0 {
+			length += decimals + 1
+		}
+		if length > MaxNumericLength {
+			return text()
+		}
+		return Numeric, uint8(length), uint8(decimals)
+	}
+	return text()
+}
+
+// suggestColumnName returns the upper case key, truncated to the maximum column name length,
+// with a numeric suffix if the name is already used
+func suggestColumnName(key string, used map[string]bool) string {
+	name := strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, key))
+	if len(name) == 0 {
+		name = "COLUMN"
+	}
+	if len(name) > MaxColumnNameLength {
+		name = name[:MaxColumnNameLength]
+	}
+	candidate := name
+	for i := 1; used[candidate]; i++ {
+		suffix := strconv.Itoa(i)
+		base := name
+		if len(base)+len(suffix) > MaxColumnNameLength {
+			base = base[:MaxColumnNameLength-len(suffix)]
+		}
+		candidate = base + suffix
+	}
+	used[candidate] = true
+	return candidate
+}
@@ -0,0 +1,33 @@
+package dbase
+
+import "testing"
+
+func TestSuggestSchemaNumericLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []interface{}
+		dataType DataType
+		length   uint8
+		decimals uint8
+	}{
+		{name: "DecimalsReduced", values: []interface{}{int64(123456789012345678), 0.125}, dataType: Numeric, length: 20, decimals: 1},
+		{name: "NoDecimalFits", values: []interface{}{int64(1234567890123456789), 0.5}, dataType: Character, length: 20},
+		{name: "MaximumLength", values: []interface{}{uint64(12345678901234567890), 0.5}, dataType: Character, length: 21},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := make([]map[string]interface{}, 0, len(tt.values))
+			for _, value := range tt.values {
+				rows = append(rows, map[string]interface{}{"VALUE": value})
+			}
+			columns := SuggestSchema(rows)
+			if len(columns) != 1 {
+				t.Fatalf("expected 1 column, got %d", len(columns))
+			}
+			column := columns[0]
+			if DataType(column.DataType) != tt.dataType || column.Length != tt.length || column.Decimals != tt.decimals {
+				t.Errorf("expected %s(%d,%d), got %s(%d,%d)", tt.dataType, tt.length, tt.decimals, column.Type(), column.Length, column.Decimals)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package dbase
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// searchChunkSize is the number of rows a search worker scans at once
+const searchChunkSize = 4096
+
+// SearchOptions control which matching rows a search returns and how the table is scanned
+type SearchOptions struct {
+	Limit          int  // Maximum number of rows returned, all matching rows if zero
+	Offset         int  // Number of matching rows skipped before the first returned row
+	StopAfterFirst bool // Return only the first matching row, the same as a limit of 1
+	Workers        int  // Number of goroutines scanning parts of the table in parallel, defaults to 1
+	// Number of rows that can not be read before the search fails, zero fails on the first error.
+	// A negative value skips all rows that can not be read.
+	MaxErrors int
+	Context   context.Context // Cancels the search, defaults to context.Background()
+}
+
+// ctx returns the context of the options, context.Background() if not set
+func (options SearchOptions) ctx() context.Context {
+	if options.Context == nil {
+		return context.Background()
+	}
+	return options.Context
+}
+
+// needed returns the number of matches required to satisfy the options, -1 if all matches are required
+func (options SearchOptions) needed() int {
+	limit := options.Limit
+	if options.StopAfterFirst {
+		limit = 1
+	}
+	if limit <= 0 {
+		return -1
+	}
+	if options.Offset > 0 {
+		return options.Offset + limit
+	}
+	return limit
+}
+
+// page returns the matches selected by the offset and limit of the options
+func (options SearchOptions) page(positions []uint32) []uint32 {
+	if options.Offset > 0 {
+		if options.Offset >= len(positions) {
+			return positions[:0]
+		}
+		positions = positions[options.Offset:]
+	}
+	if needed := options.needed(); needed >= 0 && needed-options.Offset < len(positions) {
+		positions = positions[:needed-options.Offset]
+	}
+	return positions
+}
+
+// searchErrors collects the errors of a search up to the threshold of the options
+type searchErrors struct {
+	mutex  sync.Mutex
+	max    int
+	count  int
+	errors []error
+}
+
+// add records the error and returns false once more errors occurred than tolerated
+func (s *searchErrors) add(err error) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count++
+	if s.max < 0 {
+		return true
+	}
+	s.errors = append(s.errors, err)
+	return s.count <= s.max
+}
+
+// err returns an error containing the collected errors if more errors occurred than tolerated
+func (s *searchErrors) err() error {
+	if s.max < 0 || s.count <= s.max {
+		return nil
+	}
+	e := NewErrorf("search failed, %d rows could not be read", s.count)
+	for _, err := range s.errors {
+		e = e.Details(err)
+	}
+	return e
+}
+
+// warn records the tolerated errors as warning of the file
+func (s *searchErrors) warn(file *File) {
+	if s.count > 0 {
+		file.warn("search skipped %d rows that could not be read", s.count)
+	}
+}
+
+// searchScan scans the rows in chunks and returns the positions of the rows matching the value in the order of the table.
+// The chunks are distributed over the workers and no further chunks are scanned once the preceding
+// chunks contain enough matches. Rows that can not be read are collected in failed, the scan stops
+// once more rows failed than tolerated or the context is canceled. Before each chunk the rows count is
+// validated against the file size, so rows cut off by a concurrent truncation are not read.
+func searchScan(file *File, handle fileHandle, layout *ColumnLayout, match func([]byte) bool, options SearchOptions, failed *searchErrors) ([]uint32, error) {
+	ctx := options.ctx()
+	rows := file.header.RowsCount
+	chunks := int((rows + searchChunkSize - 1) / searchChunkSize)
+	workers := options.Workers
+	if workers <= 0 || !concurrentReads(handle) {
+		workers = 1
+	}
+	if workers > chunks {
+		workers = chunks
+	}
+	needed := options.needed()
+	results := make([][]uint32, chunks)
+	done := make([]bool, chunks)
+	var (
+		next    int64
+		stop    atomic.Bool
+		shrunk  atomic.Int64
+		mutex   sync.Mutex
+		prefix  int
+		matches int
+		wg      sync.WaitGroup
+	)
+	// complete stores the matches of a chunk and stops the scan once the completed chunks
+	// at the start of the table contain enough matches
+	complete := func(chunk int, found []uint32) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		results[chunk] = found
+		done[chunk] = true
+		for prefix < chunks && done[prefix] {
+			matches += len(results[prefix])
+			prefix++
+		}
+		if needed >= 0 && matches >= needed {
+			stop.Store(true)
+		}
+	}
+	shrunk.Store(-1)
+	debugf("Searching %d rows in %d chunks with %d workers", rows, chunks, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, layout.Length)
+			for !stop.Load() {
+				chunk := int(atomic.AddInt64(&next, 1) - 1)
+				if chunk >= chunks {
+					return
+				}
+				if ctx.Err() != nil {
+					stop.Store(true)
+					return
+				}
+				found := make([]uint32, 0)
+				end := uint32(chunk+1) * searchChunkSize
+				if end > rows {
+					end = rows
+				}
+				available, err := searchRowsAvailable(file, handle)
+				if err != nil && !failed.add(NewError("failed to get the file size").Details(err)) {
+					stop.Store(true)
+					return
+				}
+				if err == nil && available < int64(end) {
+					shrunk.Store(available)
+					end = uint32(available)
+				}
+				for i := uint32(chunk) * searchChunkSize; i < end; i++ {
+					p := int64(file.header.FirstRow) + int64(i)*int64(file.header.RowLength) + int64(layout.Offset)
+					err := readAt(handle, buf, p)
+					if err != nil {
+						if !failed.add(NewErrorf("failed to read row %d", i).Details(err)) {
+							stop.Store(true)
+							return
+						}
+						continue
+					}
+					if match(buf) {
+						debugf("Found matching row %v at position: %d", i, p-int64(layout.Offset))
+						found = append(found, i)
+						// A single chunk can provide all matches required
+						if needed >= 0 && len(found) >= needed {
+							break
+						}
+					}
+				}
+				complete(chunk, found)
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, NewError("search canceled").Details(ctx.Err())
+	}
+	if available := shrunk.Load(); available >= 0 {
+		file.warn("table shrank to %d of %d rows during search", available, rows)
+	}
+	positions := make([]uint32, 0)
+	for _, found := range results[:prefix] {
+		positions = append(positions, found...)
+	}
+	return options.page(positions), nil
+}
+
+// searchRowsAvailable returns the number of complete rows the file currently contains
+func searchRowsAvailable(file *File, handle fileHandle) (int64, error) {
+	size, err := handle.Size()
+	if err != nil {
+		return 0, err
+	}
+	available := (size - int64(file.header.FirstRow)) / int64(file.header.RowLength)
+	if available < 0 {
+		return 0, nil
+	}
+	return available, nil
+}
+
+// concurrentReads returns if the handle can be read from multiple goroutines at once
+func concurrentReads(handle fileHandle) bool {
+	if g, ok := handle.(genericHandle); ok {
+		_, ok := g.ReadWriteSeeker.(io.ReaderAt)
+		return ok
+	}
+	return true
+}
@@ -0,0 +1,442 @@
+// The serve package exposes a table as REST resource, so the data of legacy tables can be used by web frontends.
+//
+// The handler serves the following endpoints relative to the path it is mounted at:
+//
+//	GET    /schema         JSON Schema of a row, see File.JSONSchema
+//	GET    /rows           Active rows, paginated with offset and limit, other query parameters filter by column value
+//	GET    /rows/{pos}     Row at the position, starting at 0
+//	GET    /keys/{value}   First active row where the key column equals the value
+//	POST   /rows           Appends a row from a JSON object (writable only)
+//	PATCH  /rows/{pos}     Sets the values of the JSON object on the row (writable only)
+//	DELETE /rows/{pos}     Marks the row as deleted (writable only)
+//
+// Rows are returned as objects with the position and the data of the row as returned by Row.ToJSON.
+// Errors are returned as objects with an error message.
+package serve
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+)
+
+const (
+	// DefaultLimit is the number of rows returned by a list request without limit
+	DefaultLimit = 100
+	// DefaultMaxLimit is the maximum number of rows returned by a list request if not configured
+	DefaultMaxLimit = 1000
+	// DefaultMaxBodySize is the maximum size of a request body in bytes if not configured
+	DefaultMaxBodySize = 1 << 20
+)
+
+// Options control which endpoints the handler serves
+type Options struct {
+	Writable    bool   // Enables the endpoints appending, changing and deleting rows
+	Key         string // Column used to look up rows by /keys/{value}, the endpoint is disabled if empty
+	MaxLimit    int    // Maximum number of rows returned by a list request, defaults to DefaultMaxLimit
+	MaxBodySize int64  // Maximum size of a request body in bytes, defaults to DefaultMaxBodySize
+}
+
+// Handler serves the rows of a table, see the package documentation for the endpoints
+type Handler struct {
+	file    *dbase.File
+	options Options
+	mutex   sync.Mutex // The row pointer of the file is shared, so requests are served one at a time
+}
+
+// Page is the response of a list request
+type Page struct {
+	Total  int    `json:"total"`  // Number of rows matching the filter
+	Offset int    `json:"offset"` // Number of matching rows skipped
+	Limit  int    `json:"limit"`  // Maximum number of rows in the page
+	Rows   []*Row `json:"rows"`   // Rows of the page
+}
+
+// Row is a row of the table in a response
+type Row struct {
+	Position uint32          `json:"position"` // Position of the row in the table, starting at 0
+	Data     json.RawMessage `json:"data"`     // Values of the row as returned by Row.ToJSON
+}
+
+// statusError is an error with the HTTP status code of the response
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+// NewHandler returns a handler serving the rows of the table.
+// The key column is validated, an error is returned if it does not exist.
+func NewHandler(file *dbase.File, options Options) (*Handler, error) {
+	if file == nil {
+		return nil, dbase.NewError("file is nil")
+	}
+	if len(options.Key) != 0 && file.ColumnPosByName(options.Key) < 0 {
+		return nil, dbase.NewErrorf("key column '%s' not found", options.Key)
+	}
+	if options.MaxLimit <= 0 {
+		options.MaxLimit = DefaultMaxLimit
+	}
+	if options.MaxBodySize <= 0 {
+		options.MaxBodySize = DefaultMaxBodySize
+	}
+	return &Handler{file: file, options: options}, nil
+}
+
+// ServeHTTP routes the request to the endpoint
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	resource, param, hasParam := strings.Cut(strings.Trim(r.URL.Path, "/"), "/")
+	var (
+		status = http.StatusOK
+		body   interface{}
+		err    error
+	)
+	switch {
+	case resource == "schema" && !hasParam && r.Method == http.MethodGet:
+		body, err = h.schema()
+	case resource == "rows" && !hasParam && r.Method == http.MethodGet:
+		body, err = h.list(r)
+	case resource == "rows" && !hasParam && r.Method == http.MethodPost && h.options.Writable:
+		status = http.StatusCreated
+		body, err = h.create(w, r)
+	case resource == "rows" && hasParam && r.Method == http.MethodGet:
+		body, err = h.get(param)
+	case resource == "rows" && hasParam && r.Method == http.MethodPatch && h.options.Writable:
+		body, err = h.update(w, param, r)
+	case resource == "rows" && hasParam && r.Method == http.MethodDelete && h.options.Writable:
+		status = http.StatusNoContent
+		err = h.delete(param)
+	case resource == "keys" && hasParam && r.Method == http.MethodGet && len(h.options.Key) != 0:
+		body, err = h.key(param)
+	case resource == "schema" || resource == "rows" || (resource == "keys" && len(h.options.Key) != 0):
+		err = &statusError{status: http.StatusMethodNotAllowed, err: dbase.NewErrorf("method %s not allowed", r.Method)}
+	default:
+		err = &statusError{status: http.StatusNotFound, err: dbase.NewErrorf("resource %s not found", r.URL.Path)}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if body == nil {
+		w.WriteHeader(status)
+		return
+	}
+	writeJSON(w, status, body)
+}
+
+// schema returns the JSON Schema of a row
+func (h *Handler) schema() (interface{}, error) {
+	schema, err := h.file.JSONSchema()
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	return json.RawMessage(schema), nil
+}
+
+// list returns the page of active rows matching the filter of the query
+func (h *Handler) list(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := queryInt(query.Get("limit"), DefaultLimit)
+	if err != nil {
+		return nil, err
+	}
+	if limit > h.options.MaxLimit {
+		limit = h.options.MaxLimit
+	}
+	filter := make(map[int]string)
+	for name, values := range query {
+		if name == "offset" || name == "limit" {
+			continue
+		}
+		pos := h.columnPos(name)
+		if pos < 0 {
+			return nil, badRequest(dbase.NewErrorf("column '%s' not found", name))
+		}
+		filter[pos] = values[0]
+	}
+	page := &Page{Offset: offset, Limit: limit, Rows: make([]*Row, 0)}
+	err = h.scan(filter, func(row func() (*dbase.Row, error)) (bool, error) {
+		page.Total++
+		if page.Total <= offset || len(page.Rows) >= limit {
+			return true, nil
+		}
+		response, err := h.response(row)
+		if err != nil {
+			return false, err
+		}
+		page.Rows = append(page.Rows, response)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// get returns the active row at the position
+func (h *Handler) get(param string) (interface{}, error) {
+	row, err := h.active(param)
+	if err != nil {
+		return nil, err
+	}
+	return toResponse(row)
+}
+
+// key returns the first active row where the key column equals the value
+func (h *Handler) key(value string) (interface{}, error) {
+	filter := map[int]string{h.file.ColumnPosByName(h.options.Key): value}
+	var found *Row
+	err := h.scan(filter, func(row func() (*dbase.Row, error)) (bool, error) {
+		response, err := h.response(row)
+		if err != nil {
+			return false, err
+		}
+		found = response
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, notFound(dbase.NewErrorf("no row with %s %s found", h.options.Key, value))
+	}
+	return found, nil
+}
+
+// create appends a row with the values of the request body
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	values, err := h.decode(w, r)
+	if err != nil {
+		return nil, err
+	}
+	row := h.file.NewRow()
+	err = row.SetFromMap(values)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	err = row.Add()
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	return h.written(row.Position)
+}
+
+// update sets the values of the request body on the active row at the position
+func (h *Handler) update(w http.ResponseWriter, param string, r *http.Request) (interface{}, error) {
+	row, err := h.active(param)
+	if err != nil {
+		return nil, err
+	}
+	values, err := h.decode(w, r)
+	if err != nil {
+		return nil, err
+	}
+	err = row.SetFromMap(values)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	err = row.Write()
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	return h.written(row.Position)
+}
+
+// delete marks the active row at the position as deleted
+func (h *Handler) delete(param string) error {
+	row, err := h.active(param)
+	if err != nil {
+		return err
+	}
+	row.Deleted = true
+	return row.Write()
+}
+
+// written reads the row at the position again, so the response contains the values as stored
+func (h *Handler) written(position uint32) (*Row, error) {
+	row, err := h.row(position)
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	return toResponse(row)
+}
+
+// active returns the row at the position of the parameter, an error if it does not exist or is deleted
+func (h *Handler) active(param string) (*dbase.Row, error) {
+	position, err := strconv.ParseUint(param, 10, 32)
+	if err != nil {
+		return nil, badRequest(dbase.NewErrorf("invalid row position %s", param))
+	}
+	if position >= uint64(h.file.RowsCount()) {
+		return nil, notFound(dbase.NewErrorf("row %d not found", position))
+	}
+	row, err := h.row(uint32(position))
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	if row.Deleted {
+		return nil, notFound(dbase.NewErrorf("row %d is deleted", position))
+	}
+	return row, nil
+}
+
+// row reads the row at the position
+func (h *Handler) row(position uint32) (*dbase.Row, error) {
+	err := h.file.GoTo(position)
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	return h.file.Row()
+}
+
+// scan calls the function for each active row matching the filter until it returns false.
+// Only the deleted flag and the filtered columns of the raw row data are interpreted,
+// the row with all values (memos included) is read only if the function calls row.
+func (h *Handler) scan(filter map[int]string, fn func(row func() (*dbase.Row, error)) (bool, error)) error {
+	layout := h.file.Layout()
+	for position := uint32(0); position < h.file.RowsCount(); position++ {
+		// The null flags of variable length columns are read at the row pointer
+		err := h.file.GoTo(position)
+		if err != nil {
+			return dbase.WrapError(err)
+		}
+		data, err := h.file.ReadRow(position)
+		if err != nil {
+			return dbase.WrapError(err)
+		}
+		if len(data) == 0 || dbase.Marker(data[0]) == dbase.Deleted {
+			continue
+		}
+		matched, err := h.matches(data, layout, filter)
+		if err != nil {
+			return dbase.WrapError(err)
+		}
+		if !matched {
+			continue
+		}
+		next, err := fn(func() (*dbase.Row, error) {
+			row, err := h.file.BytesToRow(data)
+			if err != nil {
+				return nil, dbase.WrapError(err)
+			}
+			row.Position = position
+			return row, nil
+		})
+		if err != nil || !next {
+			return err
+		}
+	}
+	return nil
+}
+
+// response reads the row of a scan and converts it into its response representation
+func (h *Handler) response(row func() (*dbase.Row, error)) (*Row, error) {
+	r, err := row()
+	if err != nil {
+		return nil, err
+	}
+	return toResponse(r)
+}
+
+// matches returns if the values of the raw row data equal the values of the filter, compared as text
+func (h *Handler) matches(data []byte, layout *dbase.RowLayout, filter map[int]string) (bool, error) {
+	for pos, value := range filter {
+		column := h.file.Column(pos)
+		c := layout.Columns[pos]
+		if len(data) < c.Offset+c.Length {
+			return false, dbase.NewErrorf("invalid row data size %v Bytes", len(data))
+		}
+		val, err := h.file.Interpret(data[c.Offset:c.Offset+c.Length], column)
+		if err != nil {
+			return false, dbase.WrapError(err)
+		}
+		if strings.TrimSpace(h.file.FormatValue(column, val)) != strings.TrimSpace(value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// columnPos returns the position of the column by case insensitive name or -1 if not found
+func (h *Handler) columnPos(name string) int {
+	for i, column := range h.file.Columns() {
+		if strings.EqualFold(column.Name(), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// decode reads the JSON object of the request body
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.options.MaxBodySize)).Decode(&values)
+	if err != nil {
+		return nil, badRequest(dbase.NewError("invalid JSON object").Details(err))
+	}
+	return values, nil
+}
+
+// toResponse converts the row into its response representation
+func toResponse(row *dbase.Row) (*Row, error) {
+	data, err := row.ToJSON()
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	return &Row{Position: row.Position, Data: data}, nil
+}
+
+// queryInt parses a non-negative integer query parameter
+func queryInt(value string, fallback int) (int, error) {
+	if len(value) == 0 {
+		return fallback, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, badRequest(dbase.NewErrorf("invalid number %s", value))
+	}
+	return i, nil
+}
+
+func badRequest(err error) error {
+	return &statusError{status: http.StatusBadRequest, err: err}
+}
+
+func notFound(err error) error {
+	return &statusError{status: http.StatusNotFound, err: err}
+}
+
+// writeError writes the error with the status of a statusError or an internal server error
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var serr *statusError
+	if errors.As(err, &serr) {
+		status = serr.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,188 @@
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// newTestHandler creates a table with ten rows named ROW0 to ROW9 and a memo each, the row 2 is deleted.
+// The returned counter contains the number of memos read since the table was created.
+func newTestHandler(t *testing.T, options Options) (*Handler, *int) {
+	t.Helper()
+	id, err := dbase.NewColumn("ID", dbase.Integer, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := dbase.NewColumn("NAME", dbase.Character, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes, err := dbase.NewColumn("NOTES", dbase.Memo, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memos := new(int)
+	config := &dbase.Config{
+		Filename:   filepath.Join(t.TempDir(), "SERVE.DBF"),
+		Converter:  dbase.NewDefaultConverter(charmap.Windows1252),
+		TrimSpaces: true,
+		Metrics: dbase.MetricsFunc(func(op dbase.Operation, bytes int, duration time.Duration, err error) {
+			if op == dbase.ReadMemoOperation {
+				*memos++
+			}
+		}),
+	}
+	file, err := dbase.NewTable(dbase.FoxProAutoincrement, config, []*dbase.Column{id, name, notes}, 64, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	for i := 0; i < 10; i++ {
+		row := file.NewRow()
+		err = row.SetFromMap(map[string]interface{}{"ID": int32(i), "NAME": fmt.Sprintf("ROW%d", i), "NOTES": fmt.Sprintf("memo %d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 {
+			row.Deleted = true
+		}
+		err = row.Add()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler, err := NewHandler(file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*memos = 0
+	return handler, memos
+}
+
+// serve sends the request to the handler and decodes the JSON response into the body if not nil
+func serve(t *testing.T, handler http.Handler, method string, target string, body string, response interface{}) int {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	if response != nil {
+		err := json.Unmarshal(recorder.Body.Bytes(), response)
+		if err != nil {
+			t.Fatalf("%s %s: %v: %s", method, target, err, recorder.Body.String())
+		}
+	}
+	return recorder.Code
+}
+
+func TestListPage(t *testing.T) {
+	handler, memos := newTestHandler(t, Options{})
+	page := &Page{}
+	status := serve(t, handler, http.MethodGet, "/rows?offset=1&limit=3", "", page)
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+	if page.Total != 9 || page.Offset != 1 || page.Limit != 3 {
+		t.Errorf("expected total 9, offset 1 and limit 3, got %d, %d and %d", page.Total, page.Offset, page.Limit)
+	}
+	positions := make([]uint32, 0, len(page.Rows))
+	for _, row := range page.Rows {
+		positions = append(positions, row.Position)
+	}
+	if fmt.Sprint(positions) != "[1 3 4]" {
+		t.Errorf("expected the rows [1 3 4], got %v", positions)
+	}
+	// Only the memos of the rows in the page are read
+	if *memos != 3 {
+		t.Errorf("expected 3 memos read, got %d", *memos)
+	}
+	*memos = 0
+	status = serve(t, handler, http.MethodGet, "/rows?name=ROW7", "", page)
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+	if page.Total != 1 || len(page.Rows) != 1 || page.Rows[0].Position != 7 {
+		t.Errorf("expected the row 7, got %d rows of %d", len(page.Rows), page.Total)
+	}
+	if *memos != 1 {
+		t.Errorf("expected 1 memo read, got %d", *memos)
+	}
+	status = serve(t, handler, http.MethodGet, "/rows?name=ROW2", "", page)
+	if status != http.StatusOK || page.Total != 0 || len(page.Rows) != 0 {
+		t.Errorf("expected no rows for the deleted row, got status %d and %d rows", status, page.Total)
+	}
+}
+
+func TestKey(t *testing.T) {
+	handler, memos := newTestHandler(t, Options{Key: "NAME"})
+	row := &Row{}
+	status := serve(t, handler, http.MethodGet, "/keys/ROW5", "", row)
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+	values := make(map[string]interface{})
+	err := json.Unmarshal(row.Data, &values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.Position != 5 || values["NAME"] != "ROW5" || values["NOTES"] != "memo 5" {
+		t.Errorf("expected the row 5, got %d: %s", row.Position, row.Data)
+	}
+	if *memos != 1 {
+		t.Errorf("expected 1 memo read, got %d", *memos)
+	}
+	for _, key := range []string{"ROW2", "ROW10"} {
+		status = serve(t, handler, http.MethodGet, "/keys/"+key, "", nil)
+		if status != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", key, http.StatusNotFound, status)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	handler, _ := newTestHandler(t, Options{Writable: true})
+	row := &Row{}
+	status := serve(t, handler, http.MethodPost, "/rows", `{"ID": 10, "NAME": "ROW10"}`, row)
+	if status != http.StatusCreated || row.Position != 10 {
+		t.Fatalf("expected the created row 10, got status %d and row %d", status, row.Position)
+	}
+	status = serve(t, handler, http.MethodPatch, "/rows/10", `{"NAME": "CHANGED"}`, row)
+	if status != http.StatusOK || !bytes.Contains(row.Data, []byte(`"CHANGED"`)) {
+		t.Errorf("expected the changed row, got status %d: %s", status, row.Data)
+	}
+	status = serve(t, handler, http.MethodDelete, "/rows/10", "", nil)
+	if status != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, status)
+	}
+	status = serve(t, handler, http.MethodGet, "/rows/10", "", nil)
+	if status != http.StatusNotFound {
+		t.Errorf("expected status %d for the deleted row, got %d", http.StatusNotFound, status)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	handler, _ := newTestHandler(t, Options{Writable: true, MaxBodySize: 64})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	body := `{"NAME": "` + strings.Repeat("X", 128) + `"}`
+	response, err := http.Post(server.URL+"/rows", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, response.StatusCode)
+	}
+	// The server closes the connection instead of reading the rest of the body
+	if !response.Close {
+		t.Error("expected the connection to be closed after the too large body")
+	}
+}
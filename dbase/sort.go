@@ -0,0 +1,456 @@
+package dbase
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// sortMemoryLimit is the amount of sort keys held in memory before a sorted run is spilled to a temporary file
+const sortMemoryLimit = 32 << 20
+
+// sortEntryOverhead approximates the memory used by a sort entry besides the key itself
+const sortEntryOverhead = 32
+
+// SortedRows iterates over the rows of a table ordered by a column.
+// It is created by File.Sorted and must be closed after use.
+type SortedRows struct {
+	file     *File
+	order    rowOrder
+	position uint32
+	ok       bool
+	err      error
+}
+
+// rowOrder provides the row positions in sorted order
+type rowOrder interface {
+	next() (uint32, bool, error)
+	close() error
+}
+
+// Sorted returns an iterator over all rows (including deleted rows) ordered by the values of the column.
+// If the table has a structural compound index (CDX) with a tag on the plain column, the index is used.
+// Otherwise the rows are sorted by an external merge sort that spills to temporary files for large tables.
+// Rows with equal values keep their physical order. The internal row pointer is not changed.
+func (file *File) Sorted(column string, desc bool) (*SortedRows, error) {
+	pos := file.columnPosByKey(column)
+	if pos < 0 {
+		return nil, NewErrorf("column '%s' not found", column)
+	}
+	col := file.table.columns[pos]
+	switch DataType(col.DataType) {
+	case Memo, Blob, General, Picture:
+		return nil, NewError("sorting memo fields is not supported")
+	}
+	debugf("Sorting rows by column %s - descending: %v", col.Name(), desc)
+	order, err := file.indexOrder(col, desc)
+	if err != nil {
+		debugf("Compound index not usable for sorting: %v", err)
+	}
+	if order == nil {
+		order, err = file.sortOrder(pos, desc)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+	}
+	rows := &SortedRows{file: file, order: order}
+	rows.advance()
+	return rows, nil
+}
+
+// EOF returns true if all rows have been returned or an error occurred
+func (rows *SortedRows) EOF() bool {
+	return !rows.ok
+}
+
+// Next returns the next row in sorted order or ErrEOF if all rows have been returned
+func (rows *SortedRows) Next() (*Row, error) {
+	if rows.err != nil {
+		return nil, WrapError(rows.err)
+	}
+	if !rows.ok {
+		return nil, WrapError(ErrEOF)
+	}
+	position := rows.position
+	rows.advance()
+	pointer := rows.file.table.rowPointer
+	defer func() { rows.file.table.rowPointer = pointer }()
+	err := rows.file.GoTo(position)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	row, err := rows.file.Row()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return row, nil
+}
+
+// Close releases the index file or temporary files used for sorting
+func (rows *SortedRows) Close() error {
+	rows.ok = false
+	return rows.order.close()
+}
+
+func (rows *SortedRows) advance() {
+	rows.position, rows.ok, rows.err = rows.order.next()
+	if rows.err != nil {
+		rows.ok = false
+	}
+}
+
+// indexOrder returns the row order of a CDX tag on the column or nil if no usable tag exists
+func (file *File) indexOrder(column *Column, desc bool) (rowOrder, error) {
+	if !StructuralFlag.Defined(file.header.TableFlags) || len(file.config.Filename) == 0 {
+		return nil, nil
+	}
+	filename, err := file.indexFilename(CDX)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if len(filename) == 0 {
+		return nil, nil
+	}
+	index, err := openCDX(filename)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	tag := index.tagFor(column)
+	if tag == nil {
+		index.Close()
+		return nil, nil
+	}
+	debugf("Using index tag %s to sort by column %s", tag.name, column.Name())
+	// The index is only used if it references every row exactly once
+	cursor := index.cursor(tag.header, false)
+	seen := make([]bool, file.header.RowsCount)
+	for {
+		entry, ok, err := cursor.next()
+		if err != nil {
+			index.Close()
+			return nil, WrapError(err)
+		}
+		if !ok {
+			break
+		}
+		if entry.recno == 0 || entry.recno > file.header.RowsCount || seen[entry.recno-1] {
+			index.Close()
+			return nil, NewErrorf("index tag %s does not match the table", tag.name)
+		}
+		seen[entry.recno-1] = true
+	}
+	for _, s := range seen {
+		if !s {
+			index.Close()
+			return nil, NewErrorf("index tag %s does not contain all rows", tag.name)
+		}
+	}
+	reverse := desc != (tag.header.Descending == 1)
+	return &indexOrder{index: index, cursor: index.cursor(tag.header, reverse)}, nil
+}
+
+// indexOrder walks the leaves of a CDX tag
+type indexOrder struct {
+	index  *cdxIndex
+	cursor *cdxCursor
+}
+
+func (o *indexOrder) next() (uint32, bool, error) {
+	entry, ok, err := o.cursor.next()
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	return entry.recno - 1, true, nil
+}
+
+func (o *indexOrder) close() error {
+	return o.index.Close()
+}
+
+// sortEntry is the sort key of a row and its position
+type sortEntry struct {
+	key      []byte
+	position uint32
+}
+
+// compareSortEntries orders by key and keeps the physical order for equal keys
+func compareSortEntries(a, b sortEntry, desc bool) int {
+	c := bytes.Compare(a.key, b.key)
+	if desc {
+		c = -c
+	}
+	if c != 0 {
+		return c
+	}
+	switch {
+	case a.position < b.position:
+		return -1
+	case a.position > b.position:
+		return 1
+	}
+	return 0
+}
+
+type sortEntries struct {
+	entries []sortEntry
+	desc    bool
+}
+
+func (s *sortEntries) Len() int      { return len(s.entries) }
+func (s *sortEntries) Swap(i, j int) { s.entries[i], s.entries[j] = s.entries[j], s.entries[i] }
+func (s *sortEntries) Less(i, j int) bool {
+	return compareSortEntries(s.entries[i], s.entries[j], s.desc) < 0
+}
+
+// sortOrder reads the column of every row, sorts the keys in memory and
+// spills sorted runs to temporary files once sortMemoryLimit is exceeded.
+func (file *File) sortOrder(pos int, desc bool) (rowOrder, error) {
+	column := file.table.columns[pos]
+	offset := file.table.layout.Columns[pos].Offset
+	buffer := &sortEntries{entries: make([]sortEntry, 0), desc: desc}
+	runs := make([]*os.File, 0)
+	size := 0
+	for i := uint32(0); i < file.header.RowsCount; i++ {
+		data, err := file.ReadRow(i)
+		if err != nil {
+			closeSortRuns(runs)
+			return nil, WrapError(err)
+		}
+		if len(data) < offset+column.Size() {
+			closeSortRuns(runs)
+			return nil, NewErrorf("invalid row data size %v Bytes", len(data))
+		}
+		val, err := file.interpret(data[offset:offset+column.Size()], column, file.table.layout.rowNullFlags(data))
+		if err != nil {
+			closeSortRuns(runs)
+			return nil, WrapError(err)
+		}
+		key := sortKey(val)
+		buffer.entries = append(buffer.entries, sortEntry{key: key, position: i})
+		size += len(key) + sortEntryOverhead
+		if size < sortMemoryLimit {
+			continue
+		}
+		run, err := spillSortRun(buffer)
+		if err != nil {
+			closeSortRuns(runs)
+			return nil, WrapError(err)
+		}
+		runs = append(runs, run)
+		buffer.entries = buffer.entries[:0]
+		size = 0
+	}
+	if len(runs) == 0 {
+		sort.Sort(buffer)
+		return &memoryOrder{entries: buffer.entries}, nil
+	}
+	if len(buffer.entries) > 0 {
+		run, err := spillSortRun(buffer)
+		if err != nil {
+			closeSortRuns(runs)
+			return nil, WrapError(err)
+		}
+		runs = append(runs, run)
+	}
+	debugf("Merging %d sorted runs", len(runs))
+	return newMergeOrder(runs, desc)
+}
+
+// sortKey encodes a value so that the byte order of the keys matches the order of the values.
+// Null values are ordered before all other values.
+func sortKey(val interface{}) []byte {
+	if val == nil {
+		return []byte{0}
+	}
+	key := []byte{1}
+	switch v := val.(type) {
+	case string:
+		return append(key, v...)
+	case []byte:
+		return append(key, v...)
+	case bool:
+		if v {
+			return append(key, 1)
+		}
+		return append(key, 0)
+	case int32:
+		return appendSortInt(key, int64(v))
+	case int64:
+		return appendSortInt(key, v)
+	case int:
+		return appendSortInt(key, int64(v))
+	case float64:
+		bits := math.Float64bits(v)
+		if bits>>63 == 1 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		return binary.BigEndian.AppendUint64(key, bits)
+	case time.Time:
+		key = appendSortInt(key, v.Unix())
+		return binary.BigEndian.AppendUint32(key, uint32(v.Nanosecond()))
+	default:
+		return append(key, fmt.Sprint(v)...)
+	}
+}
+
+func appendSortInt(key []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(key, uint64(v)^(1<<63))
+}
+
+// spillSortRun sorts the entries and writes them to a temporary file
+func spillSortRun(buffer *sortEntries) (*os.File, error) {
+	sort.Sort(buffer)
+	run, err := os.CreateTemp("", "dbase-sort-*")
+	if err != nil {
+		return nil, NewError("failed to create temporary sort file").Details(err)
+	}
+	debugf("Spilling %d sort entries to %s", len(buffer.entries), run.Name())
+	writer := bufio.NewWriter(run)
+	header := make([]byte, binary.MaxVarintLen64+4)
+	for _, entry := range buffer.entries {
+		n := binary.PutUvarint(header, uint64(len(entry.key)))
+		binary.BigEndian.PutUint32(header[n:], entry.position)
+		_, err = writer.Write(header[:n+4])
+		if err == nil {
+			_, err = writer.Write(entry.key)
+		}
+		if err != nil {
+			closeSortRuns([]*os.File{run})
+			return nil, NewError("failed to write temporary sort file").Details(err)
+		}
+	}
+	err = writer.Flush()
+	if err == nil {
+		_, err = run.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		closeSortRuns([]*os.File{run})
+		return nil, NewError("failed to write temporary sort file").Details(err)
+	}
+	return run, nil
+}
+
+func closeSortRuns(runs []*os.File) {
+	for _, run := range runs {
+		run.Close()
+		os.Remove(run.Name())
+	}
+}
+
+// memoryOrder returns the positions of entries sorted in memory
+type memoryOrder struct {
+	entries []sortEntry
+	pos     int
+}
+
+func (o *memoryOrder) next() (uint32, bool, error) {
+	if o.pos >= len(o.entries) {
+		return 0, false, nil
+	}
+	o.pos++
+	return o.entries[o.pos-1].position, true, nil
+}
+
+func (o *memoryOrder) close() error {
+	o.entries = nil
+	return nil
+}
+
+// sortRun is a sorted temporary file and its current entry
+type sortRun struct {
+	file    *os.File
+	reader  *bufio.Reader
+	current sortEntry
+}
+
+func (run *sortRun) read() (bool, error) {
+	length, err := binary.ReadUvarint(run.reader)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, NewError("failed to read temporary sort file").Details(err)
+	}
+	buf := make([]byte, 4+length)
+	_, err = io.ReadFull(run.reader, buf)
+	if err != nil {
+		return false, NewError("failed to read temporary sort file").Details(err)
+	}
+	run.current = sortEntry{key: buf[4:], position: binary.BigEndian.Uint32(buf)}
+	return true, nil
+}
+
+// mergeOrder merges the sorted runs using a min-heap
+type mergeOrder struct {
+	runs   []*sortRun
+	files  []*os.File
+	desc   bool
+	closed bool
+}
+
+func newMergeOrder(files []*os.File, desc bool) (*mergeOrder, error) {
+	o := &mergeOrder{files: files, desc: desc}
+	for _, file := range files {
+		run := &sortRun{file: file, reader: bufio.NewReader(file)}
+		ok, err := run.read()
+		if err != nil {
+			closeSortRuns(files)
+			return nil, WrapError(err)
+		}
+		if ok {
+			o.runs = append(o.runs, run)
+		}
+	}
+	heap.Init(o)
+	return o, nil
+}
+
+func (o *mergeOrder) Len() int { return len(o.runs) }
+func (o *mergeOrder) Less(i, j int) bool {
+	return compareSortEntries(o.runs[i].current, o.runs[j].current, o.desc) < 0
+}
+func (o *mergeOrder) Swap(i, j int) { o.runs[i], o.runs[j] = o.runs[j], o.runs[i] }
+func (o *mergeOrder) Push(x interface{}) {
+	o.runs = append(o.runs, x.(*sortRun))
+}
+
+func (o *mergeOrder) Pop() interface{} {
+	run := o.runs[len(o.runs)-1]
+	o.runs = o.runs[:len(o.runs)-1]
+	return run
+}
+
+func (o *mergeOrder) next() (uint32, bool, error) {
+	if o.closed || len(o.runs) == 0 {
+		return 0, false, nil
+	}
+	run := o.runs[0]
+	position := run.current.position
+	ok, err := run.read()
+	if err != nil {
+		return 0, false, WrapError(err)
+	}
+	if ok {
+		heap.Fix(o, 0)
+	} else {
+		heap.Pop(o)
+	}
+	return position, true, nil
+}
+
+func (o *mergeOrder) close() error {
+	if !o.closed {
+		o.closed = true
+		closeSortRuns(o.files)
+	}
+	return nil
+}
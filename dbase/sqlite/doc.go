@@ -0,0 +1,27 @@
+// The sqlite package registers dBase tables as virtual tables in SQLite, so tables can be queried and joined with SQL.
+// The library reads the rows underneath, SQLite only sees the values.
+//
+// The package uses the virtual table support of github.com/mattn/go-sqlite3, which requires cgo and the sqlite_vtable build tag:
+//
+//	go build -tags sqlite_vtable
+//
+// Register a driver and create one virtual table per DBF file:
+//
+//	sqlite.RegisterDriver("sqlite3_dbase", sqlite.Options{})
+//	db, err := sql.Open("sqlite3_dbase", ":memory:")
+//	_, err = db.Exec(`CREATE VIRTUAL TABLE employees USING dbase('/data/EMPLOYEES.DBF')`)
+//	_, err = db.Exec(`CREATE VIRTUAL TABLE departments USING dbase('/data/DEPARTMENTS.DBF')`)
+//	rows, err := db.Query(`SELECT e.NAME, d.NAME FROM employees e JOIN departments d ON d.ID = e.DEPT_ID`)
+//
+// Column types are mapped to SQLite types as follows:
+//
+//	Character, Varchar, Memo            TEXT (BLOB if the column is binary)
+//	Numeric (no decimals), Integer      INTEGER
+//	Numeric, Float, Double, Currency    REAL
+//	Logical                             INTEGER (0 or 1)
+//	Date, DateTime                      TEXT (2006-01-02 and 2006-01-02 15:04:05.000), NULL if empty
+//	Blob, Varbinary, General, Picture   BLOB
+//
+// The rowid of a row is its position plus one. Deleted rows are skipped unless Options.IncludeDeleted is set,
+// then a hidden column _deleted holds the deleted flag. The virtual tables are read-only.
+package sqlite
@@ -0,0 +1,272 @@
+//go:build cgo && (sqlite_vtable || vtable)
+
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+	"github.com/mattn/go-sqlite3"
+)
+
+// DefaultModuleName is the name of the module in CREATE VIRTUAL TABLE statements if not configured
+const DefaultModuleName = "dbase"
+
+// deletedColumn is the hidden column holding the deleted flag if deleted rows are included
+const deletedColumn = "_deleted"
+
+// rowidIndex is the index number of a lookup by rowid
+const rowidIndex = 1
+
+// Options control how the tables are opened
+type Options struct {
+	ModuleName     string        // Name of the module, defaults to DefaultModuleName
+	Config         *dbase.Config // Config the tables are opened with, the filename is taken from the statement. Defaults to read-only with trimmed spaces.
+	IncludeDeleted bool          // Includes deleted rows and adds the hidden column _deleted
+}
+
+// RegisterDriver registers a SQLite driver under the name that provides the module on every connection
+func RegisterDriver(name string, options Options) {
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return Register(conn, options)
+		},
+	})
+}
+
+// Register provides the module on the connection, e.g. in the ConnectHook of a custom driver
+func Register(conn *sqlite3.SQLiteConn, options Options) error {
+	if len(options.ModuleName) == 0 {
+		options.ModuleName = DefaultModuleName
+	}
+	err := conn.CreateModule(options.ModuleName, &module{options: options})
+	if err != nil {
+		return dbase.NewErrorf("failed to create module %s", options.ModuleName).Details(err)
+	}
+	return nil
+}
+
+// module creates a virtual table per CREATE VIRTUAL TABLE statement
+type module struct {
+	options Options
+}
+
+func (m *module) Create(conn *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	return m.Connect(conn, args)
+}
+
+// Connect opens the table given as first argument of the statement and declares its columns.
+// The arguments are the module name, database name, table name and the arguments of the statement.
+func (m *module) Connect(conn *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	if len(args) < 4 {
+		return nil, dbase.NewErrorf("missing filename, use CREATE VIRTUAL TABLE name USING %s('filename')", m.options.ModuleName)
+	}
+	config := &dbase.Config{ReadOnly: true, TrimSpaces: true}
+	if m.options.Config != nil {
+		c := *m.options.Config
+		config = &c
+	}
+	config.Filename = strings.Trim(strings.TrimSpace(args[3]), `'"`)
+	file, err := dbase.OpenTable(config)
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	columns := make([]string, 0, len(file.Columns())+1)
+	for _, column := range file.Columns() {
+		columns = append(columns, fmt.Sprintf("%s %s", quoteIdentifier(column.Name()), columnType(column)))
+	}
+	if m.options.IncludeDeleted {
+		columns = append(columns, deletedColumn+" INTEGER HIDDEN")
+	}
+	err = conn.DeclareVTab(fmt.Sprintf("CREATE TABLE x(%s)", strings.Join(columns, ", ")))
+	if err != nil {
+		file.Close()
+		return nil, dbase.NewErrorf("failed to declare virtual table for %s", config.Filename).Details(err)
+	}
+	return &table{file: file, includeDeleted: m.options.IncludeDeleted}, nil
+}
+
+func (m *module) DestroyModule() {}
+
+// columnType returns the SQLite type of the column
+func columnType(column *dbase.Column) string {
+	switch dbase.DataType(column.DataType) {
+	case dbase.Character, dbase.Varchar, dbase.Memo:
+		if column.Binary() {
+			return "BLOB"
+		}
+		return "TEXT"
+	case dbase.Numeric:
+		if column.Decimals > 0 {
+			return "REAL"
+		}
+		return "INTEGER"
+	case dbase.Integer, dbase.Logical:
+		return "INTEGER"
+	case dbase.Float, dbase.Double, dbase.Currency:
+		return "REAL"
+	case dbase.Date, dbase.DateTime:
+		return "TEXT"
+	}
+	return "BLOB"
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// table is a virtual table reading the rows of a dBase table
+type table struct {
+	file           *dbase.File
+	includeDeleted bool
+}
+
+// BestIndex uses an equality constraint on the rowid for a direct lookup, other constraints are checked by SQLite
+func (t *table) BestIndex(constraints []sqlite3.InfoConstraint, _ []sqlite3.InfoOrderBy) (*sqlite3.IndexResult, error) {
+	rows := float64(t.file.Header().RecordsCount())
+	result := &sqlite3.IndexResult{Used: make([]bool, len(constraints)), EstimatedCost: rows, EstimatedRows: rows}
+	for i, constraint := range constraints {
+		if constraint.Usable && constraint.Column == -1 && constraint.Op == sqlite3.OpEQ {
+			result.Used[i] = true
+			result.IdxNum = rowidIndex
+			result.EstimatedCost = 1
+			result.EstimatedRows = 1
+			break
+		}
+	}
+	return result, nil
+}
+
+func (t *table) Disconnect() error {
+	return t.file.Close()
+}
+
+func (t *table) Destroy() error {
+	return t.Disconnect()
+}
+
+// Open returns a cursor reading the rows by position, so several cursors can read the table at once
+func (t *table) Open() (sqlite3.VTabCursor, error) {
+	return &cursor{table: t}, nil
+}
+
+type cursor struct {
+	table    *table
+	position uint32
+	end      uint32
+	row      *dbase.Row
+}
+
+// Filter starts the scan, at the row of the rowid for a lookup
+func (c *cursor) Filter(idxNum int, _ string, vals []interface{}) error {
+	c.position, c.end = 0, c.table.file.Header().RecordsCount()
+	if idxNum == rowidIndex && len(vals) == 1 {
+		rowid, ok := vals[0].(int64)
+		if !ok || rowid < 1 || rowid > int64(c.end) {
+			c.position = c.end
+			return nil
+		}
+		c.position, c.end = uint32(rowid-1), uint32(rowid)
+	}
+	return c.read()
+}
+
+func (c *cursor) Next() error {
+	c.position++
+	return c.read()
+}
+
+// read reads the row at the position, skipping deleted rows unless they are included
+func (c *cursor) read() error {
+	c.row = nil
+	for ; c.position < c.end; c.position++ {
+		data, err := c.table.file.ReadRow(c.position)
+		if err != nil {
+			return dbase.NewErrorf("failed to read row %d", c.position).Details(err)
+		}
+		row, err := c.table.file.BytesToRow(data)
+		if err != nil {
+			return dbase.NewErrorf("failed to convert row %d", c.position).Details(err)
+		}
+		if row.Deleted && !c.table.includeDeleted {
+			continue
+		}
+		row.Position = c.position
+		c.row = row
+		return nil
+	}
+	return nil
+}
+
+func (c *cursor) EOF() bool {
+	return c.row == nil
+}
+
+// Column returns the value of the column, the hidden deleted column follows the table columns
+func (c *cursor) Column(ctx *sqlite3.SQLiteContext, col int) error {
+	if col == len(c.row.Fields()) {
+		ctx.ResultBool(c.row.Deleted)
+		return nil
+	}
+	field := c.row.Field(col)
+	if field == nil {
+		return dbase.NewErrorf("column %d not found", col)
+	}
+	switch v := field.GetValue().(type) {
+	case nil:
+		ctx.ResultNull()
+	case string:
+		resultText(ctx, v)
+	case []byte:
+		if columnType(field.Column()) == "TEXT" {
+			resultText(ctx, string(v))
+			break
+		}
+		if len(v) == 0 {
+			ctx.ResultZeroblob(0)
+			break
+		}
+		ctx.ResultBlob(v)
+	case bool:
+		ctx.ResultBool(v)
+	case int32:
+		ctx.ResultInt64(int64(v))
+	case int64:
+		ctx.ResultInt64(v)
+	case float64:
+		ctx.ResultDouble(v)
+	case time.Time:
+		switch {
+		case dbase.IsEmptyDate(v):
+			ctx.ResultNull()
+		case field.Type() == dbase.Date:
+			ctx.ResultText(v.Format("2006-01-02"))
+		default:
+			ctx.ResultText(v.Format("2006-01-02 15:04:05.000"))
+		}
+	default:
+		ctx.ResultText(fmt.Sprint(v))
+	}
+	return nil
+}
+
+// resultText sets the text result, empty strings are passed with a valid pointer as SQLite turns a nil pointer into NULL
+func resultText(ctx *sqlite3.SQLiteContext, s string) {
+	if len(s) == 0 {
+		s = emptyText[:0]
+	}
+	ctx.ResultText(s)
+}
+
+var emptyText = " "
+
+func (c *cursor) Rowid() (int64, error) {
+	return int64(c.position) + 1, nil
+}
+
+func (c *cursor) Close() error {
+	return nil
+}
@@ -0,0 +1,258 @@
+package dbase
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// StatsExtension is appended to the table name to name the sidecar file AnalyzeCached persists the statistics in
+const StatsExtension = ".dbfstats.json"
+
+// TableStats are the statistics of a table computed by Analyze
+type TableStats struct {
+	Analyzed time.Time      `json:"analyzed"`           // When the table was analyzed
+	Rows     uint32         `json:"rows"`               // Number of rows, including deleted rows
+	Deleted  uint32         `json:"deleted"`            // Number of deleted rows
+	Warnings []string       `json:"warnings,omitempty"` // Anomalies of the file tolerated when it was opened, e.g. a row length differing from the columns
+	Columns  []*ColumnStats `json:"columns"`            // Statistics per column of the active rows
+}
+
+// ColumnStats are the statistics of the values of a column in the active rows
+type ColumnStats struct {
+	Name      string     `json:"name"`              // Name of the column
+	Type      string     `json:"type"`              // Data type of the column
+	Empty     uint32     `json:"empty"`             // Number of empty or null values
+	MinLength int        `json:"min_length"`        // Shortest trimmed length of text and binary values that are not empty
+	MaxLength int        `json:"max_length"`        // Longest trimmed length of text and binary values
+	Min       *float64   `json:"min,omitempty"`     // Smallest numeric value
+	Max       *float64   `json:"max,omitempty"`     // Largest numeric value
+	First     *time.Time `json:"first,omitempty"`   // Earliest date or datetime value
+	Last      *time.Time `json:"last,omitempty"`    // Latest date or datetime value
+	True      uint32     `json:"true,omitempty"`    // Number of true logical values
+	Invalid   uint32     `json:"invalid,omitempty"` // Number of values that could not be read
+	lengthSet bool       // Whether MinLength was set by a value
+}
+
+// statsSidecar is the content of the sidecar file, the stamp identifies the state of the table the statistics belong to
+type statsSidecar struct {
+	ModTime  time.Time   `json:"mod_time"`
+	Size     int64       `json:"size"`
+	Modified string      `json:"modified"`
+	Stats    *TableStats `json:"stats"`
+}
+
+// Analyze reads all rows and returns the statistics of the table and its columns.
+// Values are read as stored, column modifications are not applied. The internal row pointer is not moved.
+func (file *File) Analyze() (*TableStats, error) {
+	debugf("Analyzing %d rows of table %s", file.header.RowsCount, file.config.Filename)
+	stats := &TableStats{
+		Analyzed: time.Now(),
+		Rows:     file.header.RowsCount,
+		Warnings: file.Warnings(),
+		Columns:  make([]*ColumnStats, len(file.table.columns)),
+	}
+	for i, column := range file.table.columns {
+		stats.Columns[i] = &ColumnStats{Name: column.Name(), Type: column.Type()}
+	}
+	for position := uint32(0); position < file.header.RowsCount; position++ {
+		data, err := file.ReadRow(position)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		if Marker(data[0]) == Deleted {
+			stats.Deleted++
+			continue
+		}
+		nullFlags := file.table.layout.rowNullFlags(data)
+		for i, column := range file.table.columns {
+			layout := file.table.layout.Columns[i]
+			val, err := file.interpret(data[layout.Offset:layout.Offset+layout.Length], column, nullFlags)
+			if err != nil {
+				stats.Columns[i].Invalid++
+				continue
+			}
+			stats.Columns[i].add(val)
+		}
+	}
+	return stats, nil
+}
+
+// add counts the value of the column
+func (s *ColumnStats) add(val interface{}) {
+	switch v := val.(type) {
+	case nil:
+		s.Empty++
+	case string:
+		s.length(len(strings.TrimSpace(v)))
+	case []byte:
+		s.length(len(sanitizeEmptyBytes(v)))
+	case bool:
+		if v {
+			s.True++
+		}
+	case time.Time:
+		if v.IsZero() {
+			s.Empty++
+			return
+		}
+		if s.First == nil || v.Before(*s.First) {
+			first := v
+			s.First = &first
+		}
+		if s.Last == nil || v.After(*s.Last) {
+			last := v
+			s.Last = &last
+		}
+	case int32:
+		s.number(float64(v))
+	case int64:
+		s.number(float64(v))
+	case float64:
+		if math.IsNaN(v) {
+			s.Empty++
+			return
+		}
+		s.number(v)
+	}
+}
+
+func (s *ColumnStats) length(length int) {
+	if length == 0 {
+		s.Empty++
+		return
+	}
+	if !s.lengthSet || length < s.MinLength {
+		s.MinLength = length
+		s.lengthSet = true
+	}
+	if length > s.MaxLength {
+		s.MaxLength = length
+	}
+}
+
+func (s *ColumnStats) number(f float64) {
+	if s.Min == nil || f < *s.Min {
+		min := f
+		s.Min = &min
+	}
+	if s.Max == nil || f > *s.Max {
+		max := f
+		s.Max = &max
+	}
+}
+
+type statsSink struct {
+	stats   *TableStats
+	columns map[string]*ColumnStats
+}
+
+// StatsSink computes the statistics of the records written to it into stats, e.g. next to other sinks of a TeeSink.
+// Unlike Analyze, the values are the values of the records, so modifications and stages are applied.
+// The columns are the keys of the records in the order they are first seen. Analyzed is set when the sink is closed.
+func StatsSink(stats *TableStats) Sink {
+	*stats = TableStats{Columns: make([]*ColumnStats, 0)}
+	return &statsSink{stats: stats, columns: make(map[string]*ColumnStats)}
+}
+
+func (s *statsSink) Write(record *Record) error {
+	s.stats.Rows++
+	if record.Deleted {
+		s.stats.Deleted++
+		return nil
+	}
+	for _, field := range record.Fields {
+		column, ok := s.columns[field.Key]
+		if !ok {
+			column = &ColumnStats{Name: field.Key}
+			if field.Column != nil {
+				column.Type = field.Column.Type()
+			}
+			s.columns[field.Key] = column
+			s.stats.Columns = append(s.stats.Columns, column)
+		}
+		column.add(field.Value)
+	}
+	return nil
+}
+
+func (s *statsSink) Close() error {
+	s.stats.Analyzed = time.Now()
+	return nil
+}
+
+// AnalyzeCached returns the statistics persisted in the sidecar file next to the table (see StatsExtension)
+// if the table did not change since and they are not older than maxAge (no limit if zero),
+// so repeated runs over large archives do not read every table again. Otherwise the table is analyzed
+// and the statistics are persisted. A failure to persist them is not an error, they are just not cached.
+// Tables opened through GenericIO have no sidecar file and are always analyzed.
+func (file *File) AnalyzeCached(maxAge time.Duration) (*TableStats, error) {
+	filename := file.statsFilename()
+	if len(filename) == 0 {
+		return file.Analyze()
+	}
+	stamp, err := file.statsStamp()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if b, err := os.ReadFile(filename); err == nil {
+		cached := &statsSidecar{}
+		err = json.Unmarshal(b, cached)
+		switch {
+		case err != nil || cached.Stats == nil:
+			debugf("Ignoring invalid statistics file %s", filename)
+		case !cached.ModTime.Equal(stamp.ModTime) || cached.Size != stamp.Size || cached.Modified != stamp.Modified:
+			debugf("Table changed since statistics file %s was written", filename)
+		case maxAge > 0 && time.Since(cached.Stats.Analyzed) > maxAge:
+			debugf("Statistics file %s is older than %v", filename, maxAge)
+		default:
+			debugf("Using statistics file %s", filename)
+			return cached.Stats, nil
+		}
+	}
+	stats, err := file.Analyze()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	stamp.Stats = stats
+	b, err := json.MarshalIndent(stamp, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filename, b, 0600)
+	}
+	if err != nil {
+		debugf("Failed to write statistics file %s: %v", filename, err)
+	}
+	return stats, nil
+}
+
+// statsFilename returns the name of the sidecar file, empty if the table is not a file on disk
+func (file *File) statsFilename() string {
+	if _, ok := file.io.(GenericIO); ok {
+		return ""
+	}
+	filename, err := findFile(filepath.Clean(file.config.Filename))
+	if err != nil || len(filename) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + StatsExtension
+}
+
+// statsStamp returns the modification time and size of the DBF file and the last modified date of the header
+func (file *File) statsStamp() (*statsSidecar, error) {
+	filename, err := findFile(filepath.Clean(file.config.Filename))
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, NewErrorf("failed to read file info of %s", filename).Details(err)
+	}
+	return &statsSidecar{
+		ModTime:  info.ModTime(),
+		Size:     info.Size(),
+		Modified: file.header.Modified(0).Format("2006-01-02"),
+	}, nil
+}
@@ -0,0 +1,157 @@
+package dbase
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// structPath is the destination of a column given by the path tag of a struct field, e.g.
+//
+//	Address Address `dbase:"ADDR_STREET" path:"Address.Street"`
+//
+// The path starts at the struct passed to ToStruct. Path elements can index slices and arrays,
+// e.g. `path:"Phones[1]"` or `path:"Items[0].Qty"`, to fill repeated groups of columns like PHONE1, PHONE2 or ITEM1_QTY.
+// As the tagged field is only used to hold the tags, blank fields can be used as well:
+//
+//	_ struct{} `dbase:"ADDR_CITY" path:"Address.City"`
+type structPath struct {
+	column   string        // Column name or external key of the dbase tag
+	path     string        // Path as given in the tag
+	segments []pathSegment // Parsed path
+}
+
+// pathSegment is a struct field of a path, optionally indexed
+type pathSegment struct {
+	name  string
+	index int // Index of the slice or array element, -1 if the field is not indexed
+}
+
+// getStructPaths returns the path tags of the struct fields, including embedded structs
+func getStructPaths(v interface{}) ([]structPath, error) {
+	paths := make([]structPath, 0)
+	err := extractPaths(reflect.TypeOf(v).Elem(), &paths)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return paths, nil
+}
+
+func extractPaths(structType reflect.Type, paths *[]structPath) error {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			err := extractPaths(field.Type, paths)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		path, ok := field.Tag.Lookup("path")
+		if !ok {
+			continue
+		}
+		column := field.Tag.Get("dbase")
+		if len(column) == 0 {
+			return NewErrorf("path tag %s of struct field %s without dbase tag", path, field.Name)
+		}
+		segments, err := parseStructPath(path)
+		if err != nil {
+			return NewErrorf("invalid path tag of struct field %s", field.Name).Details(err)
+		}
+		*paths = append(*paths, structPath{column: column, path: path, segments: segments})
+	}
+	return nil
+}
+
+// parseStructPath splits the path into its fields and indexes
+func parseStructPath(path string) ([]pathSegment, error) {
+	parts := strings.Split(path, ".")
+	segments := make([]pathSegment, 0, len(parts))
+	for _, part := range parts {
+		segment := pathSegment{name: part, index: -1}
+		if open := strings.IndexByte(part, '['); open >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, NewErrorf("missing ] in path element %s", part)
+			}
+			index, err := strconv.Atoi(part[open+1 : len(part)-1])
+			if err != nil || index < 0 {
+				return nil, NewErrorf("invalid index in path element %s", part)
+			}
+			segment.name, segment.index = part[:open], index
+		}
+		if len(segment.name) == 0 {
+			return nil, NewErrorf("empty path element in %s", path)
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// setStructPath sets the field at the end of the path to the value.
+// Nil pointers on the way are allocated and slices are grown to the index.
+func setStructPath(root reflect.Value, path structPath, value interface{}) error {
+	current := root
+	for _, segment := range path.segments {
+		for current.Kind() == reflect.Ptr {
+			if current.IsNil() {
+				current.Set(reflect.New(current.Type().Elem()))
+			}
+			current = current.Elem()
+		}
+		if current.Kind() != reflect.Struct {
+			return NewErrorf("path %s: %s is not a struct", path.path, segment.name)
+		}
+		field := current.FieldByName(segment.name)
+		if !field.IsValid() {
+			return NewErrorf("path %s: struct field %s not found", path.path, segment.name)
+		}
+		if !field.CanSet() {
+			return NewErrorf("path %s: struct field %s can not be set", path.path, segment.name)
+		}
+		if segment.index >= 0 {
+			switch field.Kind() {
+			case reflect.Slice:
+				if field.Len() <= segment.index {
+					field.Set(reflect.AppendSlice(field, reflect.MakeSlice(field.Type(), segment.index+1-field.Len(), segment.index+1-field.Len())))
+				}
+			case reflect.Array:
+				if field.Len() <= segment.index {
+					return NewErrorf("path %s: index %d out of range of %s", path.path, segment.index, segment.name)
+				}
+			default:
+				return NewErrorf("path %s: %s is not a slice or array", path.path, segment.name)
+			}
+			field = field.Index(segment.index)
+		}
+		current = field
+	}
+	return assignStructField(current, path.path, value)
+}
+
+// getStructPath returns the field at the end of the path, false if a pointer on the way is nil or an index is out of range
+func getStructPath(root reflect.Value, path structPath) (reflect.Value, bool) {
+	current := root
+	for _, segment := range path.segments {
+		for current.Kind() == reflect.Ptr {
+			if current.IsNil() {
+				return reflect.Value{}, false
+			}
+			current = current.Elem()
+		}
+		if current.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		current = current.FieldByName(segment.name)
+		if !current.IsValid() {
+			return reflect.Value{}, false
+		}
+		if segment.index >= 0 {
+			if (current.Kind() != reflect.Slice && current.Kind() != reflect.Array) || current.Len() <= segment.index {
+				return reflect.Value{}, false
+			}
+			current = current.Index(segment.index)
+		}
+	}
+	return current, true
+}
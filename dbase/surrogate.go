@@ -0,0 +1,153 @@
+package dbase
+
+import (
+	"math"
+	"sync"
+)
+
+// IDAllocator allocates unused values of an integer or numeric key column, e.g. to add keys to a legacy table.
+// The highest value of the column is read once, afterwards the values are counted up in memory.
+// It is safe for concurrent use, but does not see values written by other processes or without the allocator.
+type IDAllocator struct {
+	file   *File
+	column *Column
+	next   int64
+	max    int64
+	mutex  sync.Mutex
+}
+
+// NewIDAllocator scans the column (name or external key) of all rows, including deleted rows, for the highest value
+// and returns an allocator continuing after it. The column must be an integer column or a numeric column without decimals.
+func (file *File) NewIDAllocator(column string) (*IDAllocator, error) {
+	pos := file.columnPosByKey(column)
+	if pos < 0 {
+		return nil, NewErrorf("column '%s' not found", column)
+	}
+	c := file.table.columns[pos]
+	if c.Flag == byte(AutoincrementFlag) {
+		return nil, NewErrorf("column %s is an autoincrement column", c.Name())
+	}
+	allocator := &IDAllocator{file: file, column: c, next: 1}
+	switch {
+	case DataType(c.DataType) == Integer:
+		allocator.max = MaxIntegerValue
+	case DataType(c.DataType) == Numeric && c.Decimals == 0:
+		allocator.max = math.MaxInt64
+		if c.Length < 19 {
+			allocator.max = int64(math.Pow10(int(c.Length))) - 1
+		}
+	default:
+		return nil, NewErrorf("column %s is not an integer or numeric column without decimals", c.Name())
+	}
+	for position := uint32(0); position < file.header.RowsCount; position++ {
+		data, err := file.ReadRow(position)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		id, err := file.idValue(data, pos)
+		if err != nil {
+			return nil, NewErrorf("failed to read column %s of row %d", c.Name(), position).Details(err)
+		}
+		if id >= allocator.next {
+			allocator.next = id + 1
+		}
+	}
+	debugf("Allocating values of column %s starting at %d", c.Name(), allocator.next)
+	return allocator, nil
+}
+
+// Next returns the next unused value. Values are not handed out twice, even if they are never written.
+func (a *IDAllocator) Next() (int64, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.next > a.max {
+		return 0, NewErrorf("no values left in column %s, the maximum is %d", a.column.Name(), a.max)
+	}
+	id := a.next
+	a.next++
+	return id, nil
+}
+
+// EnsureColumnUnique assigns new values to active rows without a value, or zero, in the column (name or external key)
+// and to rows repeating the value of a previous row, so the column can be used as unique key afterwards,
+// e.g. before migrating a legacy table. The values are allocated like NewIDAllocator does and only the column is written,
+// other values and memos are left as they are. The file mutex and, if write locking is enabled, a table lock are held meanwhile.
+// Returns the number of changed rows.
+func (file *File) EnsureColumnUnique(column string) (changed int, err error) {
+	allocator, err := file.NewIDAllocator(column)
+	if err != nil {
+		return 0, WrapError(err)
+	}
+	pos := file.ColumnPos(allocator.column)
+	layout := file.table.layout.Columns[pos]
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	if file.config.WriteLock {
+		var unlock func() error
+		unlock, err = file.LockTable()
+		if err != nil {
+			return 0, WrapError(err)
+		}
+		defer release(unlock, &err)
+	}
+	seen := make(map[int64]bool)
+	for position := uint32(0); position < file.header.RowsCount; position++ {
+		data, err := file.ReadRow(position)
+		if err != nil {
+			return changed, WrapError(err)
+		}
+		if Marker(data[0]) == Deleted {
+			continue
+		}
+		id, err := file.idValue(data, pos)
+		if err != nil {
+			return changed, NewErrorf("failed to read column %s of row %d", allocator.column.Name(), position).Details(err)
+		}
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			continue
+		}
+		id, err = allocator.Next()
+		if err != nil {
+			return changed, WrapError(err)
+		}
+		field := &Field{column: allocator.column, value: id}
+		if DataType(allocator.column.DataType) == Integer {
+			field.value = int32(id)
+		}
+		raw, err := file.Represent(field, false)
+		if err != nil {
+			return changed, NewErrorf("failed to convert value %d of row %d", id, position).Details(err)
+		}
+		debugf("Assigning value %d to column %s of row %d", id, allocator.column.Name(), position)
+		copy(data[layout.Offset:layout.Offset+layout.Length], raw)
+		err = file.writeRow(&Row{handle: file, Position: position, raw: data})
+		if err != nil {
+			return changed, NewErrorf("failed to write row %d", position).Details(err)
+		}
+		seen[id] = true
+		changed++
+	}
+	return changed, file.commit(nil)
+}
+
+// idValue returns the value of the integer or numeric column at the position in the row data, zero if empty
+func (file *File) idValue(data []byte, pos int) (int64, error) {
+	column := file.table.columns[pos]
+	layout := file.table.layout.Columns[pos]
+	val, err := file.interpret(data[layout.Offset:layout.Offset+layout.Length], column, file.table.layout.rowNullFlags(data))
+	if err != nil {
+		return 0, WrapError(err)
+	}
+	switch v := val.(type) {
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	case nil:
+		return 0, nil
+	}
+	return 0, NewErrorf("unexpected value of type %T", val)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -12,19 +13,25 @@ import (
 
 // Table is a struct containing the table columns, modifications and the row pointer
 type Table struct {
-	name       string          // Name of the table
-	columns    []*Column       // Columns defined in this table
-	mods       []*Modification // Modification to change values or name of fields
-	rowPointer uint32          // Internal row pointer, can be moved
+	name           string                        // Name of the table
+	columns        []*Column                     // Columns defined in this table
+	mods           []*Modification               // Modification to change values or name of fields
+	rowPointer     uint32                        // Internal row pointer, can be moved
+	layout         *RowLayout                    // Precomputed position of the columns in a row
+	converters     map[*Column]EncodingConverter // Converters overriding the table converter for single columns
+	numericStrings map[*Column]bool              // Numeric columns without decimals read as strings, see SetNumericAsString
+	compressed     map[*Column]bool              // Memo columns whose memos are written compressed, see SetColumnCompression
+	comments       map[*Column]string            // Human readable descriptions of the columns, see SetColumnComment
 }
 
 // Row is a struct containing the row Position, deleted flag and data fields
 type Row struct {
 	handle     *File    // Pointer to the DBF object this row belongs to
-	Position   uint32   // Position of the row in the file
+	Position   uint32   // Position of the row in the file, starting at 0. Rows written at or behind the row count are appended
 	ByteOffset int64    // Byte offset of the row in the file
 	Deleted    bool     // Deleted flag
 	fields     []*Field // Fields in this row
+	raw        []byte   // Row data written as is instead of the fields, used by CopyRaw
 }
 
 // Column is a struct containing the column information
@@ -42,24 +49,9 @@ type Column struct {
 
 // Field is a row data field
 type Field struct {
-	column *Column     // Pointer to the column this field belongs to
-	value  interface{} // Value of the field
-}
-
-// nullFlagPosition calculates position of this column in the null flag
-func (table *Table) nullFlagPosition(column *Column) int {
-	bitCount := 0
-	for _, c := range table.columns {
-		if c.DataType != byte(Varchar) && c.DataType != byte(Varbinary) || c == column {
-			break
-		}
-		bitCount++
-		if c.Flag == byte(NullableFlag) || c.Flag == byte(NullableFlag|BinaryFlag) {
-			bitCount++
-		}
-	}
-
-	return bitCount
+	column  *Column     // Pointer to the column this field belongs to
+	value   interface{} // Value of the field
+	memoPos []byte      // Address of the memo the value was read from or last written to, overwritten when the field is written again
 }
 
 // Returns all values of a row as a slice of interface{}
@@ -78,9 +70,9 @@ func (row *Row) Value(pos int) interface{} {
 	return row.fields[pos].value
 }
 
-// Returns the value of a row at the given column name
+// Returns the value of a row at the given column name, external key or alias of a modification
 func (row *Row) ValueByName(name string) (interface{}, error) {
-	pos := row.handle.ColumnPosByName(name)
+	pos := row.handle.columnPosByKey(name)
 	if pos < 0 {
 		return nil, NewErrorf("column %v not found", name)
 	}
@@ -289,13 +281,119 @@ func (row *Row) Field(pos int) *Field {
 	return row.fields[pos]
 }
 
-// Returns the field of a row by name or nil if not found
+// Returns the field of a row by name, external key or alias of a modification or nil if not found
 func (row *Row) FieldByName(name string) *Field {
-	return row.Field(row.handle.ColumnPosByName(name))
+	return row.Field(row.handle.columnPosByKey(name))
+}
+
+// SetValues changes the fields of the map keys (name, external key or alias of a modification) to the values.
+// Every value is validated like it is written, the changes are only applied if all values are valid.
+// Otherwise an error containing the errors of all invalid fields is returned and the row is left unchanged.
+func (row *Row) SetValues(values map[string]interface{}) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fields := make([]*Field, len(keys))
+	errs := make([]error, 0)
+	for i, key := range keys {
+		field := row.FieldByName(key)
+		if field == nil {
+			errs = append(errs, NewErrorf("column '%s' not found", key))
+			continue
+		}
+		fields[i] = field
+		err := row.handle.validateValue(field.column, values[key])
+		if err != nil {
+			errs = append(errs, NewErrorf("invalid value for column %s", key).Details(err))
+		}
+	}
+	if len(errs) > 0 {
+		err := NewErrorf("%d of %d values are invalid", len(errs), len(keys))
+		for _, e := range errs {
+			err = err.Details(e)
+		}
+		return err
+	}
+	for i, key := range keys {
+		fields[i].value = values[key]
+	}
+	return nil
+}
+
+// RowState is the state of a row captured by Row.Snapshot
+type RowState struct {
+	handle  *File
+	deleted bool
+	values  []interface{}
+}
+
+// Snapshot captures the values and the deleted flag of the row, so changes can be undone with Restore.
+// Binary values are copied, so changing them in place does not change the snapshot.
+func (row *Row) Snapshot() RowState {
+	state := RowState{handle: row.handle, deleted: row.Deleted, values: make([]interface{}, len(row.fields))}
+	for i, field := range row.fields {
+		state.values[i] = snapshotValue(field.value)
+	}
+	return state
+}
+
+// Restore sets the values and the deleted flag of the row to the snapshot, the row is not written.
+// The memo addresses of the fields are kept, so the memos are written where the row currently points.
+// Returns an error if the snapshot was taken of a row of another table.
+func (row *Row) Restore(state RowState) error {
+	if state.handle != row.handle || len(state.values) != len(row.fields) {
+		return NewError("snapshot does not belong to the table of the row")
+	}
+	row.Deleted = state.deleted
+	for i, field := range row.fields {
+		field.value = snapshotValue(state.values[i])
+	}
+	return nil
+}
+
+// snapshotValue returns a copy of binary values, other values are immutable
+func snapshotValue(value interface{}) interface{} {
+	if b, ok := value.([]byte); ok && b != nil {
+		return append([]byte{}, b...)
+	}
+	return value
+}
+
+// validateValue returns an error if the value can not be written to the column.
+// Character values longer than the column are invalid as well, as writing them cuts them off.
+// Memo values are only checked for their type, as representing them writes the memo.
+func (file *File) validateValue(column *Column, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	if DataType(column.DataType) == Memo {
+		switch value.(type) {
+		case string, []byte:
+			return nil
+		}
+		return NewErrorf("invalid type for memo field: %T", value)
+	}
+	if DataType(column.DataType) == Character {
+		raw, err := file.Represent(&Field{column: column, value: value}, true)
+		if err != nil {
+			return err
+		}
+		if len(raw) > column.Size() {
+			return NewErrorf("value of %d bytes exceeds the length %d of column %s", len(raw), column.Size(), column.Name())
+		}
+		return nil
+	}
+	_, err := file.Represent(&Field{column: column, value: value}, false)
+	return err
 }
 
 // Converts the row back to raw dbase data
 func (row *Row) ToBytes() ([]byte, error) {
+	if row.raw != nil {
+		return row.raw, nil
+	}
 	debugf("Converting row %v to row data (%d bytes)...", row.Position, row.handle.header.RowLength)
 	data := make([]byte, row.handle.header.RowLength)
 	// a row should start with te delete flag, a space ACTIVE(0x20) or DELETED(0x2A)
@@ -304,79 +402,62 @@ func (row *Row) ToBytes() ([]byte, error) {
 	} else {
 		data[0] = byte(Active)
 	}
-	// deleted flag already read
-	offset := uint16(1)
-	varPos := 0
+	layout := row.handle.table.layout
 	nullFlag := make([]byte, 1)
-	for _, field := range row.fields {
+	if layout.NullFlagLength > 0 {
+		nullFlag = make([]byte, layout.NullFlagLength)
+	}
+	for i, field := range row.fields {
 		val, err := row.handle.Represent(field, false)
 		if err != nil {
 			return nil, WrapError(err)
 		}
+		c := layout.Columns[i]
 		// Get null and length if variable length field
-		if field.column.DataType == byte(Varbinary) || field.column.DataType == byte(Varchar) {
+		// Variable length values are padded with spaces like Visual FoxPro does, nil values are null or empty if the column is not nullable
+		if c.VarLengthBit >= 0 {
+			if field.value == nil {
+				val = nil
+			}
 			length := len(val)
-			// Not null and not full size
-			if length < int(field.column.Length) && length > 0 {
-				debugf("Variable length field %v is not null and not full size (%v < %v)", field.column.Name(), length, field.column.Length)
+			if field.value == nil && c.NullBit >= 0 {
+				debugf("Variable length field %v is null", field.column.Name())
+				val = bytes.Repeat([]byte{byte(Blank)}, c.Length)
+				// Set null flag
+				nullFlag[c.NullBit/8] = setNthBit(nullFlag[c.NullBit/8], c.NullBit%8)
+			} else if length < c.Length {
+				debugf("Variable length field %v is not full size (%v < %v)", field.column.Name(), length, c.Length)
 				// Set last byte as length
-				buf := make([]byte, field.column.Length)
+				buf := bytes.Repeat([]byte{byte(Blank)}, c.Length)
 				copy(buf, val)
-				buf[field.column.Length-1] = byte(length)
+				buf[c.Length-1] = byte(length)
 				val = buf
-				// Set full size flag
-				byteIndex := varPos / 8
-				bitIndex := varPos % 8
-				nullFlag[byteIndex] = setNthBit(nullFlag[byteIndex], bitIndex)
-			} else if length == 0 { // Null
-				debugf("Variable length field %v is null", field.column.Name())
-				// Set null flag
-				byteIndex := varPos / 8
-				bitIndex := varPos % 8
-				nullFlag[byteIndex] = setNthBit(nullFlag[byteIndex], bitIndex+1)
-			}
-			// Increase variable field in nullFlag position, increase by one for length and another one for null flag
-			varPos++
-			if field.column.Flag == byte(NullableFlag) || field.column.Flag == byte(NullableFlag|BinaryFlag) {
-				varPos++
+				// Set variable length flag
+				nullFlag[c.VarLengthBit/8] = setNthBit(nullFlag[c.VarLengthBit/8], c.VarLengthBit%8)
 			}
 		}
-		copy(data[offset:offset+uint16(field.column.Length)], val)
-		offset += uint16(field.column.Length)
+		copy(data[c.Offset:c.Offset+c.Length], val)
 	}
 	// Append null flag column at the end of the row
-	if row.handle.nullFlagColumn != nil {
+	if layout.NullFlagOffset >= 0 {
 		debugf("Appending null flag column at the end of the row => %b", nullFlag)
-		copy(data[offset:offset+uint16(row.handle.nullFlagColumn.Length)], nullFlag)
+		copy(data[layout.NullFlagOffset:layout.NullFlagOffset+layout.NullFlagLength], nullFlag)
 	}
 	return data, nil
 }
 
 // Returns a complete row as a map.
+// If VirtualColumns is set in the config, the deleted state, position and file offset of the row are included.
 func (row *Row) ToMap() (map[string]interface{}, error) {
 	debugf("Converting row %v to map...", row.Position)
 	out := make(map[string]interface{})
-	var err error
 	for i, field := range row.fields {
-		val := field.GetValue()
+		val, err := row.handle.modify(i, field.GetValue())
+		if err != nil {
+			return nil, WrapError(err)
+		}
 		if i >= 0 && i < len(row.handle.table.mods) && row.handle.table.mods[i] != nil {
 			mod := row.handle.table.mods[i]
-			if mod.TrimSpaces {
-				if str, ok := val.(string); ok {
-					val = strings.TrimSpace(str)
-				}
-
-				if bslice, ok := val.([]byte); ok {
-					val = sanitizeEmptyBytes(bslice)
-				}
-			}
-			if mod.Convert != nil {
-				debugf("Converting field %v due to modification", field.Name())
-				val, err = mod.Convert(val)
-				if err != nil {
-					return nil, WrapError(err)
-				}
-			}
 			if len(mod.ExternalKey) != 0 {
 				debugf("Resolving external key %v for field %v due to modification", mod.ExternalKey, field.Name())
 				out[mod.ExternalKey] = val
@@ -385,16 +466,49 @@ func (row *Row) ToMap() (map[string]interface{}, error) {
 		}
 		out[field.Name()] = val
 	}
+	if row.handle.config.VirtualColumns {
+		out[VirtualDeleted] = row.Deleted
+		out[VirtualPosition] = row.Position
+		out[VirtualOffset] = int64(row.handle.header.FirstRow) + int64(row.Position)*int64(row.handle.header.RowLength)
+	}
 	return out, nil
 }
 
+// modify applies the trimming and conversion of the modification of the column at position to the value
+func (file *File) modify(pos int, val interface{}) (interface{}, error) {
+	if pos < 0 || pos >= len(file.table.mods) || file.table.mods[pos] == nil {
+		return val, nil
+	}
+	mod := file.table.mods[pos]
+	if mod.TrimSpaces {
+		if str, ok := val.(string); ok {
+			val = strings.TrimSpace(str)
+		}
+
+		if bslice, ok := val.([]byte); ok {
+			val = sanitizeEmptyBytes(bslice)
+		}
+	}
+	if mod.Convert != nil {
+		debugf("Converting field %v due to modification", file.table.columns[pos].Name())
+		converted, err := mod.Convert(val)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		val = converted
+	}
+	return val, nil
+}
+
 // Returns a complete row as a JSON object.
+// Dates, floating point numbers and booleans are rendered using the format of the config.
 func (row *Row) ToJSON() ([]byte, error) {
 	debugf("Converting row %v to JSON...", row.Position)
 	m, err := row.ToMap()
 	if err != nil {
 		return nil, WrapError(err)
 	}
+	row.format(m)
 	j, err := json.Marshal(m)
 	if err != nil {
 		return nil, NewError("unable to marshal row to JSON").Details(err)
@@ -405,7 +519,15 @@ func (row *Row) ToJSON() ([]byte, error) {
 // Converts a row to a struct.
 // The struct must have the same field names as the columns in the table or the dbase tag must be set.
 // dbase tags can be used to name the field. For example: `dbase:"<table_name>.<field_name>"` or `dbase:"<field_name>"`
+// A path tag sets the column of the dbase tag in a nested struct or slice element instead,
+// e.g. `dbase:"ADDR_STREET" path:"Address.Street"` or `dbase:"PHONE2" path:"Phones[1]"`.
 func (row *Row) ToStruct(v interface{}) error {
+	return row.ToStructWithOptions(v, StructOptions{IgnoreMissingColumns: true})
+}
+
+// ToStructWithOptions converts a row to a struct using the given options.
+// With OmitEmpty, empty column values do not overwrite the struct fields.
+func (row *Row) ToStructWithOptions(v interface{}, options StructOptions) error {
 	rt := reflect.TypeOf(v)
 	if rt.Kind() != reflect.Ptr {
 		return NewErrorf("expected pointer, got %v", rt.Kind())
@@ -434,13 +556,104 @@ func (row *Row) ToStruct(v interface{}) error {
 			delete(tags, tag)
 		}
 	}
+	paths, err := getStructPaths(v)
+	if err != nil {
+		return WrapError(err)
+	}
+	if !options.IgnoreMissingColumns {
+		err := row.checkStructColumns(v, tags, paths, m)
+		if err != nil {
+			return WrapError(err)
+		}
+	}
 
 	for k, val := range m {
+		if options.OmitEmpty && (val == nil || reflect.ValueOf(val).IsZero()) {
+			continue
+		}
 		err := setStructField(tags, v, k, val)
 		if err != nil {
 			return WrapError(err)
 		}
 	}
+	if len(paths) == 0 {
+		return nil
+	}
+	keys := make(map[string]string, len(m))
+	for k := range m {
+		keys[strings.ToUpper(k)] = k
+	}
+	root := reflect.ValueOf(v).Elem()
+	for _, path := range paths {
+		k, ok := keys[strings.ToUpper(path.column)]
+		if !ok {
+			continue
+		}
+		val := m[k]
+		if val == nil || (options.OmitEmpty && reflect.ValueOf(val).IsZero()) {
+			continue
+		}
+		err := setStructPath(root, path, val)
+		if err != nil {
+			return NewErrorf("failed to set column %s", path.column).Details(err)
+		}
+	}
+	return nil
+}
+
+// checkStructColumns returns an error if an exported struct field is not mapped to any value of the row.
+// Fields at the start of a path and the columns of path tags are checked as well.
+func (row *Row) checkStructColumns(v interface{}, tags map[string]string, paths []structPath, m map[string]interface{}) error {
+	mapped := make(map[string]bool, len(m))
+	columns := make(map[string]bool, len(m))
+	for k := range m {
+		columns[strings.ToUpper(k)] = true
+		if fieldName, ok := tags[strings.ToUpper(k)]; ok {
+			k = fieldName
+		}
+		mapped[k] = true
+	}
+	for _, path := range paths {
+		if !columns[strings.ToUpper(path.column)] {
+			return NewErrorf("no column %s found for path %s", path.column, path.path)
+		}
+		mapped[path.segments[0].name] = true
+	}
+	rt := reflect.TypeOf(v).Elem()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if len(field.PkgPath) != 0 || field.Anonymous {
+			continue
+		}
+		if _, ok := field.Tag.Lookup("path"); ok {
+			continue
+		}
+		if !mapped[field.Name] {
+			return NewErrorf("no column found for struct field %v", field.Name)
+		}
+	}
+	return nil
+}
+
+// ApplyStruct sets the fields of the row to the values of the struct.
+// Columns without a struct field keep their value, with OmitEmpty zero struct fields are skipped as well,
+// so a row can be partially updated from a struct before it is written.
+func (row *Row) ApplyStruct(v interface{}, options StructOptions) error {
+	m, err := row.handle.structToMap(v, options)
+	if err != nil {
+		return WrapError(err)
+	}
+	for i, field := range row.fields {
+		key := field.Name()
+		if mod := row.handle.table.mods[i]; mod != nil && len(mod.ExternalKey) != 0 {
+			if _, ok := m[mod.ExternalKey]; ok {
+				key = mod.ExternalKey
+			}
+		}
+		if val, ok := m[key]; ok {
+			field.value = val
+		}
+	}
 	return nil
 }
 
@@ -454,10 +667,33 @@ func (c *Column) Type() string {
 	return string(c.DataType)
 }
 
+// Returns the length of the column in bytes.
+// Clipper and Harbour store the high byte of the length of character columns longer than 254 bytes in the decimals.
+func (c *Column) Size() int {
+	if c.extended() {
+		return int(c.Decimals)<<8 | int(c.Length)
+	}
+	return int(c.Length)
+}
+
+// extended returns if the column is a character column using the Clipper convention for lengths above 254 bytes
+func (c *Column) extended() bool {
+	return c.DataType == byte(Character) && c.Decimals > 0
+}
+
 func (c *Column) Reflect() (reflect.Type, error) {
+	if c.Binary() && DataType(c.DataType) == Character {
+		return reflect.TypeOf([]byte{}), nil
+	}
 	return DataType(c.DataType).Reflect()
 }
 
+// Binary returns if the column has the binary flag (NOCPTRANS).
+// Values of binary character and memo columns are read and written as []byte without encoding conversion.
+func (c *Column) Binary() bool {
+	return ColumnFlag(c.Flag)&BinaryFlag != 0 && ColumnFlag(c.Flag)&AutoincrementFlag != AutoincrementFlag
+}
+
 // SetValue allows to change the field value
 func (field *Field) SetValue(value interface{}) error {
 	if field == nil {
@@ -489,6 +725,8 @@ func (field Field) Column() *Column {
 
 // Create a new DBF file with the specified version, configuration and columns
 // Please only use this for development and testing purposes and dont build new applications with it
+// Returns an error if a column has a data type or requires a feature the file version does not support,
+// see FileVersion.SupportsType and FileVersion.Supports.
 func NewTable(version FileVersion, config *Config, columns []*Column, memoBlockSize uint16, io IO) (*File, error) {
 	if len(columns) == 0 {
 		return nil, errors.New("no columns specified")
@@ -496,6 +734,10 @@ func NewTable(version FileVersion, config *Config, columns []*Column, memoBlockS
 	if config.Converter == nil {
 		return nil, errors.New("no converter specified")
 	}
+	err := checkFeatures(version, columns)
+	if err != nil {
+		return nil, WrapError(err)
+	}
 	file := &File{
 		config: config,
 		io:     io,
@@ -513,6 +755,7 @@ func NewTable(version FileVersion, config *Config, columns []*Column, memoBlockS
 		},
 		dbaseMutex: &sync.Mutex{},
 		memoMutex:  &sync.Mutex{},
+		locks:      &fileLocks{},
 	}
 	debugf("Creating new DBF file: %v - type: %v - year: %v - month: %v - day: %v - first row: %v - row length: %v - code page: %v - columns: %v", config.Filename, file.header.FileType, file.header.Year, file.header.Month, file.header.Day, file.header.FirstRow, file.header.RowLength, file.header.CodePage, len(columns))
 	// Determines how many bytes are needed for the _NullFlag field if needed
@@ -530,10 +773,16 @@ func NewTable(version FileVersion, config *Config, columns []*Column, memoBlockS
 				nullFlagLength++
 			}
 		}
+		if column.extended() && !config.ExtendedCharacterColumns {
+			return nil, NewErrorf("column %s is longer than %d bytes, set ExtendedCharacterColumns to create it", column.Name(), MaxCharacterLength)
+		}
 		// Set the column position in the row
 		column.Position = uint32(file.header.RowLength)
 		// Add the column length to the row length
-		file.header.RowLength += uint16(column.Length)
+		if int(file.header.RowLength)+column.Size() > MaxRowLength {
+			return nil, NewErrorf("row length exceeds %d bytes at column %s", MaxRowLength, column.Name())
+		}
+		file.header.RowLength += uint16(column.Size())
 		// Add columns to the table
 		file.table.columns = append(file.table.columns, column)
 	}
@@ -544,6 +793,7 @@ func NewTable(version FileVersion, config *Config, columns []*Column, memoBlockS
 			Unused:    [2]byte{0x00, 0x00},
 			BlockSize: memoBlockSize,
 		}
+		file.memoHeader.NextFree = nextMemoBlock(file.memoHeader)
 		debugf("Initializing related memo file header - block size: %v", file.memoHeader.BlockSize)
 	}
 	// If there are nullable or variable length fields, add the null flag column
@@ -567,8 +817,10 @@ func NewTable(version FileVersion, config *Config, columns []*Column, memoBlockS
 		file.header.RowLength += uint16(length)
 		debugf("Initializing null flag column - length: %v", length)
 	}
+	file.table.mods = make([]*Modification, len(file.table.columns))
+	file.table.layout = newRowLayout(file.table.columns, file.nullFlagColumn, file.header.RowLength)
 
-	err := file.Init()
+	err = file.Init()
 	if err != nil {
 		return nil, WrapError(err)
 	}
@@ -626,31 +878,128 @@ func NewColumn(name string, dataType DataType, length uint8, decimals uint8, nul
 	return column, nil
 }
 
-// Writes the row to the file at the row pointer position
+// NewExtendedColumn creates a character column of up to MaxExtendedCharacterLength bytes.
+// Columns longer than 254 bytes are stored using the Clipper and Harbour convention, which uses the decimals
+// as high byte of the length. Only Clipper, Harbour and compatible software read such columns correctly,
+// so they are only created and written if ExtendedCharacterColumns is set in the config.
+func NewExtendedColumn(name string, length uint16) (*Column, error) {
+	if length <= MaxCharacterLength {
+		return NewColumn(name, Character, uint8(length), 0, false)
+	}
+	if length > MaxExtendedCharacterLength {
+		return nil, NewErrorf("extended character values can only be between 1 to %d characters long", MaxExtendedCharacterLength)
+	}
+	column, err := NewColumn(name, Character, 1, 0, false)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	column.Length = uint8(length & 0xFF)
+	column.Decimals = uint8(length >> 8)
+	return column, nil
+}
+
+// Writes the row to the file at the row position.
+// If the row is appended, the autoincrement columns are set to their next value
+// and the column header is rewritten once the row is written. The header of the table is locked meanwhile,
+// so processes respecting the FoxPro locking scheme do not assign the same values.
+// With ReuseDeleted an appended row overwrites the first row marked as deleted.
 func (row *Row) Write() error {
-	return row.handle.WriteRow(row)
+	row.handle.dbaseMutex.Lock()
+	defer row.handle.dbaseMutex.Unlock()
+	return row.handle.commit(row.write())
+}
+
+// write writes the row, the caller must hold the file mutex
+func (row *Row) write() (err error) {
+	if row.Position < row.handle.header.RowsCount {
+		return row.handle.writeRow(row)
+	}
+	if row.handle.config.ReuseDeleted {
+		position, ok, err := row.handle.deletedRow()
+		if err != nil {
+			return WrapError(err)
+		}
+		if ok {
+			debugf("Reusing deleted row %d", position)
+			row.Position = position
+			row.Deleted = false
+		}
+	}
+	unlock, err := row.handle.lockAutoincrement()
+	if err != nil {
+		return WrapError(err)
+	}
+	defer release(unlock, &err)
+	next := row.increment()
+	err = row.handle.writeRow(row)
+	if err != nil {
+		// Reset the next values, the row was not added
+		for column, value := range next {
+			column.Next = value
+		}
+		return WrapError(err)
+	}
+	if len(next) == 0 {
+		return nil
+	}
+	return row.handle.writeAutoincrement()
 }
 
 // Increment increases set the value of the auto increment Column to the Next value
 // Also increases the Next value by the amount of Step
-// Rewrites the columns header
-func (row *Row) Increment() error {
+// Rewrites the columns header while holding the header lock of the table
+func (row *Row) Increment() (err error) {
+	unlock, err := row.handle.lockAutoincrement()
+	if err != nil {
+		return WrapError(err)
+	}
+	defer release(unlock, &err)
+	row.increment()
+	return row.handle.writeAutoincrement()
+}
+
+// increment sets the autoincrement fields to the next value of their column and advances the next value by the step.
+// Only the row and columns in memory are changed, the previous next values are returned by column.
+func (row *Row) increment() map[*Column]uint32 {
+	next := make(map[*Column]uint32)
 	for _, field := range row.fields {
 		if field.column.Flag == byte(AutoincrementFlag) {
+			next[field.column] = field.column.Next
 			field.value = int32(field.column.Next)
 			field.column.Next += uint32(field.column.Step)
 			debugf("Incrementing autoincrement field %s to %v (Step: %v)", field.column.Name(), field.value, field.column.Step)
 		}
 	}
-	err := row.handle.WriteColumns()
-	if err != nil {
-		return WrapError(err)
-	}
-	return nil
+	return next
 }
 
 // Appends the row as a new entry to the file
 func (row *Row) Add() error {
-	row.Position = row.handle.header.RowsCount + 1
-	return row.Write()
+	row.handle.dbaseMutex.Lock()
+	defer row.handle.dbaseMutex.Unlock()
+	row.Position = row.handle.header.RowsCount
+	return row.handle.commit(row.write())
+}
+
+// deletedRowBatch is the number of rows read at once when searching a row marked as deleted
+const deletedRowBatch = 512
+
+// deletedRow returns the position of the first row marked as deleted.
+// The search continues behind the rows found not to be deleted by the previous search and
+// writeRow moves the position back when it marks a row before it as deleted,
+// so appending rows one by one does not read the whole table for every row.
+func (file *File) deletedRow() (uint32, bool, error) {
+	for file.reusable < file.header.RowsCount {
+		rows, err := file.ReadRows(file.reusable, deletedRowBatch)
+		if err != nil {
+			return 0, false, WrapError(err)
+		}
+		for _, data := range rows {
+			if Marker(data[0]) == Deleted {
+				return file.reusable, true, nil
+			}
+			file.reusable++
+		}
+	}
+	return 0, false, nil
 }
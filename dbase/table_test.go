@@ -0,0 +1,131 @@
+package dbase
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestReuseDeletedRows(t *testing.T) {
+	column, err := NewColumn("NAME", Character, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanned := 0
+	config := &Config{
+		Filename:     filepath.Join(t.TempDir(), "REUSE.DBF"),
+		Converter:    NewDefaultConverter(charmap.Windows1252),
+		ReuseDeleted: true,
+		Metrics: MetricsFunc(func(op Operation, bytes int, _ time.Duration, _ error) {
+			switch op {
+			case ReadRowsOperation:
+				scanned += bytes / 11
+			case DeletedOperation:
+				scanned++
+			}
+		}),
+	}
+	file, err := NewTable(FoxBasePlus, config, []*Column{column}, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	add := func() uint32 {
+		t.Helper()
+		row := file.NewRow()
+		err := row.FieldByName("NAME").SetValue("ROW")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = row.Add()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return row.Position
+	}
+	for i := 0; i < 1000; i++ {
+		add()
+	}
+	// Appending to a table without deleted rows must not read the whole table for every row
+	if scanned > 2000 {
+		t.Errorf("%d rows read to append 1000 rows", scanned)
+	}
+	for _, position := range []uint32{500, 10} {
+		err = file.GoTo(position)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row, err := file.Row()
+		if err != nil {
+			t.Fatal(err)
+		}
+		row.Deleted = true
+		err = row.Write()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, expected := range []uint32{10, 500, 1000} {
+		if position := add(); position != expected {
+			t.Errorf("expected the row to be written at %d, got %d", expected, position)
+		}
+	}
+	if file.header.RowsCount != 1001 {
+		t.Errorf("expected 1001 rows, got %d", file.header.RowsCount)
+	}
+}
+
+func TestExtendedCharacterColumns(t *testing.T) {
+	column, err := NewExtendedColumn("LONG", 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Filename: filepath.Join(t.TempDir(), "LONG.DBF"), Converter: NewDefaultConverter(charmap.Windows1252), TrimSpaces: true}
+	_, err = NewTable(FoxPro, config, []*Column{column}, 0, nil)
+	if err == nil {
+		t.Fatal("expected an error creating an extended column without ExtendedCharacterColumns")
+	}
+	config.ExtendedCharacterColumns = true
+	file, err := NewTable(FoxPro, config, []*Column{column}, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := strings.Repeat("L", 300)
+	row := file.NewRow()
+	err = row.FieldByName("LONG").SetValue(value)
+	if err == nil {
+		err = row.Add()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Extended columns are always read, but only written with ExtendedCharacterColumns
+	file, err = OpenTable(&Config{Filename: config.Filename, TrimSpaces: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	row, err = file.Row()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read := row.FieldByName("LONG").GetValue(); read != value {
+		t.Errorf("expected %d characters, got %v", len(value), read)
+	}
+	err = row.Write()
+	if err == nil {
+		t.Error("expected an error writing an extended column without ExtendedCharacterColumns")
+	}
+	err = (&Config{Filename: config.Filename, ReadOnly: true, ExtendedCharacterColumns: true}).Validate()
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for ExtendedCharacterColumns on a read-only table, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package dbase
+
+import (
+	"io"
+	"sync"
+)
+
+// TempTableName is the file name of tables created by NewTempTable, only used in logs and errors
+const TempTableName = "CURSOR.DBF"
+
+// NewTempTable creates a Visual FoxPro table with the columns that is kept in memory, e.g. as stand-in for FoxPro cursors
+// when porting old business logic. It supports all operations of tables on disk, including memos, searching and exports.
+// The memory is released on Close, nothing is written to disk.
+func NewTempTable(columns []*Column, converter EncodingConverter) (*File, error) {
+	// Temporary tables are not read by other software, so extended character columns are always supported
+	config := &Config{
+		Filename:                 TempTableName,
+		Converter:                converter,
+		ExtendedCharacterColumns: true,
+	}
+	memoryIO := GenericIO{
+		Handle:        &memoryHandle{},
+		RelatedHandle: &memoryHandle{},
+	}
+	file, err := NewTable(FoxProVar, config, columns, DefaultMemoBlockSize, memoryIO)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return file, nil
+}
+
+// memoryHandle is an in-memory io.ReadWriteSeeker, including positioned access and truncating, used by temporary tables
+type memoryHandle struct {
+	data   []byte
+	offset int64
+	closed bool
+	mutex  sync.Mutex
+}
+
+func (m *memoryHandle) Read(p []byte) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	n, err := m.readAt(p, m.offset)
+	m.offset += int64(n)
+	return n, err
+}
+
+func (m *memoryHandle) ReadAt(p []byte, offset int64) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.readAt(p, offset)
+}
+
+func (m *memoryHandle) readAt(p []byte, offset int64) (int, error) {
+	if m.closed {
+		return 0, NewError("temporary table is closed")
+	}
+	if offset < 0 {
+		return 0, NewErrorf("negative offset %d", offset)
+	}
+	if offset >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memoryHandle) Write(p []byte) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	n, err := m.writeAt(p, m.offset)
+	m.offset += int64(n)
+	return n, err
+}
+
+func (m *memoryHandle) WriteAt(p []byte, offset int64) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.writeAt(p, offset)
+}
+
+func (m *memoryHandle) writeAt(p []byte, offset int64) (int, error) {
+	if m.closed {
+		return 0, NewError("temporary table is closed")
+	}
+	if offset < 0 {
+		return 0, NewErrorf("negative offset %d", offset)
+	}
+	end := offset + int64(len(p))
+	if end > int64(len(m.data)) {
+		if end > int64(cap(m.data)) {
+			// Grow by at least the doubled size, so appending rows does not copy the data every time
+			grown := make([]byte, end, end+int64(cap(m.data)))
+			copy(grown, m.data)
+			m.data = grown
+		} else {
+			m.data = m.data[:end]
+		}
+	}
+	return copy(m.data[offset:], p), nil
+}
+
+func (m *memoryHandle) Seek(offset int64, whence int) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += m.offset
+	case io.SeekEnd:
+		offset += int64(len(m.data))
+	default:
+		return 0, NewErrorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, NewErrorf("negative offset %d", offset)
+	}
+	m.offset = offset
+	return offset, nil
+}
+
+func (m *memoryHandle) Truncate(size int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if size < 0 {
+		return NewErrorf("negative size %d", size)
+	}
+	if size <= int64(len(m.data)) {
+		m.data = m.data[:size]
+		return nil
+	}
+	m.data = append(m.data, make([]byte, size-int64(len(m.data)))...)
+	return nil
+}
+
+func (m *memoryHandle) Size() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return int64(len(m.data))
+}
+
+// Close releases the memory, the handle can not be used afterwards
+func (m *memoryHandle) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data = nil
+	m.closed = true
+	return nil
+}
@@ -0,0 +1,131 @@
+package dbase
+
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+)
+
+// topValuesMinCapacity is the minimum number of distinct values counted by TopValues.
+// Up to this number of distinct values the counts are exact.
+const topValuesMinCapacity = 10000
+
+// ValueCount is a value of a column and the number of rows containing it
+type ValueCount struct {
+	Value interface{} // The value as returned by Row.ToMap
+	Count uint32      // Number of rows with the value, may be overestimated by up to Error
+	Error uint32      // Maximum overestimation of the count, zero if the count is exact
+}
+
+// TopValues returns the k most frequent values of the column, ordered by count.
+// The rows are streamed using the space saving algorithm, so memory is bounded by max(100*k, 10000) counters.
+// As long as the column has fewer distinct values the counts are exact, otherwise the most frequent
+// values are still found and the possible overestimation of each count is reported as Error.
+// Deleted rows are skipped and the internal row pointer is restored afterwards.
+func (file *File) TopValues(column string, k int) ([]*ValueCount, error) {
+	if k <= 0 {
+		return nil, NewErrorf("invalid number of values %d", k)
+	}
+	key, err := file.mapKey(column)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	capacity := 100 * k
+	if capacity < topValuesMinCapacity {
+		capacity = topValuesMinCapacity
+	}
+	debugf("Counting top %d values of column %s with %d counters", k, column, capacity)
+	counters := &valueCounters{index: make(map[string]*valueCounter)}
+	pointer := file.table.rowPointer
+	defer func() { file.table.rowPointer = pointer }()
+	file.table.rowPointer = 0
+	it := &rowIterator{file: file}
+	for !file.EOF() {
+		row, err := it.next()
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		if row.Deleted {
+			continue
+		}
+		values, err := row.ToMap()
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		counters.add(values[key], capacity)
+	}
+	sort.Slice(counters.heap, func(i, j int) bool {
+		a, b := counters.heap[i], counters.heap[j]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return bytes.Compare([]byte(a.key), []byte(b.key)) < 0
+	})
+	if len(counters.heap) > k {
+		counters.heap = counters.heap[:k]
+	}
+	result := make([]*ValueCount, len(counters.heap))
+	for i, c := range counters.heap {
+		result[i] = &ValueCount{Value: c.value, Count: c.count, Error: c.error}
+	}
+	return result, nil
+}
+
+type valueCounter struct {
+	key   string
+	value interface{}
+	count uint32
+	error uint32
+	index int
+}
+
+// valueCounters is a min heap of the counters by count with an index by value key
+type valueCounters struct {
+	heap  []*valueCounter
+	index map[string]*valueCounter
+}
+
+// add counts the value, if all counters are in use the counter with the lowest count is replaced
+func (v *valueCounters) add(value interface{}, capacity int) {
+	key := string(sortKey(value))
+	if c, ok := v.index[key]; ok {
+		c.count++
+		heap.Fix(v, c.index)
+		return
+	}
+	if len(v.heap) < capacity {
+		heap.Push(v, &valueCounter{key: key, value: value, count: 1})
+		return
+	}
+	min := v.heap[0]
+	delete(v.index, min.key)
+	min.key = key
+	min.value = value
+	min.error = min.count
+	min.count++
+	v.index[key] = min
+	heap.Fix(v, 0)
+}
+
+func (v *valueCounters) Len() int           { return len(v.heap) }
+func (v *valueCounters) Less(i, j int) bool { return v.heap[i].count < v.heap[j].count }
+
+func (v *valueCounters) Swap(i, j int) {
+	v.heap[i], v.heap[j] = v.heap[j], v.heap[i]
+	v.heap[i].index = i
+	v.heap[j].index = j
+}
+
+func (v *valueCounters) Push(x interface{}) {
+	c := x.(*valueCounter)
+	c.index = len(v.heap)
+	v.heap = append(v.heap, c)
+	v.index[c.key] = c
+}
+
+func (v *valueCounters) Pop() interface{} {
+	c := v.heap[len(v.heap)-1]
+	v.heap = v.heap[:len(v.heap)-1]
+	delete(v.index, c.key)
+	return c
+}
@@ -0,0 +1,81 @@
+package dbase
+
+// maxTrailingData is the number of bytes behind the rows that are kept to be preserved when rows are appended
+const maxTrailingData = 1 << 20
+
+// EOFMarker returns if the rows of the file are followed by the end of file marker 0x1A
+func (file *File) EOFMarker() bool {
+	return file.eofMarker
+}
+
+// TrailingData returns the bytes stored behind the rows and the end of file marker, some tools store metadata there.
+// The data is preserved when rows are appended.
+func (file *File) TrailingData() []byte {
+	return append([]byte(nil), file.trailing...)
+}
+
+// readTrailer reads the end of file marker and the trailing data behind the rows
+func (c ioCore) readTrailer(file *File) error {
+	handle, err := c.handle(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	size, err := handle.Size()
+	if err != nil {
+		return NewError("failed to get the file size").Details(err)
+	}
+	end := file.rowsEnd()
+	if size <= end {
+		return nil
+	}
+	length := size - end
+	preserve := length <= maxTrailingData+1
+	if !preserve {
+		file.warn("%d bytes behind the rows are not preserved when rows are appended", length)
+		length = 1
+	}
+	data := make([]byte, length)
+	err = readAt(handle, data, end)
+	if err != nil {
+		return NewErrorf("failed to read the %d bytes behind the rows", length).Details(err)
+	}
+	if Marker(data[0]) == EOFMarker {
+		file.eofMarker = true
+		data = data[1:]
+	}
+	if preserve && len(data) > 0 {
+		file.trailing = data
+	}
+	debugf("EOF marker: %v - trailing data: %d bytes", file.eofMarker, len(file.trailing))
+	return nil
+}
+
+// writeTrailer writes the end of file marker and the trailing data behind the rows, e.g. after rows were appended
+func (file *File) writeTrailer() error {
+	if !file.eofMarker && len(file.trailing) == 0 {
+		return nil
+	}
+	c, ok := file.defaults().io.(coreIO)
+	if !ok {
+		return nil
+	}
+	handle, err := c.core().handle(file)
+	if err != nil {
+		return WrapError(err)
+	}
+	data := make([]byte, 0, len(file.trailing)+1)
+	if file.eofMarker {
+		data = append(data, byte(EOFMarker))
+	}
+	data = append(data, file.trailing...)
+	err = writeAt(handle, data, file.rowsEnd())
+	if err != nil {
+		return NewError("failed to write the data behind the rows").Details(err)
+	}
+	return nil
+}
+
+// rowsEnd returns the offset behind the last row
+func (file *File) rowsEnd() int64 {
+	return int64(file.header.FirstRow) + int64(file.header.RowsCount)*int64(file.header.RowLength)
+}
@@ -0,0 +1,175 @@
+package dbase
+
+// Update sets the changes on all rows where the key column equals the key value and writes the rows back.
+// The changes are keyed by column name or by the external key of a column modification.
+// Deleted rows are not updated. Returns the number of updated rows.
+// The internal row pointer is restored afterwards.
+func (file *File) Update(keyColumn string, keyValue interface{}, changes map[string]interface{}) (int, error) {
+	pos := file.columnPosByKey(keyColumn)
+	if pos < 0 {
+		return 0, NewErrorf("key column '%s' not found", keyColumn)
+	}
+	values, err := file.changedFields(changes)
+	if err != nil {
+		return 0, WrapError(err)
+	}
+	debugf("Updating rows where %s = %v with %d changes", keyColumn, keyValue, len(values))
+	pointer := file.table.rowPointer
+	defer func() { file.table.rowPointer = pointer }()
+	rows, err := file.Search(&Field{column: file.table.columns[pos], value: keyValue}, true)
+	if err != nil {
+		return 0, WrapError(err)
+	}
+	updated := 0
+	for _, row := range rows {
+		if row.Deleted {
+			continue
+		}
+		for i, val := range values {
+			row.fields[i].value = val
+		}
+		err = row.Write()
+		if err != nil {
+			return updated, NewErrorf("failed to update row %d", row.Position).Details(err)
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// changedFields resolves the keys of the changes to the column positions
+func (file *File) changedFields(changes map[string]interface{}) (map[int]interface{}, error) {
+	values := make(map[int]interface{}, len(changes))
+	for name, val := range changes {
+		pos := file.columnPosByKey(name)
+		if pos < 0 {
+			return nil, NewErrorf("column '%s' not found", name)
+		}
+		values[pos] = val
+	}
+	return values, nil
+}
+
+// columnPosByKey returns the position of the column by name or by the external key or an alias of a modification or -1 if not found
+func (file *File) columnPosByKey(key string) int {
+	if pos := file.ColumnPosByName(key); pos >= 0 {
+		return pos
+	}
+	for i, mod := range file.table.mods {
+		if mod != nil && len(mod.ExternalKey) != 0 && mod.ExternalKey == key {
+			return i
+		}
+	}
+	for i, mod := range file.table.mods {
+		if mod == nil {
+			continue
+		}
+		for _, alias := range mod.Aliases {
+			if alias == key {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Upsert updates the first row matching the values of the key columns or appends a new row if there is none.
+// The key columns and the changed columns are taken from values, keyed by column name or external key.
+// The search and the write are done while holding the file mutex and, if write locking is enabled, a table lock,
+// so no other writer can add a row with the same key in between.
+// Returns the written row and true if the row was appended.
+func (file *File) Upsert(keyColumns []string, values map[string]interface{}) (row *Row, appended bool, err error) {
+	if len(keyColumns) == 0 {
+		return nil, false, NewError("missing key columns")
+	}
+	fields, err := file.changedFields(values)
+	if err != nil {
+		return nil, false, WrapError(err)
+	}
+	keys := make([]*Field, len(keyColumns))
+	for i, name := range keyColumns {
+		pos := file.columnPosByKey(name)
+		if pos < 0 {
+			return nil, false, NewErrorf("key column '%s' not found", name)
+		}
+		val, ok := fields[pos]
+		if !ok {
+			return nil, false, NewErrorf("missing value for key column '%s'", name)
+		}
+		keys[i] = &Field{column: file.table.columns[pos], value: val}
+	}
+	file.dbaseMutex.Lock()
+	defer file.dbaseMutex.Unlock()
+	if file.config.WriteLock {
+		var unlock func() error
+		unlock, err = file.LockTable()
+		if err != nil {
+			return nil, false, WrapError(err)
+		}
+		defer release(unlock, &err)
+	}
+	row, err = file.findByKeys(keys)
+	if err != nil {
+		return nil, false, WrapError(err)
+	}
+	if row != nil {
+		debugf("Upsert updates row %d", row.Position)
+		for i, val := range fields {
+			row.fields[i].value = val
+		}
+		err = file.commit(row.write())
+		if err != nil {
+			return nil, false, WrapError(err)
+		}
+		return row, false, nil
+	}
+	row, err = file.RowFromMap(values)
+	if err != nil {
+		return nil, false, WrapError(err)
+	}
+	row.Position = file.header.RowsCount
+	debugf("Upsert appends row %d", row.Position)
+	err = file.commit(row.write())
+	if err != nil {
+		return nil, false, WrapError(err)
+	}
+	return row, true, nil
+}
+
+// findByKeys returns the first active row where all key fields match or nil if there is none.
+// The internal row pointer is restored afterwards.
+func (file *File) findByKeys(keys []*Field) (*Row, error) {
+	pointer := file.table.rowPointer
+	defer func() { file.table.rowPointer = pointer }()
+	rows, err := file.Search(keys[0], true)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	for _, row := range rows {
+		if row.Deleted {
+			continue
+		}
+		match, err := row.matches(keys)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		if match {
+			return row, nil
+		}
+	}
+	return nil, nil
+}
+
+// matches returns if the fields of the row equal the key fields, see Field.Equal.
+// The search only finds rows containing the representation of the first key, so all keys are compared.
+func (row *Row) matches(keys []*Field) (bool, error) {
+	for _, key := range keys {
+		if key.column.DataType == byte(Memo) {
+			return false, NewErrorf("memo column %s can not be used as key", key.column.Name())
+		}
+		if !key.Equal(row.fields[row.handle.ColumnPos(key.column)]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
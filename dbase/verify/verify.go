@@ -0,0 +1,291 @@
+// The verify package checks that go-dbase reproduces a table file byte by byte.
+// A copy of the table is rewritten row by row without modifications and compared to the original,
+// so users can confirm the library does not change their specific files before writing to them.
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+)
+
+// modificationDate is the range of the header containing the last modification date, which is updated on every write
+var modificationDate = [2]int64{1, 4}
+
+// Result describes the outcome of a round trip verification
+type Result struct {
+	Rows     uint32 // Number of rows rewritten in the copy
+	Offset   int64  // Offset of the first divergent byte in the table file or -1 if the files match
+	Row      int64  // Position of the row containing the divergent byte or -1 if it is not part of a row
+	Column   string // Name of the column containing the divergent byte, empty if it is not part of a column
+	Expected []byte // Original column data, memo content or byte at the divergent offset
+	Actual   []byte // Rewritten column data, memo content or byte at the divergent offset
+}
+
+// OK returns true if no divergence was found
+func (r *Result) OK() bool {
+	return r.Offset < 0
+}
+
+func (r *Result) String() string {
+	if r.OK() {
+		return fmt.Sprintf("%d rows verified, no divergence found", r.Rows)
+	}
+	if r.Row < 0 {
+		return fmt.Sprintf("divergence at offset %d outside of rows: expected %q, got %q", r.Offset, r.Expected, r.Actual)
+	}
+	return fmt.Sprintf("divergence at offset %d in row %d column %s: expected %q, got %q", r.Offset, r.Row, r.Column, r.Expected, r.Actual)
+}
+
+// VerifyRoundTrip verifies the table file at path using the default configuration.
+func VerifyRoundTrip(path string) (*Result, error) {
+	return VerifyRoundTripWithConfig(&dbase.Config{Filename: path})
+}
+
+// VerifyRoundTripWithConfig opens the table of the config, rewrites every row unmodified into a copy
+// and compares the copy byte by byte with the original, reporting the first divergent offset.
+// The original files are only read. The table and its related files are copied to a temporary directory,
+// which is removed afterwards. The configured IO is ignored, the files are always accessed by their path.
+//
+// The last modification date in the header is ignored, as every write updates it.
+// A memo is overwritten in place if it still fits into its blocks and appended to the memo file otherwise,
+// so memo columns are compared by the content they reference instead of their block address.
+func VerifyRoundTripWithConfig(config *dbase.Config) (*Result, error) {
+	if config == nil || len(config.Filename) == 0 {
+		return nil, dbase.NewError("missing filename")
+	}
+	dir, err := os.MkdirTemp("", "dbase-verify-")
+	if err != nil {
+		return nil, dbase.NewError("failed to create temporary directory").Details(err)
+	}
+	defer os.RemoveAll(dir)
+	filename, err := copyTable(config.Filename, dir)
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	rows, err := rewrite(config, filename)
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	result, err := compare(config, filename)
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	result.Rows = rows
+	return result, nil
+}
+
+// copyTable copies the table file and all files with the same base name (memo and index files) into dir
+// and returns the path of the copied table file
+func copyTable(filename string, dir string) (string, error) {
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		return "", dbase.NewErrorf("failed to read directory of %s", filename).Details(err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.EqualFold(strings.TrimSuffix(name, filepath.Ext(name)), base) {
+			continue
+		}
+		err = copyFile(filepath.Join(filepath.Dir(filename), name), filepath.Join(dir, name))
+		if err != nil {
+			return "", dbase.WrapError(err)
+		}
+	}
+	return filepath.Join(dir, filepath.Base(filename)), nil
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return dbase.NewErrorf("failed to open %s", src).Details(err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return dbase.NewErrorf("failed to create %s", dst).Details(err)
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return dbase.NewErrorf("failed to copy %s", src).Details(err)
+	}
+	return nil
+}
+
+// rewrite writes every row of the table copy back to its position and returns the number of rows
+func rewrite(config *dbase.Config, filename string) (uint32, error) {
+	file, err := open(config, filename, false)
+	if err != nil {
+		return 0, dbase.WrapError(err)
+	}
+	defer file.Close()
+	rows := uint32(0)
+	for !file.EOF() {
+		row, err := file.Next()
+		if err != nil {
+			return rows, dbase.NewErrorf("failed to read row %d", file.Pointer()).Details(err)
+		}
+		err = row.Write()
+		if err != nil {
+			return rows, dbase.NewErrorf("failed to write row %d", row.Position).Details(err)
+		}
+		rows++
+	}
+	return rows, nil
+}
+
+// compare compares the original table file with the rewritten copy
+func compare(config *dbase.Config, filename string) (*Result, error) {
+	original, err := open(config, config.Filename, true)
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	defer original.Close()
+	copied, err := open(config, filename, true)
+	if err != nil {
+		return nil, dbase.WrapError(err)
+	}
+	defer copied.Close()
+	expected, err := os.ReadFile(config.Filename)
+	if err != nil {
+		return nil, dbase.NewErrorf("failed to read %s", config.Filename).Details(err)
+	}
+	actual, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, dbase.NewErrorf("failed to read %s", filename).Details(err)
+	}
+	header := original.Header()
+	layout := original.Layout()
+	columns := original.Columns()
+	firstRow := int64(header.FirstRow)
+	rowLength := int64(header.RowLength)
+	lastRow := firstRow + int64(header.RowsCount)*rowLength
+	// Mark the bytes of each row containing memo addresses
+	memo := make([]int, rowLength)
+	for i := range memo {
+		memo[i] = -1
+	}
+	for i, c := range layout.Columns {
+		if c.Type != dbase.Memo {
+			continue
+		}
+		for j := c.Offset; j < c.Offset+c.Length && j < len(memo); j++ {
+			memo[j] = i
+		}
+	}
+	result := &Result{Offset: -1, Row: -1}
+	length := int64(len(expected))
+	if int64(len(actual)) < length {
+		length = int64(len(actual))
+	}
+	for offset := int64(0); offset < length; offset++ {
+		if offset >= modificationDate[0] && offset < modificationDate[1] {
+			continue
+		}
+		if offset < firstRow || offset >= lastRow || rowLength == 0 {
+			if expected[offset] != actual[offset] {
+				return diverged(result, offset, expected[offset:offset+1], actual[offset:offset+1]), nil
+			}
+			continue
+		}
+		position := (offset - firstRow) / rowLength
+		start := firstRow + position*rowLength
+		column := memo[offset-start]
+		if column < 0 {
+			if expected[offset] != actual[offset] {
+				return describe(result, offset, position, int(offset-start), layout, columns, expected[start:start+rowLength], actual[start:start+rowLength]), nil
+			}
+			continue
+		}
+		// Compare the memo content once per column and skip the address bytes
+		c := layout.Columns[column]
+		address := start + int64(c.Offset)
+		same, expectedMemo, actualMemo, err := compareMemo(original, copied, expected[address:address+int64(c.Length)], actual[address:address+int64(c.Length)])
+		if err != nil {
+			return nil, dbase.NewErrorf("failed to compare memo of row %d column %s", position, columns[column].Name()).Details(err)
+		}
+		if !same {
+			result = diverged(result, address, expectedMemo, actualMemo)
+			result.Row = position
+			result.Column = columns[column].Name()
+			return result, nil
+		}
+		offset = address + int64(c.Length) - 1
+	}
+	if len(expected) != len(actual) {
+		return diverged(result, length, expected[length:], actual[length:]), nil
+	}
+	return result, nil
+}
+
+// compareMemo reads the memos referenced by both addresses and compares their content and type
+func compareMemo(original *dbase.File, copied *dbase.File, expectedAddress []byte, actualAddress []byte) (bool, []byte, []byte, error) {
+	expected, expectedText, err := original.ReadMemo(expectedAddress)
+	if err != nil {
+		return false, nil, nil, dbase.WrapError(err)
+	}
+	actual, actualText, err := copied.ReadMemo(actualAddress)
+	if err != nil {
+		return false, nil, nil, dbase.WrapError(err)
+	}
+	return expectedText == actualText && bytes.Equal(expected, actual), expected, actual, nil
+}
+
+func diverged(result *Result, offset int64, expected []byte, actual []byte) *Result {
+	result.Offset = offset
+	result.Expected = expected
+	result.Actual = actual
+	return result
+}
+
+// describe sets the row, column and the column data of a divergent byte inside a row
+func describe(result *Result, offset int64, position int64, inRow int, layout *dbase.RowLayout, columns []*dbase.Column, expected []byte, actual []byte) *Result {
+	result.Offset = offset
+	result.Row = position
+	from, to := inRow, inRow+1
+	switch {
+	case inRow == 0:
+		result.Column = "deleted flag"
+	case layout.NullFlagOffset >= 0 && inRow >= layout.NullFlagOffset:
+		result.Column = "_NullFlags"
+		from, to = layout.NullFlagOffset, layout.NullFlagOffset+layout.NullFlagLength
+	default:
+		for i, c := range layout.Columns {
+			if inRow >= c.Offset && inRow < c.Offset+c.Length {
+				result.Column = columns[i].Name()
+				from, to = c.Offset, c.Offset+c.Length
+				break
+			}
+		}
+	}
+	result.Expected = expected[from:to]
+	result.Actual = actual[from:to]
+	return result
+}
+
+// open opens the table file with the settings of the config
+func open(config *dbase.Config, filename string, readOnly bool) (*dbase.File, error) {
+	c := *config
+	c.Filename = filename
+	c.IO = nil
+	c.ReadOnly = readOnly
+	if readOnly {
+		// Write settings can not be combined with read-only files
+		c.Exclusive, c.WriteLock, c.ReuseDeleted = false, false, false
+	}
+	c.Metrics = nil
+	file, err := dbase.OpenTable(&c)
+	if err != nil {
+		return nil, dbase.NewErrorf("failed to open %s", filename).Details(err)
+	}
+	return file, nil
+}
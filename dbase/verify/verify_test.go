@@ -0,0 +1,112 @@
+package verify
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden file with the results of the test data")
+
+// golden contains the verification results and the compared files of the test data
+const golden = "testdata/roundtrip.golden"
+
+// testData are the tables of the examples, their files are the expected output of rewriting them
+var testData = []string{
+	"../../examples/test_data/table/TEST.DBF",
+	"../../examples/test_data/database/EXPENSES.DBC",
+	"../../examples/test_data/database/employees.dbf",
+	"../../examples/test_data/database/expense categories.dbf",
+	"../../examples/test_data/database/expense details.dbf",
+	"../../examples/test_data/database/expense reports.dbf",
+}
+
+// TestRoundTripTestData verifies the tables of the test data and compares the rewritten table, memo and index files
+// byte by byte with the originals. Known divergences are part of the golden file, e.g. values the writing tool formatted
+// differently than go-dbase does. Run the test with -update to accept changed results.
+func TestRoundTripTestData(t *testing.T) {
+	var out bytes.Buffer
+	for _, path := range testData {
+		result, err := VerifyRoundTrip(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		fmt.Fprintf(&out, "%s: %s\n", filepath.Base(path), result)
+		files, err := rewriteFiles(t, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		for _, line := range files {
+			fmt.Fprintf(&out, "\t%s\n", line)
+		}
+	}
+	if *update {
+		err := os.WriteFile(golden, out.Bytes(), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected, out.Bytes()) {
+		t.Errorf("results differ from %s, expected:\n%s\ngot:\n%s", golden, expected, out.Bytes())
+	}
+}
+
+// rewriteFiles rewrites a copy of the table and returns the comparison of each of its files with the original
+func rewriteFiles(t *testing.T, path string) ([]string, error) {
+	dir := t.TempDir()
+	filename, err := copyTable(path, dir)
+	if err != nil {
+		return nil, err
+	}
+	_, err = rewrite(&dbase.Config{Filename: path}, filename)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		expected, err := os.ReadFile(filepath.Join(filepath.Dir(path), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		actual, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(entry.Name(), filepath.Base(path)) && len(actual) >= int(modificationDate[1]) {
+			// The last modification date is updated by every write
+			copy(actual[modificationDate[0]:modificationDate[1]], expected[modificationDate[0]:modificationDate[1]])
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", entry.Name(), difference(expected, actual)))
+	}
+	sort.Strings(lines)
+	return lines, nil
+}
+
+// difference describes the first difference of the files
+func difference(expected, actual []byte) string {
+	for i := 0; i < len(expected) && i < len(actual); i++ {
+		if expected[i] != actual[i] {
+			return fmt.Sprintf("differs at offset %d", i)
+		}
+	}
+	if len(expected) != len(actual) {
+		return fmt.Sprintf("%d bytes instead of %d", len(actual), len(expected))
+	}
+	return "identical"
+}
@@ -0,0 +1,100 @@
+package dbase
+
+// Feature is a capability of the table format that not all file versions support
+type Feature string
+
+const (
+	FeatureMemo          Feature = "memo"          // Memo columns stored in a memo file
+	FeatureAutoincrement Feature = "autoincrement" // Autoincrement integer columns
+	FeatureVarchar       Feature = "varchar"       // Varchar and varbinary columns
+	FeatureNullFlags     Feature = "nullflags"     // Nullable columns, tracked in the _NullFlags column
+)
+
+// versionFeatures lists the features of the file versions, based on the Visual FoxPro table file structure
+// https://learn.microsoft.com/en-us/previous-versions/visualstudio/foxpro/st4a0s68(v=vs.71)
+var versionFeatures = map[FileVersion][]Feature{
+	FoxBase:             {},
+	FoxBase2:            {},
+	FoxBasePlus:         {},
+	DBaseSQLTable:       {},
+	FoxBasePlusMemo:     {FeatureMemo},
+	DBaseMemo:           {FeatureMemo},
+	DBaseSQLMemo:        {FeatureMemo},
+	FoxPro2Memo:         {FeatureMemo},
+	FoxPro:              {FeatureMemo, FeatureNullFlags},
+	FoxProAutoincrement: {FeatureMemo, FeatureNullFlags, FeatureAutoincrement},
+	FoxProVar:           {FeatureMemo, FeatureNullFlags, FeatureAutoincrement, FeatureVarchar},
+}
+
+// Supports returns if tables of the file version can contain the feature.
+// Unknown file versions support no features.
+func (v FileVersion) Supports(feature Feature) bool {
+	for _, f := range versionFeatures[v] {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsType returns if tables of the file version can contain columns of the data type.
+// Character, numeric, date and logical columns are supported by all versions, memo columns require memo support,
+// float and general columns FoxPro 2 or Visual FoxPro, the other types Visual FoxPro and blob, varchar and
+// varbinary columns the varchar feature.
+func (v FileVersion) SupportsType(dataType DataType) bool {
+	visualFoxPro := v == FoxPro || v == FoxProAutoincrement || v == FoxProVar
+	switch dataType {
+	case Character, Numeric, Date, Logical:
+		return true
+	case Memo:
+		return v.Supports(FeatureMemo)
+	case Float, General:
+		return v == FoxPro2Memo || visualFoxPro
+	case Currency, Double, DateTime, Integer, Picture:
+		return visualFoxPro
+	case Blob, Varchar, Varbinary:
+		return v.Supports(FeatureVarchar)
+	}
+	return false
+}
+
+// Features returns the features the file version supports
+func (v FileVersion) Features() []Feature {
+	return append([]Feature{}, versionFeatures[v]...)
+}
+
+// columnFeatures returns the features required to store the column
+func columnFeatures(column *Column) []Feature {
+	features := make([]Feature, 0)
+	switch DataType(column.DataType) {
+	case Memo, General, Picture, Blob:
+		features = append(features, FeatureMemo)
+	case Varchar, Varbinary:
+		features = append(features, FeatureVarchar)
+	}
+	if column.Flag == byte(AutoincrementFlag) {
+		features = append(features, FeatureAutoincrement)
+	} else if column.Flag&byte(NullableFlag) != 0 {
+		features = append(features, FeatureNullFlags)
+	}
+	return features
+}
+
+// checkFeatures returns an error if a column has a data type or requires a feature the file version does not support
+// or an autoincrement column is not a valid autoincrement integer column
+func checkFeatures(version FileVersion, columns []*Column) error {
+	for _, column := range columns {
+		if !version.SupportsType(DataType(column.DataType)) {
+			return NewErrorf("file version 0x%02x does not support data type %v of column %s", byte(version), DataType(column.DataType), column.Name())
+		}
+		if column.Flag == byte(AutoincrementFlag) && (DataType(column.DataType) != Integer || column.Step == 0) {
+			return NewErrorf("autoincrement column %s must be an integer column with a step of at least 1", column.Name())
+		}
+		for _, feature := range columnFeatures(column) {
+			if !version.Supports(feature) {
+				return NewErrorf("file version 0x%02x does not support %s required by column %s", byte(version), feature, column.Name())
+			}
+		}
+	}
+	return nil
+}
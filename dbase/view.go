@@ -0,0 +1,213 @@
+package dbase
+
+// View is a read-only subset of the rows and columns of a table, like SET FILTER and SET FIELDS in FoxPro, see File.View
+type View struct {
+	file    *File
+	filter  func(*Row) bool
+	columns []int // Positions of the columns of the view, all columns if nil
+	err     error // Error of an unknown column, returned by all methods
+}
+
+// View returns a view of the active rows the filter returns true for, a nil filter includes all active rows.
+// The columns (name, external key or alias of a modification) limit the values exported and materialized by the view
+// to the columns in the given order, all columns are included if columns is empty. Rows returned by the view
+// contain all columns of the table, so they can be used like rows read from the table.
+// The filter is evaluated whenever the view is read, so the view reflects changes of the table.
+func (file *File) View(filter func(*Row) bool, columns []string) *View {
+	view := &View{file: file, filter: filter}
+	if len(columns) == 0 {
+		return view
+	}
+	view.columns = make([]int, 0, len(columns))
+	for _, name := range columns {
+		pos := file.columnPosByKey(name)
+		if pos < 0 {
+			view.err = NewErrorf("column '%s' not found", name)
+			return view
+		}
+		view.columns = append(view.columns, pos)
+	}
+	return view
+}
+
+// Columns returns the columns of the view
+func (view *View) Columns() []*Column {
+	if view.columns == nil {
+		return view.file.Columns()
+	}
+	columns := make([]*Column, 0, len(view.columns))
+	for _, pos := range view.columns {
+		columns = append(columns, view.file.table.columns[pos])
+	}
+	return columns
+}
+
+// Rows returns the rows of the view.
+// If MaxRowsInMemory or MaxBytesInMemory is set in the config and the rows exceed it, an ErrMemoryLimit error is returned.
+func (view *View) Rows() ([]*Row, error) {
+	budget := &memoryBudget{config: view.file.config}
+	rows := make([]*Row, 0)
+	err := view.each(func(row *Row) error {
+		size := int64(0)
+		for _, field := range row.fields {
+			size += valueSize(field.value)
+		}
+		err := budget.add(size)
+		if err != nil {
+			return WrapError(err)
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return rows, nil
+}
+
+// Count returns the number of rows of the view
+func (view *View) Count() (int, error) {
+	count := 0
+	err := view.each(func(*Row) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, WrapError(err)
+	}
+	return count, nil
+}
+
+// Search returns the rows of the view with the value in the field, see File.SearchWithOptions
+func (view *View) Search(field *Field, exactMatch bool, options SearchOptions) ([]*Row, error) {
+	if view.err != nil {
+		return nil, view.err
+	}
+	// The limit and offset apply to the rows of the view, so all matches are searched
+	limit, offset := options.Limit, options.Offset
+	if options.StopAfterFirst {
+		limit = 1
+	}
+	options.Limit, options.Offset, options.StopAfterFirst = 0, 0, false
+	rows, err := view.file.SearchWithOptions(field, exactMatch, options)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	matches := make([]*Row, 0)
+	for _, row := range rows {
+		if !view.includes(row) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		matches = append(matches, row)
+		if limit > 0 && len(matches) >= limit {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// Export writes the rows of the view with the columns of the view into the sink and closes the sink afterwards.
+// Values are converted as returned by Row.ToMap. Returns the number of records written.
+func (view *View) Export(sink Sink) (written int, err error) {
+	defer func() {
+		closeErr := sink.Close()
+		if err == nil && closeErr != nil {
+			err = NewError("failed to close sink").Details(closeErr)
+		}
+	}()
+	err = view.each(func(row *Row) error {
+		record, err := view.file.newRecord(row)
+		if err != nil {
+			return NewErrorf("failed to convert row %d", row.Position).Details(err)
+		}
+		if view.columns != nil {
+			fields := make([]*RecordField, 0, len(view.columns))
+			for _, pos := range view.columns {
+				fields = append(fields, record.Fields[pos])
+			}
+			record.Fields = fields
+		}
+		err = sink.Write(record)
+		if err != nil {
+			return NewErrorf("failed to write row %d", row.Position).Details(err)
+		}
+		written++
+		return nil
+	})
+	return written, err
+}
+
+// Materialize copies the rows of the view with the columns of the view into a temporary table kept in memory,
+// see NewTempTable. Values are copied as stored, column modifications are not applied. Autoincrement columns
+// are copied as plain integer columns, so the values are kept.
+func (view *View) Materialize() (*File, error) {
+	if view.err != nil {
+		return nil, view.err
+	}
+	columns := make([]*Column, 0)
+	for _, column := range view.Columns() {
+		c := *column
+		if ColumnFlag(c.Flag)&AutoincrementFlag == AutoincrementFlag {
+			c.Flag &^= byte(AutoincrementFlag)
+			c.Next, c.Step = 0, 0
+		}
+		columns = append(columns, &c)
+	}
+	temp, err := NewTempTable(columns, view.file.config.Converter)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	err = view.each(func(row *Row) error {
+		copied := temp.NewRow()
+		for i, column := range view.Columns() {
+			copied.fields[i].value = row.fields[view.file.ColumnPos(column)].value
+		}
+		err := copied.Add()
+		if err != nil {
+			return NewErrorf("failed to copy row %d", row.Position).Details(err)
+		}
+		return nil
+	})
+	if err != nil {
+		temp.Close()
+		return nil, WrapError(err)
+	}
+	return temp, nil
+}
+
+// includes returns if the row belongs to the view
+func (view *View) includes(row *Row) bool {
+	return !row.Deleted && (view.filter == nil || view.filter(row))
+}
+
+// each calls fn for the rows of the view in the order of the table, the internal row pointer is restored afterwards
+func (view *View) each(fn func(row *Row) error) error {
+	if view.err != nil {
+		return view.err
+	}
+	file := view.file
+	pointer := file.table.rowPointer
+	defer func() { file.table.rowPointer = pointer }()
+	file.table.rowPointer = 0
+	it := &rowIterator{file: file}
+	for !file.EOF() {
+		position := file.table.rowPointer
+		row, err := it.next()
+		if err != nil {
+			return NewErrorf("failed to read row %d", position).Details(err)
+		}
+		row.Position = position
+		if !view.includes(row) {
+			continue
+		}
+		err = fn(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package dbase
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultSettleTime is the time the files of a table have to keep their size before DirectoryWatcher opens the table
+const DefaultSettleTime = 2 * time.Second
+
+// WatchOptions configure how WatchDirectoryWithOptions opens the arrived tables
+type WatchOptions struct {
+	Config  *Config                          // Template of the config used to open the tables, the filename is replaced. Defaults to read-only.
+	Settle  time.Duration                    // Time the sizes of the DBF and FPT file have to be unchanged, defaults to DefaultSettleTime
+	OnError func(filename string, err error) // Called if a table can not be opened or the watcher fails, errors are only logged if nil
+}
+
+// DirectoryWatcher opens DBF tables arriving in a directory, e.g. a share legacy systems export their tables to
+type DirectoryWatcher struct {
+	watcher *fsnotify.Watcher
+	dir     string
+	pattern string
+	options WatchOptions
+	handler func(*File)
+	pending map[string]*watchedTable // Tables waiting to settle by upper case file name
+	mutex   sync.Mutex
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// watchedTable tracks the file sizes of a table until they stop changing
+type watchedTable struct {
+	filename string
+	size     int64
+	memoSize int64
+	timer    *time.Timer
+}
+
+// handledTable is the state of the files of a table when it was handed to the handler
+type handledTable struct {
+	size     int64
+	memoSize int64
+	modTime  time.Time
+}
+
+// WatchDirectory calls the handler with every DBF table matching the pattern (see filepath.Match, case insensitive)
+// that is created or modified in the directory, see WatchDirectoryWithOptions.
+func WatchDirectory(dir, pattern string, handler func(*File)) (*DirectoryWatcher, error) {
+	return WatchDirectoryWithOptions(dir, pattern, WatchOptions{}, handler)
+}
+
+// WatchDirectoryWithOptions calls the handler with every DBF table matching the pattern (see filepath.Match, case insensitive)
+// that is created or modified in the directory. The table is opened once the sizes of the DBF file and, if the table has memo columns,
+// of the memo file did not change for the settle time, so files still being copied are not read.
+// The table is closed after the handler returned. Tables present before the watcher started are ignored.
+// The handler is called from a single goroutine, one table at a time. Stop the watcher using Close.
+func WatchDirectoryWithOptions(dir, pattern string, options WatchOptions, handler func(*File)) (*DirectoryWatcher, error) {
+	if handler == nil {
+		return nil, NewError("missing handler")
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, NewErrorf("invalid pattern %s", pattern).Details(err)
+	}
+	if options.Config == nil {
+		options.Config = &Config{ReadOnly: true}
+	}
+	if options.Settle <= 0 {
+		options.Settle = DefaultSettleTime
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, NewError("failed to create file watcher").Details(err)
+	}
+	err = watcher.Add(dir)
+	if err != nil {
+		watcher.Close()
+		return nil, NewErrorf("failed to watch directory %s", dir).Details(err)
+	}
+	w := &DirectoryWatcher{
+		watcher: watcher,
+		dir:     dir,
+		pattern: strings.ToUpper(pattern),
+		options: options,
+		handler: handler,
+		pending: make(map[string]*watchedTable),
+		done:    make(chan struct{}),
+	}
+	ready := make(chan *watchedTable)
+	w.wg.Add(2)
+	go w.watch(ready)
+	go w.handle(ready)
+	debugf("Watching directory %s for tables matching %s", dir, pattern)
+	return w, nil
+}
+
+// Close stops watching the directory and waits until a running handler returned. Pending tables are not opened.
+func (w *DirectoryWatcher) Close() error {
+	w.mutex.Lock()
+	select {
+	case <-w.done:
+		w.mutex.Unlock()
+		return nil
+	default:
+	}
+	close(w.done)
+	for _, table := range w.pending {
+		table.timer.Stop()
+	}
+	w.pending = make(map[string]*watchedTable)
+	w.mutex.Unlock()
+	err := w.watcher.Close()
+	w.wg.Wait()
+	if err != nil {
+		return NewError("failed to close file watcher").Details(err)
+	}
+	return nil
+}
+
+// watch schedules the tables of the file events until the watcher is closed
+func (w *DirectoryWatcher) watch(ready chan<- *watchedTable) {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			if filename := w.tableFilename(event.Name); len(filename) > 0 {
+				w.schedule(filename, ready)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.fail(w.dir, NewErrorf("failed to watch directory %s", w.dir).Details(err))
+		}
+	}
+}
+
+// handle opens the settled tables and calls the handler until the watcher is closed
+func (w *DirectoryWatcher) handle(ready <-chan *watchedTable) {
+	defer w.wg.Done()
+	handled := make(map[string]handledTable)
+	for {
+		select {
+		case <-w.done:
+			return
+		case table := <-ready:
+			state, err := w.state(table.filename)
+			if err != nil {
+				w.fail(table.filename, err)
+				continue
+			}
+			key := strings.ToUpper(table.filename)
+			if last, ok := handled[key]; ok && last == state {
+				debugf("Table %s did not change since it was handled", table.filename)
+				continue
+			}
+			config := *w.options.Config
+			config.Filename = table.filename
+			file, err := OpenTable(&config)
+			if err != nil {
+				w.fail(table.filename, err)
+				continue
+			}
+			debugf("Handling table %s", table.filename)
+			w.handler(file)
+			err = file.Close()
+			if err != nil {
+				w.fail(table.filename, err)
+			}
+			// Changes made by the handler are not reported as new table
+			state, err = w.state(table.filename)
+			if err == nil {
+				handled[key] = state
+			}
+		}
+	}
+}
+
+// tableFilename returns the DBF file the file of the event belongs to, empty if it does not match the pattern
+func (w *DirectoryWatcher) tableFilename(name string) string {
+	ext := strings.ToUpper(filepath.Ext(name))
+	switch FileExtension(ext) {
+	case DBF:
+	case FPT:
+		filename, err := findFile(strings.TrimSuffix(name, filepath.Ext(name)) + string(DBF))
+		if err != nil || len(filename) == 0 {
+			return ""
+		}
+		name = filename
+	default:
+		return ""
+	}
+	if ok, _ := filepath.Match(w.pattern, strings.ToUpper(filepath.Base(name))); !ok {
+		return ""
+	}
+	return name
+}
+
+// schedule checks the sizes of the table after the settle time, an already pending check is postponed
+func (w *DirectoryWatcher) schedule(filename string, ready chan<- *watchedTable) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	select {
+	case <-w.done:
+		return
+	default:
+	}
+	key := strings.ToUpper(filename)
+	if table, ok := w.pending[key]; ok {
+		table.timer.Reset(w.options.Settle)
+		return
+	}
+	table := &watchedTable{filename: filename, size: -1, memoSize: -1}
+	table.timer = time.AfterFunc(w.options.Settle, func() { w.settle(key, table, ready) })
+	w.pending[key] = table
+	debugf("Waiting for table %s to settle", filename)
+}
+
+// settle passes the table to the handler if its sizes did not change since the last check, otherwise it checks again later
+func (w *DirectoryWatcher) settle(key string, table *watchedTable, ready chan<- *watchedTable) {
+	size, memoSize, complete := w.sizes(table.filename)
+	w.mutex.Lock()
+	if w.pending[key] != table {
+		w.mutex.Unlock()
+		return
+	}
+	if !complete || size != table.size || memoSize != table.memoSize {
+		table.size, table.memoSize = size, memoSize
+		table.timer.Reset(w.options.Settle)
+		w.mutex.Unlock()
+		return
+	}
+	delete(w.pending, key)
+	w.mutex.Unlock()
+	select {
+	case ready <- table:
+	case <-w.done:
+	}
+}
+
+// sizes returns the size of the DBF file and of the memo file, -1 if there is none.
+// The table is not complete if the header can not be read yet or the memo file is missing.
+func (w *DirectoryWatcher) sizes(filename string) (int64, int64, bool) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return -1, -1, false
+	}
+	header := headerSnapshot(&Config{Filename: filename})
+	if header == nil {
+		return info.Size(), -1, false
+	}
+	if !MemoFlag.Defined(header.TableFlags) {
+		return info.Size(), -1, true
+	}
+	memo, err := findFile(memoFilename(filename))
+	if err != nil || len(memo) == 0 {
+		return info.Size(), -1, false
+	}
+	memoInfo, err := os.Stat(memo)
+	if err != nil {
+		return info.Size(), -1, false
+	}
+	return info.Size(), memoInfo.Size(), true
+}
+
+// state returns the sizes and the modification time of the files of the table
+func (w *DirectoryWatcher) state(filename string) (handledTable, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return handledTable{}, NewErrorf("failed to read file info of %s", filename).Details(err)
+	}
+	size, memoSize, _ := w.sizes(filename)
+	if size < 0 {
+		size = info.Size()
+	}
+	return handledTable{size: size, memoSize: memoSize, modTime: info.ModTime()}, nil
+}
+
+// fail passes the error to OnError or logs it
+func (w *DirectoryWatcher) fail(filename string, err error) {
+	if w.options.OnError != nil {
+		w.options.OnError(filename, err)
+		return
+	}
+	debugf("Watching table %s failed: %v", filename, err)
+}
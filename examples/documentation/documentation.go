@@ -78,6 +78,7 @@ func main() {
 				Type:       column.Type(),
 				GolangType: typ,
 				Length:     column.Length,
+				Comment:    tables[name].ColumnComment(column.Name()),
 			})
 		}
 	}
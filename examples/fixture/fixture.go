@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Valentin-Kaiser/go-dbase/dbase"
+)
+
+func main() {
+	// Open debug log file so we see what's going on
+	f, err := os.OpenFile("debug.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	dbase.Debug(true, io.MultiWriter(os.Stdout, f))
+
+	// The expected rows were recorded from the table created by Visual FoxPro using dbase.FixtureJSON.
+	// Remove the columns you do not rely on to limit the verification.
+	expected, err := os.ReadFile("../test_data/database/employees.fixture.json")
+	if err != nil {
+		panic(err)
+	}
+
+	mismatches, err := dbase.VerifyFixture("../test_data/database/employees.dbf", expected)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, mismatch := range mismatches {
+		fmt.Println(mismatch)
+	}
+	if len(mismatches) > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("Fixture verified")
+}
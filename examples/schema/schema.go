@@ -67,7 +67,11 @@ func main() {
 				panic(err)
 			}
 
-			tableStructSchema += fmt.Sprintf("\t%-12.12s %-12.12s `dbase:\"%v\"`\n", column.Name(), typ, column.Name())
+			tableStructSchema += fmt.Sprintf("\t%-12.12s %-12.12s `dbase:\"%v\"`", column.Name(), typ, column.Name())
+			if comment := tables[name].ColumnComment(column.Name()); len(comment) > 0 {
+				tableStructSchema += " // " + comment
+			}
+			tableStructSchema += "\n"
 		}
 		tableStructSchema += "}\n\n"
 		tablesStructs = append(tablesStructs, tableStructSchema)
@@ -3,6 +3,9 @@ module github.com/Valentin-Kaiser/go-dbase
 go 1.20
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/klauspost/compress v1.17.9
+	github.com/mattn/go-sqlite3 v1.14.22
 	golang.org/x/sys v0.29.0
 	golang.org/x/text v0.21.0
 )
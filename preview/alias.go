@@ -0,0 +1,46 @@
+package dbase
+
+import (
+	v1 "github.com/Valentin-Kaiser/go-dbase/dbase"
+)
+
+// Types shared with the v1 API
+type (
+	Config            = v1.Config
+	Column            = v1.Column
+	Row               = v1.Row
+	Field             = v1.Field
+	Header            = v1.Header
+	DataType          = v1.DataType
+	FileVersion       = v1.FileVersion
+	Modification      = v1.Modification
+	EncodingConverter = v1.EncodingConverter
+	IO                = v1.IO
+	MetricsCollector  = v1.MetricsCollector
+	Error             = v1.Error
+	OpenError         = v1.OpenError
+	OpenReason        = v1.OpenReason
+)
+
+// Errors shared with the v1 API, check them with errors.Is
+var (
+	ErrEOF                = v1.ErrEOF
+	ErrBOF                = v1.ErrBOF
+	ErrIncomplete         = v1.ErrIncomplete
+	ErrNoFPT              = v1.ErrNoFPT
+	ErrNoDBF              = v1.ErrNoDBF
+	ErrInvalidPosition    = v1.ErrInvalidPosition
+	ErrInvalidEncoding    = v1.ErrInvalidEncoding
+	ErrLocked             = v1.ErrLocked
+	ErrMemoTooLarge       = v1.ErrMemoTooLarge
+	ErrMemoryLimit        = v1.ErrMemoryLimit
+	ErrNumericOverflow    = v1.ErrNumericOverflow
+	ErrInvalidMemoAddress = v1.ErrInvalidMemoAddress
+	ErrChecksumMismatch   = v1.ErrChecksumMismatch
+	ErrInvalidConfig      = v1.ErrInvalidConfig
+	ErrUnknownDataType    = v1.ErrUnknownDataType
+	ErrInvalidHeader      = v1.ErrInvalidHeader
+	ErrUnsupportedVersion = v1.ErrUnsupportedVersion
+	ErrInvalidColumns     = v1.ErrInvalidColumns
+	ErrCodePageMismatch   = v1.ErrCodePageMismatch
+)
@@ -0,0 +1,13 @@
+// Package dbase is the preview of the v2 API of go-dbase. It consolidates the additions of the v1 API into a smaller surface:
+//
+//   - Context first: every method reading or writing rows takes a context and stops when it is cancelled.
+//   - Functional options: Open takes options instead of the growing Config struct, WithConfig gives access to all settings.
+//   - Stateless reads: rows are read by their position (int64), there is no shared row pointer, so a table can be read concurrently.
+//   - Typed errors: errors are the sentinel errors and error types of v1, to be checked with errors.Is and errors.As.
+//   - Streaming iterators: Rows returns an iterator reading the rows in batches instead of a slice.
+//
+// The package is built on the v1 package and shares its types through aliases, so both can be used side by side during
+// a migration and Table.V1 returns the underlying v1 file. See MIGRATION.md for the mapping of the v1 API.
+// The API is not stable yet, so the package lives in the preview directory of the v1 module, the import path
+// github.com/Valentin-Kaiser/go-dbase/v2 is reserved for the v2 module. Once it is stable, the package becomes that module.
+package dbase
@@ -0,0 +1,104 @@
+package dbase
+
+import (
+	"context"
+
+	v1 "github.com/Valentin-Kaiser/go-dbase/dbase"
+)
+
+// iteratorBatch is the number of rows an iterator reads at once
+const iteratorBatch = 256
+
+// Iterator streams the rows of a table in batches
+//
+//	it := table.Rows(ctx, false)
+//	defer it.Close()
+//	for it.Next() {
+//		row := it.Row()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator struct {
+	ctx            context.Context
+	table          *Table
+	includeDeleted bool
+	position       int64 // Position of the current row
+	next           int64 // Position of the first row not read yet
+	end            int64
+	batch          [][]byte
+	offset         int64 // Position of the first row of the batch
+	row            *Row
+	err            error
+}
+
+// Rows returns an iterator over the rows of the table, deleted rows are skipped unless included.
+// The number of rows is fixed when the iterator is created, rows appended afterwards are not returned.
+func (t *Table) Rows(ctx context.Context, includeDeleted bool) *Iterator {
+	return &Iterator{ctx: ctx, table: t, includeDeleted: includeDeleted, position: -1, end: t.Count()}
+}
+
+// Next advances to the next row and returns false at the end of the table, if the context is done or on error
+func (it *Iterator) Next() bool {
+	it.row = nil
+	for it.err == nil {
+		index := it.next - it.offset
+		if index >= int64(len(it.batch)) {
+			if it.next >= it.end {
+				return false
+			}
+			if err := it.ctx.Err(); err != nil {
+				it.err = v1.WrapError(err)
+				return false
+			}
+			count := iteratorBatch
+			if it.end-it.next < int64(count) {
+				count = int(it.end - it.next)
+			}
+			batch, err := it.table.file.ReadRows(uint32(it.next), count)
+			if err != nil {
+				it.err = v1.NewErrorf("failed to read rows at %d", it.next).Details(err)
+				return false
+			}
+			if len(batch) == 0 {
+				return false
+			}
+			it.batch, it.offset, index = batch, it.next, 0
+		}
+		position := it.next
+		it.next++
+		row, err := it.table.toRow(it.batch[index], position)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if row.Deleted && !it.includeDeleted {
+			continue
+		}
+		it.position, it.row = position, row
+		return true
+	}
+	return false
+}
+
+// Row returns the current row
+func (it *Iterator) Row() *Row {
+	return it.row
+}
+
+// Position returns the position of the current row
+func (it *Iterator) Position() int64 {
+	return it.position
+}
+
+// Err returns the error that stopped the iteration, nil at the end of the table
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close releases the buffered rows, the table stays open
+func (it *Iterator) Close() error {
+	it.batch, it.row = nil, nil
+	it.next = it.end
+	return nil
+}
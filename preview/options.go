@@ -0,0 +1,88 @@
+package dbase
+
+import (
+	"time"
+
+	v1 "github.com/Valentin-Kaiser/go-dbase/dbase"
+)
+
+// Option configures how a table is opened
+type Option func(*v1.Config)
+
+// WithConverter sets the encoding converter of the table
+func WithConverter(converter EncodingConverter) Option {
+	return func(c *v1.Config) {
+		c.Converter = converter
+	}
+}
+
+// WithInterpretedCodePage uses the converter of the code page mark of the table instead of a given converter
+func WithInterpretedCodePage() Option {
+	return func(c *v1.Config) {
+		c.InterpretCodePage = true
+	}
+}
+
+// WithReadOnly opens the table in read-only mode
+func WithReadOnly() Option {
+	return func(c *v1.Config) {
+		c.ReadOnly = true
+	}
+}
+
+// WithExclusive opens the table in exclusive mode
+func WithExclusive() Option {
+	return func(c *v1.Config) {
+		c.Exclusive = true
+	}
+}
+
+// WithUntested skips the check of the file version
+func WithUntested() Option {
+	return func(c *v1.Config) {
+		c.Untested = true
+	}
+}
+
+// WithTrimSpaces trims spaces from the start and end of string values
+func WithTrimSpaces() Option {
+	return func(c *v1.Config) {
+		c.TrimSpaces = true
+	}
+}
+
+// WithStrictEncoding fails with ErrInvalidEncoding on bytes the code page does not define
+func WithStrictEncoding() Option {
+	return func(c *v1.Config) {
+		c.StrictEncoding = true
+	}
+}
+
+// WithWriteLock locks the rows while they are written, retrying for the timeout if another process holds the lock
+func WithWriteLock(timeout time.Duration) Option {
+	return func(c *v1.Config) {
+		c.WriteLock = true
+		c.LockTimeout = timeout
+	}
+}
+
+// WithIO sets the IO implementation, e.g. to read from memory
+func WithIO(io IO) Option {
+	return func(c *v1.Config) {
+		c.IO = io
+	}
+}
+
+// WithMetrics sets the collector called after each file operation
+func WithMetrics(metrics MetricsCollector) Option {
+	return func(c *v1.Config) {
+		c.Metrics = metrics
+	}
+}
+
+// WithConfig gives access to all settings of the v1 config that have no option yet. The filename is set by Open.
+func WithConfig(fn func(*Config)) Option {
+	return func(c *v1.Config) {
+		fn(c)
+	}
+}
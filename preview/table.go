@@ -0,0 +1,115 @@
+package dbase
+
+import (
+	"context"
+	"math"
+
+	v1 "github.com/Valentin-Kaiser/go-dbase/dbase"
+)
+
+// Table is an open dBase table. Rows are addressed by their position, so reads do not depend on a shared row pointer.
+type Table struct {
+	file *v1.File
+}
+
+// Open opens the table with the options
+func Open(ctx context.Context, filename string, opts ...Option) (*Table, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, v1.WrapError(err)
+	}
+	config := &v1.Config{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	config.Filename = filename
+	file, err := v1.OpenTable(config)
+	if err != nil {
+		return nil, v1.WrapError(err)
+	}
+	return &Table{file: file}, nil
+}
+
+// V1 returns the underlying v1 file for functionality not covered by the v2 API yet
+func (t *Table) V1() *v1.File {
+	return t.file
+}
+
+// Close closes the table
+func (t *Table) Close() error {
+	return t.file.Close()
+}
+
+// Header returns the header of the table
+func (t *Table) Header() *Header {
+	return t.file.Header()
+}
+
+// Columns returns the columns of the table
+func (t *Table) Columns() []*Column {
+	return t.file.Columns()
+}
+
+// Count returns the number of rows including deleted rows
+func (t *Table) Count() int64 {
+	return int64(t.file.RowsCount())
+}
+
+// ReadAt reads the row at the position
+func (t *Table) ReadAt(ctx context.Context, position int64) (*Row, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, v1.WrapError(err)
+	}
+	if position < 0 || position >= t.Count() {
+		return nil, v1.NewErrorf("position %d out of range, the table has %d rows", position, t.Count()).Details(ErrEOF)
+	}
+	data, err := t.file.ReadRow(uint32(position))
+	if err != nil {
+		return nil, v1.WrapError(err)
+	}
+	return t.toRow(data, position)
+}
+
+// WriteAt writes the row at the position, the position must be within the table or the next position to append the row
+func (t *Table) WriteAt(ctx context.Context, position int64, row *Row) error {
+	if err := ctx.Err(); err != nil {
+		return v1.WrapError(err)
+	}
+	if row == nil {
+		return v1.NewError("missing row")
+	}
+	if position < 0 || position > t.Count() || position >= math.MaxUint32 {
+		return v1.NewErrorf("position %d out of range, the table has %d rows", position, t.Count()).Details(ErrInvalidPosition)
+	}
+	row.Position = uint32(position)
+	return row.Write()
+}
+
+// Append appends the row and returns its position
+func (t *Table) Append(ctx context.Context, row *Row) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, v1.WrapError(err)
+	}
+	if row == nil {
+		return 0, v1.NewError("missing row")
+	}
+	err := row.Add()
+	if err != nil {
+		return 0, v1.WrapError(err)
+	}
+	return int64(row.Position), nil
+}
+
+// NewRow returns an empty row of the table to be written with WriteAt or Append
+func (t *Table) NewRow() *Row {
+	return t.file.NewRow()
+}
+
+// toRow converts the raw data of the row at the position
+func (t *Table) toRow(data []byte, position int64) (*Row, error) {
+	row, err := t.file.BytesToRow(data)
+	if err != nil {
+		return nil, v1.NewErrorf("failed to convert row %d", position).Details(err)
+	}
+	row.Position = uint32(position)
+	return row, nil
+}